@@ -0,0 +1,3 @@
+#!/bin/sh
+
+curl --json '{ "transaction": "deposit", "wallet_id": "5a486373-14d8-4643-ad9b-2cadc77f7a98", "amount": 5000, "cron": "0 9 1 * *" }' http://localhost/schedules -v
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// supported load models
+const (
+	modelClosed = "closed" // fixed number of workers each sending next request once previous one completes
+	modelOpen   = "open"   // requests are sent at fixed rate regardless of completion of previous ones
+)
+
+// errDropped reports open model request which was not sent as too many requests were in flight.
+var errDropped = errors.New("too many requests in flight")
+
+// options represents benchmark options.
+type options struct {
+	addr        string        // base URL of the service
+	wallets     int           // number of wallets provisioned before the run
+	fund        int           // amount deposited into every provisioned wallet
+	mix         string        // operations mix, see parseMix
+	model       string        // modelClosed or modelOpen
+	workers     int           // number of workers of closed model, concurrency of provisioning and verification
+	rate        float64       // requests per second of open model
+	maxInflight int           // maximum number of requests in flight of open model
+	duration    time.Duration // duration of the run
+	timeout     time.Duration // request timeout
+	hot         int           // number of hot wallets
+	hotShare    float64       // share of transactions and reads targeting hot wallets
+	maxAmount   int           // maximum transaction amount
+	seed        int64         // random generator seed
+}
+
+// validate validates options.
+func (o *options) validate() error {
+	switch {
+	case o.wallets < 1:
+		return errors.New("at least one wallet is required")
+	case o.fund < 0:
+		return errors.New("fund amount can not be negative")
+	case o.model != modelClosed && o.model != modelOpen:
+		return errors.Newf("load model %q is not supported", o.model)
+	case o.workers < 1:
+		return errors.New("at least one worker is required")
+	case o.model == modelOpen && o.rate <= 0:
+		return errors.New("open model requires positive rate")
+	case o.model == modelOpen && o.maxInflight < 1:
+		return errors.New("open model requires positive maximum of requests in flight")
+	case o.duration <= 0:
+		return errors.New("duration must be positive")
+	case o.timeout <= 0:
+		return errors.New("timeout must be positive")
+	case o.hot < 0 || o.hotShare < 0 || o.hotShare > 1:
+		return errors.New("hot wallets share must be within [0, 1]")
+	case o.maxAmount < 1:
+		return errors.New("maximum amount must be positive")
+	}
+	return nil
+}
+
+// request represents planned operation.
+type request struct {
+	op     string
+	wallet *wallet // wallet transaction or read targets
+	amount int     // transaction amount, reserved beforehand for withdrawals
+}
+
+// bench generates load against the service and tracks wallet balances it expects.
+type bench struct {
+	opts     *options
+	mix      *mix
+	client   *client
+	pool     *pool
+	recorder *recorder
+
+	created atomic.Int64 // number of wallets created, used for naming
+}
+
+// newBench constructs a new benchmark of the given options.
+func newBench(opts *options, httpClient *http.Client) (*bench, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	m, err := parseMix(opts.mix)
+	if err != nil {
+		return nil, err
+	}
+	return &bench{
+		opts:     opts,
+		mix:      m,
+		client:   newClient(opts.addr, httpClient),
+		pool:     &pool{hot: opts.hot, hotShare: opts.hotShare},
+		recorder: newRecorder(),
+	}, nil
+}
+
+// provision creates and funds wallets load is generated against.
+// Rate limited requests are retried, so provisioning succeeds even if service limits are lower than concurrency.
+func (b *bench) provision(ctx context.Context) error {
+	return b.parallel(ctx, b.opts.wallets, func(ctx context.Context, i int) error {
+		var w *wallet
+		err := retry(ctx, func() (err error) {
+			w, err = b.create(ctx)
+			return err
+		})
+		if err != nil {
+			return errors.Wrap(err, "unable to create wallet")
+		}
+		if b.opts.fund == 0 {
+			return nil
+		}
+
+		err = retry(ctx, func() error {
+			ctx, cancel := context.WithTimeout(ctx, b.opts.timeout)
+			defer cancel()
+
+			_, err := b.client.transaction(ctx, ledger.TransactionDeposit, w.id, b.opts.fund)
+			w.settle(b.opts.fund, classify(err))
+			return err
+		})
+		if err != nil {
+			return errors.Wrap(err, "unable to fund wallet")
+		}
+		return nil
+	})
+}
+
+// create creates a new wallet and adds it to the pool.
+func (b *bench) create(ctx context.Context) (*wallet, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.opts.timeout)
+	defer cancel()
+
+	created, err := b.client.createWallet(ctx, fmt.Sprintf("ledgerbench %d", b.created.Add(1)))
+	if err != nil {
+		return nil, err
+	}
+	w := &wallet{id: created.ID}
+	b.pool.add(w)
+	return w, nil
+}
+
+// run generates load according to the configured model until duration passes or ctx is done.
+// It returns once all sent requests complete.
+func (b *bench) run(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, b.opts.duration)
+	defer cancel()
+
+	// requests in flight are let to complete once the run is over, so their outcome is known
+	requests := context.WithoutCancel(ctx)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	if b.opts.model == modelClosed {
+		for i := 0; i < b.opts.workers; i++ {
+			wg.Add(1)
+			go func(r *rand.Rand) {
+				defer wg.Done()
+				for ctx.Err() == nil {
+					b.execute(requests, b.plan(r), time.Now())
+				}
+			}(rand.New(rand.NewSource(b.opts.seed + int64(i))))
+		}
+		return
+	}
+
+	// Requests are scheduled at fixed intervals and their latency is measured from the scheduled time,
+	// so service stalls are accounted for rather than delaying subsequent requests.
+	r := rand.New(rand.NewSource(b.opts.seed))
+	interval := time.Duration(float64(time.Second) / b.opts.rate)
+	inflight := make(chan struct{}, b.opts.maxInflight)
+
+	start := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for next := 0; ; next++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		scheduled := start.Add(time.Duration(next) * interval)
+		timer.Reset(time.Until(scheduled.Add(interval)))
+
+		req := b.plan(r)
+		select {
+		case inflight <- struct{}{}:
+		default:
+			b.settle(req, errDropped)
+			b.recorder.record(req.op, 0, errDropped)
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-inflight }()
+			b.execute(requests, req, scheduled)
+		}()
+	}
+}
+
+// plan plans random operation according to the mix.
+// Withdrawal amount is reserved on the wallet, if nothing is available deposit is planned instead.
+func (b *bench) plan(r *rand.Rand) *request {
+	req := &request{op: b.mix.pick(r)}
+	if req.op == opCreate {
+		return req
+	}
+
+	req.wallet = b.pool.pick(r)
+	req.amount = r.Intn(b.opts.maxAmount) + 1
+	if req.op == opWithdraw {
+		if req.amount = req.wallet.reserve(req.amount); req.amount == 0 {
+			req.op, req.amount = opDeposit, r.Intn(b.opts.maxAmount)+1
+		}
+	}
+	return req
+}
+
+// execute executes planned operation and records it as started at the given time.
+func (b *bench) execute(ctx context.Context, req *request, start time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, b.opts.timeout)
+	defer cancel()
+
+	var err error
+	switch req.op {
+	case opCreate:
+		_, err = b.create(ctx)
+	case opDeposit:
+		_, err = b.client.transaction(ctx, ledger.TransactionDeposit, req.wallet.id, req.amount)
+	case opWithdraw:
+		_, err = b.client.transaction(ctx, ledger.TransactionWithdraw, req.wallet.id, req.amount)
+	case opGet:
+		_, err = b.client.getWallet(ctx, req.wallet.id)
+	}
+	b.recorder.record(req.op, time.Since(start), err)
+	b.settle(req, err)
+}
+
+// settle records outcome of the transaction on its wallet.
+func (b *bench) settle(req *request, err error) {
+	switch req.op {
+	case opDeposit:
+		req.wallet.settle(req.amount, classify(err))
+	case opWithdraw:
+		req.wallet.settle(-req.amount, classify(err))
+	}
+}
+
+// mismatch represents wallet which balance is not the expected one.
+type mismatch struct {
+	id        string
+	low, high int   // expected balance range
+	balance   int   // actual balance
+	err       error // error retrieving the wallet, if any
+}
+
+// verification represents result of wallets verification.
+type verification struct {
+	wallets    int        // number of verified wallets
+	uncertain  int        // number of wallets having transactions of unknown outcome
+	mismatches []mismatch // wallets which balance is not the expected one
+}
+
+// verify retrieves every wallet and compares its balance against the expected one, rate limited requests are retried.
+// Balance of wallet having transactions of unknown outcome is expected to be within the range of possible balances.
+func (b *bench) verify(ctx context.Context) (*verification, error) {
+	wallets := b.pool.all()
+	results := make([]*mismatch, len(wallets))
+
+	var uncertain atomic.Int64
+	err := b.parallel(ctx, len(wallets), func(ctx context.Context, i int) error {
+		w := wallets[i]
+		low, high := w.expected()
+		if low != high {
+			uncertain.Add(1)
+		}
+
+		var got *api.Wallet
+		err := retry(ctx, func() (err error) {
+			ctx, cancel := context.WithTimeout(ctx, b.opts.timeout)
+			defer cancel()
+
+			got, err = b.client.getWallet(ctx, w.id)
+			return err
+		})
+		switch {
+		case err != nil:
+			results[i] = &mismatch{id: w.id, low: low, high: high, err: err}
+		case got.Balance < low || got.Balance > high:
+			results[i] = &mismatch{id: w.id, low: low, high: high, balance: got.Balance}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	v := verification{wallets: len(wallets), uncertain: int(uncertain.Load())}
+	for _, m := range results {
+		if m != nil {
+			v.mismatches = append(v.mismatches, *m)
+		}
+	}
+	return &v, nil
+}
+
+// report writes verification result.
+func (v *verification) report(w io.Writer) error {
+	fmt.Fprintf(w, "verified %d wallets, %d having transactions of unknown outcome, %d mismatched\n", v.wallets, v.uncertain, len(v.mismatches))
+	if len(v.mismatches) == 0 {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "wallet\texpected\tbalance")
+	for _, m := range v.mismatches {
+		expected := fmt.Sprint(m.low)
+		if m.low != m.high {
+			expected = fmt.Sprintf("%d..%d", m.low, m.high)
+		}
+		balance := fmt.Sprint(m.balance)
+		if m.err != nil {
+			balance = m.err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", m.id, expected, balance)
+	}
+	return tw.Flush()
+}
+
+// parallel calls fn for every index in [0, n) by configured number of workers, it returns first error encountered.
+func (b *bench) parallel(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	indexes := make(chan int)
+	for i := 0; i < min(b.opts.workers, n); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(ctx, i); err != nil {
+					once.Do(func() { first = err; cancel() })
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if first != nil {
+		return first
+	}
+	return ctx.Err()
+}
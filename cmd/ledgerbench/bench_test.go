@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// fakeLedger is in memory ledger service serving wallets and transactions endpoints.
+type fakeLedger struct {
+	mu       sync.Mutex
+	balances map[string]int
+	requests int
+
+	lost    int  // every lost-th transaction is applied, but responded with server error
+	limited int  // every limited-th transaction is rejected as rate limited
+	double  bool // deposits are applied twice
+}
+
+// ServeHTTP implements http.Handler.
+func (l *fakeLedger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/wallets":
+		id := fmt.Sprintf("wallet-%d", len(l.balances))
+		l.balances[id] = 0
+		json.NewEncoder(w).Encode(&api.Wallet{ID: id}) // nolint
+
+	case r.Method == http.MethodPost && r.URL.Path == "/transactions":
+		var req api.CreateTransactionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		balance, ok := l.balances[req.WalletID]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		l.requests++
+		if l.limited > 0 && l.requests%l.limited == 0 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		switch req.Type {
+		case ledger.TransactionDeposit:
+			balance += req.Amount
+			if l.double {
+				balance += req.Amount
+			}
+		case ledger.TransactionWithdraw:
+			if balance < req.Amount {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			balance -= req.Amount
+		}
+		l.balances[req.WalletID] = balance
+
+		if l.lost > 0 && l.requests%l.lost == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(&api.CreateTransactionResponse{WalletID: req.WalletID, Balance: balance}) // nolint
+
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/wallets/"):
+		id := strings.TrimPrefix(r.URL.Path, "/wallets/")
+		balance, ok := l.balances[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(&api.Wallet{ID: id, Balance: balance}) // nolint
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestRun(t *testing.T) {
+	var testcases = []struct {
+		model  string
+		ledger *fakeLedger
+		err    error
+	}{
+		{modelClosed, &fakeLedger{}, nil},
+		{modelOpen, &fakeLedger{}, nil},
+		{modelClosed, &fakeLedger{lost: 7, limited: 5}, nil}, // outcomes unknown, balances are within expected range
+		{modelOpen, &fakeLedger{lost: 7, limited: 5}, nil},
+		{modelClosed, &fakeLedger{double: true}, errMismatch},
+	}
+
+	for i, tt := range testcases {
+		tt.ledger.balances = make(map[string]int)
+		server := httptest.NewServer(tt.ledger)
+
+		opts := options{
+			addr:        server.URL,
+			wallets:     4,
+			fund:        500,
+			mix:         "create=1,deposit=40,withdraw=50,get=9",
+			model:       tt.model,
+			workers:     4,
+			rate:        2000,
+			maxInflight: 16,
+			duration:    200 * time.Millisecond,
+			timeout:     time.Second,
+			hot:         1,
+			hotShare:    0.5,
+			maxAmount:   300,
+			seed:        int64(i),
+		}
+		if err := run(context.Background(), &opts, server.Client(), io.Discard); err != tt.err {
+			t.Errorf("#%d got %v, want %v", i, err, tt.err)
+		}
+		server.Close()
+	}
+}
+
+func TestParseMix(t *testing.T) {
+	var testcases = []struct {
+		mix  string
+		ops  []string
+		fail bool
+	}{
+		{mix: "deposit=1", ops: []string{opDeposit}},
+		{mix: "create=1, get=0, withdraw=3", ops: []string{opCreate, opWithdraw}},
+		{mix: "deposit", fail: true},
+		{mix: "transfer=1", fail: true},
+		{mix: "deposit=-1", fail: true},
+		{mix: "deposit=1,deposit=2", fail: true},
+		{mix: "get=0", fail: true},
+	}
+
+	for i, tt := range testcases {
+		m, err := parseMix(tt.mix)
+		if (err != nil) != tt.fail {
+			t.Fatalf("#%d got %v, want fail %v", i, err, tt.fail)
+		}
+		if err != nil {
+			continue
+		}
+
+		// every picked operation has positive weight and every such operation is picked
+		picked := make(map[string]bool)
+		r := rand.New(rand.NewSource(1))
+		for n := 0; n < 1000; n++ {
+			picked[m.pick(r)] = true
+		}
+		if len(picked) != len(tt.ops) {
+			t.Errorf("#%d got %v, want %v", i, picked, tt.ops)
+		}
+		for _, op := range tt.ops {
+			if !picked[op] {
+				t.Errorf("#%d got %v, want %v", i, picked, tt.ops)
+			}
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	latencies := make([]time.Duration, 1000)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	var testcases = []struct {
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{nil, 50, 0},
+		{latencies[:1], 99.9, time.Millisecond},
+		{latencies, 0, time.Millisecond},
+		{latencies, 50, 500 * time.Millisecond},
+		{latencies, 99, 990 * time.Millisecond},
+		{latencies, 99.9, 999 * time.Millisecond},
+		{latencies, 100, time.Second},
+	}
+
+	for i, tt := range testcases {
+		if got := percentile(tt.sorted, tt.p); got != tt.want {
+			t.Errorf("#%d got %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	var testcases = []struct {
+		err     error
+		outcome outcome
+		class   string
+	}{
+		{nil, applied, ""},
+		{&statusError{code: http.StatusBadRequest}, rejected, "http 400"},
+		{&statusError{code: http.StatusTooManyRequests}, rejected, "http 429"},
+		{&statusError{code: http.StatusServiceUnavailable}, rejected, "http 503"},
+		{&statusError{code: http.StatusInternalServerError}, unknown, "http 500"},
+		{errors.Wrap(context.DeadlineExceeded, "post"), unknown, "timeout"},
+		{errors.New("connection refused"), unknown, "connection"},
+		{errDropped, rejected, "dropped"},
+	}
+
+	for i, tt := range testcases {
+		if got := classify(tt.err); got != tt.outcome {
+			t.Errorf("#%d got %v, want %v", i, got, tt.outcome)
+		}
+		if tt.err == nil {
+			continue
+		}
+		if got := errorClass(tt.err); got != tt.class {
+			t.Errorf("#%d got %v, want %v", i, got, tt.class)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var testcases = []struct {
+		statuses []int // statuses responded in order, the last one repeatedly
+		calls    int
+		status   int
+	}{
+		{[]int{http.StatusOK}, 1, http.StatusOK},
+		{[]int{http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusOK}, 3, http.StatusOK},
+		{[]int{http.StatusTooManyRequests, http.StatusInternalServerError}, 2, http.StatusInternalServerError},
+		{[]int{http.StatusNotFound}, 1, http.StatusNotFound},
+	}
+
+	for i, tt := range testcases {
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			status := tt.statuses[min(calls, len(tt.statuses)-1)]
+			calls++
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			io.WriteString(w, `{}`)
+		}))
+
+		c := newClient(server.URL, server.Client())
+		err := retry(context.Background(), func() error {
+			_, err := c.getWallet(context.Background(), "wallet")
+			return err
+		})
+		server.Close()
+
+		status := http.StatusOK
+		if err != nil {
+			status = err.(*statusError).code
+		}
+		if calls != tt.calls || status != tt.status {
+			t.Errorf("#%d got %d calls and status %d, want %d and %d", i, calls, status, tt.calls, tt.status)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// statusError represents non-successful HTTP response.
+type statusError struct {
+	code       int
+	retryAfter time.Duration // delay requested by Retry-After header, defaultRetryAfter if none
+}
+
+// Error implements error.
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s", e.code, http.StatusText(e.code))
+}
+
+// outcome represents what is known about the effect of a request.
+type outcome int
+
+const (
+	applied  outcome = iota // request succeeded
+	rejected                // request was refused without taking effect
+	unknown                 // request might or might not have taken effect, e.g. it timed out
+)
+
+// classify returns outcome of the request failed with err.
+// Client errors, rate limited and load shed requests are refused before they are processed, dropped requests are never sent,
+// while server errors and transport failures may happen once transaction is persisted.
+func classify(err error) outcome {
+	if err == nil {
+		return applied
+	}
+	if status, ok := err.(*statusError); ok && (status.code < http.StatusInternalServerError || status.code == http.StatusServiceUnavailable) {
+		return rejected
+	}
+	if errors.Is(err, errDropped) {
+		return rejected
+	}
+	return unknown
+}
+
+// errorClass returns class errors are grouped by in the report.
+func errorClass(err error) string {
+	if status, ok := err.(*statusError); ok {
+		return fmt.Sprintf("http %d", status.code)
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return "timeout"
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, errDropped):
+		return "dropped"
+	default:
+		return "connection"
+	}
+}
+
+// defaultRetryAfter is delay before retrying rate limited request not specifying one.
+const defaultRetryAfter = time.Second
+
+// retry calls fn until it succeeds or fails with other error than rate limited or load shed response.
+// Retries are delayed as requested by the service.
+func retry(ctx context.Context, fn func() error) error {
+	for {
+		err := fn()
+		status, ok := err.(*statusError)
+		if !ok || (status.code != http.StatusTooManyRequests && status.code != http.StatusServiceUnavailable) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(status.retryAfter):
+		}
+	}
+}
+
+// client talks to the ledger service HTTP API.
+type client struct {
+	base string
+	http *http.Client
+}
+
+// newClient constructs a new client of the service at the given base URL.
+func newClient(base string, httpClient *http.Client) *client {
+	return &client{base: strings.TrimSuffix(base, "/"), http: httpClient}
+}
+
+// createWallet creates a new wallet.
+func (c *client) createWallet(ctx context.Context, name string) (*api.Wallet, error) {
+	var wallet api.Wallet
+	if err := c.do(ctx, http.MethodPost, "/wallets", &api.CreateWalletRequest{Name: name}, &wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// transaction posts transaction of the given type.
+func (c *client) transaction(ctx context.Context, typ, walletID string, amount int) (*api.CreateTransactionResponse, error) {
+	var receipt api.CreateTransactionResponse
+	req := api.CreateTransactionRequest{Type: typ, WalletID: walletID, Amount: amount}
+	if err := c.do(ctx, http.MethodPost, "/transactions", &req, &receipt); err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// getWallet retrieves the wallet.
+func (c *client) getWallet(ctx context.Context, id string) (*api.Wallet, error) {
+	var wallet api.Wallet
+	if err := c.do(ctx, http.MethodGet, "/wallets/"+id, nil, &wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// do sends JSON encoded request body, if any, and decodes successful response into v.
+func (c *client) do(ctx context.Context, method, path string, body, v any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body) // nolint
+		err := statusError{code: resp.StatusCode, retryAfter: defaultRetryAfter}
+		if seconds, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil {
+			err.retryAfter = time.Duration(seconds) * time.Second
+		}
+		return &err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// errMismatch reports wallets which balance is not the expected one.
+var errMismatch = errors.New("wallet balances do not match expected ones")
+
+// program flags
+var opts options
+
+// initialise program state
+func init() {
+	flag.StringVar(&opts.addr, "addr", "http://localhost:8000", "base URL of the ledger service")
+	flag.IntVar(&opts.wallets, "wallets", 100, "number of wallets provisioned before the run")
+	flag.IntVar(&opts.fund, "fund", 100000, "amount in cents deposited into every provisioned wallet")
+	flag.StringVar(&opts.mix, "mix", "create=1,deposit=45,withdraw=45,get=9", "operations mix as OPERATION=WEIGHT list of create, deposit, withdraw and get")
+	flag.StringVar(&opts.model, "model", modelClosed, "load model: closed or open")
+	flag.IntVar(&opts.workers, "workers", 16, "number of closed model workers, also concurrency of provisioning and verification")
+	flag.Float64Var(&opts.rate, "rate", 100, "open model requests per second")
+	flag.IntVar(&opts.maxInflight, "max-inflight", 1024, "open model maximum of requests in flight, requests over it are dropped")
+	flag.DurationVar(&opts.duration, "duration", 30*time.Second, "duration of the run")
+	flag.DurationVar(&opts.timeout, "timeout", 10*time.Second, "request timeout")
+	flag.IntVar(&opts.hot, "hot", 0, "number of hot wallets")
+	flag.Float64Var(&opts.hotShare, "hot-share", 0.8, "share of transactions and reads targeting hot wallets")
+	flag.IntVar(&opts.maxAmount, "max-amount", 1000, "maximum transaction amount in cents")
+	flag.Int64Var(&opts.seed, "seed", time.Now().UnixNano(), "random generator seed")
+}
+
+// main program entry point.
+func main() {
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	context.AfterFunc(ctx, cancel) // once interrupted, another interrupt terminates the program
+
+	if err := run(ctx, &opts, &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 1024}}, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "ledgerbench:", err)
+		os.Exit(1)
+	}
+}
+
+// run provisions wallets, generates load against them, reports latencies and errors and verifies wallet balances.
+func run(ctx context.Context, opts *options, httpClient *http.Client, w io.Writer) error {
+	b, err := newBench(opts, httpClient)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "provisioning %d wallets at %s\n", opts.wallets, opts.addr)
+	if err := b.provision(ctx); err != nil {
+		return errors.Wrap(err, "unable to provision wallets")
+	}
+
+	fmt.Fprintf(w, "running %s model for %v, seed %d\n\n", opts.model, opts.duration, opts.seed)
+	start := time.Now()
+	b.run(ctx)
+	if err := b.recorder.report(w, time.Since(start)); err != nil {
+		return err
+	}
+
+	// balances are verified even if the run was interrupted
+	fmt.Fprintln(w)
+	v, err := b.verify(context.WithoutCancel(ctx))
+	if err != nil {
+		return errors.Wrap(err, "unable to verify wallets")
+	}
+	if err := v.report(w); err != nil {
+		return err
+	}
+	if len(v.mismatches) > 0 {
+		return errMismatch
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// supported operations
+const (
+	opCreate   = "create"
+	opDeposit  = "deposit"
+	opWithdraw = "withdraw"
+	opGet      = "get"
+)
+
+// operations lists supported operations in the order they are reported.
+var operations = []string{opCreate, opDeposit, opWithdraw, opGet}
+
+// mix represents weighted mix of operations.
+type mix struct {
+	ops     []string
+	weights []int
+	total   int
+}
+
+// parseMix parses comma separated list of operation weights, e.g. "create=1,deposit=45,withdraw=45,get=9".
+// Operations not listed are not performed.
+func parseMix(s string) (*mix, error) {
+	var m mix
+	for _, entry := range strings.Split(s, ",") {
+		op, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, errors.Newf("operation weight %q is not valid, expected OPERATION=WEIGHT", entry)
+		}
+		if !isOperation(op) {
+			return nil, errors.Newf("operation %q is not supported", op)
+		}
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 0 {
+			return nil, errors.Newf("weight of operation %q is not valid: %q", op, value)
+		}
+		for _, v := range m.ops {
+			if v == op {
+				return nil, errors.Newf("operation %q is given twice", op)
+			}
+		}
+		m.ops, m.weights, m.total = append(m.ops, op), append(m.weights, weight), m.total+weight
+	}
+	if m.total == 0 {
+		return nil, errors.New("at least one operation must have positive weight")
+	}
+	return &m, nil
+}
+
+// isOperation reports whether op is supported operation.
+func isOperation(op string) bool {
+	for _, v := range operations {
+		if v == op {
+			return true
+		}
+	}
+	return false
+}
+
+// pick returns random operation according to the weights.
+func (m *mix) pick(r *rand.Rand) string {
+	n := r.Intn(m.total)
+	for i, w := range m.weights {
+		if n < w {
+			return m.ops[i]
+		}
+		n -= w
+	}
+	return m.ops[len(m.ops)-1]
+}
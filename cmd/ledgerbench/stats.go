@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// percentiles lists reported latency percentiles.
+var percentiles = []float64{50, 90, 99, 99.9}
+
+// recorder records latencies and errors of performed operations.
+type recorder struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration // latencies of successful requests by operation
+	errors    map[string]map[string]int  // number of errors by operation and error class
+}
+
+// newRecorder constructs a new recorder.
+func newRecorder() *recorder {
+	return &recorder{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]map[string]int),
+	}
+}
+
+// record records operation which took d and failed with err, if any.
+func (r *recorder) record(op string, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err == nil {
+		r.latencies[op] = append(r.latencies[op], d)
+		return
+	}
+	if r.errors[op] == nil {
+		r.errors[op] = make(map[string]int)
+	}
+	r.errors[op][errorClass(err)]++
+}
+
+// percentile returns p-th percentile of sorted durations using nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	// tolerance absorbs floating point error of fractional percentiles, e.g. 99.9
+	rank := int(math.Ceil(p/100*float64(len(sorted)) - 1e-9))
+	return sorted[max(rank, 1)-1]
+}
+
+// report writes throughput, latency percentiles of successful requests and errors by class of every operation.
+func (r *recorder) report(w io.Writer, elapsed time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "operation\trequests\terrors\trps\t")
+	for _, p := range percentiles {
+		fmt.Fprintf(tw, "p%v\t", p)
+	}
+	fmt.Fprintln(tw, "max\t")
+
+	for _, op := range operations {
+		latencies := r.latencies[op]
+		var failed int
+		for _, n := range r.errors[op] {
+			failed += n
+		}
+		if len(latencies)+failed == 0 {
+			continue
+		}
+
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		requests := len(latencies) + failed
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t", op, requests, failed, float64(requests)/elapsed.Seconds())
+		for _, p := range percentiles {
+			fmt.Fprintf(tw, "%v\t", round(percentile(latencies, p)))
+		}
+		fmt.Fprintf(tw, "%v\t\n", round(percentile(latencies, 100)))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.errors) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "operation\terror\tcount")
+	for _, op := range operations {
+		classes := make([]string, 0, len(r.errors[op]))
+		for class := range r.errors[op] {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			fmt.Fprintf(tw, "%s\t%s\t%d\n", op, class, r.errors[op][class])
+		}
+	}
+	return tw.Flush()
+}
+
+// round rounds latency for reporting.
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
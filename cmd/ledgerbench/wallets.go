@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// wallet represents state of the wallet the generator expects.
+type wallet struct {
+	id string
+
+	mu       sync.Mutex
+	balance  int // sum of applied transactions
+	reserved int // amount of withdrawals in flight
+	credit   int // amount of deposits of unknown outcome
+	debit    int // amount of withdrawals of unknown outcome
+}
+
+// reserve reserves at most amount for withdrawal, so concurrent withdrawals never overdraw expected balance.
+// Withdrawals of unknown outcome are considered to be applied. Reserved amount is returned, zero if nothing is available.
+func (w *wallet) reserve(amount int) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	available := w.balance - w.reserved - w.debit
+	if available < amount {
+		amount = available
+	}
+	if amount <= 0 {
+		return 0
+	}
+	w.reserved += amount
+	return amount
+}
+
+// settle records outcome of the transaction changing balance by delta.
+// Withdrawal must have been reserved beforehand.
+func (w *wallet) settle(delta int, o outcome) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if delta < 0 {
+		w.reserved += delta
+	}
+
+	switch {
+	case o == applied:
+		w.balance += delta
+	case o == unknown && delta > 0:
+		w.credit += delta
+	case o == unknown:
+		w.debit -= delta
+	}
+}
+
+// expected returns range the wallet balance is expected to be within, bounds are equal unless some outcomes are unknown.
+func (w *wallet) expected() (int, int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.balance - w.debit, w.balance + w.credit
+}
+
+// pool represents wallets load is generated against.
+// Share of requests given by hotShare targets the first hot wallets, the rest is spread evenly across all wallets.
+type pool struct {
+	hot      int
+	hotShare float64
+
+	mu      sync.RWMutex
+	wallets []*wallet
+}
+
+// add adds wallet to the pool.
+func (p *pool) add(w *wallet) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.wallets = append(p.wallets, w)
+}
+
+// all returns all wallets of the pool.
+func (p *pool) all() []*wallet {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]*wallet(nil), p.wallets...)
+}
+
+// pick returns random wallet of the pool.
+func (p *pool) pick(r *rand.Rand) *wallet {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	n := len(p.wallets)
+	if p.hot > 0 && r.Float64() < p.hotShare {
+		n = min(p.hot, n)
+	}
+	return p.wallets[r.Intn(n)]
+}
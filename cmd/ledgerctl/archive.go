@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/archive"
+	"github.com/deividaspetraitis/ledger/database/backend"
+	"github.com/deividaspetraitis/ledger/database/schema"
+	"github.com/deividaspetraitis/ledger/scheduler"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// exportArchive exports all wallets and schedules into archive file or standard output.
+func exportArchive(ctx context.Context, env *env, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", string(archive.FormatNDJSON), "archive format: ndjson or csv")
+	path := flags.String("file", "", "archive file, standard output if not set")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if len(*path) > 0 {
+		f, err := os.Create(*path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w, err := archive.NewWriter(out, archive.Format(*format))
+	if err != nil {
+		return err
+	}
+
+	n, err := archive.Export(ctx, env.store, w, &ledger.WalletAggregate{}, &scheduler.ScheduleAggregate{})
+	if err != nil {
+		return err
+	}
+
+	// summary is not mixed into the archive possibly written to standard output
+	fmt.Fprintf(os.Stderr, "ledgerctl: exported %d wallets and %d schedules\n", n[0], n[1])
+	return nil
+}
+
+// importArchive imports wallets and schedules from archive file or standard input and verifies that
+// every imported aggregate restored from the store matches the one replayed from the archive.
+func importArchive(ctx context.Context, env *env, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", string(archive.FormatNDJSON), "archive format: ndjson or csv")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if path := flags.Arg(0); len(path) > 0 && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	r, err := archive.NewReader(in, archive.Format(*format))
+	if err != nil {
+		return err
+	}
+
+	var (
+		view   verifications
+		failed int
+	)
+	_, err = archive.Import(ctx, r, env.store, func(ctx context.Context, records []*schema.Record) error {
+		v, err := compare(ctx, env, records)
+		if err != nil {
+			return err
+		}
+		if !v.OK {
+			failed++
+		}
+		view = append(view, v)
+		return nil
+	})
+
+	// imported wallets are reported even if import was interrupted
+	if err := env.output.print(view); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return errors.Newf("%d of %d imported aggregates do not match the archive", failed, len(view))
+	}
+	return nil
+}
+
+// compare compares aggregate restored from the store with the aggregate replayed from its archived records.
+func compare(ctx context.Context, env *env, records []*schema.Record) (*verification, error) {
+	if records[0].Aggregate == es.ParseAggregateName(&scheduler.ScheduleAggregate{}) {
+		return compareSchedule(ctx, env, records)
+	}
+
+	id := records[0].AggregateID
+
+	// archived wallet
+	want := &verification{ID: id}
+	err := replay(ctx, id, &sliceIterator{records: records}, env.cfg.Events.Cutover(), func(record *schema.Record, _ es.MarshalUnmarshaler, wallet *ledger.WalletAggregate) {
+		want.Version, want.Balance, want.Head = record.Version, wallet.Balance, wallet.ChainHead()
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		want.Error = "archive: " + err.Error()
+		return want, nil
+	}
+
+	// imported wallet
+	wallet, err := env.get(ctx, &ledger.WalletAggregate{}, id)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		want.Error = "store: " + err.Error()
+		return want, nil
+	}
+
+	got := &verification{
+		ID:      id,
+		Version: uint64(wallet.Root().Version()),
+		Balance: wallet.Balance,
+		Head:    wallet.ChainHead(),
+	}
+	switch {
+	case got.Version != want.Version:
+		got.Error = fmt.Sprintf("version %d, want %d", got.Version, want.Version)
+	case got.Balance != want.Balance:
+		got.Error = fmt.Sprintf("balance %d, want %d", got.Balance, want.Balance)
+	case got.Head != want.Head:
+		got.Error = fmt.Sprintf("head %s, want %s", got.Head, want.Head)
+	default:
+		got.OK = true
+	}
+
+	return got, nil
+}
+
+// compareSchedule compares schedule restored from the store with the schedule replayed from its archived records.
+func compareSchedule(ctx context.Context, env *env, records []*schema.Record) (*verification, error) {
+	id := records[0].AggregateID
+
+	// archived schedule, unknown events are never skipped
+	var want scheduler.ScheduleAggregate
+	if err := (&schema.Restorer{}).Restore(ctx, &want, id, &sliceIterator{records: records}); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return &verification{ID: id, Error: "archive: " + err.Error()}, nil
+	}
+
+	// imported schedule
+	got, err := backend.Get[*scheduler.ScheduleAggregate](ctx, env.store, &scheduler.ScheduleAggregate{}, id)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return &verification{ID: id, Error: "store: " + err.Error()}, nil
+	}
+
+	v := &verification{
+		ID:      id,
+		Version: uint64(got.Root().Version()),
+	}
+	switch {
+	case v.Version != uint64(want.Root().Version()):
+		v.Error = fmt.Sprintf("version %d, want %d", v.Version, want.Root().Version())
+	case got.Status != want.Status:
+		v.Error = fmt.Sprintf("status %s, want %s", got.Status, want.Status)
+	default:
+		v.OK = true
+	}
+
+	return v, nil
+}
@@ -0,0 +1,585 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+	"github.com/deividaspetraitis/ledger/index"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// walletView represents rendered wallet.
+type walletView struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Balance  int    `json:"balance"`
+	Tier     string `json:"tier,omitempty"`
+	Degraded bool   `json:"degraded,omitempty"`
+
+	OverdraftLimit  int `json:"overdraft_limit,omitempty"`
+	OverdraftRate   int `json:"overdraft_rate,omitempty"`
+	AvailableCredit int `json:"available_credit,omitempty"`
+}
+
+func newWalletView(w *ledger.Wallet) *walletView {
+	return &walletView{
+		ID:              w.ID,
+		Name:            w.Name,
+		Balance:         w.Balance,
+		Tier:            w.Tier,
+		Degraded:        w.Degraded,
+		OverdraftLimit:  w.OverdraftLimit,
+		OverdraftRate:   w.OverdraftRate,
+		AvailableCredit: w.AvailableCredit(),
+	}
+}
+
+// wallets implements tabular.
+type wallets []*walletView
+
+func (v wallets) header() []string {
+	return []string{"ID", "NAME", "BALANCE", "DEGRADED"}
+}
+
+func (v wallets) rows() [][]string {
+	var rows [][]string
+	for _, w := range v {
+		rows = append(rows, []string{w.ID, w.Name, strconv.Itoa(w.Balance), strconv.FormatBool(w.Degraded)})
+	}
+	return rows
+}
+
+// eventView represents rendered wallet event.
+type eventView struct {
+	Version   uint64    `json:"version"`
+	Type      string    `json:"type"`
+	Amount    int       `json:"amount"`
+	Balance   int       `json:"balance"` // Wallet balance after the event
+	Timestamp time.Time `json:"timestamp"`
+
+	TransactionID     string            `json:"transaction_id,omitempty"`
+	Description       string            `json:"description,omitempty"`
+	ExternalReference string            `json:"external_reference,omitempty"`
+	Metadata          map[string]string `json:"metadata,omitempty"`
+}
+
+// events implements tabular.
+type events []*eventView
+
+func (v events) header() []string {
+	return []string{"VERSION", "TYPE", "AMOUNT", "BALANCE", "TIMESTAMP", "REFERENCE", "DESCRIPTION"}
+}
+
+func (v events) rows() [][]string {
+	var rows [][]string
+	for _, e := range v {
+		rows = append(rows, []string{
+			strconv.FormatUint(e.Version, 10),
+			e.Type,
+			strconv.Itoa(e.Amount),
+			strconv.Itoa(e.Balance),
+			e.Timestamp.Format(time.RFC3339),
+			e.ExternalReference,
+			e.Description,
+		})
+	}
+	return rows
+}
+
+// verification represents rendered wallet verification result.
+type verification struct {
+	ID      string `json:"id"`
+	Version uint64 `json:"version"` // Last verified version
+	Head    string `json:"head"`    // Hash chain head at the last verified version
+	Balance int    `json:"balance"` // Balance at the last verified version
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+// verifications implements tabular.
+type verifications []*verification
+
+func (v verifications) header() []string {
+	return []string{"ID", "VERSION", "BALANCE", "HEAD", "STATUS"}
+}
+
+func (v verifications) rows() [][]string {
+	var rows [][]string
+	for _, r := range v {
+		status := "ok"
+		if !r.OK {
+			status = "failed: " + r.Error
+		}
+		rows = append(rows, []string{r.ID, strconv.FormatUint(r.Version, 10), strconv.Itoa(r.Balance), r.Head, status})
+	}
+	return rows
+}
+
+// recordView represents rendered raw persisted event.
+type recordView struct {
+	Version   uint64          `json:"version"`
+	Type      string          `json:"type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+	Metadata  json.RawMessage `json:"metadata,omitempty"`
+}
+
+// records implements tabular.
+type records []*recordView
+
+func (v records) header() []string {
+	return []string{"VERSION", "TYPE", "TIMESTAMP", "DATA", "METADATA"}
+}
+
+func (v records) rows() [][]string {
+	var rows [][]string
+	for _, r := range v {
+		rows = append(rows, []string{
+			strconv.FormatUint(r.Version, 10),
+			r.Type,
+			r.Timestamp.Format(time.RFC3339),
+			string(r.Data),
+			string(r.Metadata),
+		})
+	}
+	return rows
+}
+
+// walletArg returns the only wallet ID argument.
+func walletArg(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("exactly one wallet ID argument is expected")
+	}
+	return args[0], nil
+}
+
+// createWallet creates a new wallet.
+func createWallet(ctx context.Context, env *env, args []string) error {
+	flags := flag.NewFlagSet("create-wallet", flag.ContinueOnError)
+	name := flags.String("name", "", "wallet name")
+	tier := flags.String("tier", "", "fee schedule tier")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	wallet, err := ledger.CreateWallet(ctx, env.save, &ledger.CreateWalletRequest{Name: *name, Tier: *tier})
+	if err != nil {
+		return err
+	}
+
+	return env.output.print(wallets{newWalletView(wallet)})
+}
+
+// postTransaction posts a transaction to the wallet.
+func postTransaction(ctx context.Context, env *env, args []string) error {
+	flags := flag.NewFlagSet("transaction", flag.ContinueOnError)
+	id := flags.String("wallet", "", "wallet ID")
+	typ := flags.String("type", "", "transaction type: deposit or withdraw")
+	amount := flags.Int("amount", 0, "transaction amount in cents")
+	description := flags.String("description", "", "transaction description")
+	reference := flags.String("reference", "", "transaction reference in external system")
+	metadata := make(metadataFlag)
+	flags.Var(metadata, "meta", "transaction metadata entry KEY=VALUE, may be repeated")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	req := ledger.TransactionRequest{
+		Type:              *typ,
+		WalletID:          *id,
+		Amount:            *amount,
+		Description:       *description,
+		ExternalReference: *reference,
+	}
+	if len(metadata) > 0 {
+		req.Metadata = metadata
+	}
+
+	receipt, err := ledger.CreateTransaction(ctx, env.save, env.get, env.fees, &req)
+	if err != nil {
+		return err
+	}
+
+	return env.output.print(receipts{{
+		TransactionID: receipt.TransactionID,
+		WalletID:      receipt.ID,
+		Balance:       receipt.Balance,
+		Version:       receipt.Version,
+	}})
+}
+
+// receiptView represents rendered processed transaction.
+type receiptView struct {
+	TransactionID string `json:"transaction_id"`
+	WalletID      string `json:"wallet_id"`
+	Balance       int    `json:"balance"` // Wallet balance once transaction was processed
+	Version       uint64 `json:"version"` // Wallet version once transaction was processed
+}
+
+// receipts implements tabular.
+type receipts []*receiptView
+
+func (v receipts) header() []string {
+	return []string{"TRANSACTION", "WALLET", "BALANCE", "VERSION"}
+}
+
+func (v receipts) rows() [][]string {
+	var rows [][]string
+	for _, r := range v {
+		rows = append(rows, []string{r.TransactionID, r.WalletID, strconv.Itoa(r.Balance), strconv.FormatUint(r.Version, 10)})
+	}
+	return rows
+}
+
+// metadataFlag implements flag.Value collecting repeated KEY=VALUE metadata entries.
+type metadataFlag map[string]string
+
+func (m metadataFlag) String() string {
+	return fmt.Sprint(map[string]string(m))
+}
+
+func (m metadataFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok {
+		return errors.Newf("metadata entry %q is not KEY=VALUE", s)
+	}
+	m[k] = v
+	return nil
+}
+
+// transactionView represents rendered persisted transaction.
+type transactionView struct {
+	ID                string            `json:"id,omitempty"`
+	WalletID          string            `json:"wallet_id"`
+	Version           uint64            `json:"version"`
+	Type              string            `json:"type"`
+	Amount            int               `json:"amount"`
+	Description       string            `json:"description,omitempty"`
+	ExternalReference string            `json:"external_reference,omitempty"`
+	Metadata          map[string]string `json:"metadata,omitempty"`
+	Timestamp         time.Time         `json:"timestamp"`
+}
+
+// transactions implements tabular.
+type transactions []*transactionView
+
+func (v transactions) header() []string {
+	return []string{"ID", "WALLET", "VERSION", "TYPE", "AMOUNT", "TIMESTAMP", "REFERENCE", "DESCRIPTION"}
+}
+
+func (v transactions) rows() [][]string {
+	var rows [][]string
+	for _, t := range v {
+		rows = append(rows, []string{
+			t.ID,
+			t.WalletID,
+			strconv.FormatUint(t.Version, 10),
+			t.Type,
+			strconv.Itoa(t.Amount),
+			t.Timestamp.Format(time.RFC3339),
+			t.ExternalReference,
+			t.Description,
+		})
+	}
+	return rows
+}
+
+// search shows transactions of the given external reference across all wallets.
+func search(ctx context.Context, env *env, args []string) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	reference := flags.String("reference", "", "transaction reference in external system")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(*reference) == 0 {
+		return ledger.ErrNotValidReference
+	}
+
+	idx := index.New(nil, env.store.RecordsAfter)
+	if err := idx.Rebuild(ctx, env.store.IDs); err != nil {
+		return err
+	}
+
+	entries, err := idx.FindByReference(ctx, *reference)
+	if err != nil {
+		return err
+	}
+
+	var view transactions
+	for _, e := range entries {
+		view = append(view, &transactionView{
+			ID:                e.ID,
+			WalletID:          e.WalletID,
+			Version:           e.Version,
+			Type:              e.Type,
+			Amount:            e.Amount,
+			Description:       e.Description,
+			ExternalReference: e.ExternalReference,
+			Metadata:          e.Metadata,
+			Timestamp:         e.Timestamp,
+		})
+	}
+	return env.output.print(view)
+}
+
+// overdraft sets wallet credit line.
+func overdraft(ctx context.Context, env *env, args []string) error {
+	flags := flag.NewFlagSet("overdraft", flag.ContinueOnError)
+	id := flags.String("wallet", "", "wallet ID")
+	limit := flags.Int("limit", 0, "credit line in cents, zero closes it")
+	rate := flags.Int("rate", 0, "annual overdraft interest rate in basis points")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	wallet, err := ledger.SetOverdraft(ctx, env.save, env.get, &ledger.SetOverdraftRequest{
+		WalletID: *id,
+		Limit:    *limit,
+		Rate:     *rate,
+	})
+	if err != nil {
+		return err
+	}
+
+	return env.output.print(wallets{newWalletView(wallet)})
+}
+
+// balance shows current wallet balance.
+func balance(ctx context.Context, env *env, args []string) error {
+	id, err := walletArg(args)
+	if err != nil {
+		return err
+	}
+
+	wallet, err := ledger.GetWallet(ctx, env.get, id)
+	if err != nil {
+		return err
+	}
+
+	return env.output.print(wallets{newWalletView(wallet)})
+}
+
+// history shows wallet events along the balance after each of them.
+// Events are shown up until the first one failing verification.
+func history(ctx context.Context, env *env, args []string) error {
+	id, err := walletArg(args)
+	if err != nil {
+		return err
+	}
+
+	it, err := env.store.Records(ctx, &ledger.WalletAggregate{}, id)
+	if err != nil {
+		return err
+	}
+
+	var view events
+	err = replay(ctx, id, it, env.cfg.Events.Cutover(), func(record *schema.Record, event es.MarshalUnmarshaler, wallet *ledger.WalletAggregate) {
+		e := &eventView{
+			Version:   record.Version,
+			Type:      record.Type,
+			Balance:   wallet.Balance,
+			Timestamp: record.Timestamp,
+		}
+		switch v := event.(type) {
+		case *ledger.WalletInitialized:
+			e.Amount = v.Balance
+		case *ledger.Deposit:
+			e.Amount, e.TransactionID = v.Amount, v.ID
+			e.Description, e.ExternalReference, e.Metadata = v.Description, v.ExternalReference, v.Metadata
+		case *ledger.Withdraw:
+			e.Amount, e.TransactionID = -v.Amount, v.ID
+			e.Description, e.ExternalReference, e.Metadata = v.Description, v.ExternalReference, v.Metadata
+		case *ledger.FeeCharged:
+			e.Amount = -v.Amount
+		case *ledger.FeeCollected:
+			e.Amount = v.Amount
+		case *ledger.OverdraftInterestAccrued:
+			e.Amount = -v.Amount
+		case *ledger.InterestPosted:
+			e.Amount = v.Amount
+		}
+		view = append(view, e)
+	})
+	if err != nil {
+		if errors.Is(err, ledger.ErrEntryNotFound) {
+			return err
+		}
+		// events replayed up to the failure are shown along the error
+		if err := env.output.print(view); err != nil {
+			return err
+		}
+		return err
+	}
+
+	return env.output.print(view)
+}
+
+// verify verifies hash chain and replay integrity of given wallets or all wallets if none were given.
+func verify(ctx context.Context, env *env, args []string) error {
+	ids := args
+	if len(ids) == 0 {
+		var err error
+		if ids, err = env.store.IDs(ctx, &ledger.WalletAggregate{}); err != nil {
+			return err
+		}
+	}
+
+	var (
+		view   verifications
+		failed int
+	)
+	for _, id := range ids {
+		it, err := env.store.Records(ctx, &ledger.WalletAggregate{}, id)
+		if err != nil {
+			return err
+		}
+
+		// only records passing verification are reported
+		v := &verification{ID: id}
+		err = replay(ctx, id, it, env.cfg.Events.Cutover(), func(record *schema.Record, _ es.MarshalUnmarshaler, wallet *ledger.WalletAggregate) {
+			v.Version, v.Balance, v.Head = record.Version, wallet.Balance, wallet.ChainHead()
+		})
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+
+		v.OK = err == nil
+		if err != nil {
+			v.Error = err.Error()
+			failed++
+		}
+		view = append(view, v)
+	}
+
+	if err := env.output.print(view); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return errors.Newf("%d of %d wallets failed verification", failed, len(ids))
+	}
+	return nil
+}
+
+// restore restores every wallet from scratch the same way the service does and shows them,
+// unknown events are handled according to configured policy. Nothing is persisted.
+func restore(ctx context.Context, env *env, args []string) error {
+	ids, err := env.store.IDs(ctx, &ledger.WalletAggregate{})
+	if err != nil {
+		return err
+	}
+
+	var (
+		view   wallets
+		failed int
+	)
+	for _, id := range ids {
+		wallet, err := ledger.GetWallet(ctx, env.get, id)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "ledgerctl: unable to restore wallet %s: %v\n", id, err)
+			failed++
+			continue
+		}
+		view = append(view, newWalletView(wallet))
+	}
+
+	if err := env.output.print(view); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return errors.Newf("%d of %d wallets failed to restore", failed, len(ids))
+	}
+	return nil
+}
+
+// projection implements tabular.
+type projection struct {
+	Name    string `json:"name"`
+	Wallets int    `json:"wallets"`
+	Entries int    `json:"entries"`
+}
+
+type projections []*projection
+
+func (v projections) header() []string {
+	return []string{"PROJECTION", "WALLETS", "ENTRIES"}
+}
+
+func (v projections) rows() [][]string {
+	var rows [][]string
+	for _, p := range v {
+		rows = append(rows, []string{p.Name, strconv.Itoa(p.Wallets), strconv.Itoa(p.Entries)})
+	}
+	return rows
+}
+
+// rebuild rebuilds transactions index the service serves lookups by transaction ID and external reference from
+// by reading every wallet stream from scratch and shows number of indexed entries. Nothing is persisted.
+func rebuild(ctx context.Context, env *env, args []string) error {
+	ids, err := env.store.IDs(ctx, &ledger.WalletAggregate{})
+	if err != nil {
+		return err
+	}
+
+	idx := index.New(nil, env.store.RecordsAfter)
+	if err := idx.Rebuild(ctx, func(context.Context, es.Aggregate) ([]string, error) { return ids, nil }); err != nil {
+		return err
+	}
+
+	return env.output.print(projections{
+		{Name: "transactions", Wallets: len(ids), Entries: idx.Transactions()},
+		{Name: "references", Wallets: len(ids), Entries: idx.Len()},
+	})
+}
+
+// dump dumps raw persisted wallet events.
+func dump(ctx context.Context, env *env, args []string) error {
+	id, err := walletArg(args)
+	if err != nil {
+		return err
+	}
+
+	it, err := env.store.Records(ctx, &ledger.WalletAggregate{}, id)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	var view records
+	for it.Next() {
+		record, err := it.Value()
+		if err != nil {
+			return err
+		}
+		view = append(view, &recordView{
+			Version:   record.Version,
+			Type:      record.Type,
+			Timestamp: record.Timestamp,
+			Data:      record.Data,
+			Metadata:  record.Metadata,
+		})
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+
+	if len(view) == 0 {
+		return ledger.ErrEntryNotFound
+	}
+
+	return env.output.print(view)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/config"
+	"github.com/deividaspetraitis/ledger/database/backend"
+	"github.com/deividaspetraitis/ledger/fee"
+
+	"github.com/deividaspetraitis/go/database"
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// program flags
+var (
+	cfgPath string
+	format  string
+)
+
+// initialise program state
+func init() {
+	flag.StringVar(&cfgPath, "config", os.Getenv("config"), "PATH to env, YAML or TOML configuration file")
+	flag.StringVar(&format, "output", formatTable, "output format: table or json")
+	flag.Usage = usage
+}
+
+// env represents dependencies shared by commands.
+type env struct {
+	cfg    *config.Config
+	store  backend.Store
+	save   database.SaveAggregateFunc
+	get    database.GetAggregateFunc[*ledger.WalletAggregate]
+	fees   *fee.Schedule
+	output *output
+}
+
+// command represents ledgerctl subcommand.
+type command struct {
+	name  string
+	args  string
+	usage string
+	run   func(ctx context.Context, env *env, args []string) error
+}
+
+// commands lists supported subcommands.
+var commands = []*command{
+	{name: "create-wallet", args: "-name NAME [-tier TIER]", usage: "create a new wallet", run: createWallet},
+	{name: "transaction", args: "-wallet ID -type deposit|withdraw -amount CENTS [-description TEXT] [-reference REF] [-meta KEY=VALUE...]", usage: "post a transaction", run: postTransaction},
+	{name: "overdraft", args: "-wallet ID -limit CENTS [-rate BPS]", usage: "set wallet credit line", run: overdraft},
+	{name: "balance", args: "ID", usage: "show wallet balance", run: balance},
+	{name: "history", args: "ID", usage: "show wallet events history", run: history},
+	{name: "search", args: "-reference REF", usage: "search transactions of all wallets by external reference", run: search},
+	{name: "verify", args: "[ID...]", usage: "verify hash chain and replay integrity of given or all wallets", run: verify},
+	{name: "restore", usage: "restore all wallets from scratch the same way serverd does and show them", run: restore},
+	{name: "rebuild", usage: "rebuild transactions index from all wallets and show its size", run: rebuild},
+	{name: "dump", args: "ID", usage: "dump raw persisted events of the wallet", run: dump},
+	{name: "export", args: "[-format ndjson|csv] [-file PATH]", usage: "export all wallets and schedules into archive", run: exportArchive},
+	{name: "import", args: "[-format ndjson|csv] [PATH]", usage: "import wallets and schedules from archive and verify them", run: importArchive},
+	{name: "reconcile", args: "[-format csv|camt053] [-tolerance DURATION] [-references] PATH", usage: "reconcile bank statement against wallets transactions", run: reconcileStatement},
+}
+
+// usage prints program usage.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] <command> [arguments]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-14s %s\n  %-14s   %s\n", c.name, c.args, "", c.usage)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// main program entry point.
+func main() {
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := run(ctx, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "ledgerctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, name string, args []string) error {
+	var cmd *command
+	for _, c := range commands {
+		if c.name == name {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		return errors.Newf("unknown command: %s", name)
+	}
+
+	output, err := newOutput(os.Stdout, format)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.New(cfgPath, nil)
+	if err != nil {
+		return errors.Wrap(err, "parsing configuration file")
+	}
+
+	// connect to DB instance of the configured store backend
+	store, closeStore, err := backend.Open(ctx, cfg.Store, cfg.Database, cfg.Events)
+	if err != nil {
+		return errors.Wrap(err, "unable to construct store")
+	}
+	defer closeStore()
+
+	fees, err := fee.New(cfg.Fees)
+	if err != nil {
+		return errors.Wrap(err, "unable to construct fee schedule")
+	}
+
+	// commands work with the store directly, wallets are always restored from scratch.
+	return cmd.run(ctx, &env{
+		cfg:   cfg,
+		store: store,
+		save:  store.Save,
+		get: func(ctx context.Context, aggregate es.Aggregate, id string) (*ledger.WalletAggregate, error) {
+			return backend.Get[*ledger.WalletAggregate](ctx, store, aggregate, id)
+		},
+		fees:   fees,
+		output: output,
+	}, args)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// supported output formats
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// tabular is implemented by command results which can be rendered as a table.
+type tabular interface {
+	// header returns table columns.
+	header() []string
+
+	// rows returns table rows.
+	rows() [][]string
+}
+
+// output renders command results in the configured format.
+type output struct {
+	w      io.Writer
+	format string
+}
+
+// newOutput constructs a new output writing results to w.
+func newOutput(w io.Writer, format string) (*output, error) {
+	switch format {
+	case formatTable, formatJSON:
+	default:
+		return nil, errors.Newf("unsupported output format: %s", format)
+	}
+	return &output{w: w, format: format}, nil
+}
+
+// print renders result v.
+func (o *output) print(v tabular) error {
+	if o.format == formatJSON {
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(v.header(), "\t"))
+	for _, row := range v.rows() {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/index"
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+	"github.com/deividaspetraitis/ledger/reconcile"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// reconciliation implements tabular.
+type reconciliation struct {
+	*api.Reconciliation
+}
+
+func (v reconciliation) header() []string {
+	return []string{"STATUS", "DATE", "REFERENCE", "AMOUNT", "WALLET", "VERSION", "LEDGER AMOUNT", "REASON"}
+}
+
+func (v reconciliation) rows() [][]string {
+	var rows [][]string
+	for _, r := range v.Results {
+		row := make([]string, 8)
+		row[0], row[7] = r.Status, r.Reason
+		if e := r.Entry; e != nil {
+			row[1], row[2], row[3] = e.Date.Format(time.DateOnly), e.Reference, strconv.Itoa(e.Amount)
+		}
+		if tx := r.Transaction; tx != nil {
+			if len(row[1]) == 0 {
+				row[1] = tx.Date.Format(time.DateOnly)
+			}
+			row[4], row[5], row[6] = tx.WalletID, strconv.FormatUint(tx.Version, 10), strconv.Itoa(tx.Amount)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// reconcileStatement reconciles bank statement file against wallets transactions.
+func reconcileStatement(ctx context.Context, env *env, args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	format := flags.String("format", string(reconcile.FormatCSV), "statement format: csv or camt053")
+	tolerance := flags.Duration("tolerance", 0, "maximum difference between statement and ledger dates, configured one if not set")
+	references := flags.Bool("references", false, "resolve wallets of entries by transactions external references, indexes all wallets first")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("exactly one statement file argument is expected")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	entries, err := reconcile.Parse(f, reconcile.Format(*format))
+	if err != nil {
+		return err
+	}
+
+	var findWallet reconcile.FindWalletFunc
+	if *references {
+		idx := index.New(nil, env.store.RecordsAfter)
+		if err := idx.Rebuild(ctx, env.store.IDs); err != nil {
+			return err
+		}
+		findWallet = idx.FindWallet
+	}
+
+	reconciler := reconcile.NewReconciler(env.cfg.Reconcile, reconcile.NewGetItemsFunc(env.store.Records), findWallet)
+	report, err := reconciler.Reconcile(ctx, &reconcile.Request{
+		Entries:   entries,
+		Tolerance: *tolerance,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := env.output.print(reconciliation{api.NewReconciliationResponse(report)}); err != nil {
+		return err
+	}
+
+	if n := report.Mismatched + report.Unmatched; n > 0 {
+		return errors.Newf("%d of %d items are not reconciled", n, len(report.Results))
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// visitFunc is called for every replayed record along the wallet state after applying the record.
+type visitFunc func(record *schema.Record, event es.MarshalUnmarshaler, wallet *ledger.WalletAggregate)
+
+// replay restores the wallet identified by id from records read from the iterator one event at a time,
+// verifying events hash chain and wallet invariants along the way.
+// Unlinked records persisted before the cutover are accepted at the beginning of the stream, see schema.Verify.
+// Unlike the store replay never skips unknown events, they are reported as an error.
+func replay(ctx context.Context, id string, it schema.Iterator, cutover time.Time, visit visitFunc) error {
+	defer it.Close()
+
+	var (
+		wallet ledger.WalletAggregate
+		head   string
+	)
+	for it.Next() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		record, err := it.Value()
+		if err != nil {
+			return err
+		}
+
+		if head, err = schema.Verify(head, record, cutover); err != nil {
+			return err
+		}
+
+		event, err := schema.Decode(&wallet, record.Type, record.Data)
+		if err != nil {
+			return &schema.UnknownEventError{AggregateID: id, Version: record.Version, Type: record.Type, Err: err}
+		}
+
+		e := es.NewEvent(id, &wallet, event)
+		if uint64(e.Version) != record.Version {
+			return errors.Newf("version %d found where %d expected", record.Version, e.Version)
+		}
+
+		if err := invariants(&wallet, id, event); err != nil {
+			return errors.Wrapf(err, "version %d", record.Version)
+		}
+
+		if err := wallet.Reply([]*es.Event{e}); err != nil {
+			return errors.Wrapf(err, "version %d", record.Version)
+		}
+		wallet.SetChainHead(head)
+
+		// debits may overdraw the wallet up to its credit line only, accrued interest may exceed it.
+		switch event.(type) {
+		case *ledger.Withdraw, *ledger.FeeCharged:
+			if wallet.Balance < -wallet.OverdraftLimit {
+				return errors.Newf("version %d: negative balance %d", record.Version, wallet.Balance)
+			}
+		}
+
+		if visit != nil {
+			visit(record, event, &wallet)
+		}
+	}
+
+	if err := it.Error(); err != nil {
+		return err
+	}
+
+	if wallet.Root().Version() == 0 {
+		return ledger.ErrEntryNotFound
+	}
+
+	return nil
+}
+
+// invariants verifies that event may be applied to the wallet in its current state.
+func invariants(wallet *ledger.WalletAggregate, id string, event es.MarshalUnmarshaler) error {
+	// version is already advanced for the event being verified
+	initialised := wallet.Root().Version() > 1
+
+	switch e := event.(type) {
+	case *ledger.WalletInitialized:
+		if initialised {
+			return errors.New("wallet initialised more than once")
+		}
+		if e.ID != id {
+			return errors.Newf("wallet initialised with foreign id %s", e.ID)
+		}
+	case *ledger.Deposit:
+		if !initialised {
+			return errors.New("deposit into not initialised wallet")
+		}
+		if e.WalletID != id {
+			return errors.Newf("deposit of foreign wallet %s", e.WalletID)
+		}
+	case *ledger.Withdraw:
+		if !initialised {
+			return errors.New("withdrawal from not initialised wallet")
+		}
+		if e.WalletID != id {
+			return errors.Newf("withdrawal of foreign wallet %s", e.WalletID)
+		}
+	case *ledger.FeeCharged:
+		if !initialised {
+			return errors.New("fee charged to not initialised wallet")
+		}
+		if e.WalletID != id {
+			return errors.Newf("fee charged to foreign wallet %s", e.WalletID)
+		}
+	case *ledger.FeeCollected:
+		if !initialised {
+			return errors.New("fee collected by not initialised wallet")
+		}
+		if e.WalletID != id || e.Source == id {
+			return errors.Newf("fee collected by foreign wallet %s", e.WalletID)
+		}
+	case *ledger.OverdraftLimitSet:
+		if !initialised {
+			return errors.New("overdraft set on not initialised wallet")
+		}
+		if e.WalletID != id {
+			return errors.Newf("overdraft set on foreign wallet %s", e.WalletID)
+		}
+		if e.Limit < 0 || e.Rate < 0 || wallet.Balance < -e.Limit {
+			return errors.Newf("overdraft limit %d not valid for balance %d", e.Limit, wallet.Balance)
+		}
+	case *ledger.OverdraftInterestAccrued:
+		if !initialised {
+			return errors.New("interest accrued on not initialised wallet")
+		}
+		if e.WalletID != id {
+			return errors.Newf("interest accrued on foreign wallet %s", e.WalletID)
+		}
+		if !e.Day.After(wallet.AccruedThrough) {
+			return errors.Newf("interest accrued for %s twice", e.Day.Format(time.DateOnly))
+		}
+	case *ledger.InterestTermsSet:
+		if !initialised {
+			return errors.New("interest terms set on not initialised wallet")
+		}
+		if e.WalletID != id {
+			return errors.Newf("interest terms set on foreign wallet %s", e.WalletID)
+		}
+		if err := e.InterestTerms.Validate(); err != nil {
+			return err
+		}
+	case *ledger.InterestPosted:
+		if !initialised {
+			return errors.New("interest posted to not initialised wallet")
+		}
+		if e.WalletID != id {
+			return errors.Newf("interest posted to foreign wallet %s", e.WalletID)
+		}
+		if e.Amount < 0 || !e.To.After(e.From) || e.From.Before(wallet.InterestPostedThrough) {
+			return errors.Newf("interest posted for overlapping period %s - %s", e.From.Format(time.DateOnly), e.To.Format(time.DateOnly))
+		}
+	}
+
+	return nil
+}
+
+// sliceIterator implements schema.Iterator over slice of records.
+type sliceIterator struct {
+	records []*schema.Record
+	i       int
+}
+
+// Next implements schema.Iterator.
+func (s *sliceIterator) Next() bool {
+	s.i++
+	return s.i <= len(s.records)
+}
+
+// Value implements schema.Iterator.
+func (s *sliceIterator) Value() (*schema.Record, error) {
+	return s.records[s.i-1], nil
+}
+
+// Error implements schema.Iterator.
+func (s *sliceIterator) Error() error {
+	return nil
+}
+
+// Close implements schema.Iterator.
+func (s *sliceIterator) Close() {}
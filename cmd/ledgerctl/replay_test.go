@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// walletRecords returns sealed records of a wallet with 10 deposited and 4 withdrawn.
+func walletRecords(t *testing.T) []*schema.Record {
+	wallet, err := ledger.NewWallet(&ledger.CreateWalletRequest{Name: "test"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	for _, tx := range []*ledger.Transaction{
+		{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: 10},
+		{Type: ledger.TransactionWithdraw, WalletID: wallet.ID, Amount: 4},
+	} {
+		if err := wallet.ProcessTransaction(tx); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+	}
+
+	records, _, err := schema.Encode(wallet, wallet.Events())
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return records
+}
+
+func TestReplay(t *testing.T) {
+	var testcases = []struct {
+		name     string
+		tamper   func(records []*schema.Record) []*schema.Record
+		balances []int
+		err      error
+	}{
+		{
+			name:     "untouched",
+			tamper:   func(records []*schema.Record) []*schema.Record { return records },
+			balances: []int{0, 10, 6},
+		},
+		{
+			name:   "no events",
+			tamper: func(records []*schema.Record) []*schema.Record { return nil },
+			err:    ledger.ErrEntryNotFound,
+		},
+		{
+			name: "edited payload",
+			tamper: func(records []*schema.Record) []*schema.Record {
+				records[2].Data = []byte(`{"wallet_id":"` + records[2].AggregateID + `","amount":1}`)
+				return records
+			},
+			balances: []int{0, 10},
+			err:      schema.ErrChainBroken,
+		},
+		{
+			name: "stripped links",
+			tamper: func(records []*schema.Record) []*schema.Record {
+				for _, r := range records {
+					r.Metadata = nil
+				}
+				return records
+			},
+			err: schema.ErrChainBroken,
+		},
+		{
+			name: "unknown event",
+			tamper: func(records []*schema.Record) []*schema.Record {
+				records[2].Type = "Refund.v1"
+				_, _ = schema.Seal("", records)
+				return records
+			},
+			balances: []int{0, 10},
+			err:      schema.ErrUnknownEvent,
+		},
+		{
+			name: "negative balance",
+			tamper: func(records []*schema.Record) []*schema.Record {
+				records[2].Data = []byte(`{"wallet_id":"` + records[2].AggregateID + `","amount":11}`)
+				_, _ = schema.Seal("", records)
+				return records
+			},
+			balances: []int{0, 10},
+			err:      errors.New("negative balance"),
+		},
+		{
+			name: "initialised twice",
+			tamper: func(records []*schema.Record) []*schema.Record {
+				records[1].Type, records[1].Data = records[0].Type, records[0].Data
+				_, _ = schema.Seal("", records)
+				return records
+			},
+			balances: []int{0},
+			err:      errors.New("initialised more than once"),
+		},
+	}
+
+	for i, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			records := walletRecords(t)
+			id := records[0].AggregateID
+
+			var balances []int
+			err := replay(context.Background(), id, &sliceIterator{records: tt.tamper(records)}, time.Time{}, func(_ *schema.Record, _ es.MarshalUnmarshaler, wallet *ledger.WalletAggregate) {
+				balances = append(balances, wallet.Balance)
+			})
+
+			switch {
+			case tt.err == nil && err != nil:
+				t.Errorf("#%d got %v, want %v", i, err, nil)
+			case tt.err != nil && err == nil:
+				t.Errorf("#%d got %v, want %v", i, err, tt.err)
+			case tt.err != nil && !errors.Is(err, tt.err) && !containsError(err, tt.err):
+				t.Errorf("#%d got %v, want %v", i, err, tt.err)
+			}
+
+			if len(balances) != len(tt.balances) {
+				t.Fatalf("#%d got %v, want %v", i, balances, tt.balances)
+			}
+			for j := range balances {
+				if balances[j] != tt.balances[j] {
+					t.Errorf("#%d got %v, want %v", i, balances, tt.balances)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayOverdraft(t *testing.T) {
+	wallet, err := ledger.NewWallet(&ledger.CreateWalletRequest{Name: "test"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if err := wallet.SetOverdraft(10, 0); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if err := wallet.ProcessTransaction(&ledger.Transaction{Type: ledger.TransactionWithdraw, WalletID: wallet.ID, Amount: 10}); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	records, _, err := schema.Encode(wallet, wallet.Events())
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	// overdrawn within credit line
+	if err := replay(context.Background(), wallet.ID, &sliceIterator{records: records}, time.Time{}, nil); err != nil {
+		t.Errorf("got %v, want %v", err, nil)
+	}
+
+	// overdrawn beyond credit line
+	records[2].Data = []byte(`{"wallet_id":"` + wallet.ID + `","amount":11}`)
+	_, _ = schema.Seal("", records)
+
+	want := errors.New("negative balance")
+	if err := replay(context.Background(), wallet.ID, &sliceIterator{records: records}, time.Time{}, nil); err == nil || !containsError(err, want) {
+		t.Errorf("got %v, want %v", err, want)
+	}
+}
+
+// containsError reports whether err message contains target message.
+func containsError(err, target error) bool {
+	return strings.Contains(err.Error(), target.Error())
+}
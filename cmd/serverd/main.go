@@ -10,11 +10,21 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/deividaspetraitis/ledger"
 	"github.com/deividaspetraitis/ledger/config"
+	"github.com/deividaspetraitis/ledger/database/backend"
+	"github.com/deividaspetraitis/ledger/database/cache"
+	"github.com/deividaspetraitis/ledger/database/schema"
+	"github.com/deividaspetraitis/ledger/fee"
 	ihttp "github.com/deividaspetraitis/ledger/http"
+	"github.com/deividaspetraitis/ledger/index"
+	"github.com/deividaspetraitis/ledger/interest"
+	"github.com/deividaspetraitis/ledger/reconcile"
+	"github.com/deividaspetraitis/ledger/scheduler"
 
-	"github.com/deividaspetraitis/go/database/esdb"
+	"github.com/deividaspetraitis/go/database"
 	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
 	"github.com/deividaspetraitis/go/log"
 )
 
@@ -22,14 +32,18 @@ var shutdowntimeout = time.Duration(5) * time.Second
 
 // program flags
 var (
-	cfgPath    string
-	cpuprofile string
+	cfgPath     string
+	cpuprofile  string
+	checkConfig bool
+	overrides   config.Overrides
 )
 
 // initialise program state
 func init() {
-	flag.StringVar(&cfgPath, "config", os.Getenv("config"), "PATH to .env configuration file")
+	flag.StringVar(&cfgPath, "config", os.Getenv("config"), "PATH to env, YAML or TOML configuration file")
 	flag.StringVar(&cpuprofile, "cpuprofile", "", "write cpu profile to file")
+	flag.BoolVar(&checkConfig, "check-config", false, "print effective configuration and exit")
+	overrides = config.Flags(flag.CommandLine)
 }
 
 // main program entry point.
@@ -48,11 +62,22 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	cfg, err := config.New(cfgPath)
+	cfg, err := config.New(cfgPath, overrides)
 	if err != nil {
 		logger.WithError(err).Fatal("parsing configuration file")
 	}
 
+	if checkConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := setLogLevel(cfg.Log); err != nil {
+		logger.WithError(err).Fatal("parsing configuration file")
+	}
+
 	if err := run(ctx, cfg, logger); err != nil {
 		logger.WithError(err).Fatal("unable to start service")
 	}
@@ -68,63 +93,215 @@ func run(ctx context.Context, cfg *config.Config, logger log.Logger) error {
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
+	// Make a channel to listen for a hangup signal requesting configuration reload.
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
 	// =========================================================================
 	// Construct services
 
-	// connect to DB instance
-	esclient, err := esdb.NewClient(cfg.Database)
+	// connect to DB instance of the configured store backend
+	store, closeStore, err := backend.Open(ctx, cfg.Store, cfg.Database, cfg.Events)
 	if err != nil {
-		return errors.Wrap(err, "unable connect to database instance")
+		return errors.Wrap(err, "unable to construct store")
+	}
+
+	// sign exported wallet events hash chain heads
+	var signer *schema.Signer
+	if cfg.Events != nil && len(cfg.Events.SigningKey) > 0 {
+		if signer, err = schema.NewSigner(cfg.Events.SigningKey); err != nil {
+			return err
+		}
+	}
+
+	// index transactions of persisted wallets, transactions persisted later are indexed as they are saved
+	// and ones persisted by other writers once the index catches up
+	transactions := index.New(cfg.Index, store.RecordsAfter)
+	if err := transactions.Rebuild(ctx, store.IDs); err != nil {
+		return errors.Wrap(err, "unable to index transactions")
 	}
 
+	indexCtx, stopIndex := context.WithCancel(ctx)
+	defer stopIndex()
+	go transactions.Run(indexCtx, store.IDs)
+
+	// cache wallets written and read through the store
+	wallets := cache.New[*ledger.WalletAggregate](cfg.Cache)
+
+	save := transactions.Save(wallets.Save(store.Save))
+	get := wallets.Load(func(ctx context.Context, aggregate es.Aggregate, id string) (*ledger.WalletAggregate, error) {
+		return backend.Get[*ledger.WalletAggregate](ctx, store, aggregate, id)
+	})
+
+	// charge transactions fees according to the schedule
+	fees, err := fee.New(cfg.Fees)
+	if err != nil {
+		return errors.Wrap(err, "unable to construct fee schedule")
+	}
+	if err := checkHouse(ctx, get, fees); err != nil {
+		return err
+	}
+
+	// serialise transactions per wallet
+	processor := ledger.NewProcessor(cfg.Processor, save, get, fees)
+
+	// execute scheduled transactions through the processor, idempotency keys prevent executing an occurrence twice
+	schedules := scheduler.New(cfg.Scheduler, store.Save, func(ctx context.Context, aggregate es.Aggregate, id string) (*scheduler.ScheduleAggregate, error) {
+		return backend.Get[*scheduler.ScheduleAggregate](ctx, store, aggregate, id)
+	}, store.IDs, processor.CreateTransaction)
+
+	schedulerCtx, stopScheduler := context.WithCancel(ctx)
+	defer stopScheduler()
+	go schedules.Run(schedulerCtx)
+
+	// collect fees which were not collected right after being charged
+	collector := ledger.NewCollector(cfg.Collector, processor.Fees, get, store.IDs, processor.Execute)
+
+	collectorCtx, stopCollector := context.WithCancel(ctx)
+	defer stopCollector()
+	go collector.Run(collectorCtx)
+
+	// accrue overdraft interest through the processor, so it's serialised with wallet transactions
+	accrual := ledger.NewAccrual(cfg.Overdraft, store.Records, cfg.Events.Policy(), get, store.IDs, processor.Execute)
+
+	accrualCtx, stopAccrual := context.WithCancel(ctx)
+	defer stopAccrual()
+	go accrual.Run(accrualCtx)
+
+	// post savings interest monthly through the processor, accrual is reconstructed from persisted events
+	poster := interest.New(cfg.Interest, store.Records, cfg.Events.Policy(), get, store.IDs, processor.Execute)
+
+	posterCtx, stopPoster := context.WithCancel(ctx)
+	defer stopPoster()
+	go poster.Run(posterCtx)
+
+	// reconcile bank statements against persisted transactions
+	reconciler := reconcile.NewReconciler(cfg.Reconcile, reconcile.NewGetItemsFunc(store.Records), transactions.FindWallet)
+
 	// =========================================================================
 	// Start HTTP server
 
+	// rate limit requests, limits are reconfigured on reload
+	limiter := ihttp.NewLimiter(cfg.HTTP.Limits)
+
+	// restrict route groups to principals of client certificates
+	authorizer := ihttp.NewAuthorizer(&cfg.HTTP.TLS)
+
+	// toggle route groups, toggles are reconfigured on reload
+	toggles := ihttp.NewToggles(cfg.HTTP.Features)
+
 	api := http.Server{
-		Addr:    cfg.HTTP.Address,
-		Handler: ihttp.API(shutdown, cfg.HTTP, logger, esclient),
+		Addr: cfg.HTTP.Address,
+		Handler: ihttp.API(shutdown, limiter, authorizer, toggles, logger, save, get, processor, func(ctx context.Context, id string) (*schema.SignedHead, error) {
+			// wallet is restored from scratch bypassing the cache so the whole chain is verified.
+			var wallet ledger.WalletAggregate
+			if err := store.Load(ctx, &wallet, id); err != nil {
+				return nil, err
+			}
+			return signer.Sign(id, uint64(wallet.Root().Version()), wallet.ChainHead()), nil
+		}, reconciler, schedules, accrual, poster, store.Records, transactions),
+	}
+
+	// serve TLS, certificates are reloaded once their files change
+	if cfg.HTTP.TLS.Enabled() {
+		if api.TLSConfig, err = ihttp.NewTLSConfig(&cfg.HTTP.TLS, logger); err != nil {
+			return errors.Wrap(err, "unable to configure TLS")
+		}
 	}
 
 	go func() {
 		logger.Printf("http server listening on %s", cfg.HTTP.Address)
+		if api.TLSConfig != nil {
+			serverErrors <- api.ListenAndServeTLS("", "")
+			return
+		}
 		serverErrors <- api.ListenAndServe()
 	}()
 
+	// serve metrics on a separate listener, so they are not exposed to API clients
+	if len(cfg.HTTP.DebugAddress) > 0 {
+		debug := http.Server{
+			Addr:    cfg.HTTP.DebugAddress,
+			Handler: ihttp.Debug(),
+		}
+		defer debug.Close()
+
+		go func() {
+			logger.Printf("debug server listening on %s", cfg.HTTP.DebugAddress)
+			if err := debug.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.WithError(err).Error("debug server closed")
+			}
+		}()
+	}
+
 	// ========================================================================
 	// Shutdown
 
-	// Blocking main and waiting for shutdown.
-	select {
-	case err := <-serverErrors:
-		return errors.Wrap(err, "server error")
+	// Blocking main and waiting for shutdown, configuration is reloaded meanwhile.
+	for {
+		select {
+		case <-reload:
+			reloaded, err := reloadConfig(ctx, cfg, limiter, toggles, processor, get)
+			if err != nil {
+				logger.WithError(err).Error("configuration was not reloaded")
+				continue
+			}
+			cfg = reloaded
+			logger.Printf("configuration reloaded")
 
-	case sig := <-shutdown:
-		logger.Printf("http server start shutdown caused by %v", sig)
+		case err := <-serverErrors:
+			return errors.Wrap(err, "server error")
 
-		// Give outstanding requests a deadline for completion.
-		ctx, cancel := context.WithTimeout(ctx, shutdowntimeout)
-		defer cancel()
+		case sig := <-shutdown:
+			logger.Printf("http server start shutdown caused by %v", sig)
 
-		if err := esclient.Close(); err != nil {
-			logger.WithError(err).Error("graceful shutdown did not complete")
-		}
+			// Stop indexing, executing scheduled transactions, collecting fees, accruing and posting interest.
+			stopIndex()
+			stopScheduler()
+			stopCollector()
+			stopAccrual()
+			stopPoster()
 
-		// Asking listener to shutdown and load shed.
-		err := api.Shutdown(ctx)
-		if err != nil {
-			logger.WithError(err).Error("graceful shutdown did not complete")
-			api.Close()
-			esclient.Close()
-		}
+			// Give outstanding requests a deadline for completion.
+			ctx, cancel := context.WithTimeout(ctx, shutdowntimeout)
+			defer cancel()
 
-		// Log the status of this shutdown.
-		switch {
-		case sig == syscall.SIGSTOP:
-			return errors.New("integrity issue caused shutdown")
-		case err != nil:
-			return errors.Wrap(err, "could not stop server gracefully")
+			// Asking listener to shutdown and load shed.
+			err := api.Shutdown(ctx)
+			if err != nil {
+				logger.WithError(err).Error("graceful shutdown did not complete")
+				api.Close()
+			}
+
+			// Process transactions queued already, then release the store.
+			if err := processor.Close(ctx); err != nil {
+				logger.WithError(err).Error("transactions processor did not drain")
+			}
+			if err := closeStore(); err != nil {
+				logger.WithError(err).Error("graceful shutdown did not complete")
+			}
+
+			// Log the status of this shutdown.
+			switch {
+			case sig == syscall.SIGSTOP:
+				return errors.New("integrity issue caused shutdown")
+			case err != nil:
+				return errors.Wrap(err, "could not stop server gracefully")
+			}
+
+			return nil
 		}
 	}
+}
 
+// checkHouse verifies that house revenue wallet of the fees schedule exists, if fees are charged.
+func checkHouse(ctx context.Context, get database.GetAggregateFunc[*ledger.WalletAggregate], fees *fee.Schedule) error {
+	house := fees.House()
+	if len(house) == 0 {
+		return nil
+	}
+	if _, err := get(ctx, &ledger.WalletAggregate{}, house); err != nil {
+		return errors.Wrapf(err, "unable to retrieve house revenue wallet %s", house)
+	}
 	return nil
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/config"
+	"github.com/deividaspetraitis/ledger/fee"
+	ihttp "github.com/deividaspetraitis/ledger/http"
+
+	"github.com/deividaspetraitis/go/database"
+	"github.com/deividaspetraitis/go/log"
+
+	"github.com/sirupsen/logrus"
+)
+
+// reloadable holds prefixes of configuration keys applied on reload, changes of other keys require restart.
+var reloadable = []string{
+	"log_",
+	"http_limits_",
+	"http_features_",
+	"fees_currency",
+	"fees_schedules_",
+	"fees_rounding_",
+}
+
+// setLogLevel sets minimum level of logged entries.
+func setLogLevel(cfg *config.LogConfig) error {
+	level, err := logrus.ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	logrus.SetLevel(level)
+	return nil
+}
+
+// reloadConfig re-reads configuration and applies its reloadable parts: log level, rate limits, feature toggles and fees schedule.
+// Fees schedule is applied only if its house revenue wallet exists. House is kept as collected fees are recorded
+// only by the house wallet, thus a new house would collect them once again.
+// Either all reloadable parts are applied or, if new configuration is not valid, none of them.
+// Changes of other values are logged and ignored. Returned configuration is the one in effect.
+func reloadConfig(ctx context.Context, current *config.Config, limiter *ihttp.Limiter, toggles *ihttp.Toggles, processor *ledger.Processor, get database.GetAggregateFunc[*ledger.WalletAggregate]) (*config.Config, error) {
+	next, err := config.New(cfgPath, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	// construct everything to be swapped in beforehand, so nothing is applied if any of it fails
+	level, err := logrus.ParseLevel(next.Log.Level)
+	if err != nil {
+		return nil, err
+	}
+	feesCfg := *next.Fees
+	feesCfg.House = current.Fees.House
+	fees, err := fee.New(&feesCfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkHouse(ctx, get, fees); err != nil {
+		return nil, err
+	}
+
+	for _, key := range config.Changed(current, next) {
+		if !isReloadable(key) {
+			log.WithFields(log.Fields{"key": strings.ToUpper(key)}).Warn("configuration change requires restart, ignored")
+		}
+	}
+
+	applied := *current
+	applied.Log = next.Log
+	applied.Fees = &feesCfg
+
+	http := *current.HTTP
+	http.Limits = next.HTTP.Limits
+	http.Features = next.HTTP.Features
+	applied.HTTP = &http
+
+	logrus.SetLevel(level)
+	limiter.Reconfigure(next.HTTP.Limits)
+	toggles.Reconfigure(next.HTTP.Features)
+	processor.SetFees(fees)
+
+	return &applied, nil
+}
+
+// isReloadable reports whether change of configuration key is applied on reload.
+func isReloadable(key string) bool {
+	for _, prefix := range reloadable {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
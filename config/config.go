@@ -1,55 +1,364 @@
+// Package config implements application configuration.
+//
+// Configuration values are resolved in order of precedence: command line flags, environment variables,
+// files referenced by *_FILE environment variables, configuration file and defaults.
+// Configuration file may be env, YAML, TOML or JSON file, its format is determined by the file extension.
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/backend"
+	"github.com/deividaspetraitis/ledger/database/cache"
+	"github.com/deividaspetraitis/ledger/database/filelog"
+	"github.com/deividaspetraitis/ledger/database/schema"
+	"github.com/deividaspetraitis/ledger/database/sqlite"
+	"github.com/deividaspetraitis/ledger/fee"
 	"github.com/deividaspetraitis/ledger/http"
+	"github.com/deividaspetraitis/ledger/index"
+	"github.com/deividaspetraitis/ledger/interest"
+	"github.com/deividaspetraitis/ledger/reconcile"
+	"github.com/deividaspetraitis/ledger/scheduler"
 
 	"github.com/deividaspetraitis/go/database"
 	"github.com/deividaspetraitis/go/errors"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
 // ErrConfigNotFound represents an error returned when configuration file was not found.
 var ErrConfigNotFound = errors.New("config file were not found")
 
+// fileSuffix suffixes environment variables holding path to the file the value is read from, e.g. DB_PASSWORD_FILE.
+const fileSuffix = "_FILE"
+
 // Config represents application configuration.
 type Config struct {
-	HTTP     *http.Config     `mapstructure:"http"` // HTTP server config.
-	Database *database.Config `mapstructure:"db"`   // Database instance config.
+	HTTP      *http.Config            `mapstructure:"http"`      // HTTP server config.
+	Database  *database.Config        `mapstructure:"db"`        // Database instance config.
+	Store     *backend.Config         `mapstructure:"store"`     // Aggregates store backend config.
+	Processor *ledger.ProcessorConfig `mapstructure:"processor"` // Transactions processor config.
+	Cache     *cache.Config           `mapstructure:"cache"`     // Aggregates cache config.
+	Events    *schema.Config          `mapstructure:"events"`    // Persisted events decoding config.
+	Index     *index.Config           `mapstructure:"index"`     // Transactions index config.
+	Reconcile *reconcile.Config       `mapstructure:"reconcile"` // Bank statements reconciliation config.
+	Fees      *fee.Config             `mapstructure:"fees"`      // Transaction fees schedule config.
+	Collector *ledger.CollectorConfig `mapstructure:"collector"` // Pending fees collection config.
+	Scheduler *scheduler.Config       `mapstructure:"scheduler"` // Scheduled transactions config.
+	Overdraft *ledger.OverdraftConfig `mapstructure:"overdraft"` // Overdraft interest accrual config.
+	Interest  *interest.Config        `mapstructure:"interest"`  // Savings interest posting config.
+	Log       *LogConfig              `mapstructure:"log"`       // Logging config.
+}
+
+// LogConfig represents logging configuration.
+type LogConfig struct {
+	Level string `mapstructure:"level"` // Minimum level of logged entries: debug, info, warn or error
+}
+
+// Validate implements validator.Validator.
+func (c *LogConfig) Validate() error {
+	if _, err := logrus.ParseLevel(c.Level); err != nil {
+		return err
+	}
+	return nil
 }
 
-// New accepts constructs a new Config by reading env configuration file.
-func New(path string) (*Config, error) {
+// Default returns configuration holding default values, values not configured otherwise fall back to them.
+func Default() *Config {
+	return &Config{
+		HTTP: &http.Config{
+			Address:      ":8000",
+			DebugAddress: "localhost:4000",
+			Limits:       http.LimitsConfig{}, // rate limits and load shedding are opt-in
+			TLS: http.TLSConfig{
+				MinVersion: http.TLS12,
+				Ciphers:    http.CiphersModern,
+				Reload:     time.Minute,
+			},
+		},
+		Database: &database.Config{
+			Host:     "localhost",
+			Port:     2113,
+			Username: "admin",
+		},
+		Store: &backend.Config{
+			Backend: backend.ESDB,
+			SQLite:  &sqlite.Config{Path: "ledger.db"},
+			FileLog: &filelog.Config{Path: "data", SegmentSize: 64 << 20, BatchSize: 128},
+		},
+		Processor: &ledger.ProcessorConfig{MailboxSize: 128, BatchSize: 32, IdleTimeout: time.Minute, Timeout: 10 * time.Second},
+		Cache:     &cache.Config{}, // cache is opt-in
+		Events:    &schema.Config{Unknown: schema.PolicyFail},
+		Index:     &index.Config{Interval: time.Minute},
+		Reconcile: &reconcile.Config{Tolerance: reconcile.DefaultTolerance},
+		Fees:      &fee.Config{Currency: fee.DefaultCurrency},
+		Collector: &ledger.CollectorConfig{Interval: time.Minute},
+		Scheduler: &scheduler.Config{Interval: time.Minute, Discover: 10 * time.Minute, Retry: time.Hour, Attempts: 3},
+		Overdraft: &ledger.OverdraftConfig{Interval: time.Hour},
+		Interest:  &interest.Config{Interval: time.Hour},
+		Log:       &LogConfig{Level: "info"},
+	}
+}
+
+// New constructs a new Config reading configuration file at path, if path is empty only environment is read.
+// Values of overrides take precedence over any other source, see Flags.
+// Returned configuration is validated.
+func New(path string, overrides Overrides) (*Config, error) {
 	parser := viper.NewWithOptions(
 		viper.KeyDelimiter("_"), // DATABASE_HOST instead of DATABASE.HOST in config file
 		viper.EnvKeyReplacer(strings.NewReplacer(".", "_")),
 	)
 
-	// Set config file
-	parser.SetConfigFile(path)
-
-	// Set config type to env files
-	parser.SetConfigType("env")
+	// Register every key, so it's read from environment even if config file does not set it
+	for _, v := range values(Default()) {
+		parser.SetDefault(v.key, v.value)
+	}
 
 	// Check and load environment variables
 	parser.AutomaticEnv()
 
+	// Keys of map values are not known upfront, so their variables are bound as they are found in environment,
+	// e.g. FEES_SCHEDULES_WITHDRAW_STANDARD_BPS. Map keys given by environment can not contain "_".
+	prefixes := mapPrefixes(nil, "", reflect.TypeOf(Config{}))
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		key := strings.ToLower(strings.TrimSuffix(name, fileSuffix))
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) && len(key) > len(prefix) {
+				if err := parser.BindEnv(key); err != nil {
+					return nil, errors.Wrapf(err, "unable to bind %s", name)
+				}
+			}
+		}
+	}
+
 	// Read configuration values
-	if err := parser.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			return nil, errors.Wrapf(err, "unable to read config reading config: %s", path)
-		} else {
+	if len(path) > 0 {
+		parser.SetConfigFile(path)
+		parser.SetConfigType(format(path))
+
+		if err := parser.ReadInConfig(); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, errors.Wrapf(ErrConfigNotFound, "unable to read config: %s", path)
+			}
 			return nil, errors.Wrapf(err, "failed reading config: %s", path)
 		}
 	}
 
+	// Read values of variables having *_FILE counterpart, e.g. secrets mounted into container
+	for _, key := range parser.AllKeys() {
+		env := strings.ToUpper(key)
+
+		name, ok := os.LookupEnv(env + fileSuffix)
+		if !ok {
+			continue
+		}
+		if _, ok := os.LookupEnv(env); ok {
+			return nil, errors.Newf("only one of %s and %s can be set", env, env+fileSuffix)
+		}
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read %s", env+fileSuffix)
+		}
+		parser.Set(key, strings.TrimRight(string(data), "\r\n"))
+	}
+
+	for key, value := range overrides {
+		parser.Set(key, value)
+	}
+
 	// Populate configuration
 	var cfg Config
 	if err := parser.Unmarshal(&cfg); err != nil {
 		return nil, errors.Wrapf(err, "failed to unmarshal config: %s", path)
 	}
 
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
+
+// format returns configuration file format by its extension, files having no known extension are env files.
+func format(path string) string {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")); ext {
+	case "yaml", "yml", "toml", "json":
+		return ext
+	default:
+		return "env"
+	}
+}
+
+// Validate implements validator.Validator.
+// Validate reports problems of all configuration sections at once, each prefixed by environment variables prefix of the section.
+func (c *Config) Validate() error {
+	var problems []string
+	report := func(section string, err error) {
+		if err != nil {
+			problems = append(problems, section+": "+err.Error())
+		}
+	}
+
+	if c.HTTP == nil {
+		report("HTTP", errors.New("address is required"))
+	} else {
+		report("HTTP", c.HTTP.Validate())
+	}
+	if c.Store == nil || c.Store.Backend == "" || c.Store.Backend == backend.ESDB {
+		report("DB", validateDatabase(c.Database))
+	}
+	if c.Store != nil {
+		report("STORE", c.Store.Validate())
+	}
+	if c.Processor != nil {
+		report("PROCESSOR", c.Processor.Validate())
+	}
+	if c.Cache != nil {
+		report("CACHE", c.Cache.Validate())
+	}
+	if c.Events != nil {
+		report("EVENTS", c.Events.Validate())
+	}
+	if c.Index != nil {
+		report("INDEX", c.Index.Validate())
+	}
+	if c.Reconcile != nil {
+		report("RECONCILE", c.Reconcile.Validate())
+	}
+	if c.Fees != nil {
+		report("FEES", c.Fees.Validate())
+	}
+	if c.Collector != nil {
+		report("COLLECTOR", c.Collector.Validate())
+	}
+	if c.Scheduler != nil {
+		report("SCHEDULER", c.Scheduler.Validate())
+	}
+	if c.Overdraft != nil {
+		report("OVERDRAFT", c.Overdraft.Validate())
+	}
+	if c.Interest != nil {
+		report("INTEREST", c.Interest.Validate())
+	}
+	if c.Log != nil {
+		report("LOG", c.Log.Validate())
+	}
+
+	if len(problems) > 0 {
+		return errors.Newf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateDatabase validates EventStoreDB instance configuration.
+func validateDatabase(c *database.Config) error {
+	if c == nil || len(c.Host) == 0 {
+		return errors.New("host is required")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return errors.Newf("port %d is not valid", c.Port)
+	}
+	return nil
+}
+
+// Changed returns keys of values which differ between configurations a and b, e.g. http_address.
+func Changed(a, b *Config) []string {
+	previous := make(map[string]any)
+	for _, v := range values(a) {
+		previous[v.key] = v.value
+	}
+
+	var keys []string
+	for _, v := range values(b) {
+		if value, ok := previous[v.key]; !ok || !reflect.DeepEqual(value, v.value) {
+			keys = append(keys, v.key)
+		}
+		delete(previous, v.key)
+	}
+
+	// values removed from a
+	for _, v := range values(a) {
+		if _, ok := previous[v.key]; ok {
+			keys = append(keys, v.key)
+		}
+	}
+	return keys
+}
+
+// value represents configuration value addressed by its key, e.g. http_limits_client_rate.
+type value struct {
+	key   string
+	value any
+}
+
+// values returns configuration values of cfg in order they are declared, maps are ordered by keys.
+func values(cfg *Config) []value {
+	return appendValues(nil, "", reflect.ValueOf(cfg))
+}
+
+// mapPrefixes appends key prefixes of map values of type t prefixed with prefix, e.g. fees_schedules_.
+func mapPrefixes(prefixes []string, prefix string, t reflect.Type) []string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return mapPrefixes(prefixes, prefix, t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := field.Tag.Get("mapstructure")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if len(name) == 0 {
+				name = strings.ToLower(field.Name)
+			}
+			prefixes = mapPrefixes(prefixes, prefix+name+"_", field.Type)
+		}
+		return prefixes
+	case reflect.Map:
+		return append(prefixes, prefix)
+	default:
+		return prefixes
+	}
+}
+
+// appendValues appends values of v addressed by keys prefixed with prefix, nil pointers are skipped.
+func appendValues(values []value, prefix string, v reflect.Value) []value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return values
+		}
+		return appendValues(values, prefix, v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name := field.Tag.Get("mapstructure")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if len(name) == 0 {
+				name = strings.ToLower(field.Name)
+			}
+			values = appendValues(values, prefix+name+"_", v.Field(i))
+		}
+		return values
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			values = appendValues(values, prefix+strings.ToLower(k.String())+"_", v.MapIndex(k))
+		}
+		return values
+	default:
+		return append(values, value{key: strings.TrimSuffix(prefix, "_"), value: v.Interface()})
+	}
+}
@@ -0,0 +1,308 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/fee"
+
+	"github.com/deividaspetraitis/go/errors"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// writeFile writes data into the file of the given name within test temporary directory and returns its path.
+func writeFile(t *testing.T, name, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return path
+}
+
+func TestNewFormats(t *testing.T) {
+	want := Default()
+	want.HTTP.Address = ":9000"
+	want.Database.Password = "secret"
+	want.Cache.TTL = time.Minute
+	want.Fees.House = "a18c247b-8c28-468f-97a8-0bf33a48b922"
+	want.Fees.Schedules = map[string]map[string]*fee.Rule{"withdraw": {"standard": {BasisPoints: 150, Fixed: 25}}}
+
+	var testcases = []struct {
+		name string
+		data string
+	}{
+		{"ledger.env", `
+HTTP_ADDRESS=:9000
+DB_PASSWORD=secret
+CACHE_TTL=1m
+FEES_HOUSE=a18c247b-8c28-468f-97a8-0bf33a48b922
+FEES_SCHEDULES_WITHDRAW_STANDARD_BPS=150
+FEES_SCHEDULES_WITHDRAW_STANDARD_FIXED=25
+`},
+		{".env", `
+HTTP_ADDRESS=:9000
+DB_PASSWORD=secret
+CACHE_TTL=1m
+FEES_HOUSE=a18c247b-8c28-468f-97a8-0bf33a48b922
+FEES_SCHEDULES_WITHDRAW_STANDARD_BPS=150
+FEES_SCHEDULES_WITHDRAW_STANDARD_FIXED=25
+`},
+		{"ledger.yaml", `
+http:
+  address: ":9000"
+db:
+  password: secret
+cache:
+  ttl: 1m
+fees:
+  house: a18c247b-8c28-468f-97a8-0bf33a48b922
+  schedules:
+    withdraw:
+      standard:
+        bps: 150
+        fixed: 25
+`},
+		{"ledger.toml", `
+[http]
+address = ":9000"
+
+[db]
+password = "secret"
+
+[cache]
+ttl = "1m"
+
+[fees]
+house = "a18c247b-8c28-468f-97a8-0bf33a48b922"
+
+[fees.schedules.withdraw.standard]
+bps = 150
+fixed = 25
+`},
+	}
+
+	for i, tt := range testcases {
+		cfg, err := New(writeFile(t, tt.name, tt.data), nil)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if !cmp.Equal(cfg, want) {
+			t.Errorf("#%d %s", i, cmp.Diff(want, cfg))
+		}
+	}
+}
+
+func TestNewPrecedence(t *testing.T) {
+	path := writeFile(t, "ledger.env", "HTTP_ADDRESS=:9000\nCACHE_SIZE=10\nCACHE_TTL=1m\n")
+
+	// environment overrides file, flags override environment
+	t.Setenv("CACHE_SIZE", "20")
+	t.Setenv("CACHE_TTL", "2m")
+	t.Setenv("PROCESSOR_BATCH", "64")
+	t.Setenv("HTTP_LIMITS_TRUSTEDPROXIES", "10.0.0.0/8,127.0.0.1")
+	t.Setenv("DB_PASSWORD_FILE", writeFile(t, "password", "secret\n"))
+
+	cfg, err := New(path, Overrides{"cache_ttl": "3m"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	var testcases = []struct {
+		got  any
+		want any
+	}{
+		{cfg.HTTP.Address, ":9000"},
+		{cfg.Cache.Size, 20},
+		{cfg.Cache.TTL, 3 * time.Minute},
+		{cfg.Processor.BatchSize, 64},
+		{cfg.Processor.MailboxSize, 128},
+		{cfg.Database.Password, "secret"},
+		{strings.Join(cfg.HTTP.Limits.TrustedProxies, " "), "10.0.0.0/8 127.0.0.1"},
+	}
+	for i, tt := range testcases {
+		if tt.got != tt.want {
+			t.Errorf("#%d got %v, want %v", i, tt.got, tt.want)
+		}
+	}
+
+	// value can not be given twice
+	t.Setenv("DB_PASSWORD", "secret")
+	if _, err := New(path, nil); err == nil {
+		t.Errorf("got %v, want error", err)
+	}
+}
+
+func TestNewDefaults(t *testing.T) {
+	cfg, err := New("", nil)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	// rate limits, load shedding and cache are disabled unless configured
+	var testcases = []struct {
+		got  any
+		want any
+	}{
+		{cfg.HTTP.Limits.Client.Rate, 0.0},
+		{cfg.HTTP.Limits.Wallet.Rate, 0.0},
+		{cfg.HTTP.Limits.InFlight, 0},
+		{cfg.Cache.Size, 0},
+	}
+	for i, tt := range testcases {
+		if tt.got != tt.want {
+			t.Errorf("#%d got %v, want %v", i, tt.got, tt.want)
+		}
+	}
+}
+
+func TestNewEnvironmentMaps(t *testing.T) {
+	t.Setenv("FEES_HOUSE", "a18c247b-8c28-468f-97a8-0bf33a48b922")
+	t.Setenv("FEES_SCHEDULES_WITHDRAW_STANDARD_BPS", "150")
+	t.Setenv("FEES_SCHEDULES_WITHDRAW_STANDARD_FIXED_FILE", writeFile(t, "fixed", "25\n"))
+	t.Setenv("FEES_SCHEDULES_DEPOSIT_PREMIUM_FIXED", "10")
+	t.Setenv("FEES_ROUNDING_CHF_MODE", "up")
+	t.Setenv("FEES_ROUNDING_CHF_INCREMENT", "5")
+
+	cfg, err := New("", nil)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	want := &fee.Config{
+		Currency: fee.DefaultCurrency,
+		House:    "a18c247b-8c28-468f-97a8-0bf33a48b922",
+		Schedules: map[string]map[string]*fee.Rule{
+			"withdraw": {"standard": {BasisPoints: 150, Fixed: 25}},
+			"deposit":  {"premium": {Fixed: 10}},
+		},
+		Rounding: map[string]*fee.Rounding{"chf": {Mode: fee.ModeUp, Increment: 5}},
+	}
+	if !cmp.Equal(cfg.Fees, want) {
+		t.Errorf("%s", cmp.Diff(want, cfg.Fees))
+	}
+}
+
+func TestNewEnvironmentAccess(t *testing.T) {
+	t.Setenv("HTTP_TLS_CERT", "cert.pem")
+	t.Setenv("HTTP_TLS_KEY", "key.pem")
+	t.Setenv("HTTP_TLS_CLIENTCA", "ca.pem")
+	t.Setenv("HTTP_TLS_ACCESS_ADMIN", "ops,treasury")
+
+	cfg, err := New("", nil)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	want := map[string][]string{"admin": {"ops", "treasury"}}
+	if !cmp.Equal(cfg.HTTP.TLS.Access, want) {
+		t.Errorf("%s", cmp.Diff(want, cfg.HTTP.TLS.Access))
+	}
+}
+
+func TestNewEnvironmentFeatures(t *testing.T) {
+	t.Setenv("HTTP_FEATURES_SCHEDULES", "false")
+	t.Setenv("HTTP_FEATURES_RECONCILIATIONS", "true")
+
+	cfg, err := New("", nil)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	want := map[string]bool{"schedules": false, "reconciliations": true}
+	if !cmp.Equal(cfg.HTTP.Features, want) {
+		t.Errorf("%s", cmp.Diff(want, cfg.HTTP.Features))
+	}
+}
+
+func TestNewNotFound(t *testing.T) {
+	if _, err := New(filepath.Join(t.TempDir(), ".env"), nil); !errors.Is(err, ErrConfigNotFound) {
+		t.Errorf("got %v, want %v", err, ErrConfigNotFound)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var testcases = []struct {
+		overrides Overrides
+		problems  []string // sections reported
+	}{
+		{nil, nil},
+		{Overrides{"http_address": ""}, []string{"HTTP"}},
+		{Overrides{"http_address": "8000"}, []string{"HTTP"}},
+		{Overrides{"http_debugaddress": "4000"}, []string{"HTTP"}},
+		{Overrides{"http_debugaddress": ""}, nil},
+		{Overrides{"http_limits_client_rate": "-1", "cache_size": "-1"}, []string{"HTTP", "CACHE"}},
+		{Overrides{"db_host": ""}, []string{"DB"}},
+		{Overrides{"db_host": "", "store_backend": "sqlite"}, nil},
+		{Overrides{"store_backend": "sqlite", "store_sqlite_path": ""}, []string{"STORE"}},
+		{Overrides{"store_backend": "mysql"}, []string{"STORE"}},
+		{Overrides{"events_unknown": "ignore"}, []string{"EVENTS"}},
+		{Overrides{"fees_schedules_deposit_standard_bps": "10"}, []string{"FEES"}},
+		{Overrides{"log_level": "verbose"}, []string{"LOG"}},
+		{Overrides{"scheduler_attempts": "-1", "overdraft_interval": "-1h", "interest_interval": "-1h"}, []string{"SCHEDULER", "OVERDRAFT", "INTEREST"}},
+	}
+
+	for i, tt := range testcases {
+		_, err := New("", tt.overrides)
+		if (err != nil) != (len(tt.problems) > 0) {
+			t.Fatalf("#%d got %v, want problems %v", i, err, tt.problems)
+		}
+		for _, section := range tt.problems {
+			if !strings.Contains(err.Error(), section+": ") {
+				t.Errorf("#%d got %v, want %v problem", i, err, section)
+			}
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Password = "secret"
+
+	var buf bytes.Buffer
+	if err := cfg.Write(&buf); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("got %v, want password redacted", buf.String())
+	}
+
+	// written configuration is read back
+	restored, err := New(writeFile(t, "ledger.env", buf.String()), Overrides{"db_password": "secret"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if !cmp.Equal(restored, cfg, cmpopts.EquateEmpty()) {
+		t.Errorf("%s", cmp.Diff(cfg, restored, cmpopts.EquateEmpty()))
+	}
+}
+
+func TestChanged(t *testing.T) {
+	var testcases = []struct {
+		change func(cfg *Config)
+		keys   []string
+	}{
+		{func(cfg *Config) {}, nil},
+		{func(cfg *Config) { cfg.HTTP.Address = ":9000"; cfg.Log.Level = "debug" }, []string{"http_address", "log_level"}},
+		{func(cfg *Config) { cfg.Cache.TTL = time.Second }, []string{"cache_ttl"}},
+		{func(cfg *Config) {
+			cfg.Fees.Schedules = map[string]map[string]*fee.Rule{"withdraw": {"standard": {BasisPoints: 150}}}
+		}, []string{"fees_schedules_withdraw_standard_bps", "fees_schedules_withdraw_standard_fixed", "fees_schedules_withdraw_standard_min", "fees_schedules_withdraw_standard_max"}},
+		{func(cfg *Config) { cfg.Fees = nil }, []string{"fees_currency", "fees_house"}},
+	}
+
+	for i, tt := range testcases {
+		cfg := Default()
+		tt.change(cfg)
+
+		if keys := Changed(Default(), cfg); !cmp.Equal(keys, tt.keys) {
+			t.Errorf("#%d got %v, want %v", i, keys, tt.keys)
+		}
+	}
+}
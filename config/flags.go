@@ -0,0 +1,56 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// redacted replaces values of secrets written out.
+const redacted = "[redacted]"
+
+// secrets holds keys of values which are never written out.
+var secrets = map[string]bool{
+	"db_password": true,
+}
+
+// Overrides holds configuration values by their keys, e.g. http_address, overriding any other configuration source.
+type Overrides map[string]string
+
+// Flags defines a flag for every configuration value on fs, e.g. -http-address overriding HTTP_ADDRESS.
+// Returned overrides are populated with values of flags set once fs is parsed.
+func Flags(fs *flag.FlagSet) Overrides {
+	overrides := make(Overrides)
+	for _, v := range values(Default()) {
+		key := v.key
+
+		usage := "overrides " + strings.ToUpper(key)
+		if def := fmt.Sprint(v.value); len(def) > 0 && def != "0" {
+			usage += " (default " + def + ")"
+		}
+
+		fs.Func(strings.ReplaceAll(key, "_", "-"), usage, func(value string) error {
+			overrides[key] = value
+			return nil
+		})
+	}
+	return overrides
+}
+
+// Write writes configuration to w in env file format, values of secrets are redacted.
+func (c *Config) Write(w io.Writer) error {
+	for _, v := range values(c) {
+		value := fmt.Sprint(v.value)
+		if list, ok := v.value.([]string); ok {
+			value = strings.Join(list, ",")
+		}
+		if secrets[v.key] && len(value) > 0 {
+			value = redacted
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", strings.ToUpper(v.key), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+// Package archive implements portable archives of persisted aggregates events.
+//
+// Archive is a sequence of persisted records grouped by aggregate stream and ordered by version,
+// written either as newline-delimited JSON or CSV. Records are archived as they are persisted,
+// including hash chain metadata, so archive imported into any store restores aggregates verbatim.
+package archive
+
+import (
+	"context"
+
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// Source is implemented by stores aggregates can be exported from.
+type Source interface {
+	// IDs returns IDs of all persisted aggregates of the aggregate type.
+	IDs(ctx context.Context, aggregate es.Aggregate) ([]string, error)
+
+	// Records returns iterator over persisted records of the aggregate.
+	Records(ctx context.Context, aggregate es.Aggregate, id string) (schema.Iterator, error)
+}
+
+// Sink is implemented by stores aggregates can be imported into.
+type Sink interface {
+	// Append persists records of a single aggregate preserving their versions.
+	Append(ctx context.Context, records []*schema.Record) error
+}
+
+// ErrNotValidArchive is returned when archive records are not grouped by aggregate or their versions are not contiguous.
+var ErrNotValidArchive = errors.New("given archive is not a valid archive")
+
+// Export writes records of all aggregates of the given aggregate types persisted in the source,
+// aggregates are written type after type in the given order.
+// It returns number of exported aggregates of every type in the same order.
+func Export(ctx context.Context, src Source, w Writer, aggregates ...es.Aggregate) ([]int, error) {
+	var exported []int
+	for _, aggregate := range aggregates {
+		ids, err := src.IDs(ctx, aggregate)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			if err := export(ctx, src, aggregate, id, w); err != nil {
+				return nil, errors.Wrapf(err, "unable to export %s %s", es.ParseAggregateName(aggregate), id)
+			}
+		}
+		exported = append(exported, len(ids))
+	}
+
+	return exported, w.Flush()
+}
+
+// export writes records of a single aggregate.
+func export(ctx context.Context, src Source, aggregate es.Aggregate, id string, w Writer) error {
+	it, err := src.Records(ctx, aggregate, id)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		record, err := it.Value()
+		if err != nil {
+			return err
+		}
+
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	return it.Error()
+}
+
+// VisitFunc is called with records of every imported aggregate.
+type VisitFunc func(ctx context.Context, records []*schema.Record) error
+
+// Import appends records read from the iterator into the sink one aggregate at a time.
+// visit is called after every aggregate is appended, e.g. to verify imported aggregate.
+func Import(ctx context.Context, it schema.Iterator, dst Sink, visit VisitFunc) (int, error) {
+	defer it.Close()
+
+	var (
+		records  []*schema.Record
+		imported = make(map[string]struct{})
+	)
+
+	// flush appends buffered records of a single aggregate.
+	flush := func() error {
+		if len(records) == 0 {
+			return nil
+		}
+
+		if err := dst.Append(ctx, records); err != nil {
+			return errors.Wrapf(err, "unable to import %s", records[0].AggregateID)
+		}
+
+		if visit != nil {
+			if err := visit(ctx, records); err != nil {
+				return err
+			}
+		}
+
+		imported[records[0].Aggregate+"_"+records[0].AggregateID] = struct{}{}
+		records = nil
+		return nil
+	}
+
+	for it.Next() {
+		select {
+		case <-ctx.Done():
+			return len(imported), ctx.Err()
+		default:
+		}
+
+		record, err := it.Value()
+		if err != nil {
+			return len(imported), err
+		}
+
+		if len(records) > 0 {
+			last := records[len(records)-1]
+			if last.Aggregate != record.Aggregate || last.AggregateID != record.AggregateID {
+				if err := flush(); err != nil {
+					return len(imported), err
+				}
+			}
+		}
+
+		if err := next(records, imported, record); err != nil {
+			return len(imported), err
+		}
+
+		records = append(records, record)
+	}
+
+	if err := it.Error(); err != nil {
+		return len(imported), err
+	}
+
+	err := flush()
+	return len(imported), err
+}
+
+// next verifies that record follows the buffered records of the aggregate.
+func next(records []*schema.Record, imported map[string]struct{}, record *schema.Record) error {
+	if len(records) == 0 {
+		if _, ok := imported[record.Aggregate+"_"+record.AggregateID]; ok {
+			return errors.Wrapf(ErrNotValidArchive, "records of %s are not grouped", record.AggregateID)
+		}
+		if record.Version != 1 {
+			return errors.Wrapf(ErrNotValidArchive, "%s starts at version %d", record.AggregateID, record.Version)
+		}
+		return nil
+	}
+
+	if last := records[len(records)-1]; last.Version+1 != record.Version {
+		return errors.Wrapf(ErrNotValidArchive, "%s version %d follows version %d", record.AggregateID, record.Version, last.Version)
+	}
+	return nil
+}
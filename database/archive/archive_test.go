@@ -0,0 +1,223 @@
+package archive_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/archive"
+	"github.com/deividaspetraitis/ledger/database/schema"
+	"github.com/deividaspetraitis/ledger/scheduler"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+
+	"github.com/google/uuid"
+)
+
+// memStore implements archive.Source and archive.Sink keeping records in memory.
+type memStore struct {
+	ids     map[string][]string // IDs by aggregate type
+	records map[string][]*schema.Record
+}
+
+func newMemStore() *memStore {
+	return &memStore{ids: make(map[string][]string), records: make(map[string][]*schema.Record)}
+}
+
+func (s *memStore) IDs(ctx context.Context, aggregate es.Aggregate) ([]string, error) {
+	return s.ids[es.ParseAggregateName(aggregate)], nil
+}
+
+func (s *memStore) Records(ctx context.Context, aggregate es.Aggregate, id string) (schema.Iterator, error) {
+	return &sliceIterator{records: s.records[id]}, nil
+}
+
+func (s *memStore) Append(ctx context.Context, records []*schema.Record) error {
+	id := records[0].AggregateID
+	if _, ok := s.records[id]; ok {
+		return errors.Newf("%s already exists", id)
+	}
+	s.ids[records[0].Aggregate] = append(s.ids[records[0].Aggregate], id)
+	s.records[id] = records
+	return nil
+}
+
+// sliceIterator implements schema.Iterator over slice of records.
+type sliceIterator struct {
+	records []*schema.Record
+	i       int
+}
+
+func (s *sliceIterator) Next() bool {
+	s.i++
+	return s.i <= len(s.records)
+}
+
+func (s *sliceIterator) Value() (*schema.Record, error) {
+	return s.records[s.i-1], nil
+}
+
+func (s *sliceIterator) Error() error { return nil }
+
+func (s *sliceIterator) Close() {}
+
+// walletRecords returns sealed records of a new wallet with given transactions amounts applied.
+func walletRecords(t *testing.T, amounts ...int) []*schema.Record {
+	wallet, err := ledger.NewWallet(&ledger.CreateWalletRequest{Name: "test"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	for _, amount := range amounts {
+		tx := &ledger.Transaction{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: amount}
+		if amount < 0 {
+			tx.Type, tx.Amount = ledger.TransactionWithdraw, -amount
+		}
+		if err := wallet.ProcessTransaction(tx); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+	}
+
+	records, _, err := schema.Encode(wallet, wallet.Events())
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return records
+}
+
+// scheduleRecords returns records of a new paused schedule.
+func scheduleRecords(t *testing.T) []*schema.Record {
+	now := time.Now()
+	schedule, err := scheduler.NewSchedule(&scheduler.CreateScheduleRequest{WalletID: uuid.NewString(), Type: ledger.TransactionDeposit, Amount: 10, Cron: "@daily"}, now)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if err := schedule.Pause(now); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	records, _, err := schema.Encode(schedule, schedule.Events())
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return records
+}
+
+// fixtureStore returns store holding two wallets and a schedule.
+func fixtureStore(t *testing.T) *memStore {
+	store := newMemStore()
+	for _, records := range [][]*schema.Record{walletRecords(t, 100, -25), walletRecords(t, 5), scheduleRecords(t)} {
+		if err := store.Append(context.Background(), records); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+	}
+	return store
+}
+
+func TestExportImport(t *testing.T) {
+	for _, format := range []archive.Format{archive.FormatNDJSON, archive.FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			src := fixtureStore(t)
+
+			var buf bytes.Buffer
+			w, err := archive.NewWriter(&buf, format)
+			if err != nil {
+				t.Fatalf("got %v, want %v", err, nil)
+			}
+
+			exported, err := archive.Export(context.Background(), src, w, &ledger.WalletAggregate{}, &scheduler.ScheduleAggregate{})
+			if err != nil {
+				t.Fatalf("got %v, want %v", err, nil)
+			}
+			if want := []int{2, 1}; !reflect.DeepEqual(exported, want) {
+				t.Errorf("exported got %v, want %v", exported, want)
+			}
+			ids := append(src.ids["WalletAggregate"], src.ids["ScheduleAggregate"]...)
+
+			r, err := archive.NewReader(&buf, format)
+			if err != nil {
+				t.Fatalf("got %v, want %v", err, nil)
+			}
+
+			var visited []string
+			dst := newMemStore()
+			n, err := archive.Import(context.Background(), r, dst, func(ctx context.Context, records []*schema.Record) error {
+				visited = append(visited, records[0].AggregateID)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("got %v, want %v", err, nil)
+			}
+			if n != len(ids) {
+				t.Errorf("imported got %v, want %v", n, len(ids))
+			}
+			if !reflect.DeepEqual(visited, ids) {
+				t.Errorf("visited got %v, want %v", visited, ids)
+			}
+
+			for _, id := range ids {
+				want, got := src.records[id], dst.records[id]
+				if len(got) != len(want) {
+					t.Fatalf("got %v records, want %v", len(got), len(want))
+				}
+				for i := range want {
+					if !got[i].Timestamp.Equal(want[i].Timestamp) {
+						t.Errorf("#%d timestamp got %v, want %v", i, got[i].Timestamp, want[i].Timestamp)
+					}
+					got[i].Timestamp = want[i].Timestamp
+					if !reflect.DeepEqual(got[i], want[i]) {
+						t.Errorf("#%d got %+v, want %+v", i, got[i], want[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestImportNotValidArchive(t *testing.T) {
+	var testcases = []struct {
+		name   string
+		tamper func(a, b []*schema.Record) []*schema.Record
+		err    error
+	}{
+		{
+			name: "valid",
+			tamper: func(a, b []*schema.Record) []*schema.Record {
+				return []*schema.Record{a[0], a[1], a[2], b[0], b[1]}
+			},
+		},
+		{
+			name: "ungrouped",
+			tamper: func(a, b []*schema.Record) []*schema.Record {
+				return []*schema.Record{a[0], b[0], b[1], a[1], a[2]}
+			},
+			err: archive.ErrNotValidArchive,
+		},
+		{
+			name: "missing version",
+			tamper: func(a, b []*schema.Record) []*schema.Record {
+				return []*schema.Record{a[0], a[2], b[0], b[1]}
+			},
+			err: archive.ErrNotValidArchive,
+		},
+		{
+			name: "missing beginning",
+			tamper: func(a, b []*schema.Record) []*schema.Record {
+				return []*schema.Record{a[1], a[2], b[0], b[1]}
+			},
+			err: archive.ErrNotValidArchive,
+		},
+	}
+
+	for i, tt := range testcases {
+		records := tt.tamper(walletRecords(t, 1, 2), walletRecords(t, 3))
+
+		_, err := archive.Import(context.Background(), &sliceIterator{records: records}, newMemStore(), nil)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("#%d got %v, want %v", i, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// Format represents archive format.
+type Format string
+
+// Supported archive formats.
+const (
+	FormatNDJSON Format = "ndjson" // newline-delimited JSON, one record per line
+	FormatCSV    Format = "csv"    // CSV with a header row, payloads are kept as JSON text
+)
+
+// ErrNotValidFormat is returned when archive format is not supported.
+var ErrNotValidFormat = errors.New("given archive format is not supported")
+
+// Validate implements validator.Validator.
+func (f Format) Validate() error {
+	switch f {
+	case FormatNDJSON, FormatCSV:
+		return nil
+	default:
+		return ErrNotValidFormat
+	}
+}
+
+// header lists CSV archive columns.
+var header = []string{"aggregate", "aggregate_id", "version", "type", "timestamp", "data", "metadata"}
+
+// entry represents archived record.
+type entry struct {
+	Aggregate   string          `json:"aggregate"`
+	AggregateID string          `json:"aggregate_id"`
+	Version     uint64          `json:"version"`
+	Type        string          `json:"type"`
+	Timestamp   time.Time       `json:"timestamp"`
+	Data        json.RawMessage `json:"data"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+}
+
+// Writer writes records into archive.
+type Writer interface {
+	// Write writes a single record.
+	Write(record *schema.Record) error
+
+	// Flush writes any buffered data into underlying writer.
+	Flush() error
+}
+
+// NewWriter constructs a new Writer writing archive of given format into w.
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case FormatNDJSON:
+		buf := bufio.NewWriter(w)
+		return &ndjsonWriter{buf: buf, enc: json.NewEncoder(buf)}, nil
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	default:
+		return nil, ErrNotValidFormat
+	}
+}
+
+// ndjsonWriter implements Writer writing newline-delimited JSON.
+type ndjsonWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// Write implements Writer.
+func (w *ndjsonWriter) Write(record *schema.Record) error {
+	return w.enc.Encode(&entry{
+		Aggregate:   record.Aggregate,
+		AggregateID: record.AggregateID,
+		Version:     record.Version,
+		Type:        record.Type,
+		Timestamp:   record.Timestamp,
+		Data:        record.Data,
+		Metadata:    record.Metadata,
+	})
+}
+
+// Flush implements Writer.
+func (w *ndjsonWriter) Flush() error {
+	return w.buf.Flush()
+}
+
+// csvWriter implements Writer writing CSV.
+type csvWriter struct {
+	w      *csv.Writer
+	header bool // whether header was written
+}
+
+// Write implements Writer.
+func (w *csvWriter) Write(record *schema.Record) error {
+	if !w.header {
+		if err := w.w.Write(header); err != nil {
+			return err
+		}
+		w.header = true
+	}
+
+	return w.w.Write([]string{
+		record.Aggregate,
+		record.AggregateID,
+		strconv.FormatUint(record.Version, 10),
+		record.Type,
+		record.Timestamp.Format(time.RFC3339Nano),
+		string(record.Data),
+		string(record.Metadata),
+	})
+}
+
+// Flush implements Writer.
+func (w *csvWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// Reader reads records from archive.
+// Reader implements schema.Iterator.
+type Reader struct {
+	next   func() (*schema.Record, error)
+	record *schema.Record
+	line   int
+	err    error
+}
+
+// NewReader constructs a new Reader reading archive of given format from r.
+func NewReader(r io.Reader, format Format) (*Reader, error) {
+	switch format {
+	case FormatNDJSON:
+		dec := json.NewDecoder(bufio.NewReader(r))
+		return &Reader{next: func() (*schema.Record, error) {
+			var e entry
+			if err := dec.Decode(&e); err != nil {
+				return nil, err
+			}
+			return e.record(), nil
+		}}, nil
+	case FormatCSV:
+		return &Reader{next: csvRecords(csv.NewReader(r))}, nil
+	default:
+		return nil, ErrNotValidFormat
+	}
+}
+
+// Next implements schema.Iterator.
+func (r *Reader) Next() bool {
+	if r.err != nil {
+		return false
+	}
+
+	record, err := r.next()
+	if err != nil {
+		if err != io.EOF {
+			r.err = errors.Wrapf(err, "unable to read archive record %d", r.line+1)
+		}
+		return false
+	}
+
+	r.record = record
+	r.line++
+	return true
+}
+
+// Value implements schema.Iterator.
+func (r *Reader) Value() (*schema.Record, error) {
+	return r.record, nil
+}
+
+// Error implements schema.Iterator.
+func (r *Reader) Error() error {
+	return r.err
+}
+
+// Close implements schema.Iterator.
+func (r *Reader) Close() {}
+
+// record converts archived entry into record.
+func (e *entry) record() *schema.Record {
+	return &schema.Record{
+		AggregateID: e.AggregateID,
+		Aggregate:   e.Aggregate,
+		Version:     e.Version,
+		Type:        e.Type,
+		Timestamp:   e.Timestamp,
+		Data:        e.Data,
+		Metadata:    e.Metadata,
+	}
+}
+
+// csvRecords returns function reading records from CSV archive skipping its header.
+func csvRecords(r *csv.Reader) func() (*schema.Record, error) {
+	r.FieldsPerRecord = len(header)
+
+	var skipped bool
+	return func() (*schema.Record, error) {
+		if !skipped {
+			if _, err := r.Read(); err != nil {
+				return nil, err
+			}
+			skipped = true
+		}
+
+		row, err := r.Read()
+		if err != nil {
+			return nil, err
+		}
+
+		version, err := strconv.ParseUint(row[2], 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "version")
+		}
+
+		timestamp, err := time.Parse(time.RFC3339Nano, row[4])
+		if err != nil {
+			return nil, errors.Wrap(err, "timestamp")
+		}
+
+		record := schema.Record{
+			Aggregate:   row[0],
+			AggregateID: row[1],
+			Version:     version,
+			Type:        row[3],
+			Timestamp:   timestamp,
+			Data:        []byte(row[5]),
+		}
+		if len(row[6]) > 0 {
+			record.Metadata = []byte(row[6])
+		}
+		return &record, nil
+	}
+}
@@ -0,0 +1,123 @@
+// Package backend opens aggregates store of the configured backend.
+package backend
+
+import (
+	"context"
+
+	"github.com/deividaspetraitis/ledger/database/archive"
+	eventstore "github.com/deividaspetraitis/ledger/database/esdb"
+	"github.com/deividaspetraitis/ledger/database/filelog"
+	"github.com/deividaspetraitis/ledger/database/schema"
+	"github.com/deividaspetraitis/ledger/database/sqlite"
+
+	"github.com/deividaspetraitis/go/database"
+	"github.com/deividaspetraitis/go/database/esdb"
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// Supported store backends.
+const (
+	ESDB    = "esdb"    // EventStoreDB cluster, default
+	SQLite  = "sqlite"  // embedded SQLite database
+	FileLog = "filelog" // segmented append-only local log files
+)
+
+// ErrNotValidBackend is returned when configured store backend is not supported.
+var ErrNotValidBackend = errors.New("given store backend is not supported")
+
+// Config represents store backend configuration.
+type Config struct {
+	Backend string          `mapstructure:"backend"` // Store backend, defaults to ESDB
+	SQLite  *sqlite.Config  `mapstructure:"sqlite"`  // SQLite database config
+	FileLog *filelog.Config `mapstructure:"filelog"` // File log config
+}
+
+// Validate implements validator.Validator.
+// Only configuration of the selected backend is validated.
+func (c *Config) Validate() error {
+	switch c.Backend {
+	case "", ESDB:
+		return nil
+	case SQLite:
+		if c.SQLite == nil {
+			return errors.New("sqlite configuration is required")
+		}
+		return c.SQLite.Validate()
+	case FileLog:
+		if c.FileLog == nil {
+			return errors.New("filelog configuration is required")
+		}
+		return c.FileLog.Validate()
+	default:
+		return errors.Wrapf(ErrNotValidBackend, "backend %q", c.Backend)
+	}
+}
+
+// Store persists and restores aggregates, it's implemented by every backend store.
+type Store interface {
+	archive.Source
+	archive.Sink
+
+	// Save persists an aggregate, it implements database.SaveAggregateFunc.
+	Save(ctx context.Context, aggregate es.Aggregate) error
+
+	// Load restores aggregate state, if aggregate does not exist ledger.ErrEntryNotFound is returned.
+	Load(ctx context.Context, aggregate es.Aggregate, id string) error
+
+	// RecordsAfter returns iterator over persisted records of the aggregate following the given version as they are stored.
+	RecordsAfter(ctx context.Context, aggregate es.Aggregate, id string, after uint64) (schema.Iterator, error)
+}
+
+// Open opens store of the configured backend decoding persisted events according to events config,
+// EventStoreDB instance is configured by db. Returned function closes underlying database connection.
+// If cfg is nil ESDB backend is used.
+func Open(ctx context.Context, cfg *Config, db *database.Config, events *schema.Config) (Store, func() error, error) {
+	var c Config
+	if cfg != nil {
+		c = *cfg
+	}
+
+	switch c.Backend {
+	case "", ESDB:
+		client, err := esdb.NewClient(db)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "unable connect to database instance")
+		}
+
+		store, err := eventstore.NewStore(eventstore.Adapt(client), events)
+		if err != nil {
+			client.Close()
+			return nil, nil, err
+		}
+		return store, client.Close, nil
+	case SQLite:
+		conn, err := sqlite.Open(ctx, c.SQLite)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "unable to open database")
+		}
+
+		store, err := sqlite.NewStore(conn, events)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		return store, conn.Close, nil
+	case FileLog:
+		store, err := filelog.Open(c.FileLog, events)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "unable to open event log")
+		}
+		return store, store.Close, nil
+	default:
+		return nil, nil, ErrNotValidBackend
+	}
+}
+
+// Get retrieves aggregate with restored state from the store.
+func Get[T any](ctx context.Context, s Store, aggregate es.Aggregate, id string) (T, error) {
+	if err := s.Load(ctx, aggregate, id); err != nil {
+		return *new(T), err
+	}
+	return aggregate.(T), nil
+}
@@ -0,0 +1,212 @@
+// Package cache implements write-through LRU cache of aggregates.
+//
+// Cached aggregates are validated against the store by reading only events
+// appended after the cached aggregate version, so cache never serves stale state.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/deividaspetraitis/go/database"
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// metrics counts cache hits, misses and evictions.
+var metrics = expvar.NewMap("aggregate_cache")
+
+// Config represents aggregates cache configuration.
+type Config struct {
+	Size int           `mapstructure:"size"` // Maximum number of cached aggregates, 0 disables the cache
+	TTL  time.Duration `mapstructure:"ttl"`  // Time after which cached aggregate expires, 0 means never
+}
+
+// Validate implements validator.Validator.
+func (c *Config) Validate() error {
+	if c.Size < 0 || c.TTL < 0 {
+		return errors.New("size and ttl can not be negative")
+	}
+	return nil
+}
+
+// Cloner is an aggregate capable to produce its independent copy.
+type Cloner[T any] interface {
+	es.Aggregate
+
+	// Clone returns a copy of the aggregate having the same state and version.
+	Clone() T
+}
+
+// entry represents cached aggregate.
+type entry[T any] struct {
+	id        string
+	aggregate T
+	version   es.Version
+	expires   time.Time
+}
+
+// Cache is LRU cache of aggregates keyed by aggregate ID and version.
+// Cache stores and hands out copies of aggregates, so cached state is never shared.
+type Cache[T Cloner[T]] struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List // most recently used entries are at the front
+	entries map[string]*list.Element
+}
+
+// New constructs a new Cache. If cfg is nil or size is not set cache is disabled.
+func New[T Cloner[T]](cfg *Config) *Cache[T] {
+	var c Config
+	if cfg != nil {
+		c = *cfg
+	}
+
+	return &Cache[T]{
+		size:    c.Size,
+		ttl:     c.TTL,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Enabled reports whether cache is enabled.
+func (c *Cache[T]) Enabled() bool {
+	return c.size > 0
+}
+
+// Get returns copy of cached aggregate by its ID.
+func (c *Cache[T]) Get(id string) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[id]
+	if !ok {
+		metrics.Add("misses", 1)
+		return *new(T), false
+	}
+
+	e := el.Value.(*entry[T])
+	if c.ttl > 0 && time.Now().After(e.expires) {
+		c.remove(el)
+		metrics.Add("misses", 1)
+		return *new(T), false
+	}
+
+	c.lru.MoveToFront(el)
+	metrics.Add("hits", 1)
+
+	return e.aggregate.Clone(), true
+}
+
+// Put caches copy of the aggregate unless newer version of the aggregate is already cached.
+// Aggregates having events pending to be persisted are not cached.
+func (c *Cache[T]) Put(id string, aggregate T) {
+	if !c.Enabled() || len(aggregate.Events()) > 0 {
+		return
+	}
+
+	version := aggregate.Root().Version()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[id]; ok {
+		e := el.Value.(*entry[T])
+		if e.version > version {
+			return
+		}
+		e.aggregate, e.version, e.expires = aggregate.Clone(), version, time.Now().Add(c.ttl)
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[id] = c.lru.PushFront(&entry[T]{
+		id:        id,
+		aggregate: aggregate.Clone(),
+		version:   version,
+		expires:   time.Now().Add(c.ttl),
+	})
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		metrics.Add("evictions", 1)
+	}
+}
+
+// Remove removes aggregate from the cache.
+func (c *Cache[T]) Remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[id]; ok {
+		c.remove(el)
+	}
+}
+
+// Len returns number of cached aggregates.
+func (c *Cache[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// remove removes list element from the cache.
+// Caller must hold c.mu.
+func (c *Cache[T]) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*entry[T]).id)
+}
+
+// Save wraps save so successfully persisted aggregates are written through into the cache.
+func (c *Cache[T]) Save(save database.SaveAggregateFunc) database.SaveAggregateFunc {
+	if !c.Enabled() {
+		return save
+	}
+
+	return func(ctx context.Context, aggregate es.Aggregate) error {
+		events := aggregate.Events()
+		if len(events) == 0 {
+			return save(ctx, aggregate)
+		}
+		id := events[0].AggregateID
+
+		if err := save(ctx, aggregate); err != nil {
+			// store state is unknown, e.g. append may have succeeded despite an error.
+			c.Remove(id)
+			return err
+		}
+
+		if v, ok := aggregate.(T); ok {
+			c.Put(id, v)
+		}
+		return nil
+	}
+}
+
+// Load wraps get so aggregates are served from the cache.
+// Cached aggregate is caught up by reading only events appended after its version.
+func (c *Cache[T]) Load(get database.GetAggregateFunc[T]) database.GetAggregateFunc[T] {
+	if !c.Enabled() {
+		return get
+	}
+
+	return func(ctx context.Context, aggregate es.Aggregate, id string) (T, error) {
+		if cached, ok := c.Get(id); ok {
+			aggregate = cached
+		}
+
+		v, err := get(ctx, aggregate, id)
+		if err != nil {
+			return v, err
+		}
+
+		c.Put(id, v)
+		return v, nil
+	}
+}
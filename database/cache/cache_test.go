@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+
+	"github.com/deividaspetraitis/go/es"
+)
+
+// store is an in-memory wallets store counting events it reads.
+type store struct {
+	streams map[string][]es.MarshalUnmarshaler
+	read    int // number of events read
+}
+
+func (s *store) save(ctx context.Context, aggregate es.Aggregate) error {
+	for _, v := range aggregate.Events() {
+		s.streams[v.AggregateID] = append(s.streams[v.AggregateID], v.Data)
+		if err := aggregate.Sync(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *store) get(ctx context.Context, aggregate es.Aggregate, id string) (*ledger.WalletAggregate, error) {
+	var events []*es.Event
+	for _, v := range s.streams[id][aggregate.Root().Version():] {
+		events = append(events, es.NewEvent(id, aggregate, v))
+		s.read++
+	}
+
+	if err := aggregate.Reply(events); err != nil {
+		return nil, err
+	}
+	if aggregate.Root().Version() == 0 {
+		return nil, ledger.ErrEntryNotFound
+	}
+	return aggregate.(*ledger.WalletAggregate), nil
+}
+
+// newWallet constructs a new wallet aggregate with n persisted deposits.
+func newWallet(t *testing.T, save func(ctx context.Context, aggregate es.Aggregate) error, deposits int) *ledger.WalletAggregate {
+	wallet, err := ledger.NewWallet(&ledger.CreateWalletRequest{Name: "test"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	for i := 0; i < deposits; i++ {
+		if err := wallet.ProcessTransaction(&ledger.Transaction{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: 1}); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+	}
+
+	if err := save(context.Background(), wallet); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return wallet
+}
+
+func TestCacheLRU(t *testing.T) {
+	cache := New[*ledger.WalletAggregate](&Config{Size: 2})
+	s := &store{streams: make(map[string][]es.MarshalUnmarshaler)}
+
+	a, b, c := newWallet(t, s.save, 0), newWallet(t, s.save, 0), newWallet(t, s.save, 0)
+
+	cache.Put(a.ID, a)
+	cache.Put(b.ID, b)
+	cache.Get(a.ID) // a becomes most recently used
+	cache.Put(c.ID, c)
+
+	if _, ok := cache.Get(b.ID); ok {
+		t.Errorf("least recently used aggregate was not evicted")
+	}
+	if _, ok := cache.Get(a.ID); !ok {
+		t.Errorf("recently used aggregate was evicted")
+	}
+	if n := cache.Len(); n != 2 {
+		t.Errorf("len got %v, want %v", n, 2)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	cache := New[*ledger.WalletAggregate](&Config{Size: 1, TTL: time.Millisecond})
+	s := &store{streams: make(map[string][]es.MarshalUnmarshaler)}
+
+	wallet := newWallet(t, s.save, 0)
+	cache.Put(wallet.ID, wallet)
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := cache.Get(wallet.ID); ok {
+		t.Errorf("expired aggregate was returned")
+	}
+}
+
+func TestCachePutVersion(t *testing.T) {
+	cache := New[*ledger.WalletAggregate](&Config{Size: 1})
+	s := &store{streams: make(map[string][]es.MarshalUnmarshaler)}
+
+	wallet := newWallet(t, s.save, 2)
+	stale := wallet.Clone()
+
+	if err := wallet.ProcessTransaction(&ledger.Transaction{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: 1}); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	// pending events are not cached
+	cache.Put(wallet.ID, wallet)
+	if _, ok := cache.Get(wallet.ID); ok {
+		t.Errorf("aggregate with pending events was cached")
+	}
+
+	if err := s.save(context.Background(), wallet); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	// older versions does not replace newer ones
+	cache.Put(wallet.ID, wallet)
+	cache.Put(wallet.ID, stale)
+
+	cached, ok := cache.Get(wallet.ID)
+	if !ok {
+		t.Fatalf("aggregate was not cached")
+	}
+	if cached.Root().Version() != wallet.Root().Version() || cached.Balance != 3 {
+		t.Errorf("got version %v balance %v, want version %v balance %v", cached.Root().Version(), cached.Balance, wallet.Root().Version(), 3)
+	}
+
+	// cached copy is independent from the aggregate
+	cached.Balance = 0
+	if again, _ := cache.Get(wallet.ID); again.Balance != 3 {
+		t.Errorf("balance got %v, want %v", again.Balance, 3)
+	}
+}
+
+func TestCacheWriteThrough(t *testing.T) {
+	cache := New[*ledger.WalletAggregate](&Config{Size: 10})
+	s := &store{streams: make(map[string][]es.MarshalUnmarshaler)}
+
+	save, get := cache.Save(s.save), cache.Load(s.get)
+
+	// saved wallets are served from cache without reading the stream
+	wallet := newWallet(t, save, 3)
+	loaded, err := get(context.Background(), &ledger.WalletAggregate{}, wallet.ID)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if s.read != 0 {
+		t.Errorf("events read got %v, want %v", s.read, 0)
+	}
+	if loaded.Balance != 3 {
+		t.Errorf("balance got %v, want %v", loaded.Balance, 3)
+	}
+
+	// events appended bypassing the cache are caught up
+	if err := loaded.ProcessTransaction(&ledger.Transaction{Type: ledger.TransactionWithdraw, WalletID: wallet.ID, Amount: 2}); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if err := s.save(context.Background(), loaded); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	loaded, err = get(context.Background(), &ledger.WalletAggregate{}, wallet.ID)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if s.read != 1 {
+		t.Errorf("events read got %v, want %v", s.read, 1)
+	}
+	if loaded.Balance != 1 {
+		t.Errorf("balance got %v, want %v", loaded.Balance, 1)
+	}
+
+	// missing wallets are not cached
+	if _, err := get(context.Background(), &ledger.WalletAggregate{}, "missing"); err != ledger.ErrEntryNotFound {
+		t.Errorf("got %v, want %v", err, ledger.ErrEntryNotFound)
+	}
+	if n := cache.Len(); n != 1 {
+		t.Errorf("len got %v, want %v", n, 1)
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	cache := New[*ledger.WalletAggregate](nil)
+	s := &store{streams: make(map[string][]es.MarshalUnmarshaler)}
+
+	wallet := newWallet(t, cache.Save(s.save), 1)
+	if _, err := cache.Load(s.get)(context.Background(), &ledger.WalletAggregate{}, wallet.ID); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if s.read != 2 {
+		t.Errorf("events read got %v, want %v", s.read, 2)
+	}
+}
@@ -0,0 +1,108 @@
+package eventstore
+
+import (
+	"context"
+	"io"
+	"math"
+
+	"github.com/deividaspetraitis/go/database/esdb"
+
+	client "github.com/EventStore/EventStore-Client-Go/esdb"
+)
+
+// Client represents EventStoreDB operations Store depends on.
+type Client interface {
+	// Save appends events to the stream of their aggregate,
+	// stream is expected to end right before the first event.
+	Save(ctx context.Context, events []*esdb.Event) error
+
+	// Get returns iterator over events of the aggregate stream starting at the given zero based revision.
+	Get(ctx context.Context, id string, aggregate string, from esdb.Version) (Events, error)
+
+	// Streams returns names of streams created after the given position of $all stream in order they were created,
+	// along with position of the last event read. Nil position means the beginning of $all stream.
+	Streams(ctx context.Context, after *client.Position) ([]string, *client.Position, error)
+
+	// AppendToStream appends event to the stream regardless of its revision,
+	// repeatedly appended event of the same ID is deduplicated.
+	AppendToStream(ctx context.Context, stream string, event client.EventData) error
+}
+
+// Events iterates over events of a stream, it's implemented by esdb.Iterator.
+type Events interface {
+	Next() bool
+	Value() (*esdb.Event, error)
+	Error() error
+	Close()
+}
+
+// adapter adapts esdb.Client to Client.
+type adapter struct {
+	db *esdb.Client
+}
+
+// Adapt adapts EventStoreDB client to Client.
+func Adapt(db *esdb.Client) Client {
+	return &adapter{db: db}
+}
+
+// Save implements Client.
+func (a *adapter) Save(ctx context.Context, events []*esdb.Event) error {
+	return a.db.Save(ctx, events)
+}
+
+// Get implements Client.
+func (a *adapter) Get(ctx context.Context, id string, aggregate string, from esdb.Version) (Events, error) {
+	it, err := a.db.Get(ctx, id, aggregate, from)
+	if err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// Streams implements Client.
+// Streams scans $all stream following the given position, so it's cheap only if the position is recent.
+func (a *adapter) Streams(ctx context.Context, after *client.Position) ([]string, *client.Position, error) {
+	var opts client.ReadAllOptions
+	if after != nil {
+		opts.From = *after
+	}
+
+	stream, err := a.db.ReadAll(ctx, opts, math.MaxUint64)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer stream.Close()
+
+	var (
+		streams  []string
+		position = after
+	)
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return streams, position, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		e := event.Event
+		// reading starts at the event of the given position, which was read already
+		if e == nil || (after != nil && e.Position == *after) {
+			continue
+		}
+		position = &e.Position
+
+		// stream is created by its first event
+		if e.EventNumber == 0 {
+			streams = append(streams, e.StreamID)
+		}
+	}
+}
+
+// AppendToStream implements Client.
+func (a *adapter) AppendToStream(ctx context.Context, stream string, event client.EventData) error {
+	_, err := a.db.AppendToStream(ctx, stream, client.AppendToStreamOptions{}, event)
+	return err
+}
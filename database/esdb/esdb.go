@@ -2,43 +2,70 @@ package eventstore
 
 import (
 	"context"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
 
 	"github.com/deividaspetraitis/go/database/esdb"
 	"github.com/deividaspetraitis/go/errors"
 	"github.com/deividaspetraitis/go/es"
-	"github.com/deividaspetraitis/go/log"
+
+	client "github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/gofrs/uuid"
 )
 
+// quarantinePrefix prefixes names of streams holding quarantined events.
+const quarantinePrefix = "quarantine-"
+
+// Store persists and restores aggregates using EventStoreDB.
+type Store struct {
+	db       Client
+	restorer schema.Restorer
+
+	mu       sync.Mutex       // serialises listing streams
+	streams  []string         // names of streams created through position in order they were created
+	position *client.Position // position of $all stream streams were listed through, nil until listed
+}
+
+// NewStore constructs a new Store using db, see Adapt.
+// If cfg is nil or unknown events policy is not set schema.PolicyFail is used.
+func NewStore(db Client, cfg *schema.Config) (*Store, error) {
+	policy := schema.PolicyFail
+	if cfg != nil && len(cfg.Unknown) > 0 {
+		policy = cfg.Unknown
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := Store{
+		db: db,
+	}
+	s.restorer = schema.Restorer{
+		Policy:     policy,
+		Quarantine: s.quarantine,
+		Cutover:    cfg.Cutover(),
+	}
+
+	return &s, nil
+}
+
 // Save persists an aggregate into underlying DB store.
 // Save calls aggregate.Sync if store operations were successful.
-func Save(ctx context.Context, db *esdb.Client, aggregate es.Aggregate) error {
-	var (
-		events    []*esdb.Event
-		processed []*es.Event
-	)
-
-	for _, v := range aggregate.Events() {
-		bytes, err := v.Data.MarshalJSON()
-		if err != nil {
-			return errors.Wrap(err, "failed to serialise")
-		}
-
-		events = append(events, &esdb.Event{
-			AggregateID: v.AggregateID,
-			Version:     esdb.Version(v.Version),
-			Aggregate:   es.ParseAggregateName(v.Aggregate),
-			Type:        es.ParseEventName(v.Data),
-			Timestamp:   v.Timestamp,
-			Data:        bytes,
-			Metadata:    v.Metadata,
-		})
+// Save implements database.SaveAggregateFunc.
+func (s *Store) Save(ctx context.Context, aggregate es.Aggregate) error {
+	processed := aggregate.Events()
 
-		processed = append(processed, v)
+	records, head, err := schema.Encode(aggregate, processed)
+	if err != nil {
+		return err
 	}
 
-	if err := db.Save(ctx, events); err != nil {
+	if err := s.Append(ctx, records); err != nil {
 		return err
 	}
 
@@ -49,56 +76,133 @@ func Save(ctx context.Context, db *esdb.Client, aggregate es.Aggregate) error {
 		}
 	}
 
+	if chained, ok := aggregate.(schema.Chained); ok && len(records) > 0 {
+		chained.SetChainHead(head)
+	}
+
 	return nil
 }
 
-// Get retrieves aggregate with restored state from underlying DB store.
-func Get[T any](ctx context.Context, db *esdb.Client, aggregate es.Aggregate, id string) (T, error) {
-	iterator, err := db.Get(ctx, id, es.ParseAggregateName(aggregate), esdb.Version(aggregate.Root().Version()))
-	if err != nil {
-		return *new(T), err
+// Append persists already encoded records of a single aggregate preserving their versions, timestamps and metadata.
+// EventStoreDB stamps events by the time they are appended, so records timestamps are kept in events metadata, see envelope.
+// Records not following the last persisted record of the aggregate are rejected with ledger.ErrVersionConflict.
+// Append implements archive.Sink.
+func (s *Store) Append(ctx context.Context, records []*schema.Record) error {
+	// EventStoreDB numbers appended events itself, only the first version is checked against the stream.
+	var events []*esdb.Event
+	for i, v := range records {
+		if first := records[0]; v.Aggregate != first.Aggregate || v.AggregateID != first.AggregateID || v.Version != first.Version+uint64(i) {
+			return errors.New("records must be contiguous records of a single aggregate")
+		}
+
+		metadata, err := wrap(v)
+		if err != nil {
+			return err
+		}
+
+		events = append(events, &esdb.Event{
+			AggregateID: v.AggregateID,
+			Version:     esdb.Version(v.Version),
+			Aggregate:   v.Aggregate,
+			Type:        v.Type,
+			Timestamp:   v.Timestamp,
+			Data:        v.Data,
+			Metadata:    metadata,
+		})
 	}
-	defer iterator.Close()
-
-	var events []*es.Event
-	for iterator.Next() {
-		select {
-		case <-ctx.Done():
-			return *new(T), ctx.Err()
-		default:
-			event, err := iterator.Value()
-			if err != nil {
-				return *new(T), err
-			}
-
-			ev, err := es.GetAggregateEvent(aggregate, event.Type)
-			if err != nil {
-				log.WithError(err).Print("aggregate event not found")
-				continue
-			}
-
-			if err := ev.UnmarshalJSON(event.Data); err != nil {
-				return *new(T), err
-			}
-
-			events = append(events, es.NewEvent(id, aggregate, ev))
+
+	if err := s.db.Save(ctx, events); err != nil {
+		if errors.Is(err, client.ErrWrongExpectedStreamRevision) {
+			return ledger.ErrVersionConflict
 		}
+		return err
 	}
+	return nil
+}
 
-	if err := iterator.Error(); err != nil {
-		return *new(T), err
+// Load restores aggregate state from underlying DB store.
+// Only events following current aggregate version are read, so aggregate restored earlier is caught up.
+// If aggregate does not exist ledger.ErrEntryNotFound is returned.
+func (s *Store) Load(ctx context.Context, aggregate es.Aggregate, id string) error {
+	it, err := s.db.Get(ctx, id, es.ParseAggregateName(aggregate), esdb.Version(aggregate.Root().Version()))
+	if err != nil {
+		return err
 	}
 
-	// reconstruct state
-	if err := aggregate.Reply(events); err != nil {
-		return *new(T), err
+	if err := s.restorer.Restore(ctx, aggregate, id, &iterator{it: it}); err != nil {
+		return err
 	}
 
 	// no events for given aggregate were found
 	// meaning such aggregate does not exit
 	if aggregate.Root().Version() == 0 {
-		return *new(T), ledger.ErrEntryNotFound
+		return ledger.ErrEntryNotFound
 	}
 
+	return nil
+}
+
+// Get retrieves aggregate with restored state from underlying DB store.
+func Get[T any](ctx context.Context, s *Store, aggregate es.Aggregate, id string) (T, error) {
+	if err := s.Load(ctx, aggregate, id); err != nil {
+		return *new(T), err
+	}
 	return aggregate.(T), nil
 }
+
+// Records returns iterator over all persisted records of the aggregate as they are stored, without decoding them.
+// Records implements archive.Source.
+func (s *Store) Records(ctx context.Context, aggregate es.Aggregate, id string) (schema.Iterator, error) {
+	return s.RecordsAfter(ctx, aggregate, id, 0)
+}
+
+// RecordsAfter returns iterator over persisted records of the aggregate following the given version as they are stored.
+func (s *Store) RecordsAfter(ctx context.Context, aggregate es.Aggregate, id string, after uint64) (schema.Iterator, error) {
+	it, err := s.db.Get(ctx, id, es.ParseAggregateName(aggregate), esdb.Version(after))
+	if err != nil {
+		return nil, err
+	}
+	return &iterator{it: it}, nil
+}
+
+// IDs returns IDs of all persisted aggregates of the aggregate type in order they were created.
+// Listed streams are kept, so only streams created since the previous call are looked up by reading
+// events appended to $all stream meanwhile.
+// IDs implements archive.Source.
+func (s *Store) IDs(ctx context.Context, aggregate es.Aggregate) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	streams, position, err := s.db.Streams(ctx, s.position)
+	if err != nil {
+		return nil, err
+	}
+	s.streams, s.position = append(s.streams, streams...), position
+
+	prefix := es.ParseAggregateName(aggregate) + "_"
+
+	var ids []string
+	for _, v := range s.streams {
+		if strings.HasPrefix(v, prefix) {
+			ids = append(ids, strings.TrimPrefix(v, prefix))
+		}
+	}
+	return ids, nil
+}
+
+// quarantine copies unknown record into aggregate's quarantine stream.
+// Event ID is derived from record position, so EventStoreDB is able to deduplicate repeatedly quarantined record.
+// quarantine implements schema.QuarantineFunc.
+func (s *Store) quarantine(ctx context.Context, record *schema.Record) error {
+	stream := quarantinePrefix + record.Aggregate + "_" + record.AggregateID
+
+	id := uuid.NewV5(uuid.NamespaceOID, stream+"@"+strconv.FormatUint(record.Version, 10))
+
+	return s.db.AppendToStream(ctx, stream, client.EventData{
+		EventID:     id,
+		EventType:   record.Type,
+		ContentType: client.JsonContentType,
+		Data:        record.Data,
+		Metadata:    record.Metadata,
+	})
+}
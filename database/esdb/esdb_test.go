@@ -0,0 +1,195 @@
+package eventstore_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	eventstore "github.com/deividaspetraitis/ledger/database/esdb"
+	"github.com/deividaspetraitis/ledger/database/schema"
+	"github.com/deividaspetraitis/ledger/database/storetest"
+
+	"github.com/deividaspetraitis/go/database/esdb"
+
+	client "github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeClient is in memory eventstore.Client following EventStoreDB semantics:
+// stream revisions start at 0, appends are checked against expected revision and events are timestamped on append.
+type fakeClient struct {
+	mu      sync.Mutex
+	streams map[string][]*esdb.Event
+	names   []string                      // stream names in order they were created
+	ids     map[string]bool               // IDs of events appended with AppendToStream
+	data    map[string][]client.EventData // events appended with AppendToStream by stream
+	listed  []int                         // positions streams were listed from
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		streams: make(map[string][]*esdb.Event),
+		ids:     make(map[string]bool),
+		data:    make(map[string][]client.EventData),
+	}
+}
+
+// Save implements eventstore.Client.
+func (c *fakeClient) Save(ctx context.Context, events []*esdb.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := events[0].Aggregate + "_" + events[0].AggregateID
+	stream := c.streams[name]
+	if uint64(events[0].Version) != uint64(len(stream))+1 {
+		return client.ErrWrongExpectedStreamRevision
+	}
+	if len(stream) == 0 {
+		c.names = append(c.names, name)
+	}
+
+	for _, v := range events {
+		event := *v
+		event.Version = esdb.Version(len(stream))
+		event.Timestamp = time.Now().UTC()
+		stream = append(stream, &event)
+	}
+	c.streams[name] = stream
+
+	return nil
+}
+
+// Get implements eventstore.Client.
+func (c *fakeClient) Get(ctx context.Context, id string, aggregate string, from esdb.Version) (eventstore.Events, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var events []*esdb.Event
+	if stream := c.streams[aggregate+"_"+id]; uint64(from) < uint64(len(stream)) {
+		events = append(events, stream[from:]...)
+	}
+	return &fakeEvents{ctx: ctx, events: events}, nil
+}
+
+// Streams implements eventstore.Client.
+// Position of the fake $all stream is the number of created streams.
+func (c *fakeClient) Streams(ctx context.Context, after *client.Position) ([]string, *client.Position, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var from int
+	if after != nil {
+		from = int(after.Commit)
+	}
+	c.listed = append(c.listed, from)
+
+	position := client.Position{Commit: uint64(len(c.names)), Prepare: uint64(len(c.names))}
+	return append([]string{}, c.names[from:]...), &position, nil
+}
+
+// AppendToStream implements eventstore.Client.
+func (c *fakeClient) AppendToStream(ctx context.Context, stream string, event client.EventData) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ids[event.EventID.String()] {
+		return nil
+	}
+	c.ids[event.EventID.String()] = true
+	c.data[stream] = append(c.data[stream], event)
+
+	return nil
+}
+
+// fakeEvents iterates over events of the stream, it's cancelled together with the context the same way stream reads are.
+type fakeEvents struct {
+	ctx    context.Context
+	events []*esdb.Event
+	event  *esdb.Event
+	err    error
+}
+
+// Next implements eventstore.Events.
+func (i *fakeEvents) Next() bool {
+	if i.err != nil || len(i.events) == 0 {
+		return false
+	}
+	if i.err = i.ctx.Err(); i.err != nil {
+		return false
+	}
+	i.event, i.events = i.events[0], i.events[1:]
+	return true
+}
+
+// Value implements eventstore.Events.
+func (i *fakeEvents) Value() (*esdb.Event, error) {
+	return i.event, i.err
+}
+
+// Error implements eventstore.Events.
+func (i *fakeEvents) Error() error {
+	return i.err
+}
+
+// Close implements eventstore.Events.
+func (i *fakeEvents) Close() {
+	i.events = nil
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T, cfg *schema.Config) storetest.Store {
+		store, err := eventstore.NewStore(newFakeClient(), cfg)
+		if err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+		return store
+	})
+}
+
+func TestStoreIDs(t *testing.T) {
+	ctx := context.Background()
+
+	db := newFakeClient()
+	store, err := eventstore.NewStore(db, nil)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	var want []string
+	for i := 0; i < 3; i++ {
+		wallet, err := ledger.NewWallet(&ledger.CreateWalletRequest{Name: "test"})
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if err := store.Save(ctx, wallet); err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		want = append(want, wallet.ID)
+
+		ids, err := store.IDs(ctx, &ledger.WalletAggregate{})
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if !cmp.Equal(ids, want) {
+			t.Errorf("#%d got %v, want %v", i, ids, want)
+		}
+	}
+
+	// streams are listed since the position previous listing ended at.
+	if want := []int{0, 1, 2}; !cmp.Equal(db.listed, want) {
+		t.Errorf("got %v, want %v", db.listed, want)
+	}
+}
@@ -0,0 +1,76 @@
+package eventstore
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/database/esdb"
+)
+
+// envelope represents metadata of EventStoreDB event holding record metadata along record timestamp,
+// as EventStoreDB stamps events by the time they are appended, e.g. imported records would be stamped by the import time.
+type envelope struct {
+	Timestamp time.Time `json:"timestamp"`
+	Metadata  []byte    `json:"metadata,omitempty"`
+}
+
+// wrap returns metadata of the event persisting the record.
+// Record without timestamp is stamped by EventStoreDB, so its metadata is persisted as is.
+func wrap(record *schema.Record) ([]byte, error) {
+	if record.Timestamp.IsZero() {
+		return record.Metadata, nil
+	}
+	return json.Marshal(&envelope{Timestamp: record.Timestamp, Metadata: record.Metadata})
+}
+
+// unwrap returns record metadata and timestamp of the event.
+// Events persisted before timestamps were kept carry record metadata as is and are timestamped by EventStoreDB.
+func unwrap(event *esdb.Event) ([]byte, time.Time) {
+	var e envelope
+	if err := json.Unmarshal(event.Metadata, &e); err != nil || e.Timestamp.IsZero() {
+		return event.Metadata, event.Timestamp
+	}
+	return e.Metadata, e.Timestamp.UTC()
+}
+
+// iterator adapts Events to schema.Iterator.
+type iterator struct {
+	it Events
+}
+
+// Next implements schema.Iterator.
+func (i *iterator) Next() bool {
+	return i.it.Next()
+}
+
+// Value implements schema.Iterator.
+func (i *iterator) Value() (*schema.Record, error) {
+	event, err := i.it.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, timestamp := unwrap(event)
+
+	return &schema.Record{
+		AggregateID: event.AggregateID,
+		Aggregate:   event.Aggregate,
+		Version:     uint64(event.Version) + 1, // EventStoreDB events enumeration starts at 0
+		Type:        event.Type,
+		Timestamp:   timestamp,
+		Data:        event.Data,
+		Metadata:    metadata,
+	}, nil
+}
+
+// Error implements schema.Iterator.
+func (i *iterator) Error() error {
+	return i.it.Error()
+}
+
+// Close implements schema.Iterator.
+func (i *iterator) Close() {
+	i.it.Close()
+}
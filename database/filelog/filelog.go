@@ -0,0 +1,254 @@
+// Package filelog implements aggregates store appending events to segmented local log files.
+//
+// Events are appended as CRC-32C checked records to the active segment file, a new segment is started
+// once the active one reaches configured size. Appends are queued to a single writer which writes
+// all appends found queued at once and fsyncs the segment once for all of them, thus concurrent appends share a single fsync.
+// Appended events are visible to readers only once they are synced.
+//
+// Positions of every aggregate events are kept in memory and rebuilt by scanning segments once the log is opened.
+// Process crashing in the middle of a write leaves torn record at the tail of the last segment, such tail is truncated on recovery.
+// Damaged records followed by a valid record or within other segments can not be caused by a crash, so opening such log fails with ErrCorrupted.
+//
+// Log directory must not be shared by several processes.
+package filelog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// Default log configuration values.
+const (
+	defaultSegmentSize = 64 << 20
+	defaultBatchSize   = 128
+)
+
+// Log files naming.
+const (
+	segmentExt     = ".seg"
+	quarantineFile = "quarantine.rec"
+)
+
+// ErrClosed is returned when store is used after it was closed.
+var ErrClosed = errors.New("event log is closed")
+
+// Config represents file log configuration.
+type Config struct {
+	Path        string `mapstructure:"path"`    // Directory holding log files, created if it does not exist
+	SegmentSize int64  `mapstructure:"segment"` // Size in bytes after which a new segment is started
+	BatchSize   int    `mapstructure:"batch"`   // Maximum number of appends written within a single fsync
+}
+
+// Validate implements validator.Validator.
+func (c *Config) Validate() error {
+	if len(c.Path) == 0 {
+		return errors.New("log directory path is required")
+	}
+	return nil
+}
+
+// segment represents log segment file.
+type segment struct {
+	id   int
+	file *os.File
+	size int64 // size of synced records
+}
+
+// position represents position of the record within the log.
+type position struct {
+	segment *segment
+	offset  int64
+	size    int
+}
+
+// open opens log files found in the configured directory and rebuilds records index.
+// Torn tail of the last segment is truncated.
+func (s *Store) open() error {
+	if err := os.MkdirAll(s.cfg.Path, 0o755); err != nil {
+		return err
+	}
+
+	names, err := filepath.Glob(filepath.Join(s.cfg.Path, "*"+segmentExt))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		var id int
+		if _, err := fmt.Sscanf(filepath.Base(name), "%d"+segmentExt, &id); err != nil {
+			return errors.Wrapf(err, "not a valid segment name: %s", name)
+		}
+
+		last := i == len(names)-1
+		if err := s.recover(id, name, last); err != nil {
+			return err
+		}
+	}
+
+	if len(s.segments) == 0 {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	return s.openQuarantine()
+}
+
+// recover indexes records of the segment, torn tail is truncated if it's the last segment.
+func (s *Store) recover(id int, name string, last bool) error {
+	file, err := os.OpenFile(name, os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+
+	seg := &segment{id: id, file: file}
+	s.segments = append(s.segments, seg)
+
+	size, err := scan(file, func(record *schema.Record, offset int64, size int) error {
+		return s.index(record, position{segment: seg, offset: offset, size: size})
+	})
+	switch {
+	case err == errTorn && last:
+		tail, err := torn(file, size)
+		if err != nil {
+			return errors.Wrapf(err, "unable to recover %s", name)
+		}
+		if !tail {
+			return errors.Wrapf(ErrCorrupted, "damaged record in %s at %d", name, size)
+		}
+		if err := file.Truncate(size); err != nil {
+			return errors.Wrapf(err, "unable to truncate torn tail of %s", name)
+		}
+		if err := file.Sync(); err != nil {
+			return err
+		}
+	case err == errTorn:
+		return errors.Wrapf(ErrCorrupted, "damaged record in %s at %d", name, size)
+	case err != nil:
+		return errors.Wrapf(err, "unable to recover %s", name)
+	}
+
+	seg.size = size
+	return nil
+}
+
+// torn reports whether damaged record at the given offset is a torn tail, i.e. no valid record follows it.
+func torn(file *os.File, offset int64) (bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	tail := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return false, err
+	}
+	return !framed(tail[1:]), nil
+}
+
+// index adds position of the record into index, record must follow the last indexed record of its aggregate.
+func (s *Store) index(record *schema.Record, pos position) error {
+	key := stream(record.Aggregate, record.AggregateID)
+	if uint64(len(s.streams[key]))+1 != record.Version {
+		return errors.Wrapf(ErrCorrupted, "%s version %d does not follow %d", key, record.Version, len(s.streams[key]))
+	}
+	if record.Version == 1 {
+		s.ids[record.Aggregate] = append(s.ids[record.Aggregate], record.AggregateID)
+	}
+	s.streams[key] = append(s.streams[key], pos)
+	return nil
+}
+
+// rotate starts a new active segment.
+func (s *Store) rotate() error {
+	id := 1
+	if n := len(s.segments); n > 0 {
+		id = s.segments[n-1].id + 1
+	}
+
+	name := filepath.Join(s.cfg.Path, fmt.Sprintf("%016d%s", id, segmentExt))
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+
+	// segment file entry must be durable before records are appended to it.
+	if err := syncDir(s.cfg.Path); err != nil {
+		file.Close()
+		return err
+	}
+
+	s.mu.Lock()
+	s.segments = append(s.segments, &segment{id: id, file: file})
+	s.mu.Unlock()
+	return nil
+}
+
+// active returns the active segment.
+func (s *Store) active() *segment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.segments[len(s.segments)-1]
+}
+
+// openQuarantine opens quarantine file and loads keys of already quarantined records.
+func (s *Store) openQuarantine() error {
+	file, err := os.OpenFile(filepath.Join(s.cfg.Path, quarantineFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	s.quarantineLog = &segment{file: file}
+
+	size, err := scan(file, func(record *schema.Record, _ int64, _ int) error {
+		s.quarantined[quarantineKey(record)] = true
+		return nil
+	})
+	if err == errTorn {
+		err = file.Truncate(size)
+	}
+	s.quarantineLog.size = size
+	return err
+}
+
+// syncDir syncs directory entries.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// closeFiles closes all log files.
+func (s *Store) closeFiles() error {
+	var first error
+	for _, seg := range s.segments {
+		if err := seg.file.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	if s.quarantineLog != nil {
+		if err := s.quarantineLog.file.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// stream returns key of aggregate stream.
+func stream(aggregate, id string) string {
+	return aggregate + "_" + id
+}
+
+// quarantineKey returns key deduplicating quarantined records.
+func quarantineKey(record *schema.Record) string {
+	return fmt.Sprintf("%s@%d", stream(record.Aggregate, record.AggregateID), record.Version)
+}
@@ -0,0 +1,321 @@
+package filelog_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/filelog"
+	"github.com/deividaspetraitis/ledger/database/schema"
+	"github.com/deividaspetraitis/ledger/database/storetest"
+
+	"github.com/deividaspetraitis/go/errors"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// openStore opens store in the given directory closing it once test completes.
+func openStore(t *testing.T, cfg *filelog.Config) *filelog.Store {
+	store, err := filelog.Open(cfg, nil)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// newWallet returns a new wallet with given deposits applied.
+func newWallet(t *testing.T, amounts ...int) *ledger.WalletAggregate {
+	wallet, err := ledger.NewWallet(&ledger.CreateWalletRequest{Name: "test"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	for _, amount := range amounts {
+		deposit(t, wallet, amount)
+	}
+	return wallet
+}
+
+// deposit applies deposit of the given amount on the wallet.
+func deposit(t *testing.T, wallet *ledger.WalletAggregate, amount int) {
+	if err := wallet.ProcessTransaction(&ledger.Transaction{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: amount}); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+}
+
+// restore restores wallet of the given ID from the store.
+func restore(t *testing.T, store *filelog.Store, id string) *ledger.WalletAggregate {
+	wallet, err := filelog.Get[*ledger.WalletAggregate](context.Background(), store, &ledger.WalletAggregate{}, id)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return wallet
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T, cfg *schema.Config) storetest.Store {
+		store, err := filelog.Open(&filelog.Config{Path: t.TempDir()}, cfg)
+		if err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := openStore(t, &filelog.Config{Path: t.TempDir()})
+
+	wallet := newWallet(t, 100, 25)
+	if err := store.Save(ctx, wallet); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	restored := restore(t, store, wallet.ID)
+	if !cmp.Equal(restored.Wallet, wallet.Wallet) {
+		t.Errorf("got %v, want %v", restored.Wallet, wallet.Wallet)
+	}
+	if restored.ChainHead() != wallet.ChainHead() {
+		t.Errorf("got %v, want %v", restored.ChainHead(), wallet.ChainHead())
+	}
+
+	// restored copy of the wallet gets outdated once the wallet is transacted.
+	deposit(t, wallet, 10)
+	if err := store.Save(ctx, wallet); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	deposit(t, restored, 20)
+	if err := store.Save(ctx, restored); !errors.Is(err, filelog.ErrWrongVersion) {
+		t.Errorf("got %v, want %v", err, filelog.ErrWrongVersion)
+	}
+
+	// aggregate restored earlier is caught up.
+	caught := restore(t, store, wallet.ID)
+	deposit(t, wallet, 5)
+	if err := store.Save(ctx, wallet); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if err := store.Load(ctx, caught, wallet.ID); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if !cmp.Equal(caught.Wallet, wallet.Wallet) {
+		t.Errorf("got %v, want %v", caught.Wallet, wallet.Wallet)
+	}
+
+	if err := store.Load(ctx, &ledger.WalletAggregate{}, "2f7a0d3e-7c2b-4f6e-9a55-3c1d2b4e5f60"); !errors.Is(err, ledger.ErrEntryNotFound) {
+		t.Errorf("got %v, want %v", err, ledger.ErrEntryNotFound)
+	}
+}
+
+func TestStoreConcurrentAppends(t *testing.T) {
+	ctx := context.Background()
+	cfg := filelog.Config{Path: t.TempDir(), SegmentSize: 4096, BatchSize: 4}
+	store := openStore(t, &cfg)
+
+	wallets := make([]*ledger.WalletAggregate, 64)
+	for i := range wallets {
+		wallets[i] = newWallet(t, i+1)
+	}
+
+	var wg sync.WaitGroup
+	for _, v := range wallets {
+		wg.Add(1)
+		go func(wallet *ledger.WalletAggregate) {
+			defer wg.Done()
+			if err := store.Save(ctx, wallet); err != nil {
+				t.Errorf("got %v, want %v", err, nil)
+			}
+		}(v)
+	}
+	wg.Wait()
+	store.Close()
+
+	// log spanning several segments is recovered.
+	segments, _ := filepath.Glob(filepath.Join(cfg.Path, "*.seg"))
+	if len(segments) < 2 {
+		t.Errorf("got %d segments, want more than %d", len(segments), 1)
+	}
+
+	store = openStore(t, &cfg)
+	ids, err := store.IDs(ctx, &ledger.WalletAggregate{})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if len(ids) != len(wallets) {
+		t.Errorf("got %v, want %v", len(ids), len(wallets))
+	}
+	for _, v := range wallets {
+		if restored := restore(t, store, v.ID); !cmp.Equal(restored.Wallet, v.Wallet) {
+			t.Errorf("got %v, want %v", restored.Wallet, v.Wallet)
+		}
+	}
+}
+
+func TestStoreRecovery(t *testing.T) {
+	ctx := context.Background()
+	cfg := filelog.Config{Path: t.TempDir()}
+
+	var testcases = []struct {
+		tail []byte // bytes left by interrupted write
+	}{
+		{[]byte{0x2a, 0x00}}, // torn header
+		{[]byte{0x40, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04}},             // missing payload
+		{[]byte{0x02, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}}, // checksum mismatch
+		{make([]byte, 512)}, // zeroed preallocated space
+	}
+
+	wallet := newWallet(t, 100)
+	for i, tt := range testcases {
+		store, err := filelog.Open(&cfg, nil)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if i == 0 {
+			if err := store.Save(ctx, wallet); err != nil {
+				t.Fatalf("#%d got %v, want %v", i, err, nil)
+			}
+		}
+		store.Close()
+
+		name := filepath.Join(cfg.Path, "0000000000000001.seg")
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		appendFile(t, name, tt.tail)
+
+		store, err = filelog.Open(&cfg, nil)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if restored := restore(t, store, wallet.ID); !cmp.Equal(restored.Wallet, wallet.Wallet) {
+			t.Errorf("#%d got %v, want %v", i, restored.Wallet, wallet.Wallet)
+		}
+		store.Close()
+
+		if recovered, _ := os.Stat(name); recovered.Size() != info.Size() {
+			t.Errorf("#%d got %v, want %v", i, recovered.Size(), info.Size())
+		}
+	}
+
+	// appends continue after recovered tail.
+	store := openStore(t, &cfg)
+	restored := restore(t, store, wallet.ID)
+	deposit(t, restored, 50)
+	if err := store.Save(ctx, restored); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if got := restore(t, store, wallet.ID); got.Balance != 150 {
+		t.Errorf("got %v, want %v", got.Balance, 150)
+	}
+
+	// damaged record followed by valid records is not a torn tail, acknowledged records are kept.
+	cfg = filelog.Config{Path: t.TempDir()}
+	store = openStore(t, &cfg)
+	for i := 0; i < 3; i++ {
+		if err := store.Save(ctx, newWallet(t, 100)); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+	}
+	store.Close()
+
+	name := filepath.Join(cfg.Path, "0000000000000001.seg")
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	data[16] ^= 0xff
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	if _, err := filelog.Open(&cfg, nil); !errors.Is(err, filelog.ErrCorrupted) {
+		t.Errorf("got %v, want %v", err, filelog.ErrCorrupted)
+	}
+	if info, _ := os.Stat(name); info.Size() != int64(len(data)) {
+		t.Errorf("got %v, want %v", info.Size(), len(data))
+	}
+}
+
+func TestOpenCorrupted(t *testing.T) {
+	ctx := context.Background()
+	cfg := filelog.Config{Path: t.TempDir(), SegmentSize: 1}
+
+	// every append starts a new segment.
+	store, err := filelog.Open(&cfg, nil)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.Save(ctx, newWallet(t)); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+	}
+	store.Close()
+
+	name := filepath.Join(cfg.Path, "0000000000000001.seg")
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	if _, err := filelog.Open(&cfg, nil); !errors.Is(err, filelog.ErrCorrupted) {
+		t.Errorf("got %v, want %v", err, filelog.ErrCorrupted)
+	}
+}
+
+func TestStoreClosed(t *testing.T) {
+	store, err := filelog.Open(&filelog.Config{Path: t.TempDir()}, nil)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	if err := store.Save(context.Background(), newWallet(t)); !errors.Is(err, filelog.ErrClosed) {
+		t.Errorf("got %v, want %v", err, filelog.ErrClosed)
+	}
+}
+
+// appendFile appends data to the file.
+func appendFile(t *testing.T, name string, data []byte) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+}
+
+// BenchmarkSave measures wallets saved concurrently, appends of concurrent saves share fsync.
+func BenchmarkSave(b *testing.B) {
+	ctx := context.Background()
+	store, err := filelog.Open(&filelog.Config{Path: b.TempDir()}, nil)
+	if err != nil {
+		b.Fatalf("got %v, want %v", err, nil)
+	}
+	defer store.Close()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			wallet, err := ledger.NewWallet(&ledger.CreateWalletRequest{Name: "test"})
+			if err != nil {
+				b.Fatalf("got %v, want %v", err, nil)
+			}
+			if err := store.Save(ctx, wallet); err != nil {
+				b.Fatalf("got %v, want %v", err, nil)
+			}
+		}
+	})
+}
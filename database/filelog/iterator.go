@@ -0,0 +1,46 @@
+package filelog
+
+import (
+	"context"
+
+	"github.com/deividaspetraitis/ledger/database/schema"
+)
+
+// iterator reads records at given positions, it implements schema.Iterator.
+type iterator struct {
+	ctx       context.Context
+	positions []position
+	record    *schema.Record
+	err       error
+}
+
+// Next implements schema.Iterator.
+func (i *iterator) Next() bool {
+	if i.err != nil || len(i.positions) == 0 {
+		return false
+	}
+	if i.err = i.ctx.Err(); i.err != nil {
+		return false
+	}
+
+	pos := i.positions[0]
+	i.positions = i.positions[1:]
+
+	i.record, i.err = readFrame(pos.segment.file, pos.offset, pos.size)
+	return i.err == nil
+}
+
+// Value implements schema.Iterator.
+func (i *iterator) Value() (*schema.Record, error) {
+	return i.record, i.err
+}
+
+// Error implements schema.Iterator.
+func (i *iterator) Error() error {
+	return i.err
+}
+
+// Close implements schema.Iterator.
+func (i *iterator) Close() {
+	i.positions = nil
+}
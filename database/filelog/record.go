@@ -0,0 +1,218 @@
+package filelog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// Record frame layout: payload length and CRC-32C checksum of the payload, both little endian, followed by the payload.
+const (
+	headerSize     = 8
+	maxPayloadSize = 64 << 20 // larger payload means frame header is garbage
+)
+
+// ErrCorrupted is returned when a record within a sealed segment or followed by a valid record fails its checksum or does not follow its stream.
+var ErrCorrupted = errors.New("event log is corrupted")
+
+// errTorn is returned by scan when a record is incomplete or fails its checksum.
+var errTorn = errors.New("torn record")
+
+// crc is CRC-32C table used to checksum records.
+var crc = crc32.MakeTable(crc32.Castagnoli)
+
+// appendFrame appends framed record to buf.
+func appendFrame(buf []byte, r *schema.Record) []byte {
+	payload := encode(r)
+
+	var header [headerSize]byte
+	binary.LittleEndian.PutUint32(header[:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(header[4:], crc32.Checksum(payload, crc))
+
+	buf = append(buf, header[:]...)
+	return append(buf, payload...)
+}
+
+// encode encodes record into payload.
+// Byte slices are prefixed with length incremented by one, so nil and empty slices are told apart.
+func encode(r *schema.Record) []byte {
+	var b []byte
+	b = appendBytes(b, []byte(r.Aggregate))
+	b = appendBytes(b, []byte(r.AggregateID))
+	b = binary.AppendUvarint(b, r.Version)
+	b = appendBytes(b, []byte(r.Type))
+	b = binary.AppendVarint(b, r.Timestamp.UnixNano())
+	b = appendBytes(b, r.Data)
+	return appendBytes(b, r.Metadata)
+}
+
+// appendBytes appends length prefixed byte slice to b.
+func appendBytes(b, v []byte) []byte {
+	if v == nil {
+		return binary.AppendUvarint(b, 0)
+	}
+	b = binary.AppendUvarint(b, uint64(len(v))+1)
+	return append(b, v...)
+}
+
+// decoder decodes record payload, the first error is kept and stops decoding.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+// uvarint decodes unsigned integer.
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = ErrCorrupted
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+// varint decodes signed integer.
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = ErrCorrupted
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+// bytes decodes length prefixed byte slice.
+func (d *decoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil || n == 0 {
+		return nil
+	}
+	if n-1 > uint64(len(d.b)) {
+		d.err = ErrCorrupted
+		return nil
+	}
+	v := append([]byte{}, d.b[:n-1]...)
+	d.b = d.b[n-1:]
+	return v
+}
+
+// decode decodes record payload.
+func decode(payload []byte) (*schema.Record, error) {
+	d := decoder{b: payload}
+
+	r := schema.Record{
+		Aggregate:   string(d.bytes()),
+		AggregateID: string(d.bytes()),
+		Version:     d.uvarint(),
+		Type:        string(d.bytes()),
+		Timestamp:   time.Unix(0, d.varint()).UTC(),
+		Data:        d.bytes(),
+		Metadata:    d.bytes(),
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return &r, nil
+}
+
+// readFrame reads framed record at the given offset of the segment.
+func readFrame(r io.ReaderAt, offset int64, size int) (*schema.Record, error) {
+	frame := make([]byte, size)
+	if _, err := r.ReadAt(frame, offset); err != nil {
+		return nil, err
+	}
+	payload, err := verify(frame[:headerSize], frame[headerSize:])
+	if err != nil {
+		return nil, err
+	}
+	return decode(payload)
+}
+
+// verify verifies payload against frame header.
+func verify(header, payload []byte) ([]byte, error) {
+	if int(binary.LittleEndian.Uint32(header[:4])) != len(payload) || binary.LittleEndian.Uint32(header[4:]) != crc32.Checksum(payload, crc) {
+		return nil, ErrCorrupted
+	}
+	return payload, nil
+}
+
+// framed reports whether b holds a valid record frame at any offset.
+func framed(b []byte) bool {
+	for i := 0; i+headerSize < len(b); i++ {
+		n := binary.LittleEndian.Uint32(b[i : i+4])
+		if n == 0 || n > maxPayloadSize || int(n) > len(b)-i-headerSize {
+			continue
+		}
+		payload := b[i+headerSize : i+headerSize+int(n)]
+		if _, err := verify(b[i:i+headerSize], payload); err != nil {
+			continue
+		}
+		if _, err := decode(payload); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// scan reads framed records one by one calling fn with record, its offset and frame size.
+// It returns length of the valid part of the segment, errTorn if the segment ends with incomplete or damaged record.
+func scan(r io.Reader, fn func(record *schema.Record, offset int64, size int) error) (int64, error) {
+	br := bufio.NewReader(r)
+
+	var (
+		offset int64
+		header [headerSize]byte
+	)
+	for {
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			if err == io.EOF {
+				return offset, nil
+			}
+			if err == io.ErrUnexpectedEOF {
+				return offset, errTorn
+			}
+			return offset, err
+		}
+
+		n := binary.LittleEndian.Uint32(header[:4])
+		if n == 0 || n > maxPayloadSize {
+			return offset, errTorn
+		}
+
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(br, payload); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return offset, errTorn
+			}
+			return offset, err
+		}
+		if _, err := verify(header[:], payload); err != nil {
+			return offset, errTorn
+		}
+
+		record, err := decode(payload)
+		if err != nil {
+			return offset, errTorn
+		}
+
+		size := headerSize + int(n)
+		if err := fn(record, offset, size); err != nil {
+			return offset, err
+		}
+		offset += int64(size)
+	}
+}
@@ -0,0 +1,364 @@
+package filelog
+
+import (
+	"context"
+	"sync"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// ErrWrongVersion is returned when appended events do not follow the last persisted event of the aggregate,
+// i.e. aggregate was modified concurrently. It's ledger.ErrVersionConflict, so callers do not depend on the backend.
+var ErrWrongVersion = ledger.ErrVersionConflict
+
+// Store persists and restores aggregates using segmented append-only log files.
+type Store struct {
+	cfg      Config
+	restorer schema.Restorer
+
+	mu       sync.RWMutex
+	segments []*segment
+	streams  map[string][]position // record positions by aggregate stream, position i holds version i+1
+	ids      map[string][]string   // aggregate IDs by aggregate type in order they were created
+
+	qmu           sync.Mutex
+	quarantineLog *segment
+	quarantined   map[string]bool // keys of quarantined records
+
+	requests  chan *request
+	done      chan struct{} // closed once store is being closed
+	stopped   chan struct{} // closed once writer has stopped
+	closeOnce sync.Once
+	err       error // error which broke the log, owned by the writer
+}
+
+// request represents records of a single aggregate queued to be appended.
+type request struct {
+	records []*schema.Record
+	result  chan error
+}
+
+// Open opens log in the configured directory, recovers its state and starts accepting appends.
+// If some of cfg values are not set defaults are used.
+// If events is nil or unknown events policy is not set schema.PolicyFail is used.
+func Open(cfg *Config, events *schema.Config) (*Store, error) {
+	if cfg == nil {
+		return nil, errors.New("log directory path is required")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	policy := schema.PolicyFail
+	if events != nil && len(events.Unknown) > 0 {
+		policy = events.Unknown
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := Store{
+		cfg:         *cfg,
+		streams:     make(map[string][]position),
+		ids:         make(map[string][]string),
+		quarantined: make(map[string]bool),
+		requests:    make(chan *request),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	if s.cfg.SegmentSize <= 0 {
+		s.cfg.SegmentSize = defaultSegmentSize
+	}
+	if s.cfg.BatchSize < 1 {
+		s.cfg.BatchSize = defaultBatchSize
+	}
+	s.restorer = schema.Restorer{
+		Policy:     policy,
+		Quarantine: s.quarantine,
+		Cutover:    events.Cutover(),
+	}
+
+	if err := s.open(); err != nil {
+		s.closeFiles()
+		return nil, err
+	}
+
+	go s.run()
+
+	return &s, nil
+}
+
+// Close stops accepting appends, waits for the pending write to finish and closes log files.
+func (s *Store) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		<-s.stopped
+		err = s.closeFiles()
+	})
+	return err
+}
+
+// Save persists an aggregate into underlying DB store.
+// Save calls aggregate.Sync if store operations were successful.
+// Save implements database.SaveAggregateFunc.
+func (s *Store) Save(ctx context.Context, aggregate es.Aggregate) error {
+	processed := aggregate.Events()
+
+	records, head, err := schema.Encode(aggregate, processed)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Append(ctx, records); err != nil {
+		return err
+	}
+
+	// mark recently stored events as processed.
+	for _, v := range processed {
+		if err := aggregate.Sync(v); err != nil {
+			return err
+		}
+	}
+
+	if chained, ok := aggregate.(schema.Chained); ok && len(records) > 0 {
+		chained.SetChainHead(head)
+	}
+
+	return nil
+}
+
+// Append persists already encoded records of a single aggregate preserving their versions, timestamps and metadata.
+// Records must follow the last persisted record of the aggregate, otherwise ErrWrongVersion is returned and nothing is persisted.
+// Append returns once records are synced to disk.
+// Append implements archive.Sink.
+func (s *Store) Append(ctx context.Context, records []*schema.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	req := &request{
+		records: records,
+		result:  make(chan error, 1),
+	}
+	select {
+	case s.requests <- req:
+	case <-s.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// once received request is always replied to.
+	return <-req.result
+}
+
+// run writes queued appends until store is closed.
+func (s *Store) run() {
+	defer close(s.stopped)
+
+	for {
+		select {
+		case req := <-s.requests:
+			batch := []*request{req}
+		drain:
+			for len(batch) < s.cfg.BatchSize {
+				select {
+				case req := <-s.requests:
+					batch = append(batch, req)
+				default:
+					break drain
+				}
+			}
+			s.write(batch)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// write writes records of accepted appends into the active segment, syncs it once and indexes written records.
+func (s *Store) write(batch []*request) {
+	reply := func(reqs []*request, err error) {
+		for _, req := range reqs {
+			req.result <- err
+		}
+	}
+
+	if s.err != nil {
+		reply(batch, s.err)
+		return
+	}
+
+	seg := s.active()
+	if seg.size >= s.cfg.SegmentSize {
+		if err := s.rotate(); err != nil {
+			reply(batch, errors.Wrap(err, "unable to start a new segment"))
+			return
+		}
+		seg = s.active()
+	}
+
+	var (
+		buf       []byte
+		accepted  []*request
+		positions [][]position
+		pending   = make(map[string]uint64) // last versions of streams appended within the batch
+	)
+	for _, req := range batch {
+		if err := s.check(req.records, pending); err != nil {
+			req.result <- err
+			continue
+		}
+
+		var pos []position
+		for _, r := range req.records {
+			n := len(buf)
+			buf = appendFrame(buf, r)
+			pos = append(pos, position{segment: seg, offset: seg.size + int64(n), size: len(buf) - n})
+		}
+
+		last := req.records[len(req.records)-1]
+		pending[stream(last.Aggregate, last.AggregateID)] = last.Version
+
+		accepted = append(accepted, req)
+		positions = append(positions, pos)
+	}
+
+	if len(accepted) == 0 {
+		return
+	}
+
+	_, err := seg.file.WriteAt(buf, seg.size)
+	if err == nil {
+		err = seg.file.Sync()
+	}
+	if err != nil {
+		// drop partially written records, so they are not recovered later.
+		if terr := seg.file.Truncate(seg.size); terr != nil {
+			s.err = errors.Wrap(terr, "event log is broken, restart is required")
+		}
+		reply(accepted, errors.Wrap(err, "unable to write event log"))
+		return
+	}
+
+	s.mu.Lock()
+	seg.size += int64(len(buf))
+	for i, req := range accepted {
+		for j, r := range req.records {
+			s.index(r, positions[i][j]) // nolint: records were checked
+		}
+	}
+	s.mu.Unlock()
+
+	reply(accepted, nil)
+}
+
+// check checks that records belong to a single aggregate and follow its last persisted or pending record.
+func (s *Store) check(records []*schema.Record, pending map[string]uint64) error {
+	first := records[0]
+	key := stream(first.Aggregate, first.AggregateID)
+
+	last, ok := pending[key]
+	if !ok {
+		s.mu.RLock()
+		last = uint64(len(s.streams[key]))
+		s.mu.RUnlock()
+	}
+	if first.Version != last+1 {
+		return ErrWrongVersion
+	}
+
+	for i, r := range records {
+		if r.Aggregate != first.Aggregate || r.AggregateID != first.AggregateID || r.Version != first.Version+uint64(i) {
+			return errors.New("records must be contiguous records of a single aggregate")
+		}
+	}
+	return nil
+}
+
+// Load restores aggregate state from underlying DB store.
+// Only events following current aggregate version are read, so aggregate restored earlier is caught up.
+// If aggregate does not exist ledger.ErrEntryNotFound is returned.
+func (s *Store) Load(ctx context.Context, aggregate es.Aggregate, id string) error {
+	it := s.records(ctx, aggregate, id, uint64(aggregate.Root().Version()))
+
+	if err := s.restorer.Restore(ctx, aggregate, id, it); err != nil {
+		return err
+	}
+
+	// no events for given aggregate were found
+	// meaning such aggregate does not exit
+	if aggregate.Root().Version() == 0 {
+		return ledger.ErrEntryNotFound
+	}
+
+	return nil
+}
+
+// Get retrieves aggregate with restored state from underlying DB store.
+func Get[T any](ctx context.Context, s *Store, aggregate es.Aggregate, id string) (T, error) {
+	if err := s.Load(ctx, aggregate, id); err != nil {
+		return *new(T), err
+	}
+	return aggregate.(T), nil
+}
+
+// Records returns iterator over all persisted records of the aggregate as they are stored, without decoding them.
+// Records implements archive.Source.
+func (s *Store) Records(ctx context.Context, aggregate es.Aggregate, id string) (schema.Iterator, error) {
+	return s.records(ctx, aggregate, id, 0), nil
+}
+
+// RecordsAfter returns iterator over persisted records of the aggregate following the given version as they are stored.
+func (s *Store) RecordsAfter(ctx context.Context, aggregate es.Aggregate, id string, after uint64) (schema.Iterator, error) {
+	return s.records(ctx, aggregate, id, after), nil
+}
+
+// records returns iterator over persisted records of the aggregate following the given version.
+func (s *Store) records(ctx context.Context, aggregate es.Aggregate, id string, after uint64) schema.Iterator {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var positions []position
+	if v := s.streams[stream(es.ParseAggregateName(aggregate), id)]; after < uint64(len(v)) {
+		positions = append(positions, v[after:]...)
+	}
+	return &iterator{ctx: ctx, positions: positions}
+}
+
+// IDs returns IDs of all persisted aggregates of the aggregate type in order they were created.
+// IDs implements archive.Source.
+func (s *Store) IDs(ctx context.Context, aggregate es.Aggregate) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string{}, s.ids[es.ParseAggregateName(aggregate)]...), nil
+}
+
+// quarantine appends unknown record to quarantine file, repeatedly quarantined record is kept once.
+// quarantine implements schema.QuarantineFunc.
+func (s *Store) quarantine(ctx context.Context, record *schema.Record) error {
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+
+	key := quarantineKey(record)
+	if s.quarantined[key] {
+		return nil
+	}
+
+	buf := appendFrame(nil, record)
+	if _, err := s.quarantineLog.file.WriteAt(buf, s.quarantineLog.size); err != nil {
+		return err
+	}
+	if err := s.quarantineLog.file.Sync(); err != nil {
+		return err
+	}
+	s.quarantineLog.size += int64(len(buf))
+	s.quarantined[key] = true
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package schema
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// ErrChainBroken is returned when hash chain of persisted events does not verify.
+var ErrChainBroken = errors.New("events hash chain is broken")
+
+// ChainError is returned when persisted event does not match its hash chain link.
+type ChainError struct {
+	AggregateID string // Aggregate the event belongs to
+	Version     uint64 // Event version within the aggregate stream
+	Reason      string // Human readable reason
+}
+
+// Error implements error.
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("%v: %s at version %d of %s", ErrChainBroken, e.Reason, e.Version, e.AggregateID)
+}
+
+// Is reports whether target is ErrChainBroken.
+func (e *ChainError) Is(target error) bool {
+	return target == ErrChainBroken
+}
+
+// Link represents hash chain link persisted in event metadata.
+type Link struct {
+	Hash string `json:"hash"` // Hash of the event payload and previous link hash
+	Prev string `json:"prev"` // Hash of the previous event, empty for the first chained event
+}
+
+// Chained is implemented by aggregates tracking hash chain head of their persisted events.
+type Chained interface {
+	// ChainHead returns hash of the last persisted event.
+	ChainHead() string
+
+	// SetChainHead sets hash of the last persisted event.
+	SetChainHead(hash string)
+}
+
+// Chain is embeddable implementation of Chained.
+type Chain struct {
+	head string
+}
+
+// ChainHead implements Chained.
+func (c *Chain) ChainHead() string {
+	return c.head
+}
+
+// SetChainHead implements Chained.
+func (c *Chain) SetChainHead(hash string) {
+	c.head = hash
+}
+
+// hash returns hash of the record linked to the previous record hash.
+// Aggregate ID, version and type are hashed along the payload so records can not be moved around.
+func hash(prev string, record *Record) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%d\n%s\n", prev, record.AggregateID, record.Version, record.Type)
+	h.Write(record.Data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Seal links records into hash chain following prev hash by writing links into records metadata.
+// It returns hash of the last record which becomes the new chain head.
+func Seal(prev string, records []*Record) (string, error) {
+	for _, record := range records {
+		link := Link{
+			Hash: hash(prev, record),
+			Prev: prev,
+		}
+
+		metadata, err := json.Marshal(&link)
+		if err != nil {
+			return "", err
+		}
+
+		record.Metadata = metadata
+		prev = link.Hash
+	}
+	return prev, nil
+}
+
+// Verify verifies that record is linked to prev hash and returns record hash.
+// Records persisted before chaining was introduced are not linked, they are accepted only
+// at the beginning of the stream, i.e. when prev hash is empty, and only if they were persisted
+// before the cutover. Zero cutover requires every record to be linked.
+func Verify(prev string, record *Record, cutover time.Time) (string, error) {
+	var link Link
+	if len(record.Metadata) > 0 {
+		if err := json.Unmarshal(record.Metadata, &link); err != nil {
+			return "", &ChainError{AggregateID: record.AggregateID, Version: record.Version, Reason: "malformed link"}
+		}
+	}
+
+	if len(link.Hash) == 0 {
+		if len(prev) > 0 || !record.Timestamp.Before(cutover) {
+			return "", &ChainError{AggregateID: record.AggregateID, Version: record.Version, Reason: "missing link"}
+		}
+		return "", nil
+	}
+
+	if link.Prev != prev {
+		return "", &ChainError{AggregateID: record.AggregateID, Version: record.Version, Reason: "previous hash mismatch"}
+	}
+
+	if link.Hash != hash(prev, record) {
+		return "", &ChainError{AggregateID: record.AggregateID, Version: record.Version, Reason: "hash mismatch"}
+	}
+
+	return link.Hash, nil
+}
+
+// SignedHead represents signed hash chain head of an aggregate exportable for external notarization.
+type SignedHead struct {
+	AggregateID string    // Aggregate ID
+	Version     uint64    // Version of the last event
+	Hash        string    // Hash of the last event
+	SignedAt    time.Time // Signature time
+	Signature   string    // Base64 encoded ed25519 signature of the Payload, empty if signing is not configured
+	PublicKey   string    // Base64 encoded ed25519 public key verifying the Signature
+}
+
+// Payload returns signed head payload.
+func (h *SignedHead) Payload() []byte {
+	return []byte("ledger-chain-head\n" + h.AggregateID + "\n" + strconv.FormatUint(h.Version, 10) + "\n" + h.Hash + "\n" + h.SignedAt.Format(time.RFC3339Nano))
+}
+
+// Signer signs hash chain heads.
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewSigner constructs a new Signer reading PEM encoded PKCS #8 ed25519 private key from the file at path.
+// Such key can be generated by running: openssl genpkey -algorithm ed25519
+func NewSigner(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read signing key: %s", path)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.Newf("no PEM data found in signing key: %s", path)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse signing key: %s", path)
+	}
+
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.Newf("signing key is not an ed25519 key: %s", path)
+	}
+
+	return &Signer{key: ed}, nil
+}
+
+// Sign constructs and returns signed chain head.
+// It's safe to call Sign on nil Signer, such signer returns unsigned head.
+func (s *Signer) Sign(aggregateID string, version uint64, hash string) *SignedHead {
+	head := SignedHead{
+		AggregateID: aggregateID,
+		Version:     version,
+		Hash:        hash,
+		SignedAt:    time.Now().UTC(),
+	}
+
+	if s != nil {
+		head.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, head.Payload()))
+		head.PublicKey = base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+	}
+
+	return &head
+}
@@ -0,0 +1,218 @@
+package schema_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// sealedRecords returns wallet records linked into hash chain along the chain head.
+func sealedRecords(t *testing.T) ([]*schema.Record, string) {
+	wallet, err := ledger.NewWallet(&ledger.CreateWalletRequest{Name: "test"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	for _, tx := range []string{ledger.TransactionDeposit, ledger.TransactionWithdraw} {
+		if err := wallet.ProcessTransaction(&ledger.Transaction{Type: tx, WalletID: wallet.ID, Amount: 10}); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+	}
+
+	records, head, err := schema.Encode(wallet, wallet.Events())
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return records, head
+}
+
+func TestChainVerify(t *testing.T) {
+	var testcases = []struct {
+		name    string
+		tamper  func(records []*schema.Record) []*schema.Record
+		cutover time.Time
+		err     error
+	}{
+		{
+			name:   "untouched",
+			tamper: func(records []*schema.Record) []*schema.Record { return records },
+		},
+		{
+			name: "edited payload",
+			tamper: func(records []*schema.Record) []*schema.Record {
+				records[1].Data = []byte(`{"wallet_id":"` + records[1].AggregateID + `","amount":1000}`)
+				return records
+			},
+			err: schema.ErrChainBroken,
+		},
+		{
+			name: "removed event",
+			tamper: func(records []*schema.Record) []*schema.Record {
+				return append(records[:1], records[2:]...)
+			},
+			err: schema.ErrChainBroken,
+		},
+		{
+			name: "stripped link",
+			tamper: func(records []*schema.Record) []*schema.Record {
+				records[2].Metadata = nil
+				return records
+			},
+			err: schema.ErrChainBroken,
+		},
+		{
+			name: "reordered events",
+			tamper: func(records []*schema.Record) []*schema.Record {
+				records[1].Version, records[2].Version = records[2].Version, records[1].Version
+				records[1], records[2] = records[2], records[1]
+				return records
+			},
+			err: schema.ErrChainBroken,
+		},
+		{
+			name: "stripped links",
+			tamper: func(records []*schema.Record) []*schema.Record {
+				for _, r := range records {
+					r.Metadata = nil
+				}
+				return records
+			},
+			err: schema.ErrChainBroken,
+		},
+		{
+			name: "stripped links persisted after cutover",
+			tamper: func(records []*schema.Record) []*schema.Record {
+				for _, r := range records {
+					r.Metadata = nil
+				}
+				return records
+			},
+			cutover: time.Now().Add(-time.Hour),
+			err:     schema.ErrChainBroken,
+		},
+		{
+			name: "events persisted before chaining",
+			tamper: func(records []*schema.Record) []*schema.Record {
+				// chain starts right after the legacy event
+				records[0].Metadata = nil
+				_, _ = schema.Seal("", records[1:])
+				return records
+			},
+			cutover: time.Now().Add(time.Hour),
+		},
+		{
+			name: "events persisted before chaining without cutover",
+			tamper: func(records []*schema.Record) []*schema.Record {
+				records[0].Metadata = nil
+				_, _ = schema.Seal("", records[1:])
+				return records
+			},
+			err: schema.ErrChainBroken,
+		},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			records, _ := sealedRecords(t)
+
+			var (
+				head string
+				err  error
+			)
+			for _, r := range tt.tamper(records) {
+				if head, err = schema.Verify(head, r, tt.cutover); err != nil {
+					break
+				}
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRestoreChain(t *testing.T) {
+	records, head := sealedRecords(t)
+
+	// restoring verifies chain and sets aggregate chain head
+	var wallet ledger.WalletAggregate
+	if err := (&schema.Restorer{}).Restore(context.Background(), &wallet, records[0].AggregateID, &sliceIterator{records: records}); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if wallet.ChainHead() != head {
+		t.Errorf("head got %v, want %v", wallet.ChainHead(), head)
+	}
+
+	// cloned aggregate continues the chain
+	clone := wallet.Clone()
+	if err := clone.ProcessTransaction(&ledger.Transaction{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: 1}); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	next, _, err := schema.Encode(clone, clone.Events())
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if _, err := schema.Verify(head, next[0], time.Time{}); err != nil {
+		t.Errorf("got %v, want %v", err, nil)
+	}
+
+	// tampered stream fails to restore
+	records[1].Data = []byte(`{}`)
+	var tampered ledger.WalletAggregate
+	err = (&schema.Restorer{}).Restore(context.Background(), &tampered, records[0].AggregateID, &sliceIterator{records: records})
+	if !errors.Is(err, schema.ErrChainBroken) {
+		t.Errorf("got %v, want %v", err, schema.ErrChainBroken)
+	}
+}
+
+func TestSigner(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	signer, err := schema.NewSigner(path)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	head := signer.Sign("wallet", 3, "hash")
+
+	signature, err := base64.StdEncoding.DecodeString(head.Signature)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if !ed25519.Verify(public, head.Payload(), signature) {
+		t.Errorf("signature does not verify")
+	}
+	if head.PublicKey != base64.StdEncoding.EncodeToString(public) {
+		t.Errorf("public key got %v, want %v", head.PublicKey, base64.StdEncoding.EncodeToString(public))
+	}
+
+	// nil signer exports unsigned heads
+	if head := (*schema.Signer)(nil).Sign("wallet", 3, "hash"); len(head.Signature) > 0 {
+		t.Errorf("signature got %v, want empty", head.Signature)
+	}
+}
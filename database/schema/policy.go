@@ -0,0 +1,54 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// Policy defines how unknown events are handled when aggregate is restored.
+type Policy string
+
+// Supported unknown events policies.
+const (
+	PolicyFail       Policy = "fail"       // restoring aggregate fails with *UnknownEventError
+	PolicySkip       Policy = "skip"       // event is skipped and aggregate is flagged
+	PolicyQuarantine Policy = "quarantine" // event is copied aside, skipped and aggregate is flagged
+)
+
+// ErrNotValidPolicy is returned when unknown events policy is not supported.
+var ErrNotValidPolicy = errors.New("given unknown events policy is not supported")
+
+// Validate implements validator.Validator.
+func (p Policy) Validate() error {
+	switch p {
+	case PolicyFail, PolicySkip, PolicyQuarantine:
+		return nil
+	default:
+		return ErrNotValidPolicy
+	}
+}
+
+// UnknownEventError is returned when persisted event can not be decoded into any of registered aggregate events.
+type UnknownEventError struct {
+	AggregateID string // Aggregate the event belongs to
+	Version     uint64 // Event version within the aggregate stream
+	Type        string // Persisted event type
+	Err         error  // Underlying decoding error
+}
+
+// Error implements error.
+func (e *UnknownEventError) Error() string {
+	return fmt.Sprintf("unknown event %s at version %d of %s: %v", e.Type, e.Version, e.AggregateID, e.Err)
+}
+
+// Unwrap returns underlying error.
+func (e *UnknownEventError) Unwrap() error {
+	return e.Err
+}
+
+// Flagger is implemented by aggregates recording integrity issues found while their state was restored.
+type Flagger interface {
+	// Flag marks aggregate as restored despite the given error.
+	Flag(err error)
+}
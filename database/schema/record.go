@@ -0,0 +1,68 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// Record represents persisted event.
+type Record struct {
+	AggregateID string    // Aggregate ID
+	Aggregate   string    // Aggregate type name
+	Version     uint64    // Event version within aggregate stream, starting at 1
+	Type        string    // Versioned event type name
+	Timestamp   time.Time // Event creation time
+	Data        []byte    // Event payload
+	Metadata    []byte    // Event metadata
+}
+
+// Iterator iterates over persisted records of an aggregate stream.
+type Iterator interface {
+	// Next steps to the next record, it returns false once there are no more records or an error occurred.
+	Next() bool
+
+	// Value returns current record.
+	Value() (*Record, error)
+
+	// Error returns error occurred during iteration, if any.
+	Error() error
+
+	// Close releases resources held by the iterator.
+	Close()
+}
+
+// Encode encodes aggregate events into records to be persisted.
+// If aggregate implements Chained records are linked into its hash chain, the new chain head is returned
+// and it's up to the caller to set it once records are persisted.
+func Encode(aggregate es.Aggregate, events []*es.Event) ([]*Record, string, error) {
+	var records []*Record
+	for _, v := range events {
+		data, err := v.Data.MarshalJSON()
+		if err != nil {
+			return nil, "", errors.Wrap(err, "failed to serialise")
+		}
+
+		records = append(records, &Record{
+			AggregateID: v.AggregateID,
+			Aggregate:   es.ParseAggregateName(v.Aggregate),
+			Version:     uint64(v.Version),
+			Type:        TypeName(v.Data),
+			Timestamp:   v.Timestamp,
+			Data:        data,
+			Metadata:    v.Metadata,
+		})
+	}
+
+	chained, ok := aggregate.(Chained)
+	if !ok {
+		return records, "", nil
+	}
+
+	head, err := Seal(chained.ChainHead(), records)
+	if err != nil {
+		return nil, "", err
+	}
+	return records, head, nil
+}
@@ -0,0 +1,135 @@
+package schema
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+	"github.com/deividaspetraitis/go/log"
+)
+
+// skipped counts skipped unknown events, keyed by event type.
+var skipped = expvar.NewMap("skipped_events")
+
+// QuarantineFunc copies unknown record aside for later inspection.
+type QuarantineFunc func(ctx context.Context, record *Record) error
+
+// Restorer restores aggregates state from persisted records.
+type Restorer struct {
+	Policy     Policy         // Unknown events policy, defaults to PolicyFail
+	Quarantine QuarantineFunc // Required by PolicyQuarantine
+	Cutover    time.Time      // Time since which every event must be linked into hash chain, see Verify
+}
+
+// Restore restores aggregate state from records read from the iterator.
+// Unknown events are handled according to the policy, aggregate is flagged if it
+// implements Flagger and any of its events were skipped.
+// If aggregate implements Chained records hash chain is verified starting at the aggregate chain head,
+// broken chain results in *ChainError.
+func (r *Restorer) Restore(ctx context.Context, aggregate es.Aggregate, id string, iterator Iterator) error {
+	defer iterator.Close()
+
+	var (
+		events []*es.Event
+		flags  []error
+	)
+
+	chained, verify := aggregate.(Chained)
+
+	var head string
+	if verify {
+		head = chained.ChainHead()
+	}
+	for iterator.Next() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		record, err := iterator.Value()
+		if err != nil {
+			return err
+		}
+
+		if verify {
+			if head, err = Verify(head, record, r.Cutover); err != nil {
+				return err
+			}
+		}
+
+		event, err := Decode(aggregate, record.Type, record.Data)
+		if err != nil {
+			if !errors.Is(err, ErrUnknownEvent) {
+				return err
+			}
+
+			unknown := &UnknownEventError{
+				AggregateID: id,
+				Version:     record.Version,
+				Type:        record.Type,
+				Err:         err,
+			}
+			if err := r.unknown(ctx, record, unknown); err != nil {
+				return err
+			}
+			flags = append(flags, unknown)
+
+			// version must be advanced to keep aggregate in line with the stream.
+			aggregate.Root().AdvanceVersion()
+			continue
+		}
+
+		events = append(events, es.NewEvent(id, aggregate, event))
+	}
+
+	if err := iterator.Error(); err != nil {
+		return err
+	}
+
+	// reconstruct state
+	if err := aggregate.Reply(events); err != nil {
+		return err
+	}
+
+	if verify {
+		chained.SetChainHead(head)
+	}
+
+	if flagger, ok := aggregate.(Flagger); ok {
+		for _, v := range flags {
+			flagger.Flag(v)
+		}
+	}
+
+	return nil
+}
+
+// unknown handles unknown record according to the policy.
+// It returns nil if record should be skipped.
+func (r *Restorer) unknown(ctx context.Context, record *Record, err *UnknownEventError) error {
+	switch r.Policy {
+	case PolicySkip:
+	case PolicyQuarantine:
+		if r.Quarantine == nil {
+			return errors.New("quarantine is not configured")
+		}
+		if err := r.Quarantine(ctx, record); err != nil {
+			return errors.Wrap(err, "unable to quarantine an event")
+		}
+	default:
+		return err
+	}
+
+	skipped.Add(record.Type, 1)
+
+	log.WithError(err).WithFields(log.Fields{
+		"aggregate": record.AggregateID,
+		"version":   record.Version,
+		"policy":    r.Policy,
+	}).Warnln("unknown event skipped")
+
+	return nil
+}
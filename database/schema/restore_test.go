@@ -0,0 +1,158 @@
+package schema_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// sliceIterator iterates over records slice.
+type sliceIterator struct {
+	records []*schema.Record
+	i       int
+	closed  bool
+}
+
+func (s *sliceIterator) Next() bool {
+	if s.i >= len(s.records) {
+		return false
+	}
+	s.i++
+	return true
+}
+
+func (s *sliceIterator) Value() (*schema.Record, error) { return s.records[s.i-1], nil }
+func (s *sliceIterator) Error() error                   { return nil }
+func (s *sliceIterator) Close()                         { s.closed = true }
+
+// fixtureRecords returns fixture stream records with an unknown event in the middle.
+func fixtureRecords(t *testing.T) []*schema.Record {
+	var records []*schema.Record
+	for i, r := range readFixture(t, "v2.jsonl") {
+		records = append(records, &schema.Record{
+			AggregateID: fixtureWalletID,
+			Aggregate:   "WalletAggregate",
+			Type:        r.Type,
+			Data:        r.Data,
+		})
+
+		if i == 1 {
+			records = append(records, &schema.Record{
+				AggregateID: fixtureWalletID,
+				Aggregate:   "WalletAggregate",
+				Type:        "Refund.v1",
+				Data:        json.RawMessage(`{"amount":100}`),
+			})
+		}
+	}
+
+	for i, r := range records {
+		r.Version = uint64(i + 1)
+	}
+	if _, err := schema.Seal("", records); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return records
+}
+
+func TestRestoreUnknownEvents(t *testing.T) {
+	var testcases = []struct {
+		policy      schema.Policy
+		err         error
+		degraded    bool
+		quarantined int
+	}{
+		{schema.PolicyFail, schema.ErrUnknownEvent, false, 0},
+		{"", schema.ErrUnknownEvent, false, 0}, // defaults to fail
+		{schema.PolicySkip, nil, true, 0},
+		{schema.PolicyQuarantine, nil, true, 1},
+	}
+
+	for _, tt := range testcases {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			var quarantined []*schema.Record
+			restorer := schema.Restorer{
+				Policy: tt.policy,
+				Quarantine: func(ctx context.Context, record *schema.Record) error {
+					quarantined = append(quarantined, record)
+					return nil
+				},
+			}
+
+			var wallet ledger.WalletAggregate
+			it := &sliceIterator{records: fixtureRecords(t)}
+
+			err := restorer.Restore(context.Background(), &wallet, fixtureWalletID, it)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if !it.closed {
+				t.Errorf("iterator was not closed")
+			}
+			if len(quarantined) != tt.quarantined {
+				t.Errorf("quarantined got %v, want %v", len(quarantined), tt.quarantined)
+			}
+
+			if err != nil {
+				unknown, ok := err.(*schema.UnknownEventError)
+				if !ok {
+					t.Fatalf("got %T, want %T", err, unknown)
+				}
+				if unknown.Type != "Refund.v1" || unknown.Version != 3 || unknown.AggregateID != fixtureWalletID {
+					t.Errorf("got %v", unknown)
+				}
+				return
+			}
+
+			if wallet.Degraded != tt.degraded {
+				t.Errorf("degraded got %v, want %v", wallet.Degraded, tt.degraded)
+			}
+			if wallet.Balance != 125 {
+				t.Errorf("balance got %v, want %v", wallet.Balance, 125)
+			}
+
+			// skipped event still counts towards aggregate version
+			if version := wallet.Root().Version(); version != es.Version(5) {
+				t.Errorf("version got %v, want %v", version, 5)
+			}
+		})
+	}
+}
+
+func TestRestoreQuarantineFailure(t *testing.T) {
+	restorer := schema.Restorer{
+		Policy: schema.PolicyQuarantine,
+		Quarantine: func(ctx context.Context, record *schema.Record) error {
+			return errors.New("store is down")
+		},
+	}
+
+	var wallet ledger.WalletAggregate
+	if err := restorer.Restore(context.Background(), &wallet, fixtureWalletID, &sliceIterator{records: fixtureRecords(t)}); err == nil {
+		t.Errorf("got %v, want an error", err)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	var testcases = []struct {
+		policy schema.Policy
+		err    error
+	}{
+		{schema.PolicyFail, nil},
+		{schema.PolicySkip, nil},
+		{schema.PolicyQuarantine, nil},
+		{"ignore", schema.ErrNotValidPolicy},
+	}
+
+	for i, tt := range testcases {
+		if err := tt.policy.Validate(); err != tt.err {
+			t.Errorf("#%d got %v, want %v", i, err, tt.err)
+		}
+	}
+}
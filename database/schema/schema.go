@@ -0,0 +1,201 @@
+// Package schema implements persisted representation of events shared by stores.
+//
+// Events are persisted under versioned type names, e.g. Deposit.v3.
+// Payloads persisted by older versions are transformed into the current version
+// by chain of registered upcasters before they are decoded, so aggregates only ever
+// deal with the current version of their events.
+//
+// Events of aggregates implementing Chained are linked into tamper-evident hash chain
+// persisted in events metadata and verified whenever aggregate is restored.
+package schema
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// versionSeparator separates event name and its version in the type name.
+const versionSeparator = ".v"
+
+// ErrUnknownEvent is returned when persisted event can not be mapped to any of registered aggregate events.
+var ErrUnknownEvent = errors.New("unknown event")
+
+// Config represents persisted events configuration.
+type Config struct {
+	Unknown      Policy `mapstructure:"unknown"`      // Unknown events policy, defaults to PolicyFail
+	SigningKey   string `mapstructure:"signingkey"`   // Path to ed25519 key signing exported hash chain heads, optional
+	ChainCutover string `mapstructure:"chaincutover"` // RFC 3339 time since which every event must be linked into hash chain, optional
+}
+
+// Validate implements validator.Validator.
+func (c *Config) Validate() error {
+	if len(c.ChainCutover) > 0 {
+		if _, err := time.Parse(time.RFC3339, c.ChainCutover); err != nil {
+			return errors.Wrapf(err, "chain cutover %q is not valid", c.ChainCutover)
+		}
+	}
+	if len(c.Unknown) == 0 {
+		return nil
+	}
+	return c.Unknown.Validate()
+}
+
+// Cutover returns time since which every event must be linked into hash chain,
+// zero time, requiring all events to be linked, is returned if cutover is not configured.
+// It's safe to call Cutover on nil Config.
+func (c *Config) Cutover() time.Time {
+	if c == nil {
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.RFC3339, c.ChainCutover)
+	return t
+}
+
+// Policy returns unknown events policy, PolicyFail is returned if policy is not configured.
+// It's safe to call Policy on nil Config.
+func (c *Config) Policy() Policy {
+	if c == nil || len(c.Unknown) == 0 {
+		return PolicyFail
+	}
+	return c.Unknown
+}
+
+// Upcaster transforms event payload from one version into the next one.
+type Upcaster func(data []byte) ([]byte, error)
+
+// registered event versions and upcasters
+var (
+	versions  map[string]int              // current version by event name
+	upcasters map[string]map[int]Upcaster // upcasters by event name and version they upcast from
+	mu        sync.Mutex
+)
+
+// init initialises package state.
+func init() {
+	versions = make(map[string]int)
+	upcasters = make(map[string]map[int]Upcaster)
+}
+
+// Register registers current version of the event.
+func Register(event es.MarshalUnmarshaler, version int) {
+	mu.Lock()
+	versions[es.ParseEventName(event)] = version
+	mu.Unlock()
+}
+
+// RegisterUpcaster registers upcaster transforming payloads of the event named name from given version to the next one.
+func RegisterUpcaster(name string, from int, upcaster Upcaster) {
+	mu.Lock()
+	if upcasters[name] == nil {
+		upcasters[name] = make(map[int]Upcaster)
+	}
+	upcasters[name][from] = upcaster
+	mu.Unlock()
+}
+
+// TypeName returns versioned type name of the event.
+// Events without registered version are named without one.
+func TypeName(event es.MarshalUnmarshaler) string {
+	name := es.ParseEventName(event)
+
+	mu.Lock()
+	version, ok := versions[name]
+	mu.Unlock()
+
+	if !ok {
+		return name
+	}
+	return name + versionSeparator + strconv.Itoa(version)
+}
+
+// ParseTypeName splits versioned type name into event name and version.
+// Type names without a version are treated as version 1 which predates versioning.
+func ParseTypeName(typ string) (string, int) {
+	i := strings.LastIndex(typ, versionSeparator)
+	if i < 0 {
+		return typ, 1
+	}
+
+	version, err := strconv.Atoi(typ[i+len(versionSeparator):])
+	if err != nil || version < 1 {
+		return typ, 1
+	}
+	return typ[:i], version
+}
+
+// Upcast transforms payload of event type typ into the current version of the event.
+// It returns name of the event along the transformed payload.
+func Upcast(typ string, data []byte) (string, []byte, error) {
+	name, version := ParseTypeName(typ)
+
+	mu.Lock()
+	current, ok := versions[name]
+	chain := upcasters[name]
+	mu.Unlock()
+
+	if !ok {
+		return name, data, nil
+	}
+
+	if version > current {
+		return name, nil, errors.Wrapf(ErrUnknownEvent, "%s is newer than supported version %d", typ, current)
+	}
+
+	for ; version < current; version++ {
+		upcast, ok := chain[version]
+		if !ok {
+			return name, nil, errors.Newf("no upcaster for %s version %d", name, version)
+		}
+
+		var err error
+		if data, err = upcast(data); err != nil {
+			return name, nil, errors.Wrapf(err, "failed to upcast %s version %d", name, version)
+		}
+	}
+
+	return name, data, nil
+}
+
+// Decode decodes persisted event of type typ into current version of the aggregate event.
+func Decode(aggregate es.Aggregate, typ string, data []byte) (es.MarshalUnmarshaler, error) {
+	name, data, err := Upcast(typ, data)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := es.GetAggregateEvent(aggregate, name)
+	if err != nil {
+		return nil, errors.Wrapf(ErrUnknownEvent, "%s", typ)
+	}
+
+	if err := event.UnmarshalJSON(data); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s", typ)
+	}
+
+	return event, nil
+}
+
+// RenameFields constructs Upcaster renaming top level fields of JSON object payload.
+func RenameFields(fields map[string]string) Upcaster {
+	return func(data []byte) ([]byte, error) {
+		var payload map[string]json.RawMessage
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return nil, err
+		}
+
+		for from, to := range fields {
+			if v, ok := payload[from]; ok {
+				delete(payload, from)
+				payload[to] = v
+			}
+		}
+
+		return json.Marshal(payload)
+	}
+}
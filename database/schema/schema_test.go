@@ -0,0 +1,148 @@
+package schema_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fixtureWalletID is wallet ID used across fixture streams.
+const fixtureWalletID = "1b7cc2a1-5d7e-4f5a-9b55-0f3f8a1d6c01"
+
+// record represents persisted event in the fixture stream.
+type record struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// readFixture reads fixture stream of persisted events.
+func readFixture(t *testing.T, name string) []record {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	defer f.Close()
+
+	var records []record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return records
+}
+
+// TestReplayFixtures replays fixture streams containing every historical events version.
+func TestReplayFixtures(t *testing.T) {
+	var testcases = []struct {
+		fixture string
+		wallet  ledger.Wallet
+	}{
+		{"v1.jsonl", ledger.Wallet{ID: fixtureWalletID, Name: "Family Fund", Balance: 125, Version: 4}},
+		{"v2.jsonl", ledger.Wallet{ID: fixtureWalletID, Name: "Family Fund", Balance: 125, Version: 4}},
+		{"mixed.jsonl", ledger.Wallet{ID: fixtureWalletID, Name: "Family Fund", Balance: 125, Version: 4}},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.fixture, func(t *testing.T) {
+			var aggregate ledger.WalletAggregate
+
+			var events []*es.Event
+			for i, r := range readFixture(t, tt.fixture) {
+				event, err := schema.Decode(&aggregate, r.Type, r.Data)
+				if err != nil {
+					t.Fatalf("#%d got %v, want %v", i, err, nil)
+				}
+				events = append(events, es.NewEvent(fixtureWalletID, &aggregate, event))
+			}
+
+			if err := aggregate.Reply(events); err != nil {
+				t.Fatalf("got %v, want %v", err, nil)
+			}
+
+			if !cmp.Equal(aggregate.Wallet, tt.wallet) {
+				t.Errorf("got %v, want %v", aggregate.Wallet, tt.wallet)
+			}
+		})
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	var testcases = []struct {
+		event es.MarshalUnmarshaler
+		name  string
+	}{
+		{&ledger.WalletInitialized{}, "WalletInitialized.v2"},
+		{&ledger.Deposit{}, "Deposit.v5"},
+		{&ledger.Withdraw{}, "Withdraw.v5"},
+	}
+
+	for i, tt := range testcases {
+		if name := schema.TypeName(tt.event); name != tt.name {
+			t.Errorf("#%d got %v, want %v", i, name, tt.name)
+		}
+	}
+}
+
+func TestParseTypeName(t *testing.T) {
+	var testcases = []struct {
+		typ     string
+		name    string
+		version int
+	}{
+		{"Deposit", "Deposit", 1},
+		{"Deposit.v1", "Deposit", 1},
+		{"Deposit.v12", "Deposit", 12},
+		{"Deposit.vx", "Deposit.vx", 1},
+		{"Deposit.v0", "Deposit.v0", 1},
+	}
+
+	for i, tt := range testcases {
+		name, version := schema.ParseTypeName(tt.typ)
+		if name != tt.name || version != tt.version {
+			t.Errorf("#%d got %v %v, want %v %v", i, name, version, tt.name, tt.version)
+		}
+	}
+}
+
+func TestDecodeUnknown(t *testing.T) {
+	var testcases = []struct {
+		typ string
+		err error
+	}{
+		{"Unknown.v1", schema.ErrUnknownEvent},
+		{"Deposit.v6", schema.ErrUnknownEvent}, // persisted by newer version
+	}
+
+	for i, tt := range testcases {
+		if _, err := schema.Decode(&ledger.WalletAggregate{}, tt.typ, []byte(`{}`)); !errors.Is(err, tt.err) {
+			t.Errorf("#%d got %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+func TestRenameFields(t *testing.T) {
+	data, err := schema.RenameFields(map[string]string{"A": "a", "Missing": "missing"})([]byte(`{"A":1,"b":2}`))
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if want := `{"a":1,"b":2}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
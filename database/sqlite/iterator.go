@@ -0,0 +1,41 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/database/schema"
+)
+
+// iterator adapts sql.Rows of events table to schema.Iterator.
+type iterator struct {
+	rows *sql.Rows
+}
+
+// Next implements schema.Iterator.
+func (i *iterator) Next() bool {
+	return i.rows.Next()
+}
+
+// Value implements schema.Iterator.
+func (i *iterator) Value() (*schema.Record, error) {
+	var (
+		record schema.Record
+		ts     int64
+	)
+	if err := i.rows.Scan(&record.Aggregate, &record.AggregateID, &record.Version, &record.Type, &ts, &record.Data, &record.Metadata); err != nil {
+		return nil, err
+	}
+	record.Timestamp = time.Unix(0, ts).UTC()
+	return &record, nil
+}
+
+// Error implements schema.Iterator.
+func (i *iterator) Error() error {
+	return i.rows.Err()
+}
+
+// Close implements schema.Iterator.
+func (i *iterator) Close() {
+	i.rows.Close()
+}
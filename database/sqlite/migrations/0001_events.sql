@@ -0,0 +1,27 @@
+-- events holds persisted events of all aggregates, sequence orders them globally.
+CREATE TABLE events (
+    sequence     INTEGER PRIMARY KEY AUTOINCREMENT,
+    aggregate    TEXT    NOT NULL,
+    aggregate_id TEXT    NOT NULL,
+    version      INTEGER NOT NULL,
+    type         TEXT    NOT NULL,
+    timestamp    INTEGER NOT NULL, -- unix nanoseconds
+    data         BLOB    NOT NULL,
+    metadata     BLOB,
+    UNIQUE (aggregate, aggregate_id, version)
+);
+
+-- first events of aggregates are looked up when aggregates are listed.
+CREATE INDEX events_created ON events (aggregate, version, sequence);
+
+-- quarantine holds copies of unknown events skipped while restoring aggregates.
+CREATE TABLE quarantine (
+    aggregate    TEXT    NOT NULL,
+    aggregate_id TEXT    NOT NULL,
+    version      INTEGER NOT NULL,
+    type         TEXT    NOT NULL,
+    timestamp    INTEGER NOT NULL,
+    data         BLOB    NOT NULL,
+    metadata     BLOB,
+    PRIMARY KEY (aggregate, aggregate_id, version)
+);
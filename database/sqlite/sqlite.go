@@ -0,0 +1,142 @@
+// Package sqlite implements aggregates store backed by embedded SQLite database.
+//
+// Events of all aggregates are kept in a single table keyed by aggregate type, ID and version,
+// unique constraint of which rejects events appended concurrently to the same aggregate.
+// Table sequence column orders events globally, so they can be read in order they were persisted.
+// Database schema is migrated once database is opened.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/deividaspetraitis/go/errors"
+
+	_ "modernc.org/sqlite" // registers pure Go sqlite driver
+)
+
+// defaultBusyTimeout is time in milliseconds connection waits for database lock before failing.
+const defaultBusyTimeout = 5000
+
+// Config represents SQLite database configuration.
+type Config struct {
+	Path string `mapstructure:"path"` // Path to database file, created if it does not exist
+}
+
+// Validate implements validator.Validator.
+func (c *Config) Validate() error {
+	if len(c.Path) == 0 {
+		return errors.New("database path is required")
+	}
+	return nil
+}
+
+// migrations holds database schema migrations applied in order of their file names.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Open opens database at the configured path and migrates its schema.
+func Open(ctx context.Context, cfg *Config) (*sql.DB, error) {
+	if cfg == nil {
+		return nil, errors.New("database path is required")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", dsn(cfg.Path))
+	if err != nil {
+		return nil, errors.Wrap(err, "sqlite: open database")
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "sqlite: verify connection")
+	}
+
+	if err := Migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "sqlite: migrate schema")
+	}
+
+	return db, nil
+}
+
+// dsn returns data source name of the database file.
+// Write ahead log lets readers proceed while events are appended,
+// write transactions take the lock immediately, so concurrent writers wait for each other instead of failing.
+// Every commit is synced to disk before it's acknowledged, so appended events survive power loss
+// the same way they survive with the other stores.
+func dsn(path string) string {
+	query := url.Values{}
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "busy_timeout("+strconv.Itoa(defaultBusyTimeout)+")")
+	query.Add("_pragma", "synchronous(FULL)")
+	query.Set("_txlock", "immediate")
+	return "file:" + path + "?" + query.Encode()
+}
+
+// Migrate applies database schema migrations which were not applied yet.
+// Applied migrations are recorded in migrations table, each migration is applied within its own transaction.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL)`); err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		base := strings.TrimPrefix(name, "migrations/")
+		version, err := strconv.Atoi(strings.SplitN(base, "_", 2)[0])
+		if err != nil {
+			return errors.Wrapf(err, "not a valid migration name: %s", base)
+		}
+
+		if err := migrate(ctx, db, version, base, name); err != nil {
+			return errors.Wrapf(err, "unable to apply migration %s", base)
+		}
+	}
+
+	return nil
+}
+
+// migrate applies migration of the given version unless it was applied already.
+func migrate(ctx context.Context, db *sql.DB, version int, base, name string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // nolint
+
+	var applied int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM migrations WHERE version = ?`, version).Scan(&applied); err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	query, err := migrations.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, string(query)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO migrations (version, name) VALUES (?, ?)`, version, base); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
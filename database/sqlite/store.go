@@ -0,0 +1,234 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/es"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// ErrWrongVersion is returned when appended events do not follow the last persisted event of the aggregate,
+// i.e. aggregate was modified concurrently. It's ledger.ErrVersionConflict, so callers do not depend on the backend.
+var ErrWrongVersion = ledger.ErrVersionConflict
+
+// Event represents persisted event along its position in the global events sequence.
+type Event struct {
+	Sequence uint64 // Position in the global events sequence, starting at 1
+	*schema.Record
+}
+
+// Store persists and restores aggregates using SQLite database.
+type Store struct {
+	db       *sql.DB
+	restorer schema.Restorer
+}
+
+// NewStore constructs a new Store using database opened by Open.
+// If cfg is nil or unknown events policy is not set schema.PolicyFail is used.
+func NewStore(db *sql.DB, cfg *schema.Config) (*Store, error) {
+	policy := schema.PolicyFail
+	if cfg != nil && len(cfg.Unknown) > 0 {
+		policy = cfg.Unknown
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := Store{
+		db: db,
+	}
+	s.restorer = schema.Restorer{
+		Policy:     policy,
+		Quarantine: s.quarantine,
+		Cutover:    cfg.Cutover(),
+	}
+
+	return &s, nil
+}
+
+// Save persists an aggregate into underlying DB store.
+// Save calls aggregate.Sync if store operations were successful.
+// Save implements database.SaveAggregateFunc.
+func (s *Store) Save(ctx context.Context, aggregate es.Aggregate) error {
+	processed := aggregate.Events()
+
+	records, head, err := schema.Encode(aggregate, processed)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Append(ctx, records); err != nil {
+		return err
+	}
+
+	// mark recently stored events as processed.
+	for _, v := range processed {
+		if err := aggregate.Sync(v); err != nil {
+			return err
+		}
+	}
+
+	if chained, ok := aggregate.(schema.Chained); ok && len(records) > 0 {
+		chained.SetChainHead(head)
+	}
+
+	return nil
+}
+
+// Append persists already encoded records of a single aggregate preserving their versions, timestamps and metadata.
+// Records must follow the last persisted record of the aggregate, otherwise ErrWrongVersion is returned and nothing is persisted.
+// Append implements archive.Sink.
+func (s *Store) Append(ctx context.Context, records []*schema.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // nolint
+
+	first := records[0]
+
+	var last uint64
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM events WHERE aggregate = ? AND aggregate_id = ?`, first.Aggregate, first.AggregateID).Scan(&last)
+	if err != nil {
+		return err
+	}
+	if first.Version != last+1 {
+		return ErrWrongVersion
+	}
+
+	for _, v := range records {
+		_, err := tx.ExecContext(ctx, `INSERT INTO events (aggregate, aggregate_id, version, type, timestamp, data, metadata) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			v.Aggregate, v.AggregateID, v.Version, v.Type, v.Timestamp.UnixNano(), v.Data, v.Metadata)
+		if isConstraintError(err) {
+			return ErrWrongVersion
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// isConstraintError reports whether err is a violation of events table unique constraint.
+func isConstraintError(err error) bool {
+	e, ok := err.(*sqlite.Error)
+	return ok && (e.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || e.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY)
+}
+
+// Load restores aggregate state from underlying DB store.
+// Only events following current aggregate version are read, so aggregate restored earlier is caught up.
+// If aggregate does not exist ledger.ErrEntryNotFound is returned.
+func (s *Store) Load(ctx context.Context, aggregate es.Aggregate, id string) error {
+	it, err := s.records(ctx, aggregate, id, uint64(aggregate.Root().Version()))
+	if err != nil {
+		return err
+	}
+
+	if err := s.restorer.Restore(ctx, aggregate, id, it); err != nil {
+		return err
+	}
+
+	// no events for given aggregate were found
+	// meaning such aggregate does not exit
+	if aggregate.Root().Version() == 0 {
+		return ledger.ErrEntryNotFound
+	}
+
+	return nil
+}
+
+// Get retrieves aggregate with restored state from underlying DB store.
+func Get[T any](ctx context.Context, s *Store, aggregate es.Aggregate, id string) (T, error) {
+	if err := s.Load(ctx, aggregate, id); err != nil {
+		return *new(T), err
+	}
+	return aggregate.(T), nil
+}
+
+// Records returns iterator over all persisted records of the aggregate as they are stored, without decoding them.
+// Records implements archive.Source.
+func (s *Store) Records(ctx context.Context, aggregate es.Aggregate, id string) (schema.Iterator, error) {
+	return s.records(ctx, aggregate, id, 0)
+}
+
+// RecordsAfter returns iterator over persisted records of the aggregate following the given version as they are stored.
+func (s *Store) RecordsAfter(ctx context.Context, aggregate es.Aggregate, id string, after uint64) (schema.Iterator, error) {
+	return s.records(ctx, aggregate, id, after)
+}
+
+// records returns iterator over persisted records of the aggregate following the given version.
+func (s *Store) records(ctx context.Context, aggregate es.Aggregate, id string, after uint64) (schema.Iterator, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT aggregate, aggregate_id, version, type, timestamp, data, metadata FROM events
+		WHERE aggregate = ? AND aggregate_id = ? AND version > ? ORDER BY version`, es.ParseAggregateName(aggregate), id, after)
+	if err != nil {
+		return nil, err
+	}
+	return &iterator{rows: rows}, nil
+}
+
+// IDs returns IDs of all persisted aggregates of the aggregate type in order they were created.
+// IDs implements archive.Source.
+func (s *Store) IDs(ctx context.Context, aggregate es.Aggregate) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT aggregate_id FROM events WHERE aggregate = ? AND version = 1 ORDER BY sequence`, es.ParseAggregateName(aggregate))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ReadAll returns up to limit events of all aggregates persisted after the given position of the global events sequence,
+// in order they were persisted. Subscribers keep sequence of the last event read and pass it to the following call.
+func (s *Store) ReadAll(ctx context.Context, after uint64, limit int) ([]*Event, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT sequence, aggregate, aggregate_id, version, type, timestamp, data, metadata FROM events
+		WHERE sequence > ? ORDER BY sequence LIMIT ?`, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		var (
+			event Event
+			ts    int64
+		)
+		event.Record = &schema.Record{}
+		if err := rows.Scan(&event.Sequence, &event.Aggregate, &event.AggregateID, &event.Version, &event.Type, &ts, &event.Data, &event.Metadata); err != nil {
+			return nil, err
+		}
+		event.Timestamp = time.Unix(0, ts).UTC()
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+// quarantine copies unknown record into quarantine table, repeatedly quarantined record is kept once.
+// quarantine implements schema.QuarantineFunc.
+func (s *Store) quarantine(ctx context.Context, record *schema.Record) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO quarantine (aggregate, aggregate_id, version, type, timestamp, data, metadata) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`, record.Aggregate, record.AggregateID, record.Version, record.Type, record.Timestamp.UnixNano(), record.Data, record.Metadata)
+	return err
+}
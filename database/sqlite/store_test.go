@@ -0,0 +1,262 @@
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+	"github.com/deividaspetraitis/ledger/database/sqlite"
+	"github.com/deividaspetraitis/ledger/database/storetest"
+
+	"github.com/deividaspetraitis/go/errors"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// newStore returns store of a new database within test temporary directory.
+func newStore(t *testing.T) *sqlite.Store {
+	return newStoreWithConfig(t, nil)
+}
+
+// newStoreWithConfig returns store of a new database within test temporary directory decoding events according to cfg.
+func newStoreWithConfig(t *testing.T, cfg *schema.Config) *sqlite.Store {
+	db, err := sqlite.Open(context.Background(), &sqlite.Config{Path: filepath.Join(t.TempDir(), "ledger.db")})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store, err := sqlite.NewStore(db, cfg)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return store
+}
+
+// newWallet returns a new wallet with given deposits applied.
+func newWallet(t *testing.T, amounts ...int) *ledger.WalletAggregate {
+	wallet, err := ledger.NewWallet(&ledger.CreateWalletRequest{Name: "test"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	for _, amount := range amounts {
+		deposit(t, wallet, amount)
+	}
+	return wallet
+}
+
+// deposit applies deposit of the given amount on the wallet.
+func deposit(t *testing.T, wallet *ledger.WalletAggregate, amount int) {
+	if err := wallet.ProcessTransaction(&ledger.Transaction{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: amount}); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T, cfg *schema.Config) storetest.Store {
+		return newStoreWithConfig(t, cfg)
+	})
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+
+	wallet := newWallet(t, 100, 25)
+	if err := store.Save(ctx, wallet); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	restored, err := sqlite.Get[*ledger.WalletAggregate](ctx, store, &ledger.WalletAggregate{}, wallet.ID)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if !cmp.Equal(restored.Wallet, wallet.Wallet) {
+		t.Errorf("got %v, want %v", restored.Wallet, wallet.Wallet)
+	}
+	if restored.ChainHead() != wallet.ChainHead() {
+		t.Errorf("got %v, want %v", restored.ChainHead(), wallet.ChainHead())
+	}
+
+	// restored copy of the wallet gets outdated once the wallet is transacted.
+	deposit(t, wallet, 10)
+	if err := store.Save(ctx, wallet); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	deposit(t, restored, 20)
+	if err := store.Save(ctx, restored); !errors.Is(err, sqlite.ErrWrongVersion) {
+		t.Errorf("got %v, want %v", err, sqlite.ErrWrongVersion)
+	}
+
+	// aggregate restored earlier is caught up.
+	caught, err := sqlite.Get[*ledger.WalletAggregate](ctx, store, &ledger.WalletAggregate{}, wallet.ID)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	deposit(t, wallet, 5)
+	if err := store.Save(ctx, wallet); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if err := store.Load(ctx, caught, wallet.ID); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if !cmp.Equal(caught.Wallet, wallet.Wallet) {
+		t.Errorf("got %v, want %v", caught.Wallet, wallet.Wallet)
+	}
+
+	if err := store.Load(ctx, &ledger.WalletAggregate{}, "2f7a0d3e-7c2b-4f6e-9a55-3c1d2b4e5f60"); !errors.Is(err, ledger.ErrEntryNotFound) {
+		t.Errorf("got %v, want %v", err, ledger.ErrEntryNotFound)
+	}
+}
+
+func TestStoreAppend(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+
+	wallet := newWallet(t, 100)
+	records, _, err := schema.Encode(wallet, wallet.Events())
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	var testcases = []struct {
+		records []*schema.Record
+		err     error
+	}{
+		{records[1:], sqlite.ErrWrongVersion}, // gap
+		{records, nil},
+		{records, sqlite.ErrWrongVersion},     // repeated
+		{records[1:], sqlite.ErrWrongVersion}, // overlapping
+	}
+
+	for i, tt := range testcases {
+		if err := store.Append(ctx, tt.records); !errors.Is(err, tt.err) {
+			t.Errorf("#%d got %v, want %v", i, err, tt.err)
+		}
+	}
+
+	it, err := store.Records(ctx, &ledger.WalletAggregate{}, wallet.ID)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	defer it.Close()
+
+	var got []*schema.Record
+	for it.Next() {
+		v, err := it.Value()
+		if err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+		got = append(got, v)
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	if !cmp.Equal(got, records) {
+		t.Errorf("got %v, want %v", got, records)
+	}
+}
+
+func TestStoreReadAll(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+
+	first, second := newWallet(t, 100), newWallet(t)
+	for _, v := range []*ledger.WalletAggregate{first, second} {
+		if err := store.Save(ctx, v); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+	}
+	deposit(t, first, 50)
+	if err := store.Save(ctx, first); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	ids, err := store.IDs(ctx, &ledger.WalletAggregate{})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if want := []string{first.ID, second.ID}; !cmp.Equal(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+
+	var testcases = []struct {
+		after uint64
+		limit int
+		want  []string // aggregate IDs of returned events
+	}{
+		{0, 10, []string{first.ID, first.ID, second.ID, first.ID}},
+		{1, 2, []string{first.ID, second.ID}},
+		{4, 10, nil},
+	}
+
+	for i, tt := range testcases {
+		events, err := store.ReadAll(ctx, tt.after, tt.limit)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+
+		var got []string
+		for j, v := range events {
+			if want := tt.after + uint64(j) + 1; v.Sequence != want {
+				t.Errorf("#%d got %v, want %v", i, v.Sequence, want)
+			}
+			got = append(got, v.AggregateID)
+		}
+		if !cmp.Equal(got, tt.want) {
+			t.Errorf("#%d got %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	cfg := sqlite.Config{Path: filepath.Join(t.TempDir(), "ledger.db")}
+
+	// migrations are applied once, events persist across reopening.
+	wallet := newWallet(t, 100)
+	for i := 0; i < 2; i++ {
+		db, err := sqlite.Open(ctx, &cfg)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+
+		store, err := sqlite.NewStore(db, nil)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if i == 0 {
+			err = store.Save(ctx, wallet)
+		} else {
+			err = store.Load(ctx, &ledger.WalletAggregate{}, wallet.ID)
+		}
+		if err != nil {
+			t.Errorf("#%d got %v, want %v", i, err, nil)
+		}
+
+		var applied int
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM migrations`).Scan(&applied); err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if applied != 1 {
+			t.Errorf("#%d got %v, want %v", i, applied, 1)
+		}
+
+		// commits are synced to disk before they are acknowledged
+		var synchronous int
+		if err := db.QueryRowContext(ctx, `PRAGMA synchronous`).Scan(&synchronous); err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if synchronous != 2 {
+			t.Errorf("#%d got synchronous %v, want %v", i, synchronous, 2)
+		}
+		db.Close()
+	}
+
+	if _, err := sqlite.Open(ctx, &sqlite.Config{}); err == nil {
+		t.Errorf("got %v, want error", err)
+	}
+}
@@ -0,0 +1,479 @@
+// Package storetest implements conformance tests every aggregates store backend has to pass.
+//
+// Backend runs the suite from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T, cfg *schema.Config) storetest.Store {
+//			return newStore(t, cfg)
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/archive"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// Store represents aggregates store under test.
+type Store interface {
+	archive.Source
+	archive.Sink
+
+	// Save persists an aggregate, it implements database.SaveAggregateFunc.
+	Save(ctx context.Context, aggregate es.Aggregate) error
+
+	// Load restores aggregate state, if aggregate does not exist ledger.ErrEntryNotFound is returned.
+	Load(ctx context.Context, aggregate es.Aggregate, id string) error
+
+	// RecordsAfter returns iterator over persisted records of the aggregate following the given version as they are stored.
+	RecordsAfter(ctx context.Context, aggregate es.Aggregate, id string, after uint64) (schema.Iterator, error)
+}
+
+// NewStoreFunc returns a new empty store decoding persisted events according to cfg, cfg may be nil.
+// Store is expected to be released once test completes, e.g. using t.Cleanup.
+type NewStoreFunc func(t *testing.T, cfg *schema.Config) Store
+
+// Run runs conformance tests against stores constructed by newStore, every test gets a new store.
+func Run(t *testing.T, newStore NewStoreFunc) {
+	var tests = []struct {
+		name string
+		test func(t *testing.T, newStore NewStoreFunc)
+	}{
+		{"RoundTrip", testRoundTrip},
+		{"AppendTimestamps", testAppendTimestamps},
+		{"VersionConflict", testVersionConflict},
+		{"ReadFromVersion", testReadFromVersion},
+		{"UnknownEvents", testUnknownEvents},
+		{"Ordering", testOrdering},
+		{"ConcurrentAppends", testConcurrentAppends},
+		{"Cancellation", testCancellation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore)
+		})
+	}
+}
+
+// unknownEvent is type of the event no aggregate knows about.
+const unknownEvent = "Refund.v1"
+
+// newWallet returns a new wallet with given transactions applied, deposits are positive and withdrawals negative.
+func newWallet(t *testing.T, amounts ...int) *ledger.WalletAggregate {
+	t.Helper()
+
+	wallet, err := ledger.NewWallet(&ledger.CreateWalletRequest{Name: "storetest"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	transact(t, wallet, amounts...)
+	return wallet
+}
+
+// transact applies transactions on the wallet, deposits are positive and withdrawals negative.
+func transact(t *testing.T, wallet *ledger.WalletAggregate, amounts ...int) {
+	t.Helper()
+
+	for _, amount := range amounts {
+		tx := &ledger.Transaction{ID: "tx", Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: amount}
+		if amount < 0 {
+			tx.Type, tx.Amount = ledger.TransactionWithdraw, -amount
+		}
+		if err := wallet.ProcessTransaction(tx); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+	}
+}
+
+// save persists the wallet.
+func save(t *testing.T, s Store, wallet *ledger.WalletAggregate) {
+	t.Helper()
+
+	if err := s.Save(context.Background(), wallet); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+}
+
+// restore restores wallet of the given ID.
+func restore(t *testing.T, s Store, id string) *ledger.WalletAggregate {
+	t.Helper()
+
+	var wallet ledger.WalletAggregate
+	if err := s.Load(context.Background(), &wallet, id); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return &wallet
+}
+
+// records returns all persisted records of the wallet.
+func records(t *testing.T, s Store, id string) []*schema.Record {
+	t.Helper()
+
+	it, err := s.Records(context.Background(), &ledger.WalletAggregate{}, id)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	defer it.Close()
+
+	var v []*schema.Record
+	for it.Next() {
+		record, err := it.Value()
+		if err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+		v = append(v, record)
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return v
+}
+
+// compareWallets reports mismatch of wallets state and chain head.
+func compareWallets(t *testing.T, got, want *ledger.WalletAggregate) {
+	t.Helper()
+
+	if !cmp.Equal(got.Wallet, want.Wallet) {
+		t.Errorf("got %v, want %v", got.Wallet, want.Wallet)
+	}
+	if got.ChainHead() != want.ChainHead() {
+		t.Errorf("got chain head %v, want %v", got.ChainHead(), want.ChainHead())
+	}
+}
+
+// testRoundTrip verifies that saved events are read back as they were encoded.
+// Backends may assign their own timestamps, so timestamps are not compared.
+func testRoundTrip(t *testing.T, newStore NewStoreFunc) {
+	s := newStore(t, nil)
+
+	wallet := newWallet(t, 100, -30)
+	encoded, _, err := schema.Encode(wallet, wallet.Events())
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	save(t, s, wallet)
+
+	types := []string{
+		schema.TypeName(&ledger.WalletInitialized{}),
+		schema.TypeName(&ledger.Deposit{}),
+		schema.TypeName(&ledger.Withdraw{}),
+	}
+	got := records(t, s, wallet.ID)
+	for i, v := range got {
+		if i < len(types) && v.Type != types[i] {
+			t.Errorf("#%d got %v, want %v", i, v.Type, types[i])
+		}
+	}
+	if !cmp.Equal(got, encoded, cmpopts.IgnoreFields(schema.Record{}, "Timestamp"), cmpopts.EquateEmpty()) {
+		t.Errorf("got %v, want %v", got, encoded)
+	}
+
+	compareWallets(t, restore(t, s, wallet.ID), wallet)
+
+	if err := s.Load(context.Background(), &ledger.WalletAggregate{}, newWallet(t).ID); !errors.Is(err, ledger.ErrEntryNotFound) {
+		t.Errorf("got %v, want %v", err, ledger.ErrEntryNotFound)
+	}
+}
+
+// testAppendTimestamps verifies that appended records keep their timestamps, e.g. records imported from an archive.
+func testAppendTimestamps(t *testing.T, newStore NewStoreFunc) {
+	s := newStore(t, nil)
+
+	wallet := newWallet(t, 100)
+	encoded, _, err := schema.Encode(wallet, wallet.Events())
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	for i, v := range encoded {
+		v.Timestamp = time.Date(2023, 1, 2, 3, 4, 5, 6+i, time.UTC)
+	}
+	if err := s.Append(context.Background(), encoded); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	if got := records(t, s, wallet.ID); !cmp.Equal(got, encoded, cmpopts.EquateEmpty()) {
+		t.Errorf("got %v, want %v", got, encoded)
+	}
+}
+
+// testVersionConflict verifies that events not following the last persisted event are rejected as a whole.
+func testVersionConflict(t *testing.T, newStore NewStoreFunc) {
+	ctx := context.Background()
+	s := newStore(t, nil)
+
+	wallet := newWallet(t, 100)
+	save(t, s, wallet)
+
+	first, second := restore(t, s, wallet.ID), restore(t, s, wallet.ID)
+	transact(t, first, 10)
+	save(t, s, first)
+
+	// outdated copy is rejected.
+	transact(t, second, 20, 30)
+	if err := s.Save(ctx, second); err == nil {
+		t.Errorf("got %v, want error", err)
+	}
+
+	persisted := records(t, s, wallet.ID)
+
+	// records neither following nor continuing the stream are rejected.
+	next := *persisted[len(persisted)-1]
+	next.Version++
+	gap := next
+	gap.Version++
+
+	var testcases = [][]*schema.Record{
+		{&gap},              // gap
+		persisted[:1],       // repeated
+		{&next, &gap, &gap}, // not contiguous
+	}
+	for i, tt := range testcases {
+		if err := s.Append(ctx, tt); err == nil {
+			t.Errorf("#%d got %v, want error", i, err)
+		}
+	}
+
+	if got := records(t, s, wallet.ID); len(got) != len(persisted) {
+		t.Errorf("got %v records, want %v", len(got), len(persisted))
+	}
+	compareWallets(t, restore(t, s, wallet.ID), first)
+}
+
+// testReadFromVersion verifies that restored aggregate is caught up reading only events following its version.
+func testReadFromVersion(t *testing.T, newStore NewStoreFunc) {
+	s := newStore(t, nil)
+
+	wallet := newWallet(t, 100)
+	save(t, s, wallet)
+
+	restored := restore(t, s, wallet.ID)
+
+	transact(t, wallet, 50, -20)
+	save(t, s, wallet)
+
+	// events read twice would be applied twice.
+	if err := s.Load(context.Background(), restored, wallet.ID); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	compareWallets(t, restored, wallet)
+
+	// caught up aggregate is not changed.
+	if err := s.Load(context.Background(), restored, wallet.ID); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	compareWallets(t, restored, wallet)
+
+	// raw records are read following the given version as well.
+	persisted := records(t, s, wallet.ID)
+	for _, after := range []uint64{0, 2, uint64(len(persisted))} {
+		it, err := s.RecordsAfter(context.Background(), &ledger.WalletAggregate{}, wallet.ID, after)
+		if err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+
+		var got []*schema.Record
+		for it.Next() {
+			record, err := it.Value()
+			if err != nil {
+				t.Fatalf("got %v, want %v", err, nil)
+			}
+			got = append(got, record)
+		}
+		if err := it.Error(); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+		it.Close()
+
+		if diff := cmp.Diff(persisted[after:], got, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("after %d records mismatch (-want +got):\n%s", after, diff)
+		}
+	}
+}
+
+// testUnknownEvents verifies that unknown events are handled according to the configured policy.
+func testUnknownEvents(t *testing.T, newStore NewStoreFunc) {
+	var testcases = []struct {
+		policy schema.Policy
+		err    error
+	}{
+		{schema.PolicyFail, schema.ErrUnknownEvent},
+		{schema.PolicySkip, nil},
+		{schema.PolicyQuarantine, nil},
+	}
+
+	for _, tt := range testcases {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			s := newStore(t, &schema.Config{Unknown: tt.policy})
+
+			wallet := newWallet(t, 100)
+			save(t, s, wallet)
+
+			// unknown event persisted by newer service version is linked into the chain.
+			persisted := records(t, s, wallet.ID)
+			unknown := &schema.Record{
+				AggregateID: wallet.ID,
+				Aggregate:   es.ParseAggregateName(wallet),
+				Version:     uint64(wallet.Root().Version()) + 1,
+				Type:        unknownEvent,
+				Timestamp:   persisted[len(persisted)-1].Timestamp,
+				Data:        json.RawMessage(`{"amount":100}`),
+			}
+			if _, err := schema.Seal(wallet.ChainHead(), []*schema.Record{unknown}); err != nil {
+				t.Fatalf("got %v, want %v", err, nil)
+			}
+			if err := s.Append(context.Background(), []*schema.Record{unknown}); err != nil {
+				t.Fatalf("got %v, want %v", err, nil)
+			}
+
+			// repeatedly restored aggregate meets the same unknown event.
+			for i := 0; i < 2; i++ {
+				var restored ledger.WalletAggregate
+				err := s.Load(context.Background(), &restored, wallet.ID)
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("#%d got %v, want %v", i, err, tt.err)
+				}
+				if err != nil {
+					continue
+				}
+
+				if !restored.Degraded || restored.Balance != wallet.Balance {
+					t.Errorf("#%d got %v, want degraded wallet of balance %v", i, restored.Wallet, wallet.Balance)
+				}
+				if got, want := restored.Root().Version(), es.Version(unknown.Version); got != want {
+					t.Errorf("#%d got %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+// testOrdering verifies that aggregates are listed in order they were created and their records are read in order of versions.
+func testOrdering(t *testing.T, newStore NewStoreFunc) {
+	s := newStore(t, nil)
+
+	var (
+		wallets []*ledger.WalletAggregate
+		ids     []string
+	)
+	for i := 0; i < 5; i++ {
+		wallet := newWallet(t, 100)
+		save(t, s, wallet)
+		wallets = append(wallets, wallet)
+		ids = append(ids, wallet.ID)
+
+		// earlier wallets are transacted in between
+		for _, v := range wallets {
+			transact(t, v, 1)
+			save(t, s, v)
+		}
+	}
+
+	got, err := s.IDs(context.Background(), &ledger.WalletAggregate{})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if !cmp.Equal(got, ids) {
+		t.Errorf("got %v, want %v", got, ids)
+	}
+
+	for _, wallet := range wallets {
+		for i, v := range records(t, s, wallet.ID) {
+			if v.Version != uint64(i+1) {
+				t.Errorf("%s got version %v, want %v", wallet.ID, v.Version, i+1)
+			}
+		}
+		compareWallets(t, restore(t, s, wallet.ID), wallet)
+	}
+}
+
+// testConcurrentAppends verifies that exactly one of concurrent appends to the same stream version succeeds.
+func testConcurrentAppends(t *testing.T, newStore NewStoreFunc) {
+	const writers = 16
+
+	s := newStore(t, nil)
+
+	wallet := newWallet(t, 100)
+	save(t, s, wallet)
+
+	copies := make([]*ledger.WalletAggregate, writers)
+	for i := range copies {
+		copies[i] = restore(t, s, wallet.ID)
+		transact(t, copies[i], i+1)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded []*ledger.WalletAggregate
+	)
+	for _, v := range copies {
+		wg.Add(1)
+		go func(wallet *ledger.WalletAggregate) {
+			defer wg.Done()
+			if err := s.Save(context.Background(), wallet); err == nil {
+				mu.Lock()
+				succeeded = append(succeeded, wallet)
+				mu.Unlock()
+			}
+		}(v)
+	}
+	wg.Wait()
+
+	if len(succeeded) != 1 {
+		t.Fatalf("got %v succeeded appends, want %v", len(succeeded), 1)
+	}
+	compareWallets(t, restore(t, s, wallet.ID), succeeded[0])
+}
+
+// testCancellation verifies that cancelled iteration is not mistaken for the end of the stream.
+func testCancellation(t *testing.T, newStore NewStoreFunc) {
+	const transactions = 64
+
+	s := newStore(t, nil)
+
+	amounts := make([]int, transactions)
+	for i := range amounts {
+		amounts[i] = 1
+	}
+	wallet := newWallet(t, amounts...)
+	save(t, s, wallet)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	it, err := s.Records(ctx, &ledger.WalletAggregate{}, wallet.ID)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	defer it.Close()
+
+	var n int
+	for it.Next() {
+		if n++; n == 1 {
+			cancel()
+		}
+	}
+	if n < transactions+1 && it.Error() == nil {
+		t.Errorf("got %v records without error, want %v records or error", n, transactions+1)
+	}
+
+	// aggregate is not restored partially.
+	var restored ledger.WalletAggregate
+	if err := s.Load(ctx, &restored, wallet.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
@@ -4,11 +4,20 @@ import "github.com/deividaspetraitis/go/errors"
 
 // Common service errors.
 var (
-	ErrEntryNotFound = errors.New("entry not found")
+	ErrEntryNotFound   = errors.New("entry not found")
+	ErrVersionConflict = errors.New("aggregate was modified concurrently")
 
 	ErrNotValidWalletName = errors.New("given wallet name is not a valid name")
 	ErrNotValidWalletID   = errors.New("given wallet name is not a valid ID")
+	ErrNotValidWalletTier = errors.New("given wallet tier is not a valid tier")
 
-	ErrNotValidTransaction = errors.New("given transaction type is not available")
-	ErrNotValidAmount      = errors.New("given transaction amount is not valid")
+	ErrNotValidTransaction   = errors.New("given transaction type is not available")
+	ErrNotValidTransactionID = errors.New("given transaction ID is not a valid ID")
+	ErrNotValidAmount        = errors.New("given transaction amount is not valid")
+	ErrNotValidKey           = errors.New("given idempotency key is not valid")
+	ErrNotValidDescription   = errors.New("given transaction description is not valid")
+	ErrNotValidReference     = errors.New("given transaction external reference is not valid")
+	ErrNotValidMetadata      = errors.New("given transaction metadata is not valid")
+
+	ErrDuplicateTransaction = errors.New("transaction with given idempotency key was processed already")
 )
@@ -0,0 +1,222 @@
+// Package fee implements transaction fee schedules.
+//
+// Fee schedule defines fee rules per transaction type and wallet tier. Fee consists of a proportional part
+// given in basis points of the transaction amount and a fixed part, optionally bounded by minimum and maximum.
+// Proportional part is rounded according to rounding rule of the ledger currency.
+package fee
+
+import (
+	"strings"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// Defaults.
+const (
+	DefaultTier     = "standard" // Tier of wallets created without one, used when wallet tier has no rule
+	DefaultCurrency = "EUR"      // Ledger currency when not configured
+)
+
+// ErrNotValidSchedule is returned when fee schedule configuration is not valid.
+var ErrNotValidSchedule = errors.New("given fee schedule is not valid")
+
+// Config represents fee schedule configuration.
+type Config struct {
+	Currency  string                      `mapstructure:"currency"`  // Ledger currency, defaults to DefaultCurrency
+	House     string                      `mapstructure:"house"`     // ID of the house revenue wallet fees are credited to, required if any rule is defined
+	Schedules map[string]map[string]*Rule `mapstructure:"schedules"` // Fee rules by transaction type and wallet tier
+	Rounding  map[string]*Rounding        `mapstructure:"rounding"`  // Rounding rules by currency
+}
+
+// Validate implements validator.Validator.
+func (c *Config) Validate() error {
+	_, err := New(c)
+	return err
+}
+
+// Rule represents fee rule. Amounts are in the currency minor units, e.g. cents.
+type Rule struct {
+	BasisPoints int `mapstructure:"bps"`   // Proportional part in hundredths of percent of the transaction amount
+	Fixed       int `mapstructure:"fixed"` // Fixed part
+	Min         int `mapstructure:"min"`   // Minimum fee
+	Max         int `mapstructure:"max"`   // Maximum fee, zero means unbounded
+}
+
+// Validate implements validator.Validator.
+func (r *Rule) Validate() error {
+	if r.BasisPoints < 0 || r.Fixed < 0 || r.Min < 0 || r.Max < 0 {
+		return errors.Wrap(ErrNotValidSchedule, "fee rule values can not be negative")
+	}
+	if r.Max > 0 && r.Max < r.Min {
+		return errors.Wrap(ErrNotValidSchedule, "maximum fee is less than minimum")
+	}
+	return nil
+}
+
+// Mode represents rounding mode.
+type Mode string
+
+// Supported rounding modes.
+const (
+	ModeHalfUp   Mode = "halfup"   // round half away from zero, default
+	ModeHalfEven Mode = "halfeven" // round half to even, aka bankers rounding
+	ModeUp       Mode = "up"       // round away from zero
+	ModeDown     Mode = "down"     // round towards zero
+)
+
+// Rounding represents currency rounding rule.
+type Rounding struct {
+	Mode      Mode `mapstructure:"mode"`      // Rounding mode, defaults to ModeHalfUp
+	Increment int  `mapstructure:"increment"` // Smallest amount in minor units fees are rounded to, e.g. 5 for CHF, defaults to 1
+}
+
+// Validate implements validator.Validator.
+func (r *Rounding) Validate() error {
+	switch r.Mode {
+	case ModeHalfUp, ModeHalfEven, ModeUp, ModeDown:
+	default:
+		return errors.Wrapf(ErrNotValidSchedule, "rounding mode %q is not supported", r.Mode)
+	}
+	if r.Increment < 1 {
+		return errors.Wrap(ErrNotValidSchedule, "rounding increment must be positive")
+	}
+	return nil
+}
+
+// round returns n/d rounded to the increment, n and d must be non-negative.
+func (r *Rounding) round(n, d int) int {
+	d *= r.Increment
+	q, rem := n/d, n%d
+
+	switch r.Mode {
+	case ModeUp:
+		if rem > 0 {
+			q++
+		}
+	case ModeDown:
+	case ModeHalfEven:
+		if 2*rem > d || (2*rem == d && q%2 == 1) {
+			q++
+		}
+	default:
+		if 2*rem >= d {
+			q++
+		}
+	}
+
+	return q * r.Increment
+}
+
+// Schedule calculates transaction fees.
+// It's safe to use nil Schedule, such schedule charges no fees.
+type Schedule struct {
+	currency string
+	house    string
+	rules    map[string]map[string]Rule
+	rounding Rounding
+}
+
+// New constructs a new Schedule. If cfg is nil schedule charging no fees is returned.
+func New(cfg *Config) (*Schedule, error) {
+	s := Schedule{
+		currency: DefaultCurrency,
+		rules:    make(map[string]map[string]Rule),
+		rounding: Rounding{Mode: ModeHalfUp, Increment: 1},
+	}
+	if cfg == nil {
+		return &s, nil
+	}
+
+	if len(cfg.Currency) > 0 {
+		s.currency = strings.ToUpper(cfg.Currency)
+	}
+	s.house = cfg.House
+
+	for currency, rounding := range cfg.Rounding {
+		if !strings.EqualFold(currency, s.currency) || rounding == nil {
+			continue
+		}
+		if len(rounding.Mode) > 0 {
+			s.rounding.Mode = rounding.Mode
+		}
+		if rounding.Increment != 0 {
+			s.rounding.Increment = rounding.Increment
+		}
+	}
+	if err := s.rounding.Validate(); err != nil {
+		return nil, err
+	}
+
+	for typ, tiers := range cfg.Schedules {
+		for tier, rule := range tiers {
+			if rule == nil {
+				continue
+			}
+			if err := rule.Validate(); err != nil {
+				return nil, errors.Wrapf(err, "%s %s", typ, tier)
+			}
+			for _, v := range []int{rule.Fixed, rule.Min, rule.Max} {
+				if v%s.rounding.Increment != 0 {
+					return nil, errors.Wrapf(ErrNotValidSchedule, "%s %s: amounts must be multiples of %s rounding increment", typ, tier, s.currency)
+				}
+			}
+
+			typ := strings.ToLower(typ)
+			if s.rules[typ] == nil {
+				s.rules[typ] = make(map[string]Rule)
+			}
+			s.rules[typ][strings.ToLower(tier)] = *rule
+		}
+	}
+
+	if len(s.rules) > 0 && len(s.house) == 0 {
+		return nil, errors.Wrap(ErrNotValidSchedule, "house revenue wallet is not configured")
+	}
+
+	return &s, nil
+}
+
+// Currency returns ledger currency.
+func (s *Schedule) Currency() string {
+	if s == nil {
+		return DefaultCurrency
+	}
+	return s.currency
+}
+
+// House returns ID of the house revenue wallet, empty if fees are not charged.
+func (s *Schedule) House() string {
+	if s == nil {
+		return ""
+	}
+	return s.house
+}
+
+// Fee returns fee of the transaction of given type and amount made by the wallet of given tier.
+// Transactions without a rule for the wallet tier are charged according to DefaultTier rule if any.
+func (s *Schedule) Fee(typ string, tier string, amount int) int {
+	if s == nil || amount <= 0 {
+		return 0
+	}
+
+	tiers := s.rules[strings.ToLower(typ)]
+	if len(tier) == 0 {
+		tier = DefaultTier
+	}
+
+	rule, ok := tiers[strings.ToLower(tier)]
+	if !ok {
+		if rule, ok = tiers[DefaultTier]; !ok {
+			return 0
+		}
+	}
+
+	fee := s.rounding.round(amount*rule.BasisPoints, 10000) + rule.Fixed
+	if fee < rule.Min {
+		fee = rule.Min
+	}
+	if rule.Max > 0 && fee > rule.Max {
+		fee = rule.Max
+	}
+	return fee
+}
@@ -0,0 +1,203 @@
+package fee
+
+import (
+	"testing"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+func TestScheduleFee(t *testing.T) {
+	const house = "a18c247b-8c28-468f-97a8-0bf33a48b922"
+
+	var testcases = []struct {
+		cfg    *Config
+		typ    string
+		tier   string
+		amount int
+
+		fee int
+	}{
+		// no schedule
+		{
+			cfg:    nil,
+			typ:    "WITHDRAW",
+			amount: 1000,
+			fee:    0,
+		},
+		// percentage plus fixed fee
+		{
+			cfg: &Config{House: house, Schedules: map[string]map[string]*Rule{
+				"withdraw": {"standard": {BasisPoints: 150, Fixed: 25}},
+			}},
+			typ:    "WITHDRAW",
+			amount: 1000,
+			fee:    40,
+		},
+		// transaction type without rule
+		{
+			cfg: &Config{House: house, Schedules: map[string]map[string]*Rule{
+				"withdraw": {"standard": {BasisPoints: 150, Fixed: 25}},
+			}},
+			typ:    "DEPOSIT",
+			amount: 1000,
+			fee:    0,
+		},
+		// tier rule
+		{
+			cfg: &Config{House: house, Schedules: map[string]map[string]*Rule{
+				"withdraw": {"standard": {BasisPoints: 150, Fixed: 25}, "premium": {BasisPoints: 50}},
+			}},
+			typ:    "withdraw",
+			tier:   "premium",
+			amount: 1000,
+			fee:    5,
+		},
+		// tier without rule falls back to default tier
+		{
+			cfg: &Config{House: house, Schedules: map[string]map[string]*Rule{
+				"withdraw": {"standard": {BasisPoints: 150, Fixed: 25}},
+			}},
+			typ:    "WITHDRAW",
+			tier:   "gold",
+			amount: 1000,
+			fee:    40,
+		},
+		// minimum fee
+		{
+			cfg: &Config{House: house, Schedules: map[string]map[string]*Rule{
+				"withdraw": {"standard": {BasisPoints: 100, Min: 50}},
+			}},
+			typ:    "WITHDRAW",
+			amount: 1000,
+			fee:    50,
+		},
+		// maximum fee
+		{
+			cfg: &Config{House: house, Schedules: map[string]map[string]*Rule{
+				"withdraw": {"standard": {BasisPoints: 100, Max: 500}},
+			}},
+			typ:    "WITHDRAW",
+			amount: 100000,
+			fee:    500,
+		},
+		// half up rounding, 1.5% of 1030 is 15.45
+		{
+			cfg: &Config{House: house, Schedules: map[string]map[string]*Rule{
+				"withdraw": {"standard": {BasisPoints: 150}},
+			}},
+			typ:    "WITHDRAW",
+			amount: 1030,
+			fee:    15,
+		},
+		// half up rounding, 0.5% of 1500 is 7.5
+		{
+			cfg: &Config{House: house, Schedules: map[string]map[string]*Rule{
+				"withdraw": {"standard": {BasisPoints: 50}},
+			}},
+			typ:    "WITHDRAW",
+			amount: 1500,
+			fee:    8,
+		},
+		// half even rounding, 0.5% of 1500 is 7.5
+		{
+			cfg: &Config{House: house, Currency: "eur", Schedules: map[string]map[string]*Rule{
+				"withdraw": {"standard": {BasisPoints: 50}},
+			}, Rounding: map[string]*Rounding{"EUR": {Mode: ModeHalfEven}}},
+			typ:    "WITHDRAW",
+			amount: 1500,
+			fee:    8,
+		},
+		// half even rounding, 0.5% of 1300 is 6.5
+		{
+			cfg: &Config{House: house, Schedules: map[string]map[string]*Rule{
+				"withdraw": {"standard": {BasisPoints: 50}},
+			}, Rounding: map[string]*Rounding{"eur": {Mode: ModeHalfEven}}},
+			typ:    "WITHDRAW",
+			amount: 1300,
+			fee:    6,
+		},
+		// rounding down, 0.5% of 1390 is 6.95
+		{
+			cfg: &Config{House: house, Schedules: map[string]map[string]*Rule{
+				"withdraw": {"standard": {BasisPoints: 50}},
+			}, Rounding: map[string]*Rounding{"eur": {Mode: ModeDown}}},
+			typ:    "WITHDRAW",
+			amount: 1390,
+			fee:    6,
+		},
+		// rounding up, 0.5% of 1210 is 6.05
+		{
+			cfg: &Config{House: house, Schedules: map[string]map[string]*Rule{
+				"withdraw": {"standard": {BasisPoints: 50}},
+			}, Rounding: map[string]*Rounding{"eur": {Mode: ModeUp}}},
+			typ:    "WITHDRAW",
+			amount: 1210,
+			fee:    7,
+		},
+		// rounding to increment, 1% of 1230 is 12.3 rounded to 10
+		{
+			cfg: &Config{House: house, Currency: "CHF", Schedules: map[string]map[string]*Rule{
+				"withdraw": {"standard": {BasisPoints: 100, Fixed: 5}},
+			}, Rounding: map[string]*Rounding{"chf": {Increment: 5}, "eur": {Increment: 1}}},
+			typ:    "WITHDRAW",
+			amount: 1230,
+			fee:    15,
+		},
+		// rounding rule of another currency is ignored
+		{
+			cfg: &Config{House: house, Schedules: map[string]map[string]*Rule{
+				"withdraw": {"standard": {BasisPoints: 100}},
+			}, Rounding: map[string]*Rounding{"chf": {Increment: 5}}},
+			typ:    "WITHDRAW",
+			amount: 1230,
+			fee:    12,
+		},
+	}
+
+	for i, tt := range testcases {
+		schedule, err := New(tt.cfg)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+
+		if fee := schedule.Fee(tt.typ, tt.tier, tt.amount); fee != tt.fee {
+			t.Errorf("#%d got %v, want %v", i, fee, tt.fee)
+		}
+	}
+}
+
+func TestNewNotValidSchedule(t *testing.T) {
+	const house = "a18c247b-8c28-468f-97a8-0bf33a48b922"
+
+	var testcases = []*Config{
+		// house wallet is not configured
+		{Schedules: map[string]map[string]*Rule{"withdraw": {"standard": {Fixed: 10}}}},
+		// negative values
+		{House: house, Schedules: map[string]map[string]*Rule{"withdraw": {"standard": {BasisPoints: -1}}}},
+		// maximum less than minimum
+		{House: house, Schedules: map[string]map[string]*Rule{"withdraw": {"standard": {Min: 10, Max: 5}}}},
+		// unknown rounding mode
+		{House: house, Rounding: map[string]*Rounding{"eur": {Mode: "nearest"}}},
+		// negative increment
+		{House: house, Rounding: map[string]*Rounding{"eur": {Increment: -5}}},
+		// fixed fee not a multiple of rounding increment
+		{House: house, Currency: "CHF", Schedules: map[string]map[string]*Rule{"withdraw": {"standard": {Fixed: 12}}}, Rounding: map[string]*Rounding{"chf": {Increment: 5}}},
+	}
+
+	for i, cfg := range testcases {
+		if _, err := New(cfg); !errors.Is(err, ErrNotValidSchedule) {
+			t.Errorf("#%d got %v, want %v", i, err, ErrNotValidSchedule)
+		}
+	}
+}
+
+func TestNilSchedule(t *testing.T) {
+	var schedule *Schedule
+
+	if fee := schedule.Fee("WITHDRAW", "", 1000); fee != 0 {
+		t.Errorf("fee got %v, want %v", fee, 0)
+	}
+	if currency := schedule.Currency(); currency != DefaultCurrency {
+		t.Errorf("currency got %v, want %v", currency, DefaultCurrency)
+	}
+}
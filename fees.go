@@ -0,0 +1,410 @@
+package ledger
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/fee"
+
+	"github.com/deividaspetraitis/go/database"
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+	"github.com/deividaspetraitis/go/log"
+	"github.com/deividaspetraitis/go/validator"
+)
+
+// feeMetrics exposes amounts of charged, collected and not collected fees.
+var feeMetrics = expvar.NewMap("fees")
+
+// ErrFeeCollected is returned when fee was collected already.
+var ErrFeeCollected = errors.New("fee was collected already")
+
+// FeeCharged represents wallet transaction fee event.
+// It's persisted within the same append as the transaction it was charged for.
+type FeeCharged struct {
+	WalletID    string `json:"wallet_id"`
+	Amount      int    `json:"amount"`
+	Transaction string `json:"transaction"` // Type of the transaction fee was charged for
+}
+
+// Implements es.MarshalUnmarshaler
+func (f *FeeCharged) UnmarshalJSON(b []byte) error {
+	type feeCharged FeeCharged
+	temp := feeCharged(*f)
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+	*f = FeeCharged(temp)
+	return nil
+}
+
+// Implements es.MarshalUnmarshaler
+func (f *FeeCharged) MarshalJSON() ([]byte, error) {
+	type feeCharged FeeCharged
+	temp := feeCharged(*f)
+	return json.Marshal(temp)
+}
+
+// FeeCollected represents house wallet revenue event of the fee charged to another wallet.
+type FeeCollected struct {
+	WalletID      string `json:"wallet_id"`
+	Amount        int    `json:"amount"`
+	Source        string `json:"source"`         // Wallet fee was charged to
+	SourceVersion uint64 `json:"source_version"` // Version of the FeeCharged event
+}
+
+// Implements es.MarshalUnmarshaler
+func (f *FeeCollected) UnmarshalJSON(b []byte) error {
+	type feeCollected FeeCollected
+	temp := feeCollected(*f)
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+	*f = FeeCollected(temp)
+	return nil
+}
+
+// Implements es.MarshalUnmarshaler
+func (f *FeeCollected) MarshalJSON() ([]byte, error) {
+	type feeCollected FeeCollected
+	temp := feeCollected(*f)
+	return json.Marshal(temp)
+}
+
+// feeSource identifies fee by the wallet it was charged to and version of its FeeCharged event.
+type feeSource struct {
+	wallet  string
+	version uint64
+}
+
+// charge tracks fee charged to the wallet by FeeCharged event of the given version.
+func (w *WalletAggregate) charge(version uint64, amount int) {
+	if w.charged == nil {
+		w.charged = make(map[uint64]int)
+	}
+	w.charged[version] = amount
+}
+
+// collect tracks fee collected by the wallet.
+func (w *WalletAggregate) collect(source feeSource) {
+	if w.collected == nil {
+		w.collected = make(map[feeSource]bool)
+	}
+	w.collected[source] = true
+}
+
+// pendingFees returns fees charged to the wallet which were not collected by the house wallet ordered by version.
+func (w *WalletAggregate) pendingFees(house *WalletAggregate) []*FeeCollected {
+	var fees []*FeeCollected
+	for version, amount := range w.charged {
+		if house.collected[feeSource{wallet: w.ID, version: version}] {
+			continue
+		}
+		fees = append(fees, &FeeCollected{
+			Amount:        amount,
+			Source:        w.ID,
+			SourceVersion: version,
+		})
+	}
+	sort.Slice(fees, func(i, j int) bool {
+		return fees[i].SourceVersion < fees[j].SourceVersion
+	})
+	return fees
+}
+
+// CollectFee credits fee charged to another wallet.
+// Fee is identified by its source wallet and version, if it was collected already ErrFeeCollected is returned.
+func (w *WalletAggregate) CollectFee(f *FeeCollected) error {
+	if f.Amount <= 0 || f.Source == w.ID {
+		return ErrNotValidAmount
+	}
+	if w.collected[feeSource{wallet: f.Source, version: f.SourceVersion}] {
+		return ErrFeeCollected
+	}
+
+	return w.Apply(es.NewEvent(w.ID, w, &FeeCollected{
+		WalletID:      w.ID,
+		Amount:        f.Amount,
+		Source:        f.Source,
+		SourceVersion: f.SourceVersion,
+	}))
+}
+
+// transactionFee returns fee of the transaction made by the wallet, house wallet is not charged.
+func transactionFee(fees *fee.Schedule, wallet *Wallet, tx *Transaction) int {
+	if wallet.ID == fees.House() {
+		return 0
+	}
+	return fees.Fee(tx.Type, wallet.Tier, tx.Amount)
+}
+
+// feesCharged returns fees charged by events pending to be persisted as fees to be collected by the house wallet.
+func feesCharged(wallet *WalletAggregate) []*FeeCollected {
+	var fees []*FeeCollected
+	for _, v := range wallet.Events() {
+		if e, ok := v.Data.(*FeeCharged); ok {
+			fees = append(fees, &FeeCollected{
+				Amount:        e.Amount,
+				Source:        v.AggregateID,
+				SourceVersion: uint64(v.Version),
+			})
+		}
+	}
+	return fees
+}
+
+// charged records persisted fees.
+func charged(fees []*FeeCollected) {
+	for _, v := range fees {
+		feeMetrics.Add("charged", int64(v.Amount))
+	}
+}
+
+// collected records outcome of crediting the fee to the house wallet.
+// Fee which could not be collected has been charged already, so it's logged and collected later by Collector.
+func collected(f *FeeCollected, err error) {
+	if errors.Is(err, ErrFeeCollected) {
+		return
+	}
+	if err != nil {
+		feeMetrics.Add("uncollected", int64(f.Amount))
+		log.WithError(err).WithFields(log.Fields{
+			"source":  f.Source,
+			"version": f.SourceVersion,
+			"amount":  f.Amount,
+		}).Println("unable to collect fee")
+		return
+	}
+	feeMetrics.Add("collected", int64(f.Amount))
+}
+
+// collectFee credits fee to the house wallet, fee collected already is ignored.
+func collectFee(ctx context.Context, saveAggregate database.SaveAggregateFunc, getWallet database.GetAggregateFunc[*WalletAggregate], house string, f *FeeCollected) error {
+	wallet, err := getWallet(ctx, &WalletAggregate{}, house)
+	if err != nil {
+		return errors.Wrap(err, "unable to retrieve house wallet")
+	}
+
+	if err := wallet.CollectFee(f); err != nil {
+		if errors.Is(err, ErrFeeCollected) {
+			return nil
+		}
+		return err
+	}
+
+	return saveAggregate(ctx, wallet)
+}
+
+// Default fee collection configuration values.
+const defaultCollectorInterval = time.Minute
+
+// CollectorConfig represents configuration of collecting fees which were not collected right away.
+type CollectorConfig struct {
+	Interval time.Duration `mapstructure:"interval"` // How often fees pending to be collected are looked for
+}
+
+// Validate implements validator.Validator.
+func (c *CollectorConfig) Validate() error {
+	if c.Interval < 0 {
+		return errors.New("interval can not be negative")
+	}
+	return nil
+}
+
+// Collector credits fees charged to wallets which were not collected by the house wallet.
+//
+// Fees are collected right after transactions are persisted, but collection may fail or never happen,
+// e.g. service stops meanwhile. Every interval Collector derives pending fees from persisted FeeCharged events
+// of all wallets and credits the ones house wallet did not collect yet. Collected fees are recorded on the house wallet
+// by their source wallet and version, thus fee is never collected twice and it's retried until it's collected.
+// Wallets retrieved by a collection are kept and caught up by the following ones, reading only events persisted meanwhile.
+type Collector struct {
+	interval time.Duration
+	fees     func() *fee.Schedule
+	get      database.GetAggregateFunc[*WalletAggregate]
+	ids      IDsFunc
+	execute  ExecuteFunc
+
+	mu      sync.Mutex                  // serialises collections
+	wallets map[string]*WalletAggregate // wallets retrieved by previous collections by their IDs
+}
+
+// NewCollector constructs a new Collector crediting fees to the house wallet of the fees schedule,
+// wallets are discovered using ids and get and fees are credited using execute.
+func NewCollector(cfg *CollectorConfig, fees func() *fee.Schedule, get database.GetAggregateFunc[*WalletAggregate], ids IDsFunc, execute ExecuteFunc) *Collector {
+	c := Collector{
+		interval: defaultCollectorInterval,
+		fees:     fees,
+		get:      get,
+		ids:      ids,
+		execute:  execute,
+		wallets:  make(map[string]*WalletAggregate),
+	}
+	if cfg != nil && cfg.Interval > 0 {
+		c.interval = cfg.Interval
+	}
+	return &c
+}
+
+// Run collects pending fees every configured interval until ctx is done.
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.Collect(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect credits fees charged to all wallets which were not collected yet to the house wallet.
+func (c *Collector) Collect(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	house := c.fees().House()
+	if len(house) == 0 {
+		return
+	}
+
+	ids, err := c.ids(ctx, &WalletAggregate{})
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"component": "collector",
+		}).Println("unable to discover wallets")
+		return
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		if id == house {
+			continue
+		}
+
+		// house wallet is caught up for every wallet as fees are collected meanwhile
+		collector, err := c.load(ctx, house)
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"component": "collector",
+				"wallet":    house,
+			}).Println("unable to retrieve house wallet")
+			return
+		}
+
+		wallet, err := c.load(ctx, id)
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"component": "collector",
+				"wallet":    id,
+			}).Println("unable to retrieve wallet")
+			continue
+		}
+
+		pending := wallet.pendingFees(collector)
+		if len(pending) == 0 {
+			continue
+		}
+
+		var applied []*FeeCollected
+		_, err = c.execute(ctx, house, func(w *WalletAggregate) error {
+			// operation is applied once again if house wallet is reloaded
+			applied = nil
+			for _, f := range pending {
+				if err := w.CollectFee(f); err != nil {
+					if errors.Is(err, ErrFeeCollected) {
+						continue
+					}
+					return err
+				}
+				applied = append(applied, f)
+			}
+			if len(applied) == 0 {
+				return ErrFeeCollected
+			}
+			return nil
+		})
+		if err != nil {
+			applied = pending
+		}
+		for _, f := range applied {
+			collected(f, err)
+		}
+	}
+}
+
+// load retrieves wallet catching up the one retrieved by previous collections, if any.
+// Caller must hold c.mu.
+func (c *Collector) load(ctx context.Context, id string) (*WalletAggregate, error) {
+	wallet, ok := c.wallets[id]
+	if !ok {
+		wallet = &WalletAggregate{}
+	}
+
+	wallet, err := c.get(ctx, wallet, id)
+	if err != nil {
+		// wallet might be restored partially
+		delete(c.wallets, id)
+		return nil, err
+	}
+	c.wallets[id] = wallet
+	return wallet, nil
+}
+
+// Quote represents fee preview of the transaction.
+type Quote struct {
+	Type     string // Transaction type
+	WalletID string // Wallet identifier for the transaction
+	Amount   int    // Amount of the transaction
+	Fee      int    // Fee charged on top of the transaction
+	Total    int    // Amount debited including fee for withdrawals, amount credited net of fee for deposits
+	Currency string // Ledger currency
+}
+
+// QuoteTransaction previews fee of the transaction without processing it.
+func QuoteTransaction(ctx context.Context, getWallet database.GetAggregateFunc[*WalletAggregate], fees *fee.Schedule, req *TransactionRequest) (*Quote, error) {
+	if err := validator.Validate(req); err != nil {
+		return nil, err
+	}
+
+	tx := &Transaction{
+		Type:     req.Type,
+		WalletID: req.WalletID,
+		Amount:   req.Amount,
+	}
+	if err := validator.Validate(tx); err != nil {
+		return nil, err
+	}
+
+	wallet, err := getWallet(ctx, &WalletAggregate{}, req.WalletID)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := Quote{
+		Type:     strings.ToUpper(tx.Type),
+		WalletID: tx.WalletID,
+		Amount:   tx.Amount,
+		Fee:      transactionFee(fees, &wallet.Wallet, tx),
+		Currency: fees.Currency(),
+	}
+	switch quote.Type {
+	case TransactionDeposit:
+		quote.Total = quote.Amount - quote.Fee
+	case TransactionWithdraw:
+		quote.Total = quote.Amount + quote.Fee
+	}
+
+	return &quote, nil
+}
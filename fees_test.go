@@ -0,0 +1,130 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/go/es"
+)
+
+func TestWalletCollectFee(t *testing.T) {
+	store := newMemStore(0)
+	house, err := GetWallet(context.Background(), store.get, store.createWallet(t))
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	source := newID()
+
+	var testcases = []struct {
+		fee     *FeeCollected
+		balance int
+		err     error
+	}{
+		{&FeeCollected{Amount: 10, Source: source, SourceVersion: 2}, 10, nil},
+		{&FeeCollected{Amount: 10, Source: source, SourceVersion: 2}, 10, ErrFeeCollected}, // the same fee again
+		{&FeeCollected{Amount: 5, Source: source, SourceVersion: 3}, 15, nil},
+		{&FeeCollected{Amount: 5, Source: newID(), SourceVersion: 3}, 20, nil}, // the same version of another wallet
+		{&FeeCollected{Amount: 0, Source: source, SourceVersion: 4}, 20, ErrNotValidAmount},
+		{&FeeCollected{Amount: 5, Source: house.ID, SourceVersion: 4}, 20, ErrNotValidAmount},
+	}
+
+	wallet, err := store.get(context.Background(), &WalletAggregate{}, house.ID)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	for i, tt := range testcases {
+		if err := wallet.CollectFee(tt.fee); err != tt.err {
+			t.Errorf("#%d got %v, want %v", i, err, tt.err)
+		}
+		if wallet.Balance != tt.balance {
+			t.Errorf("#%d balance got %v, want %v", i, wallet.Balance, tt.balance)
+		}
+	}
+
+	// collected fees are tracked by restored and cloned wallets
+	if err := store.save(context.Background(), wallet); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	restored, err := store.get(context.Background(), &WalletAggregate{}, house.ID)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	for _, w := range []*WalletAggregate{restored, restored.Clone()} {
+		if err := w.CollectFee(testcases[0].fee); err != ErrFeeCollected {
+			t.Errorf("got %v, want %v", err, ErrFeeCollected)
+		}
+	}
+}
+
+func TestCollector(t *testing.T) {
+	store := newMemStore(0)
+	house := store.createWallet(t)
+	id := store.createWallet(t)
+	fees := newFeeSchedule(t, house)
+
+	ids := func(ctx context.Context, aggregate es.Aggregate) ([]string, error) {
+		return []string{house, id}, nil
+	}
+
+	// fees are charged, but never collected, e.g. service stopped right after persisting transactions
+	ctx := context.Background()
+	for _, tx := range []*Transaction{
+		{Type: TransactionDeposit, WalletID: id, Amount: 1000},
+		{Type: TransactionWithdraw, WalletID: id, Amount: 500, Fee: 15},
+		{Type: TransactionWithdraw, WalletID: id, Amount: 100, Fee: 11},
+	} {
+		wallet, err := store.get(ctx, &WalletAggregate{}, id)
+		if err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+		if err := wallet.ProcessTransaction(tx); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+		if err := store.save(ctx, wallet); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+	}
+
+	processor := NewProcessor(nil, store.save, store.get, fees)
+	collector := NewCollector(&CollectorConfig{Interval: time.Hour}, processor.Fees, store.get, ids, processor.Execute)
+
+	// pending fees are collected once however many times collection runs
+	collector.Collect(ctx)
+	collector.Collect(ctx)
+
+	// wallets unchanged since the previous collection are not read again
+	store.mu.Lock()
+	reads := store.reads
+	store.mu.Unlock()
+
+	collector.Collect(ctx)
+
+	store.mu.Lock()
+	if store.reads != reads {
+		t.Errorf("events read got %v, want %v", store.reads-reads, 0)
+	}
+	store.mu.Unlock()
+
+	wallet, err := GetWallet(ctx, store.get, house)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if wallet.Balance != 26 {
+		t.Errorf("house balance got %v, want %v", wallet.Balance, 26)
+	}
+
+	store.mu.Lock()
+	stream := store.streams[house]
+	store.mu.Unlock()
+
+	var versions []uint64
+	for _, v := range stream {
+		if e, ok := v.(*FeeCollected); ok {
+			versions = append(versions, e.SourceVersion)
+		}
+	}
+	if len(versions) != 2 || versions[0] != 4 || versions[1] != 6 {
+		t.Errorf("collected versions got %v, want %v", versions, []uint64{4, 6})
+	}
+}
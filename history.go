@@ -0,0 +1,174 @@
+package ledger
+
+import (
+	"context"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// RecordsFunc returns iterator over persisted records of the aggregate.
+type RecordsFunc func(ctx context.Context, aggregate es.Aggregate, id string) (schema.Iterator, error)
+
+// HistoryEntry represents persisted wallet transaction.
+type HistoryEntry struct {
+	ID                string            // Transaction identifier, empty for transactions persisted before identifiers were introduced
+	WalletID          string            // Wallet identifier
+	Version           uint64            // Version of the transaction event within wallet stream
+	Type              string            // TransactionDeposit or TransactionWithdraw
+	Amount            int               // Amount in cents
+	Description       string            // Transaction description
+	ExternalReference string            // Reference of the transaction in external system
+	Metadata          map[string]string // Key/value data attached to the transaction
+	Timestamp         time.Time         // Time transaction was persisted
+}
+
+// NewHistoryEntry constructs history entry of the wallet event, it returns nil if event is not a transaction.
+func NewHistoryEntry(walletID string, version uint64, timestamp time.Time, event es.MarshalUnmarshaler) *HistoryEntry {
+	entry := HistoryEntry{
+		WalletID:  walletID,
+		Version:   version,
+		Timestamp: timestamp,
+	}
+	switch e := event.(type) {
+	case *Deposit:
+		entry.ID, entry.Type, entry.Amount = e.ID, TransactionDeposit, e.Amount
+		entry.Description, entry.ExternalReference, entry.Metadata = e.Description, e.ExternalReference, e.Metadata
+	case *Withdraw:
+		entry.ID, entry.Type, entry.Amount = e.ID, TransactionWithdraw, e.Amount
+		entry.Description, entry.ExternalReference, entry.Metadata = e.Description, e.ExternalReference, e.Metadata
+	default:
+		return nil
+	}
+	return &entry
+}
+
+// History returns transactions of the wallet in the order they were persisted.
+// Events not known to the service are skipped. If wallet does not exist ErrEntryNotFound is returned.
+func History(ctx context.Context, records RecordsFunc, walletID string) ([]*HistoryEntry, error) {
+	it, err := records(ctx, &WalletAggregate{}, walletID)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var (
+		entries []*HistoryEntry
+		found   bool
+	)
+	for it.Next() {
+		record, err := it.Value()
+		if err != nil {
+			return nil, err
+		}
+		found = true
+
+		event, err := schema.Decode(&WalletAggregate{}, record.Type, record.Data)
+		if err != nil {
+			// unknown events are not transactions known to the service
+			if errors.Is(err, schema.ErrUnknownEvent) {
+				continue
+			}
+			return nil, err
+		}
+
+		if entry := NewHistoryEntry(walletID, record.Version, record.Timestamp, event); entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, ErrEntryNotFound
+	}
+	return entries, nil
+}
+
+// DayEnd represents state of the wallet at the end of a day.
+type DayEnd struct {
+	Date   time.Time // Day, midnight UTC
+	Wallet Wallet    // Wallet state at the end of the day
+}
+
+// EndOfDay reconstructs end-of-day states of the wallet for every day from and until to, exclusive, from its persisted records.
+// Event belongs to the day it was persisted at in UTC, days before wallet was initialised hold zero state.
+// If from is zero days are reconstructed starting at the day of the first record.
+// Unknown events result in *schema.UnknownEventError under schema.PolicyFail and are skipped otherwise,
+// quarantining them is left to restoring of the wallet.
+func EndOfDay(it schema.Iterator, id string, from, to time.Time, unknown schema.Policy) ([]DayEnd, error) {
+	from, to = midnight(from), midnight(to)
+
+	var (
+		wallet WalletAggregate
+		days   []DayEnd
+		day    = from
+	)
+
+	// emit emits days ended before t.
+	emit := func(t time.Time) {
+		for day.Before(to) && !day.AddDate(0, 0, 1).After(t) {
+			days = append(days, DayEnd{
+				Date:   day,
+				Wallet: wallet.Wallet,
+			})
+			day = day.AddDate(0, 0, 1)
+		}
+	}
+
+	for it.Next() {
+		record, err := it.Value()
+		if err != nil {
+			return nil, err
+		}
+
+		if day.IsZero() {
+			day = midnight(record.Timestamp)
+		}
+		emit(record.Timestamp.UTC())
+		if !day.Before(to) {
+			break
+		}
+
+		event, err := schema.Decode(&wallet, record.Type, record.Data)
+		if err != nil {
+			if !errors.Is(err, schema.ErrUnknownEvent) {
+				return nil, err
+			}
+			switch unknown {
+			case schema.PolicySkip, schema.PolicyQuarantine:
+				// version must be advanced to keep wallet in line with the stream.
+				wallet.Root().AdvanceVersion()
+				continue
+			default:
+				return nil, &schema.UnknownEventError{AggregateID: id, Version: record.Version, Type: record.Type, Err: err}
+			}
+		}
+		if err := wallet.Reply([]*es.Event{es.NewEvent(id, &wallet, event)}); err != nil {
+			return nil, errors.Wrapf(err, "version %d", record.Version)
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+
+	if !day.IsZero() {
+		emit(to)
+	}
+
+	return days, nil
+}
+
+// midnight returns beginning of the day of t in UTC, zero time is returned as is.
+func midnight(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/deividaspetraitis/go/log"
+)
+
+// rejectPrincipal is rejection reason of requests of principals not allowed to call the route group.
+const rejectPrincipal = "principal"
+
+// Authorizer restricts route groups to API clients authenticated by TLS client certificates of allowed principals.
+// Route group is the first segment of the request path, e.g. admin for /admin/wallets/{id}/overdraft.
+type Authorizer struct {
+	groups map[string]map[string]bool // allowed principals by route group
+}
+
+// NewAuthorizer constructs a new Authorizer restricting route groups according to cfg.Access.
+// If no route group is restricted every request is authorized.
+func NewAuthorizer(cfg *TLSConfig) *Authorizer {
+	a := Authorizer{
+		groups: make(map[string]map[string]bool),
+	}
+	if cfg == nil {
+		return &a
+	}
+	for group, principals := range cfg.Access {
+		allowed := make(map[string]bool)
+		for _, v := range principals {
+			allowed[strings.TrimSpace(v)] = true
+		}
+		a.groups[strings.ToLower(group)] = allowed
+	}
+	return &a
+}
+
+// Authorize responds with HTTP 403 to requests of restricted route groups unless API client principal is allowed to call them.
+// Authorize implements mux.MiddlewareFunc.
+func (a *Authorizer) Authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, restricted := a.groups[routeGroup(r)]
+		if principal := Principal(r); restricted && (len(principal) == 0 || !allowed[principal]) {
+			rejections.Add(rejectPrincipal, 1)
+
+			log.WithFields(log.Fields{
+				"handler":   "authorizer",
+				"principal": principal,
+				"path":      r.URL.Path,
+			}).Debugln("request forbidden")
+
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeGroup returns route group of the request, i.e. the first segment of its path in lower case.
+func routeGroup(r *http.Request) string {
+	group, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	return strings.ToLower(group)
+}
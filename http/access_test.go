@@ -0,0 +1,100 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/deividaspetraitis/go/log"
+)
+
+func TestAuthorizer(t *testing.T) {
+	dir := t.TempDir()
+	cfg := TLSConfig{
+		Cert:     filepath.Join(dir, "cert.pem"),
+		Key:      filepath.Join(dir, "key.pem"),
+		ClientCA: filepath.Join(dir, "ca.pem"),
+		Access:   map[string][]string{"admin": {"ops", "treasury"}, "Debug": {"ops"}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	ca := newAuthority(t, "ca")
+	cert, key := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	writeFile(t, cfg.Cert, cert)
+	writeFile(t, cfg.Key, key)
+	writeFile(t, cfg.ClientCA, ca.pem)
+
+	config, err := NewTLSConfig(&cfg, log.Default())
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	server := httptest.NewUnstartedServer(NewAuthorizer(&cfg).Authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, Principal(r))
+	})))
+	server.TLS = config
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	client := func(name string) *http.Client {
+		cert, key := ca.issue(t, name, x509.ExtKeyUsageClientAuth)
+		v, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+		return newTLSClient(ca, v)
+	}
+
+	var testcases = []struct {
+		principal string
+		path      string
+		status    int
+	}{
+		{"ops", "/admin/wallets/a18c247b-8c28-468f-97a8-0bf33a48b922/overdraft", http.StatusOK},
+		{"treasury", "/admin/wallets/a18c247b-8c28-468f-97a8-0bf33a48b922/interest", http.StatusOK},
+		{"payments", "/admin/wallets/a18c247b-8c28-468f-97a8-0bf33a48b922/overdraft", http.StatusForbidden},
+		{"payments", "/Admin/wallets", http.StatusForbidden},
+		{"Ops", "/admin", http.StatusForbidden}, // principals are case sensitive
+		{"treasury", "/debug/vars", http.StatusForbidden},
+		{"ops", "/debug/vars", http.StatusOK},
+		{"payments", "/wallets/a18c247b-8c28-468f-97a8-0bf33a48b922", http.StatusOK}, // not restricted
+		{"payments", "/administration", http.StatusOK},
+	}
+
+	for i, tt := range testcases {
+		resp, err := client(tt.principal).Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("#%d got %v, want %v", i, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+func TestAuthorizerWithoutCertificate(t *testing.T) {
+	authorizer := NewAuthorizer(&TLSConfig{Access: map[string][]string{"admin": {"ops"}}})
+	handler := authorizer.Authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var testcases = []struct {
+		path   string
+		status int
+	}{
+		{"/admin/wallets/a18c247b-8c28-468f-97a8-0bf33a48b922/chain", http.StatusForbidden},
+		{"/transactions", http.StatusOK},
+	}
+
+	for i, tt := range testcases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("#%d got %v, want %v", i, w.Code, tt.status)
+		}
+	}
+}
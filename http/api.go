@@ -2,14 +2,17 @@ package http
 
 import (
 	"context"
+	"expvar"
 	"net/http"
 	"os"
 
 	"github.com/deividaspetraitis/ledger"
-	db "github.com/deividaspetraitis/ledger/database/esdb"
+	"github.com/deividaspetraitis/ledger/index"
+	"github.com/deividaspetraitis/ledger/interest"
+	"github.com/deividaspetraitis/ledger/reconcile"
+	"github.com/deividaspetraitis/ledger/scheduler"
 
-	"github.com/deividaspetraitis/go/database/esdb"
-	"github.com/deividaspetraitis/go/es"
+	"github.com/deividaspetraitis/go/database"
 	libhttp "github.com/deividaspetraitis/go/http"
 	"github.com/deividaspetraitis/go/log"
 
@@ -17,7 +20,16 @@ import (
 )
 
 // API constructs an http.Handler with all application routes defined.
-func API(shutdown chan os.Signal, cfg *Config, logger log.Logger, esclient *esdb.Client) http.Handler {
+// Wallets are persisted using save and retrieved using get,
+// transactions are processed by processor which serialises them per wallet.
+// Wallet events hash chain is verified and its head exported using getChainHead,
+// bank statements are reconciled by reconciler, scheduled transactions are managed by schedules
+// credit lines are set through accrual which tracks them for interest accrual and savings interest terms are set through poster.
+// Wallet transactions are read from records and looked up by ID or external reference using transactions index.
+// Requests are rate limited and load is shed by limiter, which may be reconfigured while API is serving,
+// route groups are restricted to API clients of allowed principals by authorizer and toggled by toggles,
+// which may be reconfigured while API is serving as well.
+func API(shutdown chan os.Signal, limiter *Limiter, authorizer *Authorizer, toggles *Toggles, logger log.Logger, save database.SaveAggregateFunc, get database.GetAggregateFunc[*ledger.WalletAggregate], processor *ledger.Processor, getChainHead getChainHeadFunc, reconciler *reconcile.Reconciler, schedules *scheduler.Scheduler, accrual *ledger.Accrual, poster *interest.Poster, records ledger.RecordsFunc, transactions *index.Index) http.Handler {
 	// =========================================================================
 	// Construct the web app api which holds all routes as well as common Middleware.
 
@@ -28,30 +40,75 @@ func API(shutdown chan os.Signal, cfg *Config, logger log.Logger, esclient *esdb
 
 	// POST /wallet creates a wallet.
 	api.API.HandleFunc("/wallets", CreateWallet(func(ctx context.Context, req *ledger.CreateWalletRequest) (*ledger.Wallet, error) {
-		return ledger.CreateWallet(ctx, func(ctx context.Context, aggregate es.Aggregate) error {
-			return db.Save(ctx, esclient, aggregate)
-		}, req)
+		return ledger.CreateWallet(ctx, save, req)
 	})).Methods(http.MethodPost)
 
 	// GET /wallet/{id} retrieves a wallet.
-	api.API.HandleFunc("/wallets/{id}", GetWallet(func(ctx context.Context, id string) (*ledger.Wallet, error) {
-		return ledger.GetWallet(ctx, func(ctx context.Context, aggregate es.Aggregate, id string) (*ledger.WalletAggregate, error) {
-			return db.Get[*ledger.WalletAggregate](ctx, esclient, aggregate, id)
-		}, id)
-	})).Methods(http.MethodGet)
+	api.API.Handle("/wallets/{id}", limiter.Wallet(walletIDFromPath, GetWallet(func(ctx context.Context, id string) (*ledger.Wallet, error) {
+		return ledger.GetWallet(ctx, get, id)
+	}))).Methods(http.MethodGet)
 
 	// POST /transactions creates a new transaction.
-	api.API.HandleFunc("/transactions", CreateTransaction(func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Wallet, error) {
-		return ledger.CreateTransaction(ctx, func(ctx context.Context, aggregate es.Aggregate) error {
-			return db.Save(ctx, esclient, aggregate)
-		}, func(ctx context.Context, aggregate es.Aggregate, id string) (*ledger.WalletAggregate, error) {
-			return db.Get[*ledger.WalletAggregate](ctx, esclient, aggregate, id)
-		}, req)
-	})).Methods(http.MethodPost)
+	api.API.Handle("/transactions", limiter.Wallet(walletIDFromBody, CreateTransaction(processor.CreateTransaction))).Methods(http.MethodPost)
+
+	// GET /transactions?external_reference={reference} searches transactions by external reference.
+	api.API.HandleFunc("/transactions", FindTransactions(transactions.FindByReference)).Methods(http.MethodGet)
+
+	// GET /transactions/{id} retrieves a transaction.
+	api.API.HandleFunc("/transactions/{id}", GetTransaction(transactions.FindByID)).Methods(http.MethodGet)
+
+	// GET /wallets/{id}/transactions retrieves wallet transactions.
+	api.API.Handle("/wallets/{id}/transactions", limiter.Wallet(walletIDFromPath, GetHistory(func(ctx context.Context, id string) ([]*ledger.HistoryEntry, error) {
+		return ledger.History(ctx, records, id)
+	}))).Methods(http.MethodGet)
+
+	// POST /transactions/quote previews transaction fee.
+	api.API.Handle("/transactions/quote", limiter.Wallet(walletIDFromBody, QuoteTransaction(processor.QuoteTransaction))).Methods(http.MethodPost)
+
+	// GET /admin/wallets/{id}/chain verifies wallet events hash chain and exports its signed head.
+	api.API.HandleFunc("/admin/wallets/{id}/chain", GetChainHead(getChainHead)).Methods(http.MethodGet)
+
+	// PUT /admin/wallets/{id}/overdraft sets wallet credit line.
+	api.API.HandleFunc("/admin/wallets/{id}/overdraft", SetOverdraft(accrual.SetOverdraft)).Methods(http.MethodPut)
+
+	// PUT /admin/wallets/{id}/interest sets wallet interest terms.
+	api.API.HandleFunc("/admin/wallets/{id}/interest", SetInterestTerms(poster.SetTerms)).Methods(http.MethodPut)
+
+	// POST /reconciliations reconciles bank statement against wallets transactions.
+	api.API.HandleFunc("/reconciliations", CreateReconciliation(reconciler.Reconcile)).Methods(http.MethodPost)
+
+	// POST /schedules creates a scheduled or recurring transaction.
+	api.API.Handle("/schedules", limiter.Wallet(walletIDFromBody, CreateSchedule(schedules.Create))).Methods(http.MethodPost)
+
+	// GET /schedules/{id} retrieves a schedule.
+	api.API.HandleFunc("/schedules/{id}", GetSchedule(schedules.Get)).Methods(http.MethodGet)
+
+	// POST /schedules/{id}/pause, /resume and /cancel change schedule status.
+	api.API.HandleFunc("/schedules/{id}/pause", ChangeSchedule(schedules.Pause)).Methods(http.MethodPost)
+	api.API.HandleFunc("/schedules/{id}/resume", ChangeSchedule(schedules.Resume)).Methods(http.MethodPost)
+	api.API.HandleFunc("/schedules/{id}/cancel", ChangeSchedule(schedules.Cancel)).Methods(http.MethodPost)
 
 	router := mux.NewRouter()
 
+	// GET /openapi.json exposes OpenAPI document of the API, GET /docs renders it.
+	router.HandleFunc("/openapi.json", GetOpenAPI()).Methods(http.MethodGet)
+	router.HandleFunc("/docs", GetDocs()).Methods(http.MethodGet)
+
 	router.PathPrefix("/").Handler(api.API)
 
+	// forbid requests of not allowed principals and disabled route groups first, then shed load and limit requests rate per client.
+	router.Use(authorizer.Authorize, toggles.Gate, limiter.Shed, limiter.Client)
+
+	return router
+}
+
+// Debug constructs an http.Handler exposing service metrics. Metrics reveal process command line, memory statistics
+// and per-wallet counters, thus it's served on a separate listener which should be reachable by operators only.
+func Debug() http.Handler {
+	router := mux.NewRouter()
+
+	// GET /debug/vars exposes service metrics.
+	router.Handle("/debug/vars", expvar.Handler()).Methods(http.MethodGet)
+
 	return router
 }
@@ -0,0 +1,26 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestDebug verifies that metrics are served by debug handler only.
+func TestDebug(t *testing.T) {
+	var testcases = []struct {
+		handler http.Handler
+		status  int
+	}{
+		{API(nil, NewLimiter(LimitsConfig{}), NewAuthorizer(nil), NewToggles(nil), nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil), http.StatusNotFound},
+		{Debug(), http.StatusOK},
+	}
+
+	for i, tt := range testcases {
+		rr := httptest.NewRecorder()
+		tt.handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+		if rr.Code != tt.status {
+			t.Errorf("#%d got %v, want %v", i, rr.Code, tt.status)
+		}
+	}
+}
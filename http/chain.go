@@ -0,0 +1,68 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+
+	"github.com/deividaspetraitis/go/errors"
+	libhttp "github.com/deividaspetraitis/go/http"
+	"github.com/deividaspetraitis/go/log"
+)
+
+// getChainHeadFunc verifies wallet events hash chain and returns its signed head.
+type getChainHeadFunc func(ctx context.Context, id string) (*schema.SignedHead, error)
+
+// GetChainHead handles HTTP requests for verifying wallet events hash chain and exporting its signed head.
+func GetChainHead(getChainHead getChainHeadFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// It's always json.
+		w.Header().Set("Content-Type", "application/json")
+
+		var request api.GetWalletRequest
+		if err := libhttp.UnmarshalRequest(r, &request); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "chain",
+				"method":  "GetChainHead",
+			}).Println("unable to unmarshal request data")
+
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		head, err := getChainHead(r.Context(), request.Parse())
+		if err != nil {
+			switch {
+			case err == ledger.ErrEntryNotFound:
+				w.WriteHeader(http.StatusNotFound)
+			case errors.Is(err, schema.ErrChainBroken):
+				log.WithError(err).WithFields(log.Fields{
+					"handler": "chain",
+					"method":  "GetChainHead",
+				}).Errorln("wallet events hash chain is broken")
+				w.WriteHeader(http.StatusConflict)
+			default:
+				log.WithError(err).WithFields(log.Fields{
+					"handler": "chain",
+					"method":  "GetChainHead",
+				}).Println("unable to verify wallet events hash chain")
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := libhttp.Marshal(w, api.NewChainHeadResponse(head)); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "chain",
+				"method":  "GetChainHead",
+			}).Println("unable to marshal response data")
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
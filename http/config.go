@@ -1,6 +1,82 @@
 package http
 
+import (
+	"net"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
 // Config represents HTTP server configuration.
 type Config struct {
-	Address string `mapstructure:"address"` // HTTP server address
+	Address      string       `mapstructure:"address"`      // HTTP server address
+	DebugAddress string       `mapstructure:"debugaddress"` // Address service metrics are served on, empty disables it
+	Limits       LimitsConfig `mapstructure:"limits"`       // Rate limiting and load shedding
+	TLS          TLSConfig    `mapstructure:"tls"`          // TLS and client certificates authentication
+
+	// Features toggles route groups by route group, e.g. schedules, groups not listed are enabled, see Toggles.
+	Features map[string]bool `mapstructure:"features"`
+}
+
+// Validate implements validator.Validator.
+func (c *Config) Validate() error {
+	if len(c.Address) == 0 {
+		return errors.New("address is required")
+	}
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return errors.Wrapf(err, "address %q is not valid", c.Address)
+	}
+	if len(c.DebugAddress) > 0 {
+		if _, _, err := net.SplitHostPort(c.DebugAddress); err != nil {
+			return errors.Wrapf(err, "debug address %q is not valid", c.DebugAddress)
+		}
+	}
+	if err := c.Limits.Validate(); err != nil {
+		return err
+	}
+	if err := c.TLS.Validate(); err != nil {
+		return errors.Wrap(err, "tls")
+	}
+	return nil
+}
+
+// LimitsConfig represents HTTP server rate limiting and load shedding configuration.
+type LimitsConfig struct {
+	Client   RateConfig `mapstructure:"client"`   // Rate limit per API client
+	Wallet   RateConfig `mapstructure:"wallet"`   // Rate limit per wallet ID
+	InFlight int        `mapstructure:"inflight"` // Maximum number of concurrently served requests, 0 disables the limit
+
+	// TrustedProxies lists IP addresses or CIDR ranges of proxies ClientIDHeader is accepted from,
+	// requests of other clients are identified by their remote address.
+	TrustedProxies []string `mapstructure:"trustedproxies"`
+}
+
+// Validate implements validator.Validator.
+func (c *LimitsConfig) Validate() error {
+	if err := c.Client.Validate(); err != nil {
+		return errors.Wrap(err, "client limit")
+	}
+	if err := c.Wallet.Validate(); err != nil {
+		return errors.Wrap(err, "wallet limit")
+	}
+	if c.InFlight < 0 {
+		return errors.New("in-flight limit can not be negative")
+	}
+	if _, err := parseNetworks(c.TrustedProxies); err != nil {
+		return errors.Wrap(err, "trusted proxies")
+	}
+	return nil
+}
+
+// RateConfig represents token bucket rate limit configuration.
+type RateConfig struct {
+	Rate  float64 `mapstructure:"rate"`  // Requests per second refilled into the bucket, 0 disables the limit
+	Burst int     `mapstructure:"burst"` // Bucket capacity, defaults to 1 if rate is set
+}
+
+// Validate implements validator.Validator.
+func (c *RateConfig) Validate() error {
+	if c.Rate < 0 || c.Burst < 0 {
+		return errors.New("rate and burst can not be negative")
+	}
+	return nil
 }
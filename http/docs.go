@@ -0,0 +1,33 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+
+	"github.com/deividaspetraitis/go/log"
+)
+
+// GetOpenAPI handles HTTP requests for retrieving OpenAPI document of the API.
+func GetOpenAPI() http.HandlerFunc {
+	return serve("GetOpenAPI", "application/json", api.OpenAPI)
+}
+
+// GetDocs handles HTTP requests for retrieving API documentation page.
+func GetDocs() http.HandlerFunc {
+	return serve("GetDocs", "text/html; charset=utf-8", api.Docs)
+}
+
+// serve returns handler responding with static content of the given type.
+func serve(method, contentType string, content []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(content); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "docs",
+				"method":  method,
+			}).Println("unable to write response data")
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+)
+
+// TestOpenAPIRoutes verifies that OpenAPI document describes every API route and nothing else.
+func TestOpenAPIRoutes(t *testing.T) {
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(api.OpenAPI, &doc); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	var documented []string
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	router, ok := API(nil, NewLimiter(LimitsConfig{}), NewAuthorizer(nil), NewToggles(nil), nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil).(*mux.Router)
+	if !ok {
+		t.Fatalf("got no router, want router")
+	}
+
+	// routers mounted as route handlers are walked as well.
+	var routes []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // route mounting API router matches any method
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			routes = append(routes, method+" "+path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	sort.Strings(documented)
+	sort.Strings(routes)
+	if !cmp.Equal(documented, routes) {
+		t.Errorf("got %v, want %v", documented, routes)
+	}
+}
+
+func TestGetDocs(t *testing.T) {
+	var testcases = []struct {
+		handler     http.HandlerFunc
+		contentType string
+		body        []byte
+	}{
+		{GetOpenAPI(), "application/json", api.OpenAPI},
+		{GetDocs(), "text/html; charset=utf-8", api.Docs},
+	}
+
+	for i, tt := range testcases {
+		rr := httptest.NewRecorder()
+		tt.handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("#%d got %v, want %v", i, rr.Code, http.StatusOK)
+		}
+		if v := rr.Header().Get("Content-Type"); v != tt.contentType {
+			t.Errorf("#%d got %v, want %v", i, v, tt.contentType)
+		}
+		if !cmp.Equal(rr.Body.Bytes(), tt.body) {
+			t.Errorf("#%d got %d bytes, want %d bytes", i, rr.Body.Len(), len(tt.body))
+		}
+	}
+}
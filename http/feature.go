@@ -0,0 +1,61 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/deividaspetraitis/go/log"
+)
+
+// rejectFeature is rejection reason of requests of disabled route groups.
+const rejectFeature = "feature"
+
+// Toggles enables and disables route groups, e.g. to stop accepting schedules without restart.
+// Route group is the first segment of the request path, see Authorizer.
+type Toggles struct {
+	disabled atomic.Pointer[map[string]bool] // disabled route groups
+}
+
+// NewToggles constructs a new Toggles disabling route groups toggled off by features.
+func NewToggles(features map[string]bool) *Toggles {
+	var t Toggles
+	t.Reconfigure(features)
+	return &t
+}
+
+// Reconfigure replaces toggles with the given ones, requests being served are not affected.
+func (t *Toggles) Reconfigure(features map[string]bool) {
+	disabled := make(map[string]bool)
+	for group, enabled := range features {
+		if !enabled {
+			disabled[strings.ToLower(group)] = true
+		}
+	}
+	t.disabled.Store(&disabled)
+}
+
+// Enabled reports whether route group is enabled.
+func (t *Toggles) Enabled(group string) bool {
+	return !(*t.disabled.Load())[strings.ToLower(group)]
+}
+
+// Gate responds with HTTP 404 to requests of disabled route groups.
+// Gate implements mux.MiddlewareFunc.
+func (t *Toggles) Gate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if group := routeGroup(r); !t.Enabled(group) {
+			rejections.Add(rejectFeature, 1)
+
+			log.WithFields(log.Fields{
+				"handler": "toggles",
+				"group":   group,
+				"path":    r.URL.Path,
+			}).Debugln("route group disabled")
+
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
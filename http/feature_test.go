@@ -0,0 +1,37 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestToggles(t *testing.T) {
+	toggles := NewToggles(map[string]bool{"schedules": false, "reconciliations": true})
+	handler := toggles.Gate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var testcases = []struct {
+		features map[string]bool // features reconfigured before request, nil keeps current ones
+		path     string
+		status   int
+	}{
+		{nil, "/schedules", http.StatusNotFound},
+		{nil, "/Schedules/a18c247b-8c28-468f-97a8-0bf33a48b922/pause", http.StatusNotFound},
+		{nil, "/reconciliations", http.StatusOK},
+		{nil, "/transactions", http.StatusOK}, // not listed
+		{map[string]bool{"Transactions": false}, "/transactions", http.StatusNotFound},
+		{nil, "/schedules", http.StatusOK}, // toggled on by reconfiguration not listing it
+	}
+
+	for i, tt := range testcases {
+		if tt.features != nil {
+			toggles.Reconfigure(tt.features)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("#%d got %v, want %v", i, w.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/log"
+
+	"github.com/gorilla/mux"
+)
+
+// ClientIDHeader is HTTP header identifying API client for the rate limiting purposes.
+// Header is accepted only from trusted proxies, other requests are identified by their remote address.
+const ClientIDHeader = "X-Client-ID"
+
+// rejections counts requests rejected by Limiter, keyed by rejection reason.
+var rejections = expvar.NewMap("http_rejected_requests")
+
+// Rejection reasons.
+const (
+	rejectClient   = "client_rate"
+	rejectWallet   = "wallet_rate"
+	rejectInFlight = "inflight"
+)
+
+// sweepInterval is how often idle token buckets are released.
+const sweepInterval = time.Minute
+
+// maxPeekSize is the maximum request body size read when looking up wallet ID in the request body.
+const maxPeekSize = 1 << 20
+
+// Limiter limits requests rate per API client and per wallet and sheds load
+// when too many requests are being served at once.
+type Limiter struct {
+	limits atomic.Pointer[limits]
+}
+
+// limits represents limits of the given configuration.
+type limits struct {
+	cfg      LimitsConfig
+	client   *limiter
+	wallet   *limiter
+	inflight chan struct{}
+	proxies  []*net.IPNet // networks of trusted proxies
+}
+
+// NewLimiter constructs a new Limiter based on given configuration.
+// Zero values in configuration disable corresponding limits.
+func NewLimiter(cfg LimitsConfig) *Limiter {
+	var l Limiter
+	l.Reconfigure(cfg)
+	return &l
+}
+
+// Reconfigure replaces limits with the ones of the given configuration, requests being served are not affected.
+// Limits which were not changed keep their state, while changed rate limits start with full buckets.
+// Reconfigure must not be called concurrently.
+func (l *Limiter) Reconfigure(cfg LimitsConfig) {
+	next := limits{cfg: cfg}
+	next.proxies, _ = parseNetworks(cfg.TrustedProxies) // validated configuration holds valid networks only
+
+	current := l.limits.Load()
+	if current != nil && current.cfg.Client == cfg.Client {
+		next.client = current.client
+	} else {
+		next.client = newLimiter(cfg.Client)
+	}
+	if current != nil && current.cfg.Wallet == cfg.Wallet {
+		next.wallet = current.wallet
+	} else {
+		next.wallet = newLimiter(cfg.Wallet)
+	}
+	if current != nil && current.cfg.InFlight == cfg.InFlight {
+		next.inflight = current.inflight
+	} else if cfg.InFlight > 0 {
+		next.inflight = make(chan struct{}, cfg.InFlight)
+	}
+
+	l.limits.Store(&next)
+}
+
+// Shed rejects requests with HTTP 503 once number of concurrently served requests reaches configured limit.
+// Shed implements mux.MiddlewareFunc.
+func (l *Limiter) Shed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inflight := l.limits.Load().inflight
+		if inflight == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		select {
+		case inflight <- struct{}{}:
+			defer func() { <-inflight }()
+			next.ServeHTTP(w, r)
+		default:
+			l.reject(w, r, rejectInFlight, http.StatusServiceUnavailable, time.Second)
+		}
+	})
+}
+
+// Client limits requests rate per API client.
+// Client implements mux.MiddlewareFunc.
+func (l *Limiter) Client(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, retry := l.limits.Load().client.allow(l.ClientID(r), time.Now()); !ok {
+			l.reject(w, r, rejectClient, http.StatusTooManyRequests, retry)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Wallet limits requests rate per wallet, wallet ID is looked up in the request using walletID.
+func (l *Limiter) Wallet(walletID walletIDFunc, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wallet := l.limits.Load().wallet
+		if wallet == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id := walletID(r)
+		if len(id) == 0 {
+			next.ServeHTTP(w, r) // let the handler reject the request
+			return
+		}
+
+		if ok, retry := wallet.allow(id, time.Now()); !ok {
+			l.reject(w, r, rejectWallet, http.StatusTooManyRequests, retry)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientID returns identifier of API client which made the request.
+// Client authenticated by TLS client certificate is identified by its principal, see Principal.
+// Request forwarded by trusted proxy is identified by ClientIDHeader, so clients can not pick their own identity,
+// other requests are identified by remote address.
+func (l *Limiter) ClientID(r *http.Request) string {
+	if principal := Principal(r); len(principal) > 0 {
+		return principal
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if id := r.Header.Get(ClientIDHeader); len(id) > 0 && trusted(l.limits.Load().proxies, host) {
+		return id
+	}
+	return host
+}
+
+// trusted reports whether host address belongs to one of networks.
+func trusted(networks []*net.IPNet, host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNetworks parses CIDR ranges or single IP addresses.
+func parseNetworks(values []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			continue
+		}
+		if ip := net.ParseIP(v); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, errors.Newf("%q is neither IP address nor CIDR range", v)
+		}
+		networks = append(networks, n)
+	}
+	return networks, nil
+}
+
+// walletIDFunc looks up wallet ID in the request.
+type walletIDFunc func(r *http.Request) string
+
+// walletIDFromPath looks up wallet ID in the request path.
+func walletIDFromPath(r *http.Request) string {
+	return mux.Vars(r)["id"]
+}
+
+// walletIDFromBody looks up wallet ID in the JSON request body.
+// Request body is restored so it can be read again by the handler.
+func walletIDFromBody(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPeekSize))
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var request struct {
+		WalletID string `json:"wallet_id"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return ""
+	}
+	return request.WalletID
+}
+
+// reject responds with given HTTP status code and Retry-After header.
+func (l *Limiter) reject(w http.ResponseWriter, r *http.Request, reason string, status int, retry time.Duration) {
+	rejections.Add(reason, 1)
+
+	log.WithFields(log.Fields{
+		"handler": "limiter",
+		"reason":  reason,
+		"client":  l.ClientID(r),
+		"path":    r.URL.Path,
+	}).Debugln("request rejected")
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+	w.WriteHeader(status)
+}
+
+// bucket represents token bucket state.
+type bucket struct {
+	tokens float64   // available tokens
+	last   time.Time // last time tokens were refilled
+}
+
+// limiter is a keyed token bucket rate limiter.
+type limiter struct {
+	rate  float64 // tokens per second
+	burst float64 // bucket capacity
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time // last time idle buckets were released
+}
+
+// newLimiter constructs a new limiter, it returns nil if rate limit is disabled.
+func newLimiter(cfg RateConfig) *limiter {
+	if cfg.Rate <= 0 {
+		return nil
+	}
+
+	burst := cfg.Burst
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &limiter{
+		rate:    cfg.Rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow reports whether event identified by key may happen at time now.
+// If not, it returns duration after which event would be allowed.
+// It's safe to call allow on nil limiter, such limiter allows all events.
+func (l *limiter) allow(key string, now time.Time) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = l.refill(b, now)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// refill returns number of tokens available in the bucket at time now.
+func (l *limiter) refill(b *bucket, now time.Time) float64 {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(l.burst, b.tokens+elapsed*l.rate)
+}
+
+// sweep releases buckets which are full and so are indistinguishable from new ones.
+// Caller must hold l.mu.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	l.swept = now
+
+	for k, b := range l.buckets {
+		if l.refill(b, now) >= l.burst {
+			delete(l.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	l := newLimiter(RateConfig{Rate: 2, Burst: 2})
+	now := time.Now()
+
+	var testcases = []struct {
+		at    time.Duration // time since now
+		key   string
+		allow bool
+		retry time.Duration
+	}{
+		{0, "a", true, 0},
+		{0, "a", true, 0},
+		{0, "a", false, 500 * time.Millisecond}, // bucket is empty
+		{0, "b", true, 0},                       // buckets are independent
+		{250 * time.Millisecond, "a", false, 250 * time.Millisecond},
+		{500 * time.Millisecond, "a", true, 0}, // one token refilled
+		{500 * time.Millisecond, "a", false, 500 * time.Millisecond},
+	}
+
+	for i, tt := range testcases {
+		allow, retry := l.allow(tt.key, now.Add(tt.at))
+		if allow != tt.allow {
+			t.Errorf("#%d allow got %v, want %v", i, allow, tt.allow)
+		}
+		if retry != tt.retry {
+			t.Errorf("#%d retry got %v, want %v", i, retry, tt.retry)
+		}
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	l := newLimiter(RateConfig{})
+	for i := 0; i < 100; i++ {
+		if allow, _ := l.allow("a", time.Now()); !allow {
+			t.Fatalf("#%d allow got %v, want %v", i, allow, true)
+		}
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l := newLimiter(RateConfig{Rate: 1, Burst: 1})
+	now := time.Now()
+
+	l.allow("a", now)
+	l.allow("b", now.Add(sweepInterval))
+
+	if _, ok := l.buckets["a"]; ok {
+		t.Errorf("bucket of idle key was not released")
+	}
+	if _, ok := l.buckets["b"]; !ok {
+		t.Errorf("bucket of active key was released")
+	}
+}
+
+func TestLimiterMiddleware(t *testing.T) {
+	limiter := NewLimiter(LimitsConfig{
+		Client:         RateConfig{Rate: 1, Burst: 1},
+		Wallet:         RateConfig{Rate: 1, Burst: 1},
+		TrustedProxies: []string{"192.0.2.0/24"}, // httptest requests remote address
+	})
+
+	handler := limiter.Client(limiter.Wallet(walletIDFromBody, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	var testcases = []struct {
+		client     string
+		body       string
+		statusCode int
+	}{
+		{"a", `{"wallet_id":"1"}`, http.StatusOK},
+		{"a", `{"wallet_id":"2"}`, http.StatusTooManyRequests}, // client limit
+		{"b", `{"wallet_id":"1"}`, http.StatusTooManyRequests}, // wallet limit
+		{"c", `{"wallet_id":"2"}`, http.StatusOK},
+		{"d", `not a json`, http.StatusOK}, // no wallet to limit
+	}
+
+	for i, tt := range testcases {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/transactions", strings.NewReader(tt.body))
+		req.Header.Set(ClientIDHeader, tt.client)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if statusCode := w.Result().StatusCode; statusCode != tt.statusCode {
+			t.Errorf("#%d HTTP status got %v, want %v", i, statusCode, tt.statusCode)
+		}
+
+		if tt.statusCode == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "1" {
+			t.Errorf("#%d Retry-After got %q, want %q", i, w.Header().Get("Retry-After"), "1")
+		}
+	}
+}
+
+func TestLimiterClientID(t *testing.T) {
+	limiter := NewLimiter(LimitsConfig{TrustedProxies: []string{"10.0.0.0/8", "2001:db8::1"}})
+
+	var testcases = []struct {
+		remote string
+		header string
+		want   string
+	}{
+		{"10.1.2.3:4567", "payments", "payments"},      // trusted proxy
+		{"10.1.2.3:4567", "", "10.1.2.3"},              // trusted proxy without header
+		{"[2001:db8::1]:4567", "payments", "payments"}, // trusted proxy address
+		{"[2001:db8::2]:4567", "payments", "2001:db8::2"},
+		{"192.0.2.1:4567", "payments", "192.0.2.1"}, // header of untrusted client is ignored
+		{"192.0.2.1:4567", "", "192.0.2.1"},
+	}
+
+	for i, tt := range testcases {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+		req.RemoteAddr = tt.remote
+		if len(tt.header) > 0 {
+			req.Header.Set(ClientIDHeader, tt.header)
+		}
+
+		if got := limiter.ClientID(req); got != tt.want {
+			t.Errorf("#%d got %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestLimitsConfigValidate(t *testing.T) {
+	var testcases = []struct {
+		cfg   LimitsConfig
+		valid bool
+	}{
+		{LimitsConfig{}, true},
+		{LimitsConfig{TrustedProxies: []string{"10.0.0.0/8", "127.0.0.1", "::1"}}, true},
+		{LimitsConfig{TrustedProxies: []string{"proxy.local"}}, false},
+		{LimitsConfig{TrustedProxies: []string{"10.0.0.0/33"}}, false},
+		{LimitsConfig{InFlight: -1}, false},
+	}
+
+	for i, tt := range testcases {
+		if err := tt.cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("#%d got %v, want valid %v", i, err, tt.valid)
+		}
+	}
+}
+
+func TestLimiterShed(t *testing.T) {
+	limiter := NewLimiter(LimitsConfig{InFlight: 1})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := limiter.Shed(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+		done <- w.Result().StatusCode
+	}()
+	<-started
+
+	// second request exceeds in-flight limit
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+	if statusCode := w.Result().StatusCode; statusCode != http.StatusServiceUnavailable {
+		t.Errorf("HTTP status got %v, want %v", statusCode, http.StatusServiceUnavailable)
+	}
+	if retry := w.Header().Get("Retry-After"); retry != "1" {
+		t.Errorf("Retry-After got %q, want %q", retry, "1")
+	}
+
+	close(release)
+	if statusCode := <-done; statusCode != http.StatusOK {
+		t.Errorf("HTTP status got %v, want %v", statusCode, http.StatusOK)
+	}
+}
+
+func TestLimiterReconfigure(t *testing.T) {
+	limiter := NewLimiter(LimitsConfig{Client: RateConfig{Rate: 1, Burst: 1}})
+	handler := limiter.Client(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var testcases = []struct {
+		limits     *LimitsConfig // limits reconfigured before the request, if any
+		statusCode int
+	}{
+		{nil, http.StatusOK},
+		{nil, http.StatusTooManyRequests},
+		{&LimitsConfig{Client: RateConfig{Rate: 1, Burst: 1}, InFlight: 10}, http.StatusTooManyRequests}, // unchanged limit keeps its state
+		{&LimitsConfig{Client: RateConfig{Rate: 1, Burst: 2}}, http.StatusOK},
+		{nil, http.StatusOK},
+		{nil, http.StatusTooManyRequests},
+		{&LimitsConfig{}, http.StatusOK}, // limit disabled
+		{nil, http.StatusOK},
+	}
+
+	for i, tt := range testcases {
+		if tt.limits != nil {
+			limiter.Reconfigure(*tt.limits)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+		req.Header.Set(ClientIDHeader, "a")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if statusCode := w.Result().StatusCode; statusCode != tt.statusCode {
+			t.Errorf("#%d HTTP status got %v, want %v", i, statusCode, tt.statusCode)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+	"github.com/deividaspetraitis/ledger/reconcile"
+
+	libhttp "github.com/deividaspetraitis/go/http"
+	"github.com/deividaspetraitis/go/log"
+)
+
+// reconcileFunc decouples actual reconciliation implementation and allows easily test HTTP handler.
+type reconcileFunc func(context.Context, *reconcile.Request) (*reconcile.Report, error)
+
+// CreateReconciliation handles HTTP requests for reconciling bank statements.
+func CreateReconciliation(reconcileStatement reconcileFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// It's always json.
+		w.Header().Set("Content-Type", "application/json")
+
+		var request api.ReconciliationRequest
+		if err := libhttp.UnmarshalRequest(r, &request); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "reconciliation",
+				"method":  "CreateReconciliation",
+			}).Println("unable to unmarshal request data")
+
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		report, err := reconcileStatement(r.Context(), request.Parse())
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "reconciliation",
+				"method":  "CreateReconciliation",
+			}).Println("unable to reconcile statement")
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := libhttp.Marshal(w, api.NewReconciliationResponse(report)); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "reconciliation",
+				"method":  "CreateReconciliation",
+			}).Println("unable to marshal response data")
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
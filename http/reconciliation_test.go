@@ -0,0 +1,119 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/reconcile"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+func TestCreateReconciliation(t *testing.T) {
+	const statement = "date,reference,amount\n2024-03-01,a18c247b-8c28-468f-97a8-0bf33a48b922,50.00\n"
+
+	// report returns report matching the only statement entry.
+	report := func(ctx context.Context, req *reconcile.Request) (*reconcile.Report, error) {
+		if req.Tolerance != 0 && req.Tolerance != 24*time.Hour {
+			return nil, errors.Newf("unexpected tolerance %v", req.Tolerance)
+		}
+		return &reconcile.Report{
+			Matched: 1,
+			Results: []*reconcile.Result{
+				{
+					Status: reconcile.StatusMatched,
+					Entry:  req.Entries[0],
+					Item: &reconcile.Item{
+						WalletID: "a18c247b-8c28-468f-97a8-0bf33a48b922",
+						Version:  2,
+						Type:     "DEPOSIT",
+						Amount:   5000,
+						Date:     time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		}, nil
+	}
+
+	var testcases = []struct {
+		url         string
+		contentType string
+		body        string
+		reconcile   reconcileFunc
+
+		response   string
+		statusCode int
+	}{
+		// format derived from content type
+		{
+			url:         "http://localhost/reconciliations",
+			contentType: "text/csv",
+			body:        statement,
+			reconcile:   report,
+			response:    `{"matched":1,"mismatched":0,"unmatched":0,"results":[{"status":"matched","entry":{"reference":"a18c247b-8c28-468f-97a8-0bf33a48b922","amount":5000,"date":"2024-03-01T00:00:00Z"},"transaction":{"wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","version":2,"transaction":"DEPOSIT","amount":5000,"date":"2024-03-01T10:00:00Z"}}]}`,
+			statusCode:  http.StatusOK,
+		},
+		// format and tolerance given by query
+		{
+			url:        "http://localhost/reconciliations?format=csv&tolerance=24h",
+			body:       statement,
+			reconcile:  report,
+			response:   `{"matched":1,"mismatched":0,"unmatched":0,"results":[{"status":"matched","entry":{"reference":"a18c247b-8c28-468f-97a8-0bf33a48b922","amount":5000,"date":"2024-03-01T00:00:00Z"},"transaction":{"wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","version":2,"transaction":"DEPOSIT","amount":5000,"date":"2024-03-01T10:00:00Z"}}]}`,
+			statusCode: http.StatusOK,
+		},
+		// unknown format
+		{
+			url:         "http://localhost/reconciliations",
+			contentType: "application/json",
+			body:        statement,
+			reconcile:   report,
+			statusCode:  http.StatusBadRequest,
+		},
+		// not a valid statement
+		{
+			url:        "http://localhost/reconciliations?format=camt053",
+			body:       statement,
+			reconcile:  report,
+			statusCode: http.StatusBadRequest,
+		},
+		// not a valid tolerance
+		{
+			url:        "http://localhost/reconciliations?format=csv&tolerance=day",
+			body:       statement,
+			reconcile:  report,
+			statusCode: http.StatusBadRequest,
+		},
+		// service error
+		{
+			url:  "http://localhost/reconciliations?format=csv",
+			body: statement,
+			reconcile: func(ctx context.Context, req *reconcile.Request) (*reconcile.Report, error) {
+				return nil, errors.New("service error")
+			},
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for i, tt := range testcases {
+		req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+		if len(tt.contentType) > 0 {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		w := httptest.NewRecorder()
+
+		CreateReconciliation(tt.reconcile)(w, req)
+
+		if statusCode := w.Result().StatusCode; statusCode != tt.statusCode {
+			t.Errorf("#%d HTTP status got %v, want %v", i, statusCode, tt.statusCode)
+		}
+
+		// we do apply TrimSpace to clean up response coming from HTTP protocol
+		if response := strings.TrimSpace(w.Body.String()); response != tt.response {
+			t.Errorf("#%d HTTP response got %v, want %s", i, response, tt.response)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+	"github.com/deividaspetraitis/ledger/scheduler"
+
+	"github.com/deividaspetraitis/go/errors"
+	libhttp "github.com/deividaspetraitis/go/http"
+	"github.com/deividaspetraitis/go/log"
+)
+
+// createScheduleFunc decouples actual schedule creation implementation and allows easily test HTTP handler.
+type createScheduleFunc func(context.Context, *scheduler.CreateScheduleRequest) (*scheduler.Schedule, error)
+
+// CreateSchedule handles HTTP requests for creating a new schedule.
+func CreateSchedule(createSchedule createScheduleFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// It's always json.
+		w.Header().Set("Content-Type", "application/json")
+
+		var request api.CreateScheduleRequest
+		if err := libhttp.UnmarshalRequest(r, &request); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "schedule",
+				"method":  "CreateSchedule",
+			}).Println("unable to unmarshal request data")
+
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		schedule, err := createSchedule(r.Context(), request.Parse())
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "schedule",
+				"method":  "CreateSchedule",
+			}).Println("unable to create a schedule")
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := libhttp.Marshal(w, api.NewScheduleResponse(schedule)); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "schedule",
+				"method":  "CreateSchedule",
+			}).Println("unable to marshal response data")
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// scheduleFunc retrieves or changes state of the schedule by its ID.
+type scheduleFunc func(ctx context.Context, id string) (*scheduler.Schedule, error)
+
+// GetSchedule handles HTTP requests for retrieving a schedule by ID.
+func GetSchedule(getSchedule scheduleFunc) http.HandlerFunc {
+	return handleSchedule("GetSchedule", getSchedule)
+}
+
+// ChangeSchedule handles HTTP requests for pausing, resuming or cancelling a schedule by ID using change.
+func ChangeSchedule(change scheduleFunc) http.HandlerFunc {
+	return handleSchedule("ChangeSchedule", change)
+}
+
+// handleSchedule handles HTTP requests addressing a schedule by ID.
+func handleSchedule(method string, fn scheduleFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// It's always json.
+		w.Header().Set("Content-Type", "application/json")
+
+		var request api.ScheduleRequest
+		if err := libhttp.UnmarshalRequest(r, &request); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "schedule",
+				"method":  method,
+			}).Println("unable to unmarshal request data")
+
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		schedule, err := fn(r.Context(), request.Parse())
+		if err != nil {
+			switch {
+			case errors.Is(err, ledger.ErrEntryNotFound):
+				w.WriteHeader(http.StatusNotFound)
+			case errors.Is(err, scheduler.ErrNotValidStatus):
+				w.WriteHeader(http.StatusConflict)
+			default:
+				log.WithError(err).WithFields(log.Fields{
+					"handler": "schedule",
+					"method":  method,
+				}).Println("unable to process schedule")
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := libhttp.Marshal(w, api.NewScheduleResponse(schedule)); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "schedule",
+				"method":  method,
+			}).Println("unable to marshal response data")
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/scheduler"
+
+	"github.com/deividaspetraitis/go/errors"
+
+	"github.com/gorilla/mux"
+)
+
+// testSchedule is a schedule returned by test services.
+var testSchedule = &scheduler.Schedule{
+	ID:       "7b9db2f1-6777-4a9e-ac3d-efe0f7456a44",
+	WalletID: "a18c247b-8c28-468f-97a8-0bf33a48b922",
+	Type:     ledger.TransactionDeposit,
+	Amount:   5000,
+	Cron:     "0 9 1 * *",
+	Start:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	Status:   scheduler.StatusActive,
+	Next:     time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC),
+}
+
+func TestCreateSchedule(t *testing.T) {
+	var testcases = []struct {
+		body   string
+		create createScheduleFunc
+
+		response   string
+		statusCode int
+	}{
+		// created
+		{
+			body: `{"transaction":"deposit","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","amount":5000,"cron":"0 9 1 * *","start":"2024-01-01T00:00:00Z"}`,
+			create: func(ctx context.Context, req *scheduler.CreateScheduleRequest) (*scheduler.Schedule, error) {
+				return testSchedule, nil
+			},
+			response:   `{"id":"7b9db2f1-6777-4a9e-ac3d-efe0f7456a44","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","transaction":"DEPOSIT","amount":5000,"cron":"0 9 1 * *","start":"2024-01-01T00:00:00Z","status":"active","next":"2024-01-01T09:00:00Z","attempts":0,"executed":0}`,
+			statusCode: http.StatusOK,
+		},
+		// not a valid recurrence
+		{
+			body: `{"transaction":"deposit","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","amount":5000,"cron":"monthly"}`,
+			create: func(ctx context.Context, req *scheduler.CreateScheduleRequest) (*scheduler.Schedule, error) {
+				return testSchedule, nil
+			},
+			statusCode: http.StatusBadRequest,
+		},
+		// service error
+		{
+			body: `{"transaction":"deposit","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","amount":5000}`,
+			create: func(ctx context.Context, req *scheduler.CreateScheduleRequest) (*scheduler.Schedule, error) {
+				return nil, errors.New("service error")
+			},
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for i, tt := range testcases {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/schedules", strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+
+		CreateSchedule(tt.create)(w, req)
+
+		if statusCode := w.Result().StatusCode; statusCode != tt.statusCode {
+			t.Errorf("#%d HTTP status got %v, want %v", i, statusCode, tt.statusCode)
+		}
+
+		// we do apply TrimSpace to clean up response coming from HTTP protocol
+		if response := strings.TrimSpace(w.Body.String()); response != tt.response {
+			t.Errorf("#%d HTTP response got %v, want %s", i, response, tt.response)
+		}
+	}
+}
+
+func TestChangeSchedule(t *testing.T) {
+	var testcases = []struct {
+		change scheduleFunc
+
+		response   string
+		statusCode int
+	}{
+		// paused
+		{
+			change: func(ctx context.Context, id string) (*scheduler.Schedule, error) {
+				paused := *testSchedule
+				paused.Status = scheduler.StatusPaused
+				return &paused, nil
+			},
+			response:   `{"id":"7b9db2f1-6777-4a9e-ac3d-efe0f7456a44","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","transaction":"DEPOSIT","amount":5000,"cron":"0 9 1 * *","start":"2024-01-01T00:00:00Z","status":"paused","next":"2024-01-01T09:00:00Z","attempts":0,"executed":0}`,
+			statusCode: http.StatusOK,
+		},
+		// cancelled, there is no next occurrence
+		{
+			change: func(ctx context.Context, id string) (*scheduler.Schedule, error) {
+				cancelled := *testSchedule
+				cancelled.Status = scheduler.StatusCancelled
+				return &cancelled, nil
+			},
+			response:   `{"id":"7b9db2f1-6777-4a9e-ac3d-efe0f7456a44","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","transaction":"DEPOSIT","amount":5000,"cron":"0 9 1 * *","start":"2024-01-01T00:00:00Z","status":"cancelled","attempts":0,"executed":0}`,
+			statusCode: http.StatusOK,
+		},
+		// not found
+		{
+			change: func(ctx context.Context, id string) (*scheduler.Schedule, error) {
+				return nil, ledger.ErrEntryNotFound
+			},
+			statusCode: http.StatusNotFound,
+		},
+		// not allowed in current status
+		{
+			change: func(ctx context.Context, id string) (*scheduler.Schedule, error) {
+				return nil, scheduler.ErrNotValidStatus
+			},
+			statusCode: http.StatusConflict,
+		},
+	}
+
+	for i, tt := range testcases {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/schedules/"+testSchedule.ID+"/pause", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": testSchedule.ID})
+		w := httptest.NewRecorder()
+
+		ChangeSchedule(tt.change)(w, req)
+
+		if statusCode := w.Result().StatusCode; statusCode != tt.statusCode {
+			t.Errorf("#%d HTTP status got %v, want %v", i, statusCode, tt.statusCode)
+		}
+
+		// we do apply TrimSpace to clean up response coming from HTTP protocol
+		if response := strings.TrimSpace(w.Body.String()); response != tt.response {
+			t.Errorf("#%d HTTP response got %v, want %s", i, response, tt.response)
+		}
+	}
+}
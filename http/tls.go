@@ -0,0 +1,252 @@
+package http
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/log"
+)
+
+// Supported minimum TLS versions.
+const (
+	TLS12 = "1.2" // default
+	TLS13 = "1.3"
+)
+
+// Supported cipher suites policies, they apply to TLS 1.2 only as TLS 1.3 cipher suites are not configurable.
+const (
+	CiphersModern     = "modern"     // ECDHE key exchange with AEAD ciphers only, default
+	CiphersCompatible = "compatible" // Go default cipher suites, including CBC mode ciphers
+)
+
+// defaultReloadInterval is how often certificate files are checked for changes when interval is not configured.
+const defaultReloadInterval = time.Minute
+
+// modernCiphers holds TLS 1.2 cipher suites allowed by CiphersModern policy.
+var modernCiphers = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// TLSConfig represents HTTP server TLS configuration.
+type TLSConfig struct {
+	Cert       string        `mapstructure:"cert"`       // Path to PEM encoded server certificate chain, TLS is disabled if not set
+	Key        string        `mapstructure:"key"`        // Path to PEM encoded server private key
+	MinVersion string        `mapstructure:"minversion"` // Minimum TLS version, TLS12 or TLS13, defaults to TLS12
+	Ciphers    string        `mapstructure:"ciphers"`    // Cipher suites policy, defaults to CiphersModern
+	ClientCA   string        `mapstructure:"clientca"`   // Path to PEM encoded CA certificates, if set clients are required to present certificate signed by one of them
+	Reload     time.Duration `mapstructure:"reload"`     // How often certificate files are checked for changes, defaults to 1m
+
+	// Access lists principals allowed to call route group by route group, e.g. admin, see Authorizer.
+	// Route groups not listed are not restricted, restricting any of them requires client CA.
+	Access map[string][]string `mapstructure:"access"`
+}
+
+// Enabled reports whether TLS is configured.
+func (c *TLSConfig) Enabled() bool {
+	return len(c.Cert) > 0 || len(c.Key) > 0
+}
+
+// Validate implements validator.Validator.
+func (c *TLSConfig) Validate() error {
+	if len(c.Access) > 0 && len(c.ClientCA) == 0 {
+		return errors.New("access rules require client CA")
+	}
+	for group, principals := range c.Access {
+		if len(principals) == 0 {
+			return errors.Newf("route group %q allows no principals", group)
+		}
+	}
+	if !c.Enabled() {
+		if len(c.ClientCA) > 0 {
+			return errors.New("client CA requires server certificate and key")
+		}
+		return nil
+	}
+	if len(c.Cert) == 0 || len(c.Key) == 0 {
+		return errors.New("both server certificate and key are required")
+	}
+	switch c.MinVersion {
+	case "", TLS12, TLS13:
+	default:
+		return errors.Newf("minimum TLS version %q is not supported", c.MinVersion)
+	}
+	switch c.Ciphers {
+	case "", CiphersModern, CiphersCompatible:
+	default:
+		return errors.Newf("cipher suites policy %q is not supported", c.Ciphers)
+	}
+	if c.Reload < 0 {
+		return errors.New("reload interval can not be negative")
+	}
+	return nil
+}
+
+// NewTLSConfig constructs server TLS configuration according to cfg.
+// Certificate, key and client CA files are read once and then checked for changes every reload interval
+// during handshakes, changed files are loaded for subsequent connections. Files failing to load are reported to
+// logger and previously loaded certificates stay in use, so files may be replaced non-atomically.
+func NewTLSConfig(cfg *TLSConfig, logger log.Logger) (*tls.Config, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled() {
+		return nil, errors.New("server certificate and key are required")
+	}
+
+	c := tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if cfg.MinVersion == TLS13 {
+		c.MinVersion = tls.VersionTLS13
+	}
+	if cfg.Ciphers != CiphersCompatible {
+		c.CipherSuites = modernCiphers
+	}
+
+	r := reloader{
+		cfg:      *cfg,
+		base:     &c,
+		logger:   logger,
+		interval: cfg.Reload,
+		now:      time.Now,
+	}
+	if r.interval <= 0 {
+		r.interval = defaultReloadInterval
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:         c.MinVersion,
+		GetCertificate:     r.certificate,
+		GetConfigForClient: r.configForClient,
+	}, nil
+}
+
+// reloader hands out TLS configuration of the most recently loaded certificate files.
+type reloader struct {
+	cfg      TLSConfig
+	base     *tls.Config // configuration loaded certificates are set on
+	logger   log.Logger
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	config  *tls.Config // configuration of loaded files
+	files   [][]byte    // contents of loaded files
+	checked time.Time   // last time files were checked for changes
+}
+
+// configForClient returns configuration of loaded certificates, files are checked for changes once reload interval has passed.
+// configForClient implements tls.Config.GetConfigForClient.
+func (r *reloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := r.now(); now.Sub(r.checked) >= r.interval {
+		r.checked = now
+		if err := r.reload(); err != nil {
+			r.logger.WithError(err).Error("unable to reload TLS certificates")
+		}
+	}
+
+	return r.config, nil
+}
+
+// certificate returns loaded server certificate.
+// certificate implements tls.Config.GetCertificate, it's used only if configuration for client is not provided.
+func (r *reloader) certificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &r.config.Certificates[0], nil
+}
+
+// load loads certificate files.
+func (r *reloader) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checked = r.now()
+	return r.reload()
+}
+
+// reload loads certificate files if their contents changed since they were loaded.
+func (r *reloader) reload() error {
+	names := []string{r.cfg.Cert, r.cfg.Key}
+	if len(r.cfg.ClientCA) > 0 {
+		names = append(names, r.cfg.ClientCA)
+	}
+
+	files := make([][]byte, len(names))
+	for i, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		files[i] = data
+	}
+
+	if r.config != nil && equal(files, r.files) {
+		return nil
+	}
+
+	cert, err := tls.X509KeyPair(files[0], files[1])
+	if err != nil {
+		return errors.Wrap(err, "unable to load server certificate")
+	}
+
+	c := r.base.Clone()
+	c.Certificates = []tls.Certificate{cert}
+
+	if len(files) > 2 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(files[2]) {
+			return errors.New("client CA file holds no certificates")
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.config, r.files = c, files
+	return nil
+}
+
+// equal reports whether contents of files are the same.
+func equal(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Principal returns identity of the API client authenticated by verified TLS client certificate:
+// certificate subject common name or, if it's not set, the whole certificate subject.
+// If request was not authenticated by client certificate empty string is returned.
+func Principal(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+
+	subject := r.TLS.VerifiedChains[0][0].Subject
+	if len(subject.CommonName) > 0 {
+		return subject.CommonName
+	}
+	return subject.String()
+}
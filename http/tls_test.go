@@ -0,0 +1,260 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/go/log"
+)
+
+// authority represents test certificate authority.
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// newAuthority returns a new self-signed certificate authority.
+func newAuthority(t *testing.T, name string) *authority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue issues certificate of the given common name, it returns PEM encoded certificate and key.
+func (a *authority) issue(t *testing.T, name string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name, Organization: []string{"ledger"}},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFile writes data into the file replacing its contents.
+func writeFile(t *testing.T, name string, data []byte) {
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+}
+
+// newTLSServer starts TLS server responding with API client principal.
+func newTLSServer(t *testing.T, cfg *TLSConfig) *httptest.Server {
+	config, err := NewTLSConfig(cfg, log.Default())
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, Principal(r))
+	}))
+	server.TLS = config
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTLSClient returns client trusting ca and presenting given certificates.
+func newTLSClient(ca *authority, certs ...tls.Certificate) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool, Certificates: certs, ServerName: "localhost"},
+		DisableKeepAlives: true,
+	}}
+}
+
+// get returns response body of the GET request.
+func get(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestTLSConfigValidate(t *testing.T) {
+	var testcases = []struct {
+		cfg   TLSConfig
+		valid bool
+	}{
+		{TLSConfig{}, true},
+		{TLSConfig{Cert: "cert.pem", Key: "key.pem"}, true},
+		{TLSConfig{Cert: "cert.pem", Key: "key.pem", ClientCA: "ca.pem", MinVersion: TLS13, Ciphers: CiphersCompatible}, true},
+		{TLSConfig{Cert: "cert.pem"}, false},
+		{TLSConfig{ClientCA: "ca.pem"}, false},
+		{TLSConfig{Cert: "cert.pem", Key: "key.pem", MinVersion: "1.1"}, false},
+		{TLSConfig{Cert: "cert.pem", Key: "key.pem", Ciphers: "all"}, false},
+		{TLSConfig{Cert: "cert.pem", Key: "key.pem", Reload: -time.Second}, false},
+		{TLSConfig{Cert: "cert.pem", Key: "key.pem", ClientCA: "ca.pem", Access: map[string][]string{"admin": {"ops"}}}, true},
+		{TLSConfig{Cert: "cert.pem", Key: "key.pem", Access: map[string][]string{"admin": {"ops"}}}, false},
+		{TLSConfig{Cert: "cert.pem", Key: "key.pem", ClientCA: "ca.pem", Access: map[string][]string{"admin": {}}}, false},
+	}
+
+	for i, tt := range testcases {
+		if err := tt.cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("#%d got %v, want valid %v", i, err, tt.valid)
+		}
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	cfg := TLSConfig{
+		Cert:     filepath.Join(dir, "cert.pem"),
+		Key:      filepath.Join(dir, "key.pem"),
+		ClientCA: filepath.Join(dir, "ca.pem"),
+	}
+
+	ca, untrusted := newAuthority(t, "ca"), newAuthority(t, "untrusted")
+	cert, key := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	writeFile(t, cfg.Cert, cert)
+	writeFile(t, cfg.Key, key)
+	writeFile(t, cfg.ClientCA, ca.pem)
+
+	server := newTLSServer(t, &cfg)
+
+	certificate := func(ca *authority, name string) tls.Certificate {
+		cert, key := ca.issue(t, name, x509.ExtKeyUsageClientAuth)
+		v, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+		return v
+	}
+
+	var testcases = []struct {
+		client    *http.Client
+		principal string
+		ok        bool
+	}{
+		{newTLSClient(ca, certificate(ca, "payments")), "payments", true},
+		{newTLSClient(ca, certificate(ca, "payouts")), "payouts", true},
+		{newTLSClient(ca), "", false},                                     // no client certificate
+		{newTLSClient(ca, certificate(untrusted, "payments")), "", false}, // not trusted client certificate
+	}
+
+	for i, tt := range testcases {
+		principal, err := get(tt.client, server.URL)
+		if (err == nil) != tt.ok {
+			t.Fatalf("#%d got %v, want ok %v", i, err, tt.ok)
+		}
+		if principal != tt.principal {
+			t.Errorf("#%d got %v, want %v", i, principal, tt.principal)
+		}
+	}
+}
+
+func TestTLSConfigCiphers(t *testing.T) {
+	dir := t.TempDir()
+	cfg := TLSConfig{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem")}
+
+	ca := newAuthority(t, "ca")
+	cert, key := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	writeFile(t, cfg.Cert, cert)
+	writeFile(t, cfg.Key, key)
+
+	server := newTLSServer(t, &cfg)
+
+	var testcases = []struct {
+		min, max uint16
+		ciphers  []uint16
+		ok       bool
+	}{
+		{tls.VersionTLS12, tls.VersionTLS13, nil, true},
+		{tls.VersionTLS12, tls.VersionTLS12, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, true},
+		{tls.VersionTLS12, tls.VersionTLS12, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA}, false}, // not AEAD
+		{tls.VersionTLS10, tls.VersionTLS11, nil, false},
+	}
+
+	for i, tt := range testcases {
+		client := newTLSClient(ca)
+		config := client.Transport.(*http.Transport).TLSClientConfig
+		config.MinVersion, config.MaxVersion, config.CipherSuites = tt.min, tt.max, tt.ciphers
+
+		if _, err := get(client, server.URL); (err == nil) != tt.ok {
+			t.Errorf("#%d got %v, want ok %v", i, err, tt.ok)
+		}
+	}
+}
+
+func TestTLSConfigReload(t *testing.T) {
+	dir := t.TempDir()
+	cfg := TLSConfig{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem"), Reload: time.Nanosecond}
+
+	first, second := newAuthority(t, "first"), newAuthority(t, "second")
+	cert, key := first.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	writeFile(t, cfg.Cert, cert)
+	writeFile(t, cfg.Key, key)
+
+	server := newTLSServer(t, &cfg)
+
+	if _, err := get(newTLSClient(first), server.URL); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	// certificate replaced non-atomically, broken pair is not loaded.
+	cert, key = second.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	writeFile(t, cfg.Cert, cert)
+	if _, err := get(newTLSClient(first), server.URL); err != nil {
+		t.Errorf("got %v, want %v", err, nil)
+	}
+
+	writeFile(t, cfg.Key, key)
+	if _, err := get(newTLSClient(second), server.URL); err != nil {
+		t.Errorf("got %v, want %v", err, nil)
+	}
+	if _, err := get(newTLSClient(first), server.URL); err == nil {
+		t.Errorf("got %v, want error", err)
+	}
+}
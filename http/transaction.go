@@ -7,12 +7,13 @@ import (
 	"github.com/deividaspetraitis/ledger"
 	"github.com/deividaspetraitis/ledger/pkg/api/v1"
 
+	"github.com/deividaspetraitis/go/errors"
 	libhttp "github.com/deividaspetraitis/go/http"
 	"github.com/deividaspetraitis/go/log"
 )
 
 // createTransactionFunc decouples actual check implementation and allows easily test HTTP handler.
-type createTransactionFunc func(context.Context, *ledger.TransactionRequest) (*ledger.Wallet, error)
+type createTransactionFunc func(context.Context, *ledger.TransactionRequest) (*ledger.Receipt, error)
 
 // CreateTransaction handles HTTP requests for creating a new transaction.
 func CreateTransaction(createTransaction createTransactionFunc) http.HandlerFunc {
@@ -31,22 +32,230 @@ func CreateTransaction(createTransaction createTransactionFunc) http.HandlerFunc
 			return
 		}
 
-		_, err := createTransaction(r.Context(), request.Parse())
+		receipt, err := createTransaction(r.Context(), request.Parse())
 		if err != nil {
+			switch {
+			case errors.Is(err, ledger.ErrEntryNotFound):
+				w.WriteHeader(http.StatusNotFound)
+			case errors.Is(err, ledger.ErrInsufficientBalance):
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			case errors.Is(err, ledger.ErrVersionConflict):
+				w.WriteHeader(http.StatusConflict)
+			case errors.Is(err, ledger.ErrNotValidTransaction), errors.Is(err, ledger.ErrNotValidWalletID), errors.Is(err, ledger.ErrNotValidAmount),
+				errors.Is(err, ledger.ErrNotValidDescription), errors.Is(err, ledger.ErrNotValidReference), errors.Is(err, ledger.ErrNotValidMetadata),
+				errors.Is(err, ledger.ErrNotValidKey):
+				w.WriteHeader(http.StatusBadRequest)
+			case errors.Is(err, ledger.ErrProcessorClosed):
+				w.WriteHeader(http.StatusServiceUnavailable)
+			case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+				// transaction was not processed in time, it might still be processed.
+				w.WriteHeader(http.StatusGatewayTimeout)
+			default:
+				log.WithError(err).WithFields(log.Fields{
+					"handler": "transaction",
+					"method":  "CreateTransaction",
+				}).Println("error to processing transaction")
+
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := libhttp.Marshal(w, api.NewCreateTransactionResponse(&request, receipt)); err != nil {
 			log.WithError(err).WithFields(log.Fields{
 				"handler": "transaction",
 				"method":  "CreateTransaction",
-			}).Println("error to processing transaction")
+			}).Println("unable to marshal response data")
 
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+	}
+}
+
+// quoteTransactionFunc decouples actual fee preview implementation and allows easily test HTTP handler.
+type quoteTransactionFunc func(context.Context, *ledger.TransactionRequest) (*ledger.Quote, error)
+
+// QuoteTransaction handles HTTP requests for previewing transaction fee.
+func QuoteTransaction(quoteTransaction quoteTransactionFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// It's always json.
+		w.Header().Set("Content-Type", "application/json")
+
+		var request api.CreateTransactionRequest
+		if err := libhttp.UnmarshalRequest(r, &request); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "transaction",
+				"method":  "QuoteTransaction",
+			}).Println("unable to unmarshal request data")
+
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		quote, err := quoteTransaction(r.Context(), request.Parse())
+		if err != nil {
+			switch {
+			case errors.Is(err, ledger.ErrEntryNotFound):
+				w.WriteHeader(http.StatusNotFound)
+			case errors.Is(err, ledger.ErrNotValidTransaction), errors.Is(err, ledger.ErrNotValidWalletID), errors.Is(err, ledger.ErrNotValidAmount):
+				w.WriteHeader(http.StatusBadRequest)
+			default:
+				log.WithError(err).WithFields(log.Fields{
+					"handler": "transaction",
+					"method":  "QuoteTransaction",
+				}).Println("unable to quote transaction")
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
 
 		w.WriteHeader(http.StatusOK)
-		if err := libhttp.Marshal(w, api.NewCreateTransactionResponse(&request)); err != nil {
+		if err := libhttp.Marshal(w, api.NewTransactionQuoteResponse(quote)); err != nil {
 			log.WithError(err).WithFields(log.Fields{
 				"handler": "transaction",
-				"method":  "CreateTransaction",
+				"method":  "QuoteTransaction",
+			}).Println("unable to marshal response data")
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// getHistoryFunc decouples actual history implementation and allows easily test HTTP handler.
+type getHistoryFunc func(ctx context.Context, walletID string) ([]*ledger.HistoryEntry, error)
+
+// GetHistory handles HTTP requests for retrieving wallet transactions.
+func GetHistory(getHistory getHistoryFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// It's always json.
+		w.Header().Set("Content-Type", "application/json")
+
+		var request api.GetWalletRequest
+		if err := libhttp.UnmarshalRequest(r, &request); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "transaction",
+				"method":  "GetHistory",
+			}).Println("unable to unmarshal request data")
+
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		history, err := getHistory(r.Context(), request.Parse())
+		if err != nil {
+			switch {
+			case errors.Is(err, ledger.ErrEntryNotFound):
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				log.WithError(err).WithFields(log.Fields{
+					"handler": "transaction",
+					"method":  "GetHistory",
+				}).Println("unable to retrieve wallet transactions")
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := libhttp.Marshal(w, api.NewTransactionsResponse(history)); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "transaction",
+				"method":  "GetHistory",
+			}).Println("unable to marshal response data")
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// findTransactionsFunc decouples actual search implementation and allows easily test HTTP handler.
+type findTransactionsFunc func(ctx context.Context, reference string) ([]*ledger.HistoryEntry, error)
+
+// FindTransactions handles HTTP requests for searching transactions by external reference.
+func FindTransactions(findTransactions findTransactionsFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// It's always json.
+		w.Header().Set("Content-Type", "application/json")
+
+		var request api.FindTransactionsRequest
+		if err := libhttp.UnmarshalRequest(r, &request); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "transaction",
+				"method":  "FindTransactions",
+			}).Println("unable to unmarshal request data")
+
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		transactions, err := findTransactions(r.Context(), request.Parse())
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "transaction",
+				"method":  "FindTransactions",
+			}).Println("unable to search transactions")
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := libhttp.Marshal(w, api.NewTransactionsResponse(transactions)); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "transaction",
+				"method":  "FindTransactions",
+			}).Println("unable to marshal response data")
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// getTransactionFunc decouples actual lookup implementation and allows easily test HTTP handler.
+type getTransactionFunc func(ctx context.Context, id string) (*ledger.HistoryEntry, error)
+
+// GetTransaction handles HTTP requests for retrieving a transaction.
+func GetTransaction(getTransaction getTransactionFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// It's always json.
+		w.Header().Set("Content-Type", "application/json")
+
+		var request api.GetTransactionRequest
+		if err := libhttp.UnmarshalRequest(r, &request); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "transaction",
+				"method":  "GetTransaction",
+			}).Println("unable to unmarshal request data")
+
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		transaction, err := getTransaction(r.Context(), request.Parse())
+		if err != nil {
+			switch {
+			case errors.Is(err, ledger.ErrEntryNotFound):
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				log.WithError(err).WithFields(log.Fields{
+					"handler": "transaction",
+					"method":  "GetTransaction",
+				}).Println("unable to retrieve a transaction")
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := libhttp.Marshal(w, api.NewTransactionResponse(transaction)); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "transaction",
+				"method":  "GetTransaction",
 			}).Println("unable to marshal response data")
 
 			w.WriteHeader(http.StatusInternalServerError)
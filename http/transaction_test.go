@@ -0,0 +1,435 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+
+	"github.com/deividaspetraitis/go/errors"
+
+	"github.com/gorilla/mux"
+)
+
+func TestQuoteTransaction(t *testing.T) {
+	const body = `{"transaction":"WITHDRAW","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","amount":1000}`
+
+	var testcases = []struct {
+		body  string
+		quote quoteTransactionFunc
+
+		response   string
+		statusCode int
+	}{
+		// quoted
+		{
+			body: body,
+			quote: func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Quote, error) {
+				return &ledger.Quote{
+					Type:     ledger.TransactionWithdraw,
+					WalletID: req.WalletID,
+					Amount:   req.Amount,
+					Fee:      25,
+					Total:    req.Amount + 25,
+					Currency: "EUR",
+				}, nil
+			},
+			response:   `{"transaction":"WITHDRAW","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","amount":1000,"fee":25,"total":1025,"currency":"EUR"}`,
+			statusCode: http.StatusOK,
+		},
+		// not a valid request
+		{
+			body: `{"transaction":"WITHDRAW","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922"}`,
+			quote: func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Quote, error) {
+				return nil, nil
+			},
+			statusCode: http.StatusBadRequest,
+		},
+		// not a valid transaction type
+		{
+			body: body,
+			quote: func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Quote, error) {
+				return nil, ledger.ErrNotValidTransaction
+			},
+			statusCode: http.StatusBadRequest,
+		},
+		// wallet not found
+		{
+			body: body,
+			quote: func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Quote, error) {
+				return nil, ledger.ErrEntryNotFound
+			},
+			statusCode: http.StatusNotFound,
+		},
+		// service error
+		{
+			body: body,
+			quote: func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Quote, error) {
+				return nil, errors.New("service error")
+			},
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for i, tt := range testcases {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/transactions/quote", strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+
+		QuoteTransaction(tt.quote)(w, req)
+
+		if statusCode := w.Result().StatusCode; statusCode != tt.statusCode {
+			t.Errorf("#%d HTTP status got %v, want %v", i, statusCode, tt.statusCode)
+		}
+
+		// we do apply TrimSpace to clean up response coming from HTTP protocol
+		if response := strings.TrimSpace(w.Body.String()); response != tt.response {
+			t.Errorf("#%d HTTP response got %v, want %s", i, response, tt.response)
+		}
+	}
+}
+
+func TestCreateTransaction(t *testing.T) {
+	const body = `{"transaction":"DEPOSIT","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","amount":1000,"description":"Payroll"}`
+
+	var testcases = []struct {
+		body   string
+		create createTransactionFunc
+
+		response   string
+		statusCode int
+	}{
+		// processed
+		{
+			body: body,
+			create: func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Receipt, error) {
+				return &ledger.Receipt{
+					TransactionID: "5b1c2f7e-3f7e-4a43-8d1f-6a1f1c1e9b10",
+					Wallet:        ledger.Wallet{ID: req.WalletID, Balance: 1500, Version: 4},
+				}, nil
+			},
+			response:   `{"id":"5b1c2f7e-3f7e-4a43-8d1f-6a1f1c1e9b10","transaction":"DEPOSIT","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","amount":1000,"description":"Payroll","balance":1500,"version":4}`,
+			statusCode: http.StatusOK,
+		},
+		// not a valid request
+		{
+			body: `{"transaction":"DEPOSIT","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922"}`,
+			create: func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Receipt, error) {
+				return nil, nil
+			},
+			statusCode: http.StatusBadRequest,
+		},
+		// wallet not found
+		{
+			body: body,
+			create: func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Receipt, error) {
+				return nil, ledger.ErrEntryNotFound
+			},
+			statusCode: http.StatusNotFound,
+		},
+		// insufficient balance
+		{
+			body: body,
+			create: func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Receipt, error) {
+				return nil, ledger.ErrInsufficientBalance
+			},
+			statusCode: http.StatusUnprocessableEntity,
+		},
+		// wallet modified concurrently
+		{
+			body: body,
+			create: func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Receipt, error) {
+				return nil, errors.Wrap(ledger.ErrVersionConflict, "unable to persist transaction")
+			},
+			statusCode: http.StatusConflict,
+		},
+		// not a valid transaction
+		{
+			body: body,
+			create: func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Receipt, error) {
+				return nil, ledger.ErrNotValidAmount
+			},
+			statusCode: http.StatusBadRequest,
+		},
+		// processor closed
+		{
+			body: body,
+			create: func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Receipt, error) {
+				return nil, ledger.ErrProcessorClosed
+			},
+			statusCode: http.StatusServiceUnavailable,
+		},
+		// not processed in time
+		{
+			body: body,
+			create: func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Receipt, error) {
+				return nil, errors.Wrap(context.DeadlineExceeded, "unable to persist transaction")
+			},
+			statusCode: http.StatusGatewayTimeout,
+		},
+		// service error
+		{
+			body: body,
+			create: func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Receipt, error) {
+				return nil, errors.New("service error")
+			},
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for i, tt := range testcases {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/transactions", strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+
+		CreateTransaction(tt.create)(w, req)
+
+		if statusCode := w.Result().StatusCode; statusCode != tt.statusCode {
+			t.Errorf("#%d HTTP status got %v, want %v", i, statusCode, tt.statusCode)
+		}
+
+		// we do apply TrimSpace to clean up response coming from HTTP protocol
+		if response := strings.TrimSpace(w.Body.String()); response != tt.response {
+			t.Errorf("#%d HTTP response got %v, want %s", i, response, tt.response)
+		}
+	}
+}
+
+func TestCreateTransactionNotValidDetails(t *testing.T) {
+	const body = `{"transaction":"DEPOSIT","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","amount":1000,"external_reference":"INV 77"}`
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/transactions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	CreateTransaction(func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Receipt, error) {
+		if req.ExternalReference != "INV 77" {
+			t.Errorf("external reference got %v, want %v", req.ExternalReference, "INV 77")
+		}
+		return nil, ledger.ErrNotValidReference
+	})(w, req)
+
+	if statusCode := w.Result().StatusCode; statusCode != http.StatusBadRequest {
+		t.Errorf("HTTP status got %v, want %v", statusCode, http.StatusBadRequest)
+	}
+}
+
+func TestCreateTransactionIdempotencyKey(t *testing.T) {
+	const body = `{"transaction":"DEPOSIT","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","amount":1000}`
+
+	var testcases = []struct {
+		key        string
+		err        error
+		statusCode int
+	}{
+		{"", nil, http.StatusOK},
+		{"payroll-2024-03", nil, http.StatusOK},
+		{strings.Repeat("k", 129), ledger.ErrNotValidKey, http.StatusBadRequest},
+	}
+
+	for i, tt := range testcases {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/transactions", strings.NewReader(body))
+		if len(tt.key) > 0 {
+			req.Header.Set(api.IdempotencyKeyHeader, tt.key)
+		}
+		w := httptest.NewRecorder()
+
+		CreateTransaction(func(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Receipt, error) {
+			if req.Key != tt.key {
+				t.Errorf("#%d key got %v, want %v", i, req.Key, tt.key)
+			}
+			if tt.err != nil {
+				return nil, tt.err
+			}
+			return &ledger.Receipt{TransactionID: "5b1c2f7e-3f7e-4a43-8d1f-6a1f1c1e9b10", Wallet: ledger.Wallet{ID: req.WalletID}}, nil
+		})(w, req)
+
+		if statusCode := w.Result().StatusCode; statusCode != tt.statusCode {
+			t.Errorf("#%d HTTP status got %v, want %v", i, statusCode, tt.statusCode)
+		}
+	}
+}
+
+func TestGetHistory(t *testing.T) {
+	const walletID = "a18c247b-8c28-468f-97a8-0bf33a48b922"
+
+	timestamp := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	var testcases = []struct {
+		history getHistoryFunc
+
+		response   string
+		statusCode int
+	}{
+		// wallet transactions
+		{
+			history: func(ctx context.Context, id string) ([]*ledger.HistoryEntry, error) {
+				return []*ledger.HistoryEntry{
+					{WalletID: id, Version: 2, Type: ledger.TransactionDeposit, Amount: 1000, Description: "Payroll", ExternalReference: "INV-1", Metadata: map[string]string{"employee": "42"}, Timestamp: timestamp},
+					{WalletID: id, Version: 3, Type: ledger.TransactionWithdraw, Amount: 200, Timestamp: timestamp},
+				}, nil
+			},
+			response:   `{"transactions":[{"wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","version":2,"transaction":"DEPOSIT","amount":1000,"description":"Payroll","external_reference":"INV-1","metadata":{"employee":"42"},"timestamp":"2024-03-01T10:00:00Z"},{"wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","version":3,"transaction":"WITHDRAW","amount":200,"timestamp":"2024-03-01T10:00:00Z"}]}`,
+			statusCode: http.StatusOK,
+		},
+		// wallet without transactions
+		{
+			history: func(ctx context.Context, id string) ([]*ledger.HistoryEntry, error) {
+				return nil, nil
+			},
+			response:   `{"transactions":[]}`,
+			statusCode: http.StatusOK,
+		},
+		// wallet not found
+		{
+			history: func(ctx context.Context, id string) ([]*ledger.HistoryEntry, error) {
+				return nil, ledger.ErrEntryNotFound
+			},
+			statusCode: http.StatusNotFound,
+		},
+		// service error
+		{
+			history: func(ctx context.Context, id string) ([]*ledger.HistoryEntry, error) {
+				return nil, errors.New("service error")
+			},
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for i, tt := range testcases {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/wallets/"+walletID+"/transactions", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": walletID})
+		w := httptest.NewRecorder()
+
+		GetHistory(tt.history)(w, req)
+
+		if statusCode := w.Result().StatusCode; statusCode != tt.statusCode {
+			t.Errorf("#%d HTTP status got %v, want %v", i, statusCode, tt.statusCode)
+		}
+
+		// we do apply TrimSpace to clean up response coming from HTTP protocol
+		if response := strings.TrimSpace(w.Body.String()); response != tt.response {
+			t.Errorf("#%d HTTP response got %v, want %s", i, response, tt.response)
+		}
+	}
+}
+
+func TestFindTransactions(t *testing.T) {
+	timestamp := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	var testcases = []struct {
+		url  string
+		find findTransactionsFunc
+
+		response   string
+		statusCode int
+	}{
+		// found
+		{
+			url: "http://localhost/transactions?external_reference=INV-1",
+			find: func(ctx context.Context, reference string) ([]*ledger.HistoryEntry, error) {
+				return []*ledger.HistoryEntry{
+					{WalletID: "a18c247b-8c28-468f-97a8-0bf33a48b922", Version: 2, Type: ledger.TransactionDeposit, Amount: 1000, ExternalReference: reference, Timestamp: timestamp},
+				}, nil
+			},
+			response:   `{"transactions":[{"wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","version":2,"transaction":"DEPOSIT","amount":1000,"external_reference":"INV-1","timestamp":"2024-03-01T10:00:00Z"}]}`,
+			statusCode: http.StatusOK,
+		},
+		// not found
+		{
+			url: "http://localhost/transactions?external_reference=INV-2",
+			find: func(ctx context.Context, reference string) ([]*ledger.HistoryEntry, error) {
+				return nil, nil
+			},
+			response:   `{"transactions":[]}`,
+			statusCode: http.StatusOK,
+		},
+		// missing reference
+		{
+			url: "http://localhost/transactions",
+			find: func(ctx context.Context, reference string) ([]*ledger.HistoryEntry, error) {
+				return nil, nil
+			},
+			statusCode: http.StatusBadRequest,
+		},
+		// service error
+		{
+			url: "http://localhost/transactions?external_reference=INV-1",
+			find: func(ctx context.Context, reference string) ([]*ledger.HistoryEntry, error) {
+				return nil, errors.New("service error")
+			},
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for i, tt := range testcases {
+		req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		w := httptest.NewRecorder()
+
+		FindTransactions(tt.find)(w, req)
+
+		if statusCode := w.Result().StatusCode; statusCode != tt.statusCode {
+			t.Errorf("#%d HTTP status got %v, want %v", i, statusCode, tt.statusCode)
+		}
+
+		// we do apply TrimSpace to clean up response coming from HTTP protocol
+		if response := strings.TrimSpace(w.Body.String()); response != tt.response {
+			t.Errorf("#%d HTTP response got %v, want %s", i, response, tt.response)
+		}
+	}
+}
+
+func TestGetTransaction(t *testing.T) {
+	const id = "5b1c2f7e-3f7e-4a43-8d1f-6a1f1c1e9b10"
+
+	timestamp := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	var testcases = []struct {
+		get getTransactionFunc
+
+		response   string
+		statusCode int
+	}{
+		// found
+		{
+			get: func(ctx context.Context, id string) (*ledger.HistoryEntry, error) {
+				return &ledger.HistoryEntry{ID: id, WalletID: "a18c247b-8c28-468f-97a8-0bf33a48b922", Version: 3, Type: ledger.TransactionWithdraw, Amount: 200, Timestamp: timestamp}, nil
+			},
+			response:   `{"id":"5b1c2f7e-3f7e-4a43-8d1f-6a1f1c1e9b10","wallet_id":"a18c247b-8c28-468f-97a8-0bf33a48b922","version":3,"transaction":"WITHDRAW","amount":200,"timestamp":"2024-03-01T10:00:00Z"}`,
+			statusCode: http.StatusOK,
+		},
+		// not found
+		{
+			get: func(ctx context.Context, id string) (*ledger.HistoryEntry, error) {
+				return nil, ledger.ErrEntryNotFound
+			},
+			statusCode: http.StatusNotFound,
+		},
+		// service error
+		{
+			get: func(ctx context.Context, id string) (*ledger.HistoryEntry, error) {
+				return nil, errors.New("service error")
+			},
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for i, tt := range testcases {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/transactions/"+id, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		w := httptest.NewRecorder()
+
+		GetTransaction(tt.get)(w, req)
+
+		if statusCode := w.Result().StatusCode; statusCode != tt.statusCode {
+			t.Errorf("#%d HTTP status got %v, want %v", i, statusCode, tt.statusCode)
+		}
+
+		// we do apply TrimSpace to clean up response coming from HTTP protocol
+		if response := strings.TrimSpace(w.Body.String()); response != tt.response {
+			t.Errorf("#%d HTTP response got %v, want %s", i, response, tt.response)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/deividaspetraitis/ledger"
 	"github.com/deividaspetraitis/ledger/pkg/api/v1"
 
+	"github.com/deividaspetraitis/go/errors"
 	libhttp "github.com/deividaspetraitis/go/http"
 	"github.com/deividaspetraitis/go/log"
 )
@@ -101,3 +102,101 @@ func GetWallet(getWallet getWalletFunc) http.HandlerFunc {
 		}
 	}
 }
+
+// setOverdraftFunc decouples actual credit line implementation and allows easily test HTTP handler.
+type setOverdraftFunc func(context.Context, *ledger.SetOverdraftRequest) (*ledger.Wallet, error)
+
+// SetOverdraft handles HTTP requests for setting wallet credit line.
+func SetOverdraft(setOverdraft setOverdraftFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// It's always json.
+		w.Header().Set("Content-Type", "application/json")
+
+		var request api.SetOverdraftRequest
+		if err := libhttp.UnmarshalRequest(r, &request); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "wallet",
+				"method":  "SetOverdraft",
+			}).Println("unable to unmarshal request data")
+
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		wallet, err := setOverdraft(r.Context(), request.Parse())
+		if err != nil {
+			switch {
+			case errors.Is(err, ledger.ErrEntryNotFound):
+				w.WriteHeader(http.StatusNotFound)
+			case errors.Is(err, ledger.ErrNotValidOverdraft):
+				w.WriteHeader(http.StatusConflict)
+			default:
+				log.WithError(err).WithFields(log.Fields{
+					"handler": "wallet",
+					"method":  "SetOverdraft",
+				}).Println("unable to set overdraft")
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := libhttp.Marshal(w, api.NewWalletResponse(wallet)); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "wallet",
+				"method":  "SetOverdraft",
+			}).Println("unable to marshal response data")
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// setInterestTermsFunc decouples actual interest terms implementation and allows easily test HTTP handler.
+type setInterestTermsFunc func(context.Context, *ledger.SetInterestTermsRequest) (*ledger.Wallet, error)
+
+// SetInterestTerms handles HTTP requests for setting wallet interest terms.
+func SetInterestTerms(setInterestTerms setInterestTermsFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// It's always json.
+		w.Header().Set("Content-Type", "application/json")
+
+		var request api.SetInterestTermsRequest
+		if err := libhttp.UnmarshalRequest(r, &request); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "wallet",
+				"method":  "SetInterestTerms",
+			}).Println("unable to unmarshal request data")
+
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		wallet, err := setInterestTerms(r.Context(), request.Parse())
+		if err != nil {
+			switch {
+			case errors.Is(err, ledger.ErrEntryNotFound):
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				log.WithError(err).WithFields(log.Fields{
+					"handler": "wallet",
+					"method":  "SetInterestTerms",
+				}).Println("unable to set interest terms")
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := libhttp.Marshal(w, api.NewWalletResponse(wallet)); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"handler": "wallet",
+				"method":  "SetInterestTerms",
+			}).Println("unable to marshal response data")
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -56,6 +56,20 @@ func TestGetWallet(t *testing.T) {
 			response:   `{"id":"60c6d3f2-ada5-4723-b509-65ce0d595c33","name":"test","balance":100}`,
 			statusCode: http.StatusOK,
 		},
+		// found, restored skipping unknown events
+		{
+			id: "3f1a0e0a-5a41-4d8e-8a0e-2f9f3c1b7d10",
+			getWallet: func(ctx context.Context, id string) (*ledger.Wallet, error) {
+				return &ledger.Wallet{
+					ID:       "3f1a0e0a-5a41-4d8e-8a0e-2f9f3c1b7d10",
+					Name:     "test",
+					Balance:  100,
+					Degraded: true,
+				}, nil
+			},
+			response:   `{"id":"3f1a0e0a-5a41-4d8e-8a0e-2f9f3c1b7d10","name":"test","balance":100,"degraded":true}`,
+			statusCode: http.StatusOK,
+		},
 		// service error
 		{
 			id: "90cbd66a-4ba0-407d-8762-c8d4043cd680",
@@ -88,3 +102,128 @@ func TestGetWallet(t *testing.T) {
 		}
 	}
 }
+
+func TestSetOverdraft(t *testing.T) {
+	var testcases = []struct {
+		body         string
+		setOverdraft setOverdraftFunc
+
+		response   string
+		statusCode int
+	}{
+		// set, part of credit line is used
+		{
+			body: `{"limit":10000,"rate":1825}`,
+			setOverdraft: func(ctx context.Context, req *ledger.SetOverdraftRequest) (*ledger.Wallet, error) {
+				return &ledger.Wallet{
+					ID:             req.WalletID,
+					Name:           "test",
+					Balance:        -2500,
+					OverdraftLimit: req.Limit,
+					OverdraftRate:  req.Rate,
+				}, nil
+			},
+			response:   `{"id":"60c6d3f2-ada5-4723-b509-65ce0d595c33","name":"test","balance":-2500,"overdraft_limit":10000,"overdraft_rate":1825,"available_credit":7500}`,
+			statusCode: http.StatusOK,
+		},
+		// negative limit
+		{
+			body: `{"limit":-1}`,
+			setOverdraft: func(ctx context.Context, req *ledger.SetOverdraftRequest) (*ledger.Wallet, error) {
+				return nil, nil
+			},
+			statusCode: http.StatusBadRequest,
+		},
+		// limit below overdrawn balance
+		{
+			body: `{"limit":100}`,
+			setOverdraft: func(ctx context.Context, req *ledger.SetOverdraftRequest) (*ledger.Wallet, error) {
+				return nil, errors.Wrap(ledger.ErrNotValidOverdraft, "limit is below overdrawn balance")
+			},
+			statusCode: http.StatusConflict,
+		},
+		// not found
+		{
+			body: `{"limit":100}`,
+			setOverdraft: func(ctx context.Context, req *ledger.SetOverdraftRequest) (*ledger.Wallet, error) {
+				return nil, ledger.ErrEntryNotFound
+			},
+			statusCode: http.StatusNotFound,
+		},
+	}
+
+	for i, tt := range testcases {
+		req := httptest.NewRequest(http.MethodPut, "http://localhost/admin/wallets/60c6d3f2-ada5-4723-b509-65ce0d595c33/overdraft", strings.NewReader(tt.body))
+		req = mux.SetURLVars(req, map[string]string{"id": "60c6d3f2-ada5-4723-b509-65ce0d595c33"})
+		w := httptest.NewRecorder()
+
+		SetOverdraft(tt.setOverdraft)(w, req)
+
+		if statusCode := w.Result().StatusCode; statusCode != tt.statusCode {
+			t.Errorf("#%d HTTP status got %v, want %v", i, statusCode, tt.statusCode)
+		}
+
+		// we do apply TrimSpace to clean up response coming from HTTP protocol
+		if response := strings.TrimSpace(w.Body.String()); response != tt.response {
+			t.Errorf("#%d HTTP response got %v, want %s", i, response, tt.response)
+		}
+	}
+}
+
+func TestSetInterestTerms(t *testing.T) {
+	var testcases = []struct {
+		body             string
+		setInterestTerms setInterestTermsFunc
+
+		response   string
+		statusCode int
+	}{
+		// set
+		{
+			body: `{"rate":250,"method":"compound","day_count":"30/360"}`,
+			setInterestTerms: func(ctx context.Context, req *ledger.SetInterestTermsRequest) (*ledger.Wallet, error) {
+				return &ledger.Wallet{
+					ID:       req.WalletID,
+					Name:     "test",
+					Balance:  100,
+					Interest: req.InterestTerms,
+				}, nil
+			},
+			response:   `{"id":"60c6d3f2-ada5-4723-b509-65ce0d595c33","name":"test","balance":100,"interest":{"rate":250,"method":"compound","day_count":"30/360"}}`,
+			statusCode: http.StatusOK,
+		},
+		// not supported day count convention
+		{
+			body: `{"rate":250,"method":"compound","day_count":"act/360"}`,
+			setInterestTerms: func(ctx context.Context, req *ledger.SetInterestTermsRequest) (*ledger.Wallet, error) {
+				return nil, nil
+			},
+			statusCode: http.StatusBadRequest,
+		},
+		// not found
+		{
+			body: `{"rate":250,"method":"simple","day_count":"act/365"}`,
+			setInterestTerms: func(ctx context.Context, req *ledger.SetInterestTermsRequest) (*ledger.Wallet, error) {
+				return nil, ledger.ErrEntryNotFound
+			},
+			statusCode: http.StatusNotFound,
+		},
+	}
+
+	for i, tt := range testcases {
+		req := httptest.NewRequest(http.MethodPut, "http://localhost/admin/wallets/60c6d3f2-ada5-4723-b509-65ce0d595c33/interest", strings.NewReader(tt.body))
+		req = mux.SetURLVars(req, map[string]string{"id": "60c6d3f2-ada5-4723-b509-65ce0d595c33"})
+		w := httptest.NewRecorder()
+
+		SetInterestTerms(tt.setInterestTerms)(w, req)
+
+		if statusCode := w.Result().StatusCode; statusCode != tt.statusCode {
+			t.Errorf("#%d HTTP status got %v, want %v", i, statusCode, tt.statusCode)
+		}
+
+		// we do apply TrimSpace to clean up response coming from HTTP protocol
+		if response := strings.TrimSpace(w.Body.String()); response != tt.response {
+			t.Errorf("#%d HTTP response got %v, want %s", i, response, tt.response)
+		}
+	}
+}
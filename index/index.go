@@ -0,0 +1,296 @@
+// Package index implements in-memory projection indexing wallet transactions.
+//
+// Index maps identifiers and external references of transactions to their positions within wallet streams.
+// It's built by replaying persisted records of all wallets and kept up to date by wrapping
+// the function persisting wallets. Index remembers the version every wallet stream was read through,
+// so transactions persisted by other writers, e.g. other service instances or ledgerctl,
+// are indexed reading only records following it once the index is caught up, see Run.
+package index
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/database"
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+	"github.com/deividaspetraitis/go/log"
+)
+
+// Default configuration values.
+const defaultInterval = time.Minute
+
+// Config represents transactions index configuration.
+type Config struct {
+	Interval time.Duration `mapstructure:"interval"` // How often transactions persisted by other writers are indexed
+}
+
+// Validate implements validator.Validator.
+func (c *Config) Validate() error {
+	if c.Interval < 0 {
+		return errors.New("interval can not be negative")
+	}
+	return nil
+}
+
+// Position represents position of the transaction event within wallet stream.
+type Position struct {
+	WalletID string // Wallet identifier
+	Version  uint64 // Version of the transaction event
+}
+
+// IDsFunc returns IDs of all persisted aggregates of the aggregate type.
+type IDsFunc func(ctx context.Context, aggregate es.Aggregate) ([]string, error)
+
+// RecordsFunc returns iterator over persisted records of the aggregate following the given version.
+type RecordsFunc func(ctx context.Context, aggregate es.Aggregate, id string, after uint64) (schema.Iterator, error)
+
+// Index is an in-memory index of wallet transactions.
+type Index struct {
+	interval time.Duration
+	records  RecordsFunc
+
+	mu           sync.RWMutex
+	transactions map[string]Position   // positions by transaction ID
+	references   map[string][]Position // positions by external reference
+	read         map[string]uint64     // version wallet streams were read through by wallet IDs
+}
+
+// New constructs a new empty Index reading transactions using records.
+func New(cfg *Config, records RecordsFunc) *Index {
+	i := Index{
+		interval:     defaultInterval,
+		records:      records,
+		transactions: make(map[string]Position),
+		references:   make(map[string][]Position),
+		read:         make(map[string]uint64),
+	}
+	if cfg != nil && cfg.Interval > 0 {
+		i.interval = cfg.Interval
+	}
+	return &i
+}
+
+// Add indexes wallet event persisted at the given version, events other than transactions are ignored.
+// Adding already indexed event has no effect.
+func (i *Index) Add(walletID string, version uint64, event es.MarshalUnmarshaler) {
+	entry := ledger.NewHistoryEntry(walletID, version, time.Time{}, event)
+	if entry == nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	position := Position{WalletID: walletID, Version: version}
+	if len(entry.ID) > 0 {
+		i.transactions[entry.ID] = position
+	}
+
+	if len(entry.ExternalReference) == 0 {
+		return
+	}
+	for _, v := range i.references[entry.ExternalReference] {
+		if v == position {
+			return
+		}
+	}
+	i.references[entry.ExternalReference] = append(i.references[entry.ExternalReference], position)
+}
+
+// Transaction returns position of the transaction of the given ID and reports whether it's indexed.
+func (i *Index) Transaction(id string) (Position, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	position, ok := i.transactions[id]
+	return position, ok
+}
+
+// Reference returns positions of transactions of the given external reference.
+func (i *Index) Reference(reference string) []Position {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return append([]Position{}, i.references[reference]...)
+}
+
+// FindWallet returns ID of the wallet having the first indexed transaction of the given external reference,
+// empty string if there is no such transaction.
+func (i *Index) FindWallet(ctx context.Context, reference string) (string, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if positions := i.references[reference]; len(positions) > 0 {
+		return positions[0].WalletID, nil
+	}
+	return "", nil
+}
+
+// Transactions returns number of indexed transactions.
+func (i *Index) Transactions() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.transactions)
+}
+
+// Len returns number of indexed external references.
+func (i *Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.references)
+}
+
+// Save wraps save so successfully persisted transactions are indexed.
+func (i *Index) Save(save database.SaveAggregateFunc) database.SaveAggregateFunc {
+	return func(ctx context.Context, aggregate es.Aggregate) error {
+		events := aggregate.Events()
+		if err := save(ctx, aggregate); err != nil {
+			return err
+		}
+
+		for _, v := range events {
+			i.Add(v.AggregateID, uint64(v.Version), v.Data)
+		}
+		return nil
+	}
+}
+
+// Run indexes transactions persisted by other writers every configured interval until ctx is done.
+// Wallets are listed by ids on every run, see Rebuild.
+func (i *Index) Run(ctx context.Context, ids IDsFunc) {
+	ticker := time.NewTicker(i.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := i.Rebuild(ctx, ids); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"component": "index",
+			}).Println("unable to catch up transactions index")
+		}
+	}
+}
+
+// Rebuild indexes persisted transactions of all wallets listed by ids.
+// Only records following the version wallet stream was read through by the previous call are read.
+func (i *Index) Rebuild(ctx context.Context, ids IDsFunc) error {
+	wallets, err := ids(ctx, &ledger.WalletAggregate{})
+	if err != nil {
+		return errors.Wrap(err, "unable to list wallets")
+	}
+
+	for _, id := range wallets {
+		if err := i.rebuild(ctx, id); err != nil {
+			return errors.Wrapf(err, "unable to index wallet %s", id)
+		}
+	}
+	return nil
+}
+
+// rebuild indexes persisted transactions of the wallet following the version its stream was read through.
+func (i *Index) rebuild(ctx context.Context, id string) error {
+	i.mu.RLock()
+	after := i.read[id]
+	i.mu.RUnlock()
+
+	it, err := i.records(ctx, &ledger.WalletAggregate{}, id, after)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		record, err := it.Value()
+		if err != nil {
+			return err
+		}
+
+		event, err := schema.Decode(&ledger.WalletAggregate{}, record.Type, record.Data)
+		if err != nil && !errors.Is(err, schema.ErrUnknownEvent) {
+			return err
+		}
+		// unknown events are not transactions known to the service
+		if err == nil {
+			i.Add(id, record.Version, event)
+		}
+
+		i.mu.Lock()
+		i.read[id] = record.Version
+		i.mu.Unlock()
+	}
+	return it.Error()
+}
+
+// FindByID returns transaction of the given ID, if it's not indexed ErrEntryNotFound is returned.
+func (i *Index) FindByID(ctx context.Context, id string) (*ledger.HistoryEntry, error) {
+	position, ok := i.Transaction(id)
+	if !ok {
+		return nil, ledger.ErrEntryNotFound
+	}
+
+	entries, err := i.find(ctx, []Position{position})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ledger.ErrEntryNotFound
+	}
+	return entries[0], nil
+}
+
+// FindByReference returns transactions of the given external reference in the order they were indexed.
+func (i *Index) FindByReference(ctx context.Context, reference string) ([]*ledger.HistoryEntry, error) {
+	return i.find(ctx, i.Reference(reference))
+}
+
+// find returns transactions at given positions, only the record persisted at the position is read.
+func (i *Index) find(ctx context.Context, positions []Position) ([]*ledger.HistoryEntry, error) {
+	var entries []*ledger.HistoryEntry
+	for _, p := range positions {
+		entry, err := i.entry(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// entry returns transaction persisted at the position, nil if there is no transaction at it.
+func (i *Index) entry(ctx context.Context, p Position) (*ledger.HistoryEntry, error) {
+	it, err := i.records(ctx, &ledger.WalletAggregate{}, p.WalletID, p.Version-1)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	if !it.Next() {
+		return nil, it.Error()
+	}
+	record, err := it.Value()
+	if err != nil {
+		return nil, err
+	}
+	if record.Version != p.Version {
+		return nil, nil
+	}
+
+	event, err := schema.Decode(&ledger.WalletAggregate{}, record.Type, record.Data)
+	if err != nil {
+		if errors.Is(err, schema.ErrUnknownEvent) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return ledger.NewHistoryEntry(p.WalletID, record.Version, record.Timestamp, event), nil
+}
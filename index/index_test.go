@@ -0,0 +1,328 @@
+package index
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/es"
+)
+
+// store is an in-memory wallets store.
+type store struct {
+	mu      sync.Mutex
+	records map[string][]*schema.Record
+	read    int // number of records read following a version
+}
+
+func newStore() *store {
+	return &store{
+		records: make(map[string][]*schema.Record),
+	}
+}
+
+func (s *store) save(ctx context.Context, aggregate es.Aggregate) error {
+	records, _, err := schema.Encode(aggregate, aggregate.Events())
+	if err != nil || len(records) == 0 {
+		return err
+	}
+
+	s.mu.Lock()
+	for _, v := range records {
+		s.records[v.AggregateID] = append(s.records[v.AggregateID], v)
+	}
+	s.mu.Unlock()
+
+	for _, v := range aggregate.Events() {
+		if err := aggregate.Sync(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *store) get(ctx context.Context, aggregate es.Aggregate, id string) (*ledger.WalletAggregate, error) {
+	s.mu.Lock()
+	records := s.records[id]
+	s.mu.Unlock()
+
+	if len(records) == 0 {
+		return nil, ledger.ErrEntryNotFound
+	}
+
+	wallet := aggregate.(*ledger.WalletAggregate)
+	for _, v := range records {
+		event, err := schema.Decode(wallet, v.Type, v.Data)
+		if err != nil {
+			return nil, err
+		}
+		if err := wallet.Reply([]*es.Event{es.NewEvent(id, wallet, event)}); err != nil {
+			return nil, err
+		}
+	}
+	return wallet, nil
+}
+
+func (s *store) iterate(ctx context.Context, aggregate es.Aggregate, id string) (schema.Iterator, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &iterator{records: s.records[id]}, nil
+}
+
+func (s *store) iterateAfter(ctx context.Context, aggregate es.Aggregate, id string, after uint64) (schema.Iterator, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []*schema.Record
+	if v := s.records[id]; after < uint64(len(v)) {
+		records = v[after:]
+	}
+	return &iterator{records: records, read: func() {
+		s.mu.Lock()
+		s.read++
+		s.mu.Unlock()
+	}}, nil
+}
+
+func (s *store) ids(ctx context.Context, aggregate es.Aggregate) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id := range s.records {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// iterator implements schema.Iterator over slice of records, read is called for every record read if set.
+type iterator struct {
+	records []*schema.Record
+	read    func()
+	i       int
+}
+
+func (it *iterator) Next() bool { it.i++; return it.i <= len(it.records) }
+
+func (it *iterator) Value() (*schema.Record, error) {
+	if it.read != nil {
+		it.read()
+	}
+	return it.records[it.i-1], nil
+}
+
+func (it *iterator) Error() error { return nil }
+func (it *iterator) Close()       {}
+
+// transact persists transactions of the wallet using save and returns their IDs.
+func transact(t *testing.T, s *store, save func(context.Context, es.Aggregate) error, txs ...*ledger.TransactionRequest) []string {
+	var ids []string
+	for i, req := range txs {
+		receipt, err := ledger.CreateTransaction(context.Background(), save, s.get, nil, req)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		ids = append(ids, receipt.TransactionID)
+	}
+	return ids
+}
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+	s := newStore()
+
+	var wallets []string
+	for i := 0; i < 2; i++ {
+		wallet, err := ledger.CreateWallet(ctx, s.save, &ledger.CreateWalletRequest{Name: "wallet"})
+		if err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+		wallets = append(wallets, wallet.ID)
+	}
+
+	// transactions persisted before the index is built
+	ids := transact(t, s, s.save,
+		&ledger.TransactionRequest{Type: ledger.TransactionDeposit, WalletID: wallets[0], Amount: 1000, ExternalReference: "INV-1", Description: "Payroll"},
+		&ledger.TransactionRequest{Type: ledger.TransactionDeposit, WalletID: wallets[0], Amount: 500},
+	)
+
+	idx := New(nil, s.iterateAfter)
+	if err := idx.Rebuild(ctx, s.ids); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	// transactions persisted through the index
+	ids = append(ids, transact(t, s, idx.Save(s.save),
+		&ledger.TransactionRequest{Type: ledger.TransactionWithdraw, WalletID: wallets[0], Amount: 200, ExternalReference: "RF-9", Metadata: map[string]string{"order": "9"}},
+		&ledger.TransactionRequest{Type: ledger.TransactionDeposit, WalletID: wallets[1], Amount: 300, ExternalReference: "INV-1"},
+	)...)
+
+	// rebuilding does not duplicate indexed transactions
+	if err := idx.Rebuild(ctx, s.ids); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	if idx.Len() != 2 {
+		t.Errorf("got %v references, want %v", idx.Len(), 2)
+	}
+	if idx.Transactions() != 4 {
+		t.Errorf("got %v transactions, want %v", idx.Transactions(), 4)
+	}
+
+	var testcases = []struct {
+		reference string
+		want      []ledger.HistoryEntry
+	}{
+		{
+			reference: "INV-1",
+			want: []ledger.HistoryEntry{
+				{WalletID: wallets[0], Version: 2, Type: ledger.TransactionDeposit, Amount: 1000, Description: "Payroll", ExternalReference: "INV-1"},
+				{WalletID: wallets[1], Version: 2, Type: ledger.TransactionDeposit, Amount: 300, ExternalReference: "INV-1"},
+			},
+		},
+		{
+			reference: "RF-9",
+			want: []ledger.HistoryEntry{
+				{WalletID: wallets[0], Version: 4, Type: ledger.TransactionWithdraw, Amount: 200, ExternalReference: "RF-9", Metadata: map[string]string{"order": "9"}},
+			},
+		},
+		{
+			reference: "unknown",
+		},
+	}
+
+	for i, tt := range testcases {
+		entries, err := idx.FindByReference(ctx, tt.reference)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if len(entries) != len(tt.want) {
+			t.Fatalf("#%d got %v entries, want %v", i, len(entries), len(tt.want))
+		}
+		for j, got := range entries {
+			want := tt.want[j]
+			if got.WalletID != want.WalletID || got.Version != want.Version || got.Type != want.Type || got.Amount != want.Amount ||
+				got.Description != want.Description || got.ExternalReference != want.ExternalReference || len(got.Metadata) != len(want.Metadata) {
+				t.Errorf("#%d.%d got %+v, want %+v", i, j, got, want)
+			}
+			for k, v := range want.Metadata {
+				if got.Metadata[k] != v {
+					t.Errorf("#%d.%d metadata %s got %v, want %v", i, j, k, got.Metadata[k], v)
+				}
+			}
+		}
+	}
+
+	wallet, err := idx.FindWallet(ctx, "RF-9")
+	if err != nil || wallet != wallets[0] {
+		t.Errorf("got %v %v, want %v %v", wallet, err, wallets[0], nil)
+	}
+
+	// transactions are found by ID regardless of whether they were indexed by rebuild or save
+	for i, want := range []ledger.HistoryEntry{
+		{WalletID: wallets[0], Version: 2, Amount: 1000},
+		{WalletID: wallets[0], Version: 3, Amount: 500},
+		{WalletID: wallets[0], Version: 4, Amount: 200},
+		{WalletID: wallets[1], Version: 2, Amount: 300},
+	} {
+		entry, err := idx.FindByID(ctx, ids[i])
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if entry.ID != ids[i] || entry.WalletID != want.WalletID || entry.Version != want.Version || entry.Amount != want.Amount {
+			t.Errorf("#%d got %+v, want %+v", i, entry, want)
+		}
+	}
+
+	if _, err := idx.FindByID(ctx, "unknown"); err != ledger.ErrEntryNotFound {
+		t.Errorf("got %v, want %v", err, ledger.ErrEntryNotFound)
+	}
+}
+
+func TestIndexCatchUp(t *testing.T) {
+	ctx := context.Background()
+	s := newStore()
+
+	wallet, err := ledger.CreateWallet(ctx, s.save, &ledger.CreateWalletRequest{Name: "wallet"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	transact(t, s, s.save,
+		&ledger.TransactionRequest{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: 1000, ExternalReference: "INV-1"},
+		&ledger.TransactionRequest{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: 500},
+	)
+
+	idx := New(nil, s.iterateAfter)
+	if err := idx.Rebuild(ctx, s.ids); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	// transactions persisted by another writer bypassing the index, e.g. ledgerctl
+	ids := transact(t, s, s.save,
+		&ledger.TransactionRequest{Type: ledger.TransactionWithdraw, WalletID: wallet.ID, Amount: 200, ExternalReference: "RF-9"},
+	)
+	if _, err := idx.FindByID(ctx, ids[0]); err != ledger.ErrEntryNotFound {
+		t.Errorf("got %v, want %v", err, ledger.ErrEntryNotFound)
+	}
+
+	// catching up reads only records following the ones indexed already
+	s.read = 0
+	if err := idx.Rebuild(ctx, s.ids); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if s.read != 1 {
+		t.Errorf("read got %v, want %v", s.read, 1)
+	}
+
+	// transaction is found reading only the record at its position
+	s.read = 0
+	entry, err := idx.FindByID(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if entry.Version != 4 || entry.Amount != 200 || entry.ExternalReference != "RF-9" {
+		t.Errorf("got %+v, want withdraw of %v at version %v", entry, 200, 4)
+	}
+	if s.read != 1 {
+		t.Errorf("read got %v, want %v", s.read, 1)
+	}
+
+	if wallet, err := idx.FindWallet(ctx, "RF-9"); err != nil || len(wallet) == 0 {
+		t.Errorf("got %v %v, want wallet", wallet, err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	s := newStore()
+
+	if _, err := ledger.History(ctx, s.iterate, "a18c247b-8c28-468f-97a8-0bf33a48b922"); err != ledger.ErrEntryNotFound {
+		t.Errorf("got %v, want %v", err, ledger.ErrEntryNotFound)
+	}
+
+	wallet, err := ledger.CreateWallet(ctx, s.save, &ledger.CreateWalletRequest{Name: "wallet"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	history, err := ledger.History(ctx, s.iterate, wallet.ID)
+	if err != nil || len(history) != 0 {
+		t.Errorf("got %v %v, want %v %v", len(history), err, 0, nil)
+	}
+
+	transact(t, s, s.save,
+		&ledger.TransactionRequest{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: 1000, Description: "Payroll"},
+		&ledger.TransactionRequest{Type: ledger.TransactionWithdraw, WalletID: wallet.ID, Amount: 400, Description: "Refund"},
+	)
+
+	history, err = ledger.History(ctx, s.iterate, wallet.ID)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if len(history) != 2 || history[0].Description != "Payroll" || history[1].Type != ledger.TransactionWithdraw || history[1].Amount != 400 {
+		t.Errorf("got %+v, want payroll deposit followed by refund withdraw", history)
+	}
+}
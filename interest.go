@@ -0,0 +1,148 @@
+package ledger
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// Interest compounding methods.
+const (
+	InterestSimple   = "simple"   // interest accrues on the balance only
+	InterestCompound = "compound" // interest accrues on the balance and interest accrued so far in the period
+)
+
+// Interest day count conventions.
+const (
+	DayCountACT365    = "act/365" // every day is 1/365 of a year
+	DayCountThirty360 = "30/360"  // every month is 30 days of 360 days year
+)
+
+var (
+	// ErrNotValidInterestTerms is returned when interest terms are not valid.
+	ErrNotValidInterestTerms = errors.New("given interest terms are not valid")
+
+	// ErrInterestPosted is returned when interest of the period was posted already.
+	ErrInterestPosted = errors.New("interest of the period is posted already")
+)
+
+// InterestTerms represents terms savings wallet earns interest by, zero rate means wallet earns no interest.
+type InterestTerms struct {
+	Rate     int    `json:"rate"`      // Annual interest rate in basis points
+	Method   string `json:"method"`    // InterestSimple or InterestCompound
+	DayCount string `json:"day_count"` // DayCountACT365 or DayCountThirty360
+}
+
+// Validate implements validator.Validator.
+func (t *InterestTerms) Validate() error {
+	if t.Rate < 0 {
+		return ErrNotValidInterestTerms
+	}
+	switch t.Method {
+	case InterestSimple, InterestCompound:
+	default:
+		return ErrNotValidInterestTerms
+	}
+	switch t.DayCount {
+	case DayCountACT365, DayCountThirty360:
+	default:
+		return ErrNotValidInterestTerms
+	}
+	return nil
+}
+
+// InterestTermsSet represents an event emitted when wallet interest terms are set.
+type InterestTermsSet struct {
+	WalletID string `json:"wallet_id"`
+	InterestTerms
+}
+
+// Implements es.MarshalUnmarshaler
+func (i *InterestTermsSet) UnmarshalJSON(b []byte) error {
+	type interestTermsSet InterestTermsSet
+	temp := interestTermsSet(*i)
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+	*i = InterestTermsSet(temp)
+	return nil
+}
+
+// Implements es.MarshalUnmarshaler
+func (i *InterestTermsSet) MarshalJSON() ([]byte, error) {
+	type interestTermsSet InterestTermsSet
+	temp := interestTermsSet(*i)
+	return json.Marshal(temp)
+}
+
+// InterestPosted represents an event emitted when interest accrued over the period is credited to the wallet.
+type InterestPosted struct {
+	WalletID string    `json:"wallet_id"`
+	Amount   int       `json:"amount"` // Interest in cents
+	From     time.Time `json:"from"`   // First day of the period
+	To       time.Time `json:"to"`     // Day after the last day of the period
+}
+
+// Implements es.MarshalUnmarshaler
+func (i *InterestPosted) UnmarshalJSON(b []byte) error {
+	type interestPosted InterestPosted
+	temp := interestPosted(*i)
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+	*i = InterestPosted(temp)
+	return nil
+}
+
+// Implements es.MarshalUnmarshaler
+func (i *InterestPosted) MarshalJSON() ([]byte, error) {
+	type interestPosted InterestPosted
+	temp := interestPosted(*i)
+	return json.Marshal(temp)
+}
+
+// SetInterestTermsRequest represents a request for setting wallet interest terms.
+type SetInterestTermsRequest struct {
+	WalletID string // Wallet identifier
+	InterestTerms
+}
+
+// Validate implements validator.Validator.
+func (r *SetInterestTermsRequest) Validate() error {
+	if !isValidID(r.WalletID) {
+		return ErrNotValidWalletID
+	}
+	return r.InterestTerms.Validate()
+}
+
+// SetInterestTerms sets terms wallet earns interest by from now on.
+func (w *WalletAggregate) SetInterestTerms(terms InterestTerms) error {
+	if err := terms.Validate(); err != nil {
+		return err
+	}
+
+	return w.Apply(es.NewEvent(w.ID, w, &InterestTermsSet{
+		WalletID:      w.ID,
+		InterestTerms: terms,
+	}))
+}
+
+// PostInterest credits interest accrued over the period from and to to the wallet.
+// Periods must not overlap with the ones posted already. Posting of nothing is recorded as well,
+// so the period is not evaluated again.
+func (w *WalletAggregate) PostInterest(from, to time.Time, amount int) error {
+	if !to.After(from) || amount < 0 {
+		return errors.Newf("not valid interest posting of %d for %s - %s", amount, from, to)
+	}
+	if from.Before(w.InterestPostedThrough) {
+		return ErrInterestPosted
+	}
+	return w.Apply(es.NewEvent(w.ID, w, &InterestPosted{
+		WalletID: w.ID,
+		Amount:   amount,
+		From:     from,
+		To:       to,
+	}))
+}
@@ -0,0 +1,114 @@
+// Package interest implements interest accrual and posting of savings wallets.
+//
+// Interest accrues daily on the end-of-day balance of the wallet reconstructed from its persisted events,
+// according to the interest terms in effect at the end of the day. Accrual depends on persisted events only,
+// thus it's deterministic and any past period can be re-run yielding the same result.
+// Interest accrued over a calendar month is posted to the wallet as ledger.InterestPosted event.
+package interest
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/es"
+)
+
+// Day represents end-of-day state of the wallet.
+type Day struct {
+	Date    time.Time            // Day, midnight UTC
+	Balance int                  // Balance at the end of the day in cents
+	Terms   ledger.InterestTerms // Interest terms in effect at the end of the day
+}
+
+// RecordsFunc returns iterator over persisted records of the aggregate.
+type RecordsFunc func(ctx context.Context, aggregate es.Aggregate, id string) (schema.Iterator, error)
+
+// EndOfDay reconstructs end-of-day states of the wallet for every day from and until to, exclusive, from its persisted records.
+// Event belongs to the day it was persisted at in UTC, days before wallet was initialised have zero balance and no interest terms.
+// If from is zero days are reconstructed starting at the day of the first record. Unknown events are handled
+// according to the policy, see ledger.EndOfDay.
+func EndOfDay(it schema.Iterator, id string, from, to time.Time, unknown schema.Policy) ([]Day, error) {
+	ends, err := ledger.EndOfDay(it, id, from, to, unknown)
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]Day, 0, len(ends))
+	for _, v := range ends {
+		days = append(days, Day{
+			Date:    v.Date,
+			Balance: v.Wallet.Balance,
+			Terms:   v.Wallet.Interest,
+		})
+	}
+	return days, nil
+}
+
+// Accrue returns interest accrued over given days, it's not rounded.
+// Only positive balances earn interest. Compound interest accrues daily on the balance along interest accrued so far.
+func Accrue(days []Day) *big.Rat {
+	accrued := new(big.Rat)
+	for _, d := range days {
+		if d.Balance <= 0 || d.Terms.Rate == 0 {
+			continue
+		}
+
+		base := new(big.Rat).SetInt64(int64(d.Balance))
+		if d.Terms.Method == ledger.InterestCompound {
+			base.Add(base, accrued)
+		}
+
+		// base * rate / 10000 * day fraction
+		interest := new(big.Rat).Mul(base, big.NewRat(int64(d.Terms.Rate), 10000))
+		interest.Mul(interest, fraction(d.Terms.DayCount, d.Date))
+		accrued.Add(accrued, interest)
+	}
+	return accrued
+}
+
+// fraction returns year fraction of the day according to the day count convention.
+// Under 30/360 the day weighs as many days as it adds to the 30/360 day count since the first day of its month,
+// thus 31st day counts for nothing and last day of February counts for the rest of the month.
+func fraction(dayCount string, day time.Time) *big.Rat {
+	if dayCount == ledger.DayCountThirty360 {
+		from, _ := Period(day)
+		return big.NewRat(int64(days360(from, day.AddDate(0, 0, 1))-days360(from, day)), 360)
+	}
+	return big.NewRat(1, 365)
+}
+
+// days360 returns number of days between start and end according to 30/360 convention, see ISDA 2006 section 4.16(f).
+// Every month is treated as having 30 days.
+func days360(start, end time.Time) int {
+	y1, m1, d1 := start.Date()
+	y2, m2, d2 := end.Date()
+	if d1 == 31 {
+		d1 = 30
+	}
+	if d2 == 31 && d1 == 30 {
+		d2 = 30
+	}
+	return 360*(y2-y1) + 30*(int(m2)-int(m1)) + (d2 - d1)
+}
+
+// Round rounds accrued interest half up to cents.
+func Round(accrued *big.Rat) int {
+	num, denom := accrued.Num(), accrued.Denom()
+
+	// (2 * num + denom) / (2 * denom)
+	n := new(big.Int).Mul(num, big.NewInt(2))
+	n.Add(n, denom)
+	d := new(big.Int).Mul(denom, big.NewInt(2))
+	return int(new(big.Int).Div(n, d).Int64())
+}
+
+// Period returns calendar month containing t, i.e. its first day and first day of the following month.
+func Period(t time.Time) (from, to time.Time) {
+	t = t.UTC()
+	from = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return from, from.AddDate(0, 1, 0)
+}
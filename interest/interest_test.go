@@ -0,0 +1,513 @@
+package interest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/es"
+)
+
+// store is an in-memory wallets store keeping records persisted at the time given by now.
+type store struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	records map[string][]*schema.Record
+	reads   int // records read while restoring aggregates by get
+}
+
+func newStore(now time.Time) *store {
+	s := store{
+		records: make(map[string][]*schema.Record),
+	}
+	s.at(now)
+	return &s
+}
+
+// at sets time following records are persisted at.
+func (s *store) at(t time.Time) {
+	s.now = func() time.Time { return t }
+}
+
+func (s *store) save(ctx context.Context, aggregate es.Aggregate) error {
+	records, _, err := schema.Encode(aggregate, aggregate.Events())
+	if err != nil || len(records) == 0 {
+		return err
+	}
+
+	s.mu.Lock()
+	for _, v := range records {
+		v.Timestamp = s.now()
+		s.records[v.AggregateID] = append(s.records[v.AggregateID], v)
+	}
+	s.mu.Unlock()
+
+	for _, v := range aggregate.Events() {
+		if err := aggregate.Sync(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *store) get(ctx context.Context, aggregate es.Aggregate, id string) (*ledger.WalletAggregate, error) {
+	return s.restore(aggregate, id, true)
+}
+
+// restore restores aggregate, records read are counted if count is set.
+func (s *store) restore(aggregate es.Aggregate, id string, count bool) (*ledger.WalletAggregate, error) {
+	s.mu.Lock()
+	records := s.records[id]
+	// only records following aggregate version are read, so aggregate restored earlier is caught up
+	if version := int(aggregate.Root().Version()); version <= len(records) {
+		records = records[version:]
+	}
+	if count {
+		s.reads += len(records)
+	}
+	s.mu.Unlock()
+
+	if len(records) == 0 && aggregate.Root().Version() == 0 {
+		return nil, ledger.ErrEntryNotFound
+	}
+
+	wallet := aggregate.(*ledger.WalletAggregate)
+	for _, v := range records {
+		event, err := schema.Decode(wallet, v.Type, v.Data)
+		if err != nil {
+			return nil, err
+		}
+		if err := wallet.Reply([]*es.Event{es.NewEvent(id, wallet, event)}); err != nil {
+			return nil, err
+		}
+	}
+	return wallet, nil
+}
+
+func (s *store) iterate(ctx context.Context, aggregate es.Aggregate, id string) (schema.Iterator, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &iterator{records: s.records[id]}, nil
+}
+
+func (s *store) ids(ctx context.Context, aggregate es.Aggregate) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id := range s.records {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (s *store) execute(ctx context.Context, id string, op func(*ledger.WalletAggregate) error) (*ledger.Wallet, error) {
+	wallet, err := s.restore(&ledger.WalletAggregate{}, id, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := op(wallet); err != nil {
+		return nil, err
+	}
+	if err := s.save(ctx, wallet); err != nil {
+		return nil, err
+	}
+	return &wallet.Wallet, nil
+}
+
+// iterator implements schema.Iterator over slice of records.
+type iterator struct {
+	records []*schema.Record
+	i       int
+}
+
+func (it *iterator) Next() bool                     { it.i++; return it.i <= len(it.records) }
+func (it *iterator) Value() (*schema.Record, error) { return it.records[it.i-1], nil }
+func (it *iterator) Error() error                   { return nil }
+func (it *iterator) Close()                         {}
+
+// date returns midnight of the given day of January 2024.
+func date(day int) time.Time {
+	return time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC)
+}
+
+// savingsWallet persists a wallet created on January 1st with the given terms,
+// 1000.00 deposited on January 10th and 500.00 withdrawn on January 20th.
+func savingsWallet(t *testing.T, s *store, terms ledger.InterestTerms) string {
+	ctx := context.Background()
+
+	s.at(date(1).Add(10 * time.Hour))
+	wallet, err := ledger.CreateWallet(ctx, s.save, &ledger.CreateWalletRequest{Name: "savings"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if _, err := s.execute(ctx, wallet.ID, func(w *ledger.WalletAggregate) error { return w.SetInterestTerms(terms) }); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	for _, tx := range []struct {
+		at time.Time
+		tx *ledger.Transaction
+	}{
+		{at: date(10).Add(12 * time.Hour), tx: &ledger.Transaction{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: 100000}},
+		{at: date(20).Add(9 * time.Hour), tx: &ledger.Transaction{Type: ledger.TransactionWithdraw, WalletID: wallet.ID, Amount: 50000}},
+	} {
+		s.at(tx.at)
+		if _, err := s.execute(ctx, wallet.ID, func(w *ledger.WalletAggregate) error { return w.ProcessTransaction(tx.tx) }); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+	}
+
+	return wallet.ID
+}
+
+func TestDays360(t *testing.T) {
+	var testcases = []struct {
+		start, end time.Time
+		days       int
+	}{
+		{start: date(1), end: date(2), days: 1},
+		{start: date(30), end: date(31), days: 0},
+		{start: date(31), end: date(32), days: 1},
+		{start: time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC), end: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), days: 3},
+		{start: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), end: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), days: 2},
+		{start: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), end: date(1), days: 1},
+		{start: date(1), end: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), days: 360},
+	}
+
+	for i, tt := range testcases {
+		if days := days360(tt.start, tt.end); days != tt.days {
+			t.Errorf("#%d got %v, want %v", i, days, tt.days)
+		}
+	}
+}
+
+func TestEndOfDay(t *testing.T) {
+	s := newStore(date(1))
+	terms := ledger.InterestTerms{Rate: 100, Method: ledger.InterestSimple, DayCount: ledger.DayCountACT365}
+	id := savingsWallet(t, s, terms)
+
+	it, _ := s.iterate(context.Background(), &ledger.WalletAggregate{}, id)
+	days, err := EndOfDay(it, id, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), date(22), schema.PolicyFail)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	if len(days) != 22 {
+		t.Fatalf("days got %v, want %v", len(days), 22)
+	}
+	for i, d := range days {
+		var balance int
+		switch {
+		case d.Date.Before(date(10)):
+			balance = 0
+		case d.Date.Before(date(20)):
+			balance = 100000
+		default:
+			balance = 50000
+		}
+		if want := time.Date(2023, 12, 31+i, 0, 0, 0, 0, time.UTC); !d.Date.Equal(want) {
+			t.Errorf("#%d date got %v, want %v", i, d.Date, want)
+		}
+		if d.Balance != balance {
+			t.Errorf("#%d balance got %v, want %v", i, d.Balance, balance)
+		}
+		// terms are set along wallet on January 1st
+		if want := i > 0; (d.Terms == terms) != want {
+			t.Errorf("#%d terms got %v, want %v", i, d.Terms, terms)
+		}
+	}
+}
+
+func TestEndOfDayUnknownEvents(t *testing.T) {
+	var testcases = []struct {
+		policy schema.Policy
+		err    bool
+	}{
+		{policy: schema.PolicyFail, err: true},
+		{policy: "", err: true},
+		{policy: schema.PolicySkip, err: false},
+		{policy: schema.PolicyQuarantine, err: false},
+	}
+
+	s := newStore(date(1))
+	id := savingsWallet(t, s, ledger.InterestTerms{Rate: 100, Method: ledger.InterestSimple, DayCount: ledger.DayCountACT365})
+
+	// event persisted by a newer service version
+	s.records[id] = append(s.records[id], &schema.Record{
+		AggregateID: id,
+		Version:     uint64(len(s.records[id]) + 1),
+		Type:        "Unknown.v1",
+		Timestamp:   date(21),
+		Data:        []byte(`{}`),
+	})
+
+	for i, tt := range testcases {
+		it, _ := s.iterate(context.Background(), &ledger.WalletAggregate{}, id)
+		days, err := EndOfDay(it, id, date(1), date(22), tt.policy)
+
+		var unknown *schema.UnknownEventError
+		if tt.err {
+			if unknown, _ = err.(*schema.UnknownEventError); unknown == nil || unknown.Version != 5 {
+				t.Errorf("#%d got %v, want %v", i, err, "unknown event at version 5")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if len(days) != 21 || days[20].Balance != 50000 {
+			t.Errorf("#%d got %v, want %v days ending with %v", i, days, 21, 50000)
+		}
+	}
+}
+
+func TestAccrue(t *testing.T) {
+	var testcases = []struct {
+		terms    ledger.InterestTerms
+		interest int
+	}{
+		// 36.50% a year is 0.1% a day: 10 days of 1.00 and 12 days of 0.50
+		{
+			terms:    ledger.InterestTerms{Rate: 3650, Method: ledger.InterestSimple, DayCount: ledger.DayCountACT365},
+			interest: 1600,
+		},
+		{
+			terms:    ledger.InterestTerms{Rate: 3650, Method: ledger.InterestCompound, DayCount: ledger.DayCountACT365},
+			interest: 1620,
+		},
+		// 36.00% a year is 0.1% a day, January 31st counts for nothing
+		{
+			terms:    ledger.InterestTerms{Rate: 3600, Method: ledger.InterestSimple, DayCount: ledger.DayCountThirty360},
+			interest: 1550,
+		},
+		{
+			terms:    ledger.InterestTerms{Rate: 3600, Method: ledger.InterestCompound, DayCount: ledger.DayCountThirty360},
+			interest: 1568,
+		},
+		{
+			terms:    ledger.InterestTerms{Method: ledger.InterestSimple, DayCount: ledger.DayCountACT365},
+			interest: 0,
+		},
+	}
+
+	for i, tt := range testcases {
+		s := newStore(date(1))
+		id := savingsWallet(t, s, tt.terms)
+
+		poster := New(nil, s.iterate, schema.PolicyFail, s.get, s.ids, s.execute)
+
+		interest, err := poster.Accrued(context.Background(), id, date(1), date(32))
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if interest != tt.interest {
+			t.Errorf("#%d got %v, want %v", i, interest, tt.interest)
+		}
+	}
+}
+
+func TestAccrueThirty360(t *testing.T) {
+	terms := ledger.InterestTerms{Rate: 3600, Method: ledger.InterestSimple, DayCount: ledger.DayCountThirty360}
+	day := func(t time.Time, balance int) Day { return Day{Date: t, Balance: balance, Terms: terms} }
+
+	var testcases = []struct {
+		days     []Day
+		interest int
+	}{
+		// 30th counts for a day, 31st for nothing
+		{days: []Day{day(date(30), 10000), day(date(31), 50000)}, interest: 10},
+		{days: []Day{day(date(29), 10000), day(date(30), 20000)}, interest: 30},
+		// last day of February counts for the rest of the month
+		{days: []Day{day(time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC), 10000)}, interest: 30},
+		{days: []Day{day(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), 10000)}, interest: 20},
+		{days: []Day{day(time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC), 10000)}, interest: 10},
+	}
+
+	for i, tt := range testcases {
+		if interest := Round(Accrue(tt.days)); interest != tt.interest {
+			t.Errorf("#%d got %v, want %v", i, interest, tt.interest)
+		}
+	}
+}
+
+func TestPoster(t *testing.T) {
+	s := newStore(date(1))
+	terms := ledger.InterestTerms{Rate: 3650, Method: ledger.InterestSimple, DayCount: ledger.DayCountACT365}
+	id := savingsWallet(t, s, terms)
+
+	ctx := context.Background()
+	poster := New(nil, s.iterate, schema.PolicyFail, s.get, s.ids, s.execute)
+	poster.now = func() time.Time { return time.Date(2024, 2, 1, 3, 0, 0, 0, time.UTC) }
+
+	// interest posted on February 1st affects February balances only
+	s.at(poster.now())
+	for i := 0; i < 2; i++ {
+		if err := poster.Tick(ctx); err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+	}
+
+	// period is not posted twice even if poster restarts
+	poster = New(nil, s.iterate, schema.PolicyFail, s.get, s.ids, s.execute)
+	wallet, err := poster.Post(ctx, id, date(15))
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if want := 50000 + 1600; wallet.Balance != want {
+		t.Errorf("balance got %v, want %v", wallet.Balance, want)
+	}
+
+	// past period re-run yields the same interest
+	interest, err := poster.Accrued(ctx, id, date(1), date(32))
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if interest != 1600 {
+		t.Errorf("re-run interest got %v, want %v", interest, 1600)
+	}
+
+	// posted interest earns interest in February: 29 days of 0.516
+	s.at(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	wallet, err = poster.Post(ctx, id, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if want := 51600 + 1496; wallet.Balance != want {
+		t.Errorf("balance got %v, want %v", wallet.Balance, want)
+	}
+}
+
+func TestPosterNothing(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(date(1))
+
+	wallet, err := ledger.CreateWallet(ctx, s.save, &ledger.CreateWalletRequest{Name: "savings"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	terms := ledger.InterestTerms{Rate: 3650, Method: ledger.InterestSimple, DayCount: ledger.DayCountACT365}
+	if _, err := s.execute(ctx, wallet.ID, func(w *ledger.WalletAggregate) error { return w.SetInterestTerms(terms) }); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	// month of no interest is recorded as posted, so it's not evaluated again
+	s.at(date(32))
+	poster := New(nil, s.iterate, schema.PolicyFail, s.get, s.ids, s.execute)
+	for i := 0; i < 2; i++ {
+		posted, err := poster.Post(ctx, wallet.ID, date(1))
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if !posted.InterestPostedThrough.Equal(date(32)) {
+			t.Errorf("#%d posted through got %v, want %v", i, posted.InterestPostedThrough, date(32))
+		}
+		if posted.Balance != 0 {
+			t.Errorf("#%d balance got %v, want %v", i, posted.Balance, 0)
+		}
+	}
+
+	var e ledger.InterestPosted
+	records := s.records[wallet.ID]
+	if last := records[len(records)-1]; last.Type != schema.TypeName(&e) {
+		t.Fatalf("got %v, want %v", last.Type, schema.TypeName(&e))
+	} else if err := e.UnmarshalJSON(last.Data); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if want := (ledger.InterestPosted{WalletID: wallet.ID, From: date(1), To: date(32)}); e != want {
+		t.Errorf("got %+v, want %+v", e, want)
+	}
+	if len(records) != 3 {
+		t.Errorf("records got %v, want %v", len(records), 3)
+	}
+}
+
+func TestPosterWallets(t *testing.T) {
+	s := newStore(date(1))
+	terms := ledger.InterestTerms{Rate: 3650, Method: ledger.InterestSimple, DayCount: ledger.DayCountACT365}
+	savingsWallet(t, s, terms)
+
+	ctx := context.Background()
+	poster := New(nil, s.iterate, schema.PolicyFail, s.get, s.ids, s.execute)
+	poster.now = func() time.Time { return time.Date(2024, 2, 1, 3, 0, 0, 0, time.UTC) }
+
+	s.at(poster.now())
+	if err := poster.Tick(ctx); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	s.mu.Lock()
+	reads := s.reads
+	s.mu.Unlock()
+
+	// wallets retrieved by the previous posting are caught up, only interest posted by it is read
+	poster.now = func() time.Time { return time.Date(2024, 3, 1, 3, 0, 0, 0, time.UTC) }
+	s.at(poster.now())
+	if err := poster.Tick(ctx); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if got := s.reads - reads; got != 1 {
+		t.Errorf("records read got %v, want %v", got, 1)
+	}
+}
+
+func TestPosterCatchUp(t *testing.T) {
+	s := newStore(date(1))
+	terms := ledger.InterestTerms{Rate: 3650, Method: ledger.InterestSimple, DayCount: ledger.DayCountACT365}
+	id := savingsWallet(t, s, terms)
+
+	// service was not running in February and March
+	ctx := context.Background()
+	poster := New(nil, s.iterate, schema.PolicyFail, s.get, s.ids, s.execute)
+	poster.now = func() time.Time { return time.Date(2024, 4, 1, 3, 0, 0, 0, time.UTC) }
+
+	s.at(poster.now())
+	for i := 0; i < 2; i++ {
+		if err := poster.Tick(ctx); err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+	}
+
+	// interest posted late earns interest as if it was posted on time:
+	// January 1600, February 29 days of 0.516 and March 31 days of 0.53096
+	var posted []*ledger.InterestPosted
+	for _, v := range s.records[id] {
+		if v.Type != schema.TypeName(&ledger.InterestPosted{}) {
+			continue
+		}
+		var e ledger.InterestPosted
+		if err := e.UnmarshalJSON(v.Data); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+		posted = append(posted, &e)
+	}
+	want := []*ledger.InterestPosted{
+		{WalletID: id, Amount: 1600, From: date(1), To: date(32)},
+		{WalletID: id, Amount: 1496, From: date(32), To: date(61)},
+		{WalletID: id, Amount: 1646, From: date(61), To: date(92)},
+	}
+	if len(posted) != len(want) {
+		t.Fatalf("posted got %v, want %v", len(posted), len(want))
+	}
+	for i := range want {
+		if *posted[i] != *want[i] {
+			t.Errorf("#%d got %+v, want %+v", i, posted[i], want[i])
+		}
+	}
+
+	wallet, err := s.get(ctx, &ledger.WalletAggregate{}, id)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if want := 50000 + 1600 + 1496 + 1646; wallet.Balance != want {
+		t.Errorf("balance got %v, want %v", wallet.Balance, want)
+	}
+}
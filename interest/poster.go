@@ -0,0 +1,251 @@
+package interest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/database"
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+	"github.com/deividaspetraitis/go/log"
+	"github.com/deividaspetraitis/go/validator"
+)
+
+// Default configuration values.
+const defaultInterval = time.Hour
+
+// Config represents interest posting configuration.
+type Config struct {
+	Interval time.Duration `mapstructure:"interval"` // How often posting of closed months is attempted
+}
+
+// Validate implements validator.Validator.
+func (c *Config) Validate() error {
+	if c.Interval < 0 {
+		return errors.New("interval can not be negative")
+	}
+	return nil
+}
+
+// ExecuteFunc applies operation on the wallet and persists it, see ledger.Processor.Execute.
+type ExecuteFunc func(ctx context.Context, id string, op func(*ledger.WalletAggregate) error) (*ledger.Wallet, error)
+
+// IDsFunc returns IDs of all persisted aggregates of the aggregate type.
+type IDsFunc func(ctx context.Context, aggregate es.Aggregate) ([]string, error)
+
+// Poster posts interest accrued by savings wallets monthly.
+//
+// Once a month has passed, interest accrued over the month by every wallet with interest terms set is posted.
+// Wallet records the last posted period, thus period is never posted twice, and months missed meanwhile are caught up.
+// Wallets which have no interest terms at the time of posting, i.e. terms were closed during the month, forfeit interest of the month.
+// Wallets retrieved by a posting are kept and caught up by the following ones, reading only events persisted meanwhile.
+type Poster struct {
+	interval time.Duration
+	records  RecordsFunc
+	unknown  schema.Policy
+	get      database.GetAggregateFunc[*ledger.WalletAggregate]
+	ids      IDsFunc
+	execute  ExecuteFunc
+	now      func() time.Time
+
+	mu       sync.Mutex
+	posted   time.Time                          // Beginning of the last period posted for all wallets
+	restored map[string]*ledger.WalletAggregate // Wallets retrieved by previous postings by their IDs
+}
+
+// New constructs a new Poster reconstructing wallets balances from records handling unknown events according to the policy,
+// discovering wallets using ids and get and posting interest using execute.
+func New(cfg *Config, records RecordsFunc, unknown schema.Policy, get database.GetAggregateFunc[*ledger.WalletAggregate], ids IDsFunc, execute ExecuteFunc) *Poster {
+	p := Poster{
+		interval: defaultInterval,
+		records:  records,
+		unknown:  unknown,
+		get:      get,
+		ids:      ids,
+		execute:  execute,
+		now:      time.Now,
+		restored: make(map[string]*ledger.WalletAggregate),
+	}
+	if cfg != nil && cfg.Interval > 0 {
+		p.interval = cfg.Interval
+	}
+	return &p
+}
+
+// SetTerms sets interest terms of the wallet, terms are in effect since the day they are set.
+func (p *Poster) SetTerms(ctx context.Context, req *ledger.SetInterestTermsRequest) (*ledger.Wallet, error) {
+	if err := validator.Validate(req); err != nil {
+		return nil, err
+	}
+
+	return p.execute(ctx, req.WalletID, func(w *ledger.WalletAggregate) error {
+		return w.SetInterestTerms(req.InterestTerms)
+	})
+}
+
+// Run posts interest of closed months every configured interval until ctx is done.
+func (p *Poster) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Tick(ctx); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"component": "interest",
+			}).Println("unable to post interest")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick posts interest of every closed month not posted yet for all wallets with interest terms.
+func (p *Poster) Tick(ctx context.Context) error {
+	from, _ := Period(p.now())
+	from, _ = Period(from.AddDate(0, 0, -1))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.posted.Equal(from) {
+		return nil
+	}
+
+	ids, err := p.ids(ctx, &ledger.WalletAggregate{})
+	if err != nil {
+		return errors.Wrap(err, "unable to discover wallets")
+	}
+
+	var failed int
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		wallet, err := p.load(ctx, id)
+		if err == nil {
+			_, err = p.post(ctx, wallet, from)
+		}
+		if err != nil {
+			failed++
+			log.WithError(err).WithFields(log.Fields{
+				"component": "interest",
+				"wallet":    id,
+			}).Println("unable to post interest")
+		}
+	}
+	if failed > 0 {
+		return errors.Newf("interest of %d wallets is not posted", failed)
+	}
+
+	p.posted = from
+	return nil
+}
+
+// posting represents interest of the period to be posted.
+type posting struct {
+	from, to time.Time
+	amount   int
+}
+
+// Post posts interest accrued by the wallet over every month not posted yet through the month containing t,
+// starting at the month following the last posted period or the month wallet was created in.
+// Wallets without interest terms and periods posted already are ignored, unchanged wallet is returned.
+func (p *Poster) Post(ctx context.Context, id string, t time.Time) (*ledger.Wallet, error) {
+	wallet, err := p.get(ctx, &ledger.WalletAggregate{}, id)
+	if err != nil {
+		return nil, err
+	}
+	return p.post(ctx, wallet, t)
+}
+
+// post posts interest accrued by the wallet as Post does.
+func (p *Poster) post(ctx context.Context, wallet *ledger.WalletAggregate, t time.Time) (*ledger.Wallet, error) {
+	_, to := Period(t)
+	id := wallet.ID
+	if wallet.Interest.Rate == 0 || !wallet.InterestPostedThrough.Before(to) {
+		return &wallet.Wallet, nil
+	}
+
+	it, err := p.records(ctx, &ledger.WalletAggregate{}, id)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	days, err := EndOfDay(it, id, wallet.InterestPostedThrough, to, p.unknown)
+	if err != nil {
+		return nil, err
+	}
+
+	// interest of a month is posted on the next one, so it's part of end-of-day balances of the following months,
+	// interest of missed months is posted only now, thus it's added to their balances instead.
+	var (
+		postings []posting
+		posted   int
+	)
+	for len(days) > 0 {
+		from, to := Period(days[0].Date)
+
+		var n int
+		for n < len(days) && days[n].Date.Before(to) {
+			days[n].Balance += posted
+			n++
+		}
+
+		amount := Round(Accrue(days[:n]))
+		postings = append(postings, posting{from: from, to: to, amount: amount})
+		posted += amount
+		days = days[n:]
+	}
+
+	return p.execute(ctx, id, func(w *ledger.WalletAggregate) error {
+		for _, v := range postings {
+			if err := w.PostInterest(v.from, v.to, v.amount); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// load retrieves wallet catching up the one retrieved by previous postings, if any.
+// Caller must hold p.mu.
+func (p *Poster) load(ctx context.Context, id string) (*ledger.WalletAggregate, error) {
+	wallet, ok := p.restored[id]
+	if !ok {
+		wallet = &ledger.WalletAggregate{}
+	}
+
+	wallet, err := p.get(ctx, wallet, id)
+	if err != nil {
+		// wallet might be restored partially
+		delete(p.restored, id)
+		return nil, err
+	}
+	p.restored[id] = wallet
+	return wallet, nil
+}
+
+// Accrued returns interest accrued by the wallet over days from and until to, rounded half up to cents.
+func (p *Poster) Accrued(ctx context.Context, id string, from, to time.Time) (int, error) {
+	it, err := p.records(ctx, &ledger.WalletAggregate{}, id)
+	if err != nil {
+		return 0, err
+	}
+	defer it.Close()
+
+	days, err := EndOfDay(it, id, from, to, p.unknown)
+	if err != nil {
+		return 0, err
+	}
+
+	return Round(Accrue(days)), nil
+}
@@ -0,0 +1,416 @@
+package ledger
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/database"
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+	"github.com/deividaspetraitis/go/log"
+	"github.com/deividaspetraitis/go/validator"
+)
+
+// ErrNotValidOverdraft is returned when overdraft limit or interest rate is not valid.
+var ErrNotValidOverdraft = errors.New("given overdraft is not valid")
+
+// OverdraftLimitSet represents an event emitted when wallet credit line is set, zero limit closes it.
+type OverdraftLimitSet struct {
+	WalletID string `json:"wallet_id"`
+	Limit    int    `json:"limit"` // Maximum overdrawn balance in cents
+	Rate     int    `json:"rate"`  // Annual interest rate of overdrawn balance in basis points
+}
+
+// Implements es.MarshalUnmarshaler
+func (o *OverdraftLimitSet) UnmarshalJSON(b []byte) error {
+	type overdraftLimitSet OverdraftLimitSet
+	temp := overdraftLimitSet(*o)
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+	*o = OverdraftLimitSet(temp)
+	return nil
+}
+
+// Implements es.MarshalUnmarshaler
+func (o *OverdraftLimitSet) MarshalJSON() ([]byte, error) {
+	type overdraftLimitSet OverdraftLimitSet
+	temp := overdraftLimitSet(*o)
+	return json.Marshal(temp)
+}
+
+// OverdraftInterestAccrued represents an event emitted when interest of overdrawn balance is charged for a day.
+type OverdraftInterestAccrued struct {
+	WalletID string    `json:"wallet_id"`
+	Amount   int       `json:"amount"`  // Interest in cents
+	Day      time.Time `json:"day"`     // Day interest was accrued for
+	Balance  int       `json:"balance"` // Overdrawn balance interest was calculated of
+	Rate     int       `json:"rate"`    // Annual interest rate in basis points
+}
+
+// Implements es.MarshalUnmarshaler
+func (o *OverdraftInterestAccrued) UnmarshalJSON(b []byte) error {
+	type overdraftInterestAccrued OverdraftInterestAccrued
+	temp := overdraftInterestAccrued(*o)
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+	*o = OverdraftInterestAccrued(temp)
+	return nil
+}
+
+// Implements es.MarshalUnmarshaler
+func (o *OverdraftInterestAccrued) MarshalJSON() ([]byte, error) {
+	type overdraftInterestAccrued OverdraftInterestAccrued
+	temp := overdraftInterestAccrued(*o)
+	return json.Marshal(temp)
+}
+
+// SetOverdraftRequest represents a request for setting wallet credit line.
+type SetOverdraftRequest struct {
+	WalletID string // Wallet identifier
+	Limit    int    // Maximum overdrawn balance in cents, zero closes credit line
+	Rate     int    // Annual interest rate of overdrawn balance in basis points
+}
+
+// Validate implements validator.Validator.
+func (r *SetOverdraftRequest) Validate() error {
+	if !isValidID(r.WalletID) {
+		return ErrNotValidWalletID
+	}
+	if r.Limit < 0 || r.Rate < 0 {
+		return ErrNotValidOverdraft
+	}
+	return nil
+}
+
+// AvailableCredit returns part of the credit line not used yet.
+func (w *Wallet) AvailableCredit() int {
+	if w.Balance >= 0 {
+		return w.OverdraftLimit
+	}
+	if credit := w.OverdraftLimit + w.Balance; credit > 0 {
+		return credit
+	}
+	return 0
+}
+
+// covers reports whether wallet funds along the credit line cover debiting given amount.
+func (w *Wallet) covers(amount int) bool {
+	return w.Balance-amount >= -w.OverdraftLimit
+}
+
+// SetOverdraft sets wallet credit line. Limit can not be lowered below currently overdrawn balance.
+func (w *WalletAggregate) SetOverdraft(limit, rate int) error {
+	if limit < 0 || rate < 0 {
+		return ErrNotValidOverdraft
+	}
+	if w.Balance < -limit {
+		return errors.Wrap(ErrNotValidOverdraft, "limit is below overdrawn balance")
+	}
+
+	return w.Apply(es.NewEvent(w.ID, w, &OverdraftLimitSet{
+		WalletID: w.ID,
+		Limit:    limit,
+		Rate:     rate,
+	}))
+}
+
+// AccrueInterest charges interest of the overdrawn end-of-day balance at the annual rate for the given day using ACT/365 day count,
+// daily interest is rounded half up to cents. Days accrued already and days without interest are ignored.
+// Interest is charged even if it exceeds the credit line.
+func (w *WalletAggregate) AccrueInterest(day time.Time, balance, rate int) error {
+	day = midnight(day)
+	if !day.After(w.AccruedThrough) || balance >= 0 || rate == 0 {
+		return nil
+	}
+
+	amount := (-balance*rate + 10000*365/2) / (10000 * 365)
+	if amount == 0 {
+		return nil
+	}
+
+	return w.Apply(es.NewEvent(w.ID, w, &OverdraftInterestAccrued{
+		WalletID: w.ID,
+		Amount:   amount,
+		Day:      day,
+		Balance:  balance,
+		Rate:     rate,
+	}))
+}
+
+// SetOverdraft sets credit line of the given wallet.
+func SetOverdraft(ctx context.Context, saveAggregate database.SaveAggregateFunc, getWallet database.GetAggregateFunc[*WalletAggregate], req *SetOverdraftRequest) (*Wallet, error) {
+	if err := validator.Validate(req); err != nil {
+		return nil, err
+	}
+
+	wallet, err := getWallet(ctx, &WalletAggregate{}, req.WalletID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := wallet.SetOverdraft(req.Limit, req.Rate); err != nil {
+		return nil, err
+	}
+
+	if err := saveAggregate(ctx, wallet); err != nil {
+		return nil, errors.Wrap(err, "unable to persist overdraft")
+	}
+
+	return &wallet.Wallet, nil
+}
+
+// Default overdraft configuration values.
+const defaultAccrualInterval = time.Hour
+
+// OverdraftConfig represents overdraft interest accrual configuration.
+type OverdraftConfig struct {
+	Interval time.Duration `mapstructure:"interval"` // How often accrual of the previous day is attempted
+}
+
+// Validate implements validator.Validator.
+func (c *OverdraftConfig) Validate() error {
+	if c.Interval < 0 {
+		return errors.New("interval can not be negative")
+	}
+	return nil
+}
+
+// ExecuteFunc applies operation on the wallet and persists it, see Processor.Execute.
+type ExecuteFunc func(ctx context.Context, id string, op func(*WalletAggregate) error) (*Wallet, error)
+
+// IDsFunc returns IDs of all persisted aggregates of the aggregate type.
+type IDsFunc func(ctx context.Context, aggregate es.Aggregate) ([]string, error)
+
+// Accrual charges daily interest of overdrawn wallets.
+//
+// Wallets with credit lines are discovered once a day and tracked as credit lines are set through SetOverdraft.
+// Retrieved wallets are kept and caught up by the following discoveries, reading only events persisted meanwhile.
+// Every interval interest of every day since the last accrued one through the previous day is accrued, so days missed
+// while the service was not running are caught up. Interest of a day is calculated of the end-of-day balance and rate
+// reconstructed from persisted events, accrual of a day is recorded on the wallet, thus it's never charged twice.
+type Accrual struct {
+	interval time.Duration
+	records  RecordsFunc
+	unknown  schema.Policy
+	get      database.GetAggregateFunc[*WalletAggregate]
+	ids      IDsFunc
+	execute  ExecuteFunc
+	now      func() time.Time
+
+	mu         sync.Mutex
+	wallets    map[string]bool             // IDs of wallets with credit lines, nil until discovered
+	discovered time.Time                   // Day wallets were discovered
+	accrued    map[string]time.Time        // Last day accrual was completed for by wallet IDs
+	restored   map[string]*WalletAggregate // Wallets retrieved by previous discoveries and accruals by their IDs
+}
+
+// NewAccrual constructs a new Accrual discovering wallets using ids and get, reconstructing their end-of-day balances
+// from records handling unknown events according to the policy and accruing interest using execute.
+func NewAccrual(cfg *OverdraftConfig, records RecordsFunc, unknown schema.Policy, get database.GetAggregateFunc[*WalletAggregate], ids IDsFunc, execute ExecuteFunc) *Accrual {
+	a := Accrual{
+		interval: defaultAccrualInterval,
+		records:  records,
+		unknown:  unknown,
+		get:      get,
+		ids:      ids,
+		execute:  execute,
+		now:      time.Now,
+		accrued:  make(map[string]time.Time),
+		restored: make(map[string]*WalletAggregate),
+	}
+	if cfg != nil && cfg.Interval > 0 {
+		a.interval = cfg.Interval
+	}
+	return &a
+}
+
+// SetOverdraft sets credit line of the wallet and tracks it for interest accrual.
+func (a *Accrual) SetOverdraft(ctx context.Context, req *SetOverdraftRequest) (*Wallet, error) {
+	if err := validator.Validate(req); err != nil {
+		return nil, err
+	}
+
+	wallet, err := a.execute(ctx, req.WalletID, func(w *WalletAggregate) error {
+		return w.SetOverdraft(req.Limit, req.Rate)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	if a.wallets != nil {
+		a.wallets[wallet.ID] = true
+	}
+	a.mu.Unlock()
+
+	return wallet, nil
+}
+
+// Run accrues interest every configured interval until ctx is done.
+func (a *Accrual) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		a.Accrue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Accrue accrues interest of all tracked wallets for every day not accrued yet through the previous day.
+func (a *Accrual) Accrue(ctx context.Context) {
+	day := midnight(a.now()).AddDate(0, 0, -1)
+
+	ids, err := a.known(ctx, day)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"component": "accrual",
+		}).Println("unable to discover wallets with credit lines")
+		return
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+
+		a.mu.Lock()
+		done := !a.accrued[id].Before(day)
+		a.mu.Unlock()
+		if done {
+			continue
+		}
+
+		if err := a.accrue(ctx, id, day); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"component": "accrual",
+				"wallet":    id,
+			}).Println("unable to accrue overdraft interest")
+			continue
+		}
+
+		a.mu.Lock()
+		a.accrued[id] = day
+		a.mu.Unlock()
+	}
+}
+
+// accrue accrues interest of the wallet for every day since the last accrued one through the given day.
+func (a *Accrual) accrue(ctx context.Context, id string, through time.Time) error {
+	a.mu.Lock()
+	wallet, err := a.load(ctx, id)
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// wallet never accrued interest is reconstructed since its first event
+	var from time.Time
+	if !wallet.AccruedThrough.IsZero() {
+		from = wallet.AccruedThrough.AddDate(0, 0, 1)
+	}
+	if from.After(through) {
+		return nil
+	}
+
+	it, err := a.records(ctx, &WalletAggregate{}, id)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	days, err := EndOfDay(it, id, from, through.AddDate(0, 0, 1), a.unknown)
+	if err != nil {
+		return err
+	}
+
+	var overdrawn bool
+	for _, d := range days {
+		overdrawn = overdrawn || (d.Wallet.Balance < 0 && d.Wallet.OverdraftRate > 0)
+	}
+	if !overdrawn {
+		return nil
+	}
+
+	_, err = a.execute(ctx, id, func(w *WalletAggregate) error {
+		// interest of a day is persisted on the next one, so it's part of end-of-day balances of the following days,
+		// interest of missed days is persisted only now, thus it's added to their balances instead.
+		var accrued int
+		for _, d := range days {
+			balance := w.Balance
+			if err := w.AccrueInterest(d.Date, d.Wallet.Balance-accrued, d.Wallet.OverdraftRate); err != nil {
+				return err
+			}
+			accrued += balance - w.Balance
+		}
+		return nil
+	})
+	return err
+}
+
+// known returns IDs of wallets with credit lines, discovering them once for the given day.
+// Wallets which fail to be retrieved are logged and skipped until the next discovery.
+func (a *Accrual) known(ctx context.Context, day time.Time) ([]string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	day = midnight(day)
+	if a.wallets == nil || !a.discovered.Equal(day) {
+		ids, err := a.ids(ctx, &WalletAggregate{})
+		if err != nil {
+			return nil, err
+		}
+
+		wallets := make(map[string]bool)
+		for _, id := range ids {
+			wallet, err := a.load(ctx, id)
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{
+					"component": "accrual",
+					"wallet":    id,
+				}).Println("unable to retrieve wallet")
+				continue
+			}
+			if wallet.OverdraftLimit > 0 || wallet.Balance < 0 {
+				wallets[id] = true
+			}
+		}
+		a.wallets = wallets
+		a.discovered = day
+	}
+
+	var ids []string
+	for id := range a.wallets {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// load retrieves wallet catching up the one retrieved previously, if any.
+// Caller must hold a.mu.
+func (a *Accrual) load(ctx context.Context, id string) (*WalletAggregate, error) {
+	wallet, ok := a.restored[id]
+	if !ok {
+		wallet = &WalletAggregate{}
+	}
+
+	wallet, err := a.get(ctx, wallet, id)
+	if err != nil {
+		// wallet might be restored partially
+		delete(a.restored, id)
+		return nil, err
+	}
+	a.restored[id] = wallet
+	return wallet, nil
+}
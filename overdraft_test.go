@@ -0,0 +1,334 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// overdrawnWallet returns a wallet aggregate with the given credit line and balance.
+func overdrawnWallet(t testing.TB, limit, rate, balance int) *WalletAggregate {
+	id := newID()
+
+	var wallet WalletAggregate
+	events := []*es.Event{
+		es.NewEvent(id, &wallet, &WalletInitialized{ID: id, Name: "test wallet"}),
+		es.NewEvent(id, &wallet, &OverdraftLimitSet{WalletID: id, Limit: limit, Rate: rate}),
+		es.NewEvent(id, &wallet, &Withdraw{WalletID: id, Amount: -balance}),
+	}
+	if err := wallet.Reply(events); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return &wallet
+}
+
+func TestWalletOverdraft(t *testing.T) {
+	var testcases = []struct {
+		limit   int
+		balance int
+		tx      *Transaction
+
+		available int
+		err       error
+	}{
+		// no credit line
+		{
+			tx:  &Transaction{Type: TransactionWithdraw, Amount: 1},
+			err: ErrInsufficientBalance,
+		},
+		// withdrawal within credit line
+		{
+			limit:     100,
+			tx:        &Transaction{Type: TransactionWithdraw, Amount: 100},
+			available: 0,
+		},
+		// withdrawal along with fee exceeds credit line
+		{
+			limit:     100,
+			tx:        &Transaction{Type: TransactionWithdraw, Amount: 95, Fee: 10},
+			available: 100,
+			err:       ErrInsufficientBalance,
+		},
+		// deposit repays used credit
+		{
+			limit:     100,
+			balance:   -80,
+			tx:        &Transaction{Type: TransactionDeposit, Amount: 50},
+			available: 70,
+		},
+		// deposit improving balance beyond credit line is accepted
+		{
+			limit:     100,
+			balance:   -120,
+			tx:        &Transaction{Type: TransactionDeposit, Amount: 10},
+			available: 0,
+		},
+	}
+
+	for i, tt := range testcases {
+		wallet := overdrawnWallet(t, tt.limit, 0, tt.balance)
+		tt.tx.WalletID = wallet.ID
+
+		if err := wallet.ProcessTransaction(tt.tx); err != tt.err {
+			t.Errorf("#%d got %v, want %v", i, err, tt.err)
+		}
+		if available := wallet.AvailableCredit(); available != tt.available {
+			t.Errorf("#%d available credit got %v, want %v", i, available, tt.available)
+		}
+	}
+}
+
+func TestWalletSetOverdraft(t *testing.T) {
+	var testcases = []struct {
+		limit   int
+		balance int
+
+		err error
+	}{
+		{limit: 100, balance: -100},
+		{limit: 0, balance: 0},
+		{limit: 50, balance: -100, err: ErrNotValidOverdraft},
+		{limit: -1, err: ErrNotValidOverdraft},
+	}
+
+	for i, tt := range testcases {
+		wallet := overdrawnWallet(t, 100, 0, tt.balance)
+
+		if err := wallet.SetOverdraft(tt.limit, 0); !errors.Is(err, tt.err) {
+			t.Errorf("#%d got %v, want %v", i, err, tt.err)
+		}
+		if tt.err == nil && wallet.OverdraftLimit != tt.limit {
+			t.Errorf("#%d limit got %v, want %v", i, wallet.OverdraftLimit, tt.limit)
+		}
+	}
+}
+
+func TestWalletAccrueInterest(t *testing.T) {
+	day := time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC)
+
+	var testcases = []struct {
+		rate    int
+		balance int
+
+		interest int
+	}{
+		// 100.00 at 18.25% a year is exactly 0.05 a day
+		{rate: 1825, balance: -10000, interest: 5},
+		// 1000.00 at 10% a year is 0.27397 a day
+		{rate: 1000, balance: -100000, interest: 27},
+		// 10.00 at 10% a year rounds down to nothing
+		{rate: 1000, balance: -1000, interest: 0},
+		// not overdrawn
+		{rate: 1000, balance: 100, interest: 0},
+		// no interest rate
+		{balance: -10000, interest: 0},
+	}
+
+	for i, tt := range testcases {
+		wallet := overdrawnWallet(t, 200000, tt.rate, tt.balance)
+
+		if err := wallet.AccrueInterest(day, tt.balance, tt.rate); err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if balance := wallet.Balance; balance != tt.balance-tt.interest {
+			t.Errorf("#%d balance got %v, want %v", i, balance, tt.balance-tt.interest)
+		}
+
+		// the same day is never accrued twice
+		if err := wallet.AccrueInterest(day.Add(time.Hour), tt.balance, tt.rate); err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if balance := wallet.Balance; balance != tt.balance-tt.interest {
+			t.Errorf("#%d balance after repeated accrual got %v, want %v", i, balance, tt.balance-tt.interest)
+		}
+	}
+}
+
+// records returns iterator over persisted records of the wallet.
+func (s *memStore) records(ctx context.Context, aggregate es.Aggregate, id string) (schema.Iterator, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it := &recordsIterator{}
+	for i, v := range s.streams[id] {
+		data, err := v.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		it.records = append(it.records, &schema.Record{
+			AggregateID: id,
+			Version:     uint64(i + 1),
+			Type:        schema.TypeName(v),
+			Timestamp:   s.stamps[id][i],
+			Data:        data,
+		})
+	}
+	return it, nil
+}
+
+// recordsIterator implements schema.Iterator over slice of records.
+type recordsIterator struct {
+	records []*schema.Record
+	i       int
+}
+
+func (it *recordsIterator) Next() bool                     { it.i++; return it.i <= len(it.records) }
+func (it *recordsIterator) Value() (*schema.Record, error) { return it.records[it.i-1], nil }
+func (it *recordsIterator) Error() error                   { return nil }
+func (it *recordsIterator) Close()                         {}
+
+func TestAccrual(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	store := newMemStore(0)
+	store.now = clock
+	overdrawn := store.createWallet(t)
+	plain := store.createWallet(t)
+
+	ids := func(ctx context.Context, aggregate es.Aggregate) ([]string, error) {
+		return []string{overdrawn, plain}, nil
+	}
+
+	processor := NewProcessor(nil, store.save, store.get, nil)
+	accrual := NewAccrual(nil, store.records, schema.PolicyFail, store.get, ids, processor.Execute)
+	accrual.now = clock
+
+	ctx := context.Background()
+	transaction := func(typ string, amount int) {
+		if _, err := processor.CreateTransaction(ctx, &TransactionRequest{Type: typ, WalletID: overdrawn, Amount: amount}); err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+	}
+
+	if _, err := accrual.SetOverdraft(ctx, &SetOverdraftRequest{WalletID: overdrawn, Limit: 20000, Rate: 1825}); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	transaction(TransactionWithdraw, 10000)
+
+	// wallet is paid back before accrual runs, interest is charged of the end-of-day balance
+	now = time.Date(2024, 3, 2, 0, 30, 0, 0, time.UTC)
+	transaction(TransactionDeposit, 10000)
+
+	now = time.Date(2024, 3, 2, 1, 0, 0, 0, time.UTC)
+	accrual.Accrue(ctx)
+	accrual.Accrue(ctx)
+
+	// days missed meanwhile are caught up, interest of each day is part of the balance of following days
+	now = time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)
+	transaction(TransactionWithdraw, 10000)
+
+	now = time.Date(2024, 3, 5, 1, 0, 0, 0, time.UTC)
+	accrual.Accrue(ctx)
+	accrual.Accrue(ctx)
+
+	for _, tt := range []struct {
+		id      string
+		balance int
+	}{
+		{id: overdrawn, balance: -10020},
+		{id: plain, balance: 0},
+	} {
+		wallet, err := GetWallet(ctx, store.get, tt.id)
+		if err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+		if wallet.Balance != tt.balance {
+			t.Errorf("balance got %v, want %v", wallet.Balance, tt.balance)
+		}
+	}
+
+	var accrued []*OverdraftInterestAccrued
+	for _, v := range store.streams[overdrawn] {
+		if e, ok := v.(*OverdraftInterestAccrued); ok {
+			accrued = append(accrued, e)
+		}
+	}
+	want := []*OverdraftInterestAccrued{
+		{WalletID: overdrawn, Amount: 5, Day: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Balance: -10000, Rate: 1825},
+		{WalletID: overdrawn, Amount: 5, Day: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), Balance: -10005, Rate: 1825},
+		{WalletID: overdrawn, Amount: 5, Day: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC), Balance: -10010, Rate: 1825},
+		{WalletID: overdrawn, Amount: 5, Day: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), Balance: -10015, Rate: 1825},
+	}
+	if len(accrued) != len(want) {
+		t.Fatalf("accrued got %v, want %v", len(accrued), len(want))
+	}
+	for i := range want {
+		if *accrued[i] != *want[i] {
+			t.Errorf("#%d got %+v, want %+v", i, accrued[i], want[i])
+		}
+	}
+}
+
+func TestAccrualDiscovery(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	store := newMemStore(0)
+	store.now = clock
+	id := store.createWallet(t)
+
+	ids := func(ctx context.Context, aggregate es.Aggregate) ([]string, error) {
+		return []string{id}, nil
+	}
+
+	processor := NewProcessor(nil, store.save, store.get, nil)
+	if _, err := processor.CreateTransaction(context.Background(), &TransactionRequest{Type: TransactionDeposit, WalletID: id, Amount: 100}); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	accrual := NewAccrual(nil, store.records, schema.PolicyFail, store.get, ids, processor.Execute)
+	accrual.now = clock
+	accrual.Accrue(context.Background())
+
+	// wallets discovered the previous day are caught up, unchanged ones are not read again
+	store.mu.Lock()
+	reads := store.reads
+	store.mu.Unlock()
+
+	now = now.AddDate(0, 0, 1)
+	accrual.Accrue(context.Background())
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.reads != reads {
+		t.Errorf("events read got %v, want %v", store.reads-reads, 0)
+	}
+}
+
+func TestAccrualWalletNotFound(t *testing.T) {
+	store := newMemStore(0)
+	overdrawn := store.createWallet(t)
+
+	// wallet failing to be retrieved does not prevent accrual of others
+	ids := func(ctx context.Context, aggregate es.Aggregate) ([]string, error) {
+		return []string{newID(), overdrawn}, nil
+	}
+
+	processor := NewProcessor(nil, store.save, store.get, nil)
+	accrual := NewAccrual(nil, store.records, schema.PolicyFail, store.get, ids, processor.Execute)
+	accrual.now = func() time.Time { return time.Now().AddDate(0, 0, 1) }
+
+	ctx := context.Background()
+	if _, err := accrual.SetOverdraft(ctx, &SetOverdraftRequest{WalletID: overdrawn, Limit: 10000, Rate: 1825}); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if _, err := processor.CreateTransaction(ctx, &TransactionRequest{Type: TransactionWithdraw, WalletID: overdrawn, Amount: 10000}); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	accrual.Accrue(ctx)
+
+	wallet, err := GetWallet(ctx, store.get, overdrawn)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if wallet.Balance != -10005 {
+		t.Errorf("balance got %v, want %v", wallet.Balance, -10005)
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/database/schema"
+)
+
+// ChainHead represents API response of signed wallet events hash chain head.
+type ChainHead struct {
+	WalletID  string    `json:"wallet_id"`
+	Version   uint64    `json:"version"`    // Version of the last wallet event
+	Hash      string    `json:"hash"`       // Hash of the last wallet event
+	SignedAt  time.Time `json:"signed_at"`  // Signature time
+	Signature string    `json:"signature"`  // Base64 encoded ed25519 signature, empty if signing is not configured
+	PublicKey string    `json:"public_key"` // Base64 encoded ed25519 public key verifying the signature
+}
+
+// NewChainHeadResponse constructs and returns response ChainHead entity.
+func NewChainHeadResponse(h *schema.SignedHead) *ChainHead {
+	return &ChainHead{
+		WalletID:  h.AggregateID,
+		Version:   h.Version,
+		Hash:      h.Hash,
+		SignedAt:  h.SignedAt,
+		Signature: h.Signature,
+		PublicKey: h.PublicKey,
+	}
+}
+
+// MarshalHTTP implements http.Marshaler.
+func (r *ChainHead) MarshalHTTP(w http.ResponseWriter) error {
+	return json.NewEncoder(w).Encode(r)
+}
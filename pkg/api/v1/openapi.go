@@ -0,0 +1,15 @@
+package api
+
+import (
+	_ "embed"
+)
+
+// OpenAPI is OpenAPI 3 document describing the API, it's verified against request and response types of the package by tests.
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+// Docs is a self-contained HTML page rendering OpenAPI document served at /openapi.json and sending requests to the API.
+//
+//go:embed docs.html
+var Docs []byte
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// definition represents subset of OpenAPI schema object verified against API entities.
+type definition struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Format               string                 `json:"format"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*definition `json:"properties"`
+	Items                *definition            `json:"items"`
+	AdditionalProperties *definition            `json:"additionalProperties"`
+}
+
+// document represents subset of OpenAPI document verified against API entities.
+type document struct {
+	Components struct {
+		Schemas map[string]*definition `json:"schemas"`
+	} `json:"components"`
+}
+
+// entities maps OpenAPI document schemas to API entities they describe.
+// Responses are always encoded by the service, thus their required properties are verified as well.
+var entities = map[string]struct {
+	entity   any
+	response bool
+}{
+	"Wallet":                    {Wallet{}, true},
+	"InterestTerms":             {InterestTerms{}, true},
+	"CreateWalletRequest":       {CreateWalletRequest{}, false},
+	"SetOverdraftRequest":       {SetOverdraftRequest{}, false},
+	"SetInterestTermsRequest":   {SetInterestTermsRequest{}, false},
+	"CreateTransactionRequest":  {CreateTransactionRequest{}, false},
+	"CreateTransactionResponse": {CreateTransactionResponse{}, true},
+	"TransactionQuote":          {TransactionQuote{}, true},
+	"Transaction":               {Transaction{}, true},
+	"Transactions":              {Transactions{}, true},
+	"ChainHead":                 {ChainHead{}, true},
+	"Reconciliation":            {Reconciliation{}, true},
+	"ReconciliationResult":      {ReconciliationResult{}, true},
+	"StatementEntry":            {StatementEntry{}, true},
+	"ReconciledTransaction":     {ReconciledTransaction{}, true},
+	"CreateScheduleRequest":     {CreateScheduleRequest{}, false},
+	"Schedule":                  {Schedule{}, true},
+}
+
+// field represents JSON encoded struct field.
+type field struct {
+	typ       reflect.Type
+	omitempty bool
+}
+
+// fields returns JSON encoded fields of the struct type, embedded structs are flattened.
+func fields(typ reflect.Type) map[string]field {
+	v := make(map[string]field)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag, ok := f.Tag.Lookup("json")
+		if f.Anonymous && !ok {
+			for name, ef := range fields(f.Type) {
+				v[name] = ef
+			}
+			continue
+		}
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if len(name) == 0 {
+			name = f.Name
+		}
+		v[name] = field{typ: f.Type, omitempty: strings.Contains(opts, "omitempty")}
+	}
+	return v
+}
+
+// parseDocument parses embedded OpenAPI document.
+func parseDocument(t *testing.T) *document {
+	var doc document
+	if err := json.Unmarshal(OpenAPI, &doc); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return &doc
+}
+
+// TestOpenAPISchemas verifies that OpenAPI document schemas do not drift from API entities.
+func TestOpenAPISchemas(t *testing.T) {
+	doc := parseDocument(t)
+
+	for name := range doc.Components.Schemas {
+		if _, ok := entities[name]; !ok {
+			t.Errorf("%s got no entity, want entity", name)
+		}
+	}
+
+	for name, v := range entities {
+		t.Run(name, func(t *testing.T) {
+			s, ok := doc.Components.Schemas[name]
+			if !ok {
+				t.Fatalf("got no schema, want schema")
+			}
+			if s.Type != "object" {
+				t.Errorf("got %v, want %v", s.Type, "object")
+			}
+
+			var (
+				properties []string
+				required   []string
+			)
+			for k, f := range fields(reflect.TypeOf(v.entity)) {
+				properties = append(properties, k)
+				if !f.omitempty {
+					required = append(required, k)
+				}
+
+				p, ok := s.Properties[k]
+				if !ok {
+					continue
+				}
+				if err := compare(doc, p, f.typ); len(err) > 0 {
+					t.Errorf("%s got %s, want %v", k, err, f.typ)
+				}
+			}
+
+			var got []string
+			for k := range s.Properties {
+				got = append(got, k)
+			}
+			sort.Strings(got)
+			sort.Strings(properties)
+			if !cmp.Equal(got, properties) {
+				t.Errorf("got properties %v, want %v", got, properties)
+			}
+
+			if v.response {
+				got = append([]string{}, s.Required...)
+				sort.Strings(got)
+				sort.Strings(required)
+				if !cmp.Equal(got, required) {
+					t.Errorf("got required %v, want %v", got, required)
+				}
+			}
+		})
+	}
+}
+
+// compare compares schema against Go type and returns description of the mismatch, empty string if they match.
+func compare(doc *document, s *definition, typ reflect.Type) string {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	if len(s.Ref) > 0 {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			return "unresolved " + s.Ref
+		}
+		if v, ok := entities[name]; !ok || reflect.TypeOf(v.entity) != typ {
+			return s.Ref
+		}
+		return ""
+	}
+
+	var want string
+	switch {
+	case typ == reflect.TypeOf(time.Time{}):
+		if s.Type != "string" || s.Format != "date-time" {
+			return s.Type + " " + s.Format
+		}
+		return ""
+	case typ.Kind() == reflect.String:
+		want = "string"
+	case typ.Kind() == reflect.Bool:
+		want = "boolean"
+	case typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Uint64:
+		want = "integer"
+	case typ.Kind() == reflect.Map:
+		if s.Type != "object" || s.AdditionalProperties == nil {
+			return s.Type
+		}
+		return compare(doc, s.AdditionalProperties, typ.Elem())
+	case typ.Kind() == reflect.Slice:
+		if s.Type != "array" || s.Items == nil {
+			return s.Type
+		}
+		return compare(doc, s.Items, typ.Elem())
+	default:
+		return s.Type
+	}
+
+	if s.Type != want {
+		return s.Type
+	}
+	return ""
+}
+
+// TestOpenAPIReferences verifies that every reference of OpenAPI document resolves.
+func TestOpenAPIReferences(t *testing.T) {
+	var doc map[string]any
+	if err := json.Unmarshal(OpenAPI, &doc); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, e := range v {
+				if ref, ok := e.(string); ok && k == "$ref" && resolve(doc, ref) == nil {
+					t.Errorf("got unresolved %s, want resolved", ref)
+				}
+				walk(e)
+			}
+		case []any:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	walk(doc)
+}
+
+// resolve returns document object referenced by local reference, nil if there is no such object.
+func resolve(doc map[string]any, ref string) any {
+	var v any = doc
+	for _, k := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
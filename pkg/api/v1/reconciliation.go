@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/reconcile"
+)
+
+// maxStatementSize limits size of uploaded statement files.
+const maxStatementSize = 16 << 20
+
+// ReconciliationRequest represents HTTP request for reconciling bank statement.
+// Statement file is the request body, its format is given by format query parameter
+// or derived from the content type, optional tolerance query parameter overrides date tolerance.
+type ReconciliationRequest struct {
+	Format    reconcile.Format
+	Tolerance time.Duration
+	Entries   []*reconcile.Entry
+}
+
+// Validate validates request data and returns an error if it's not a valid.
+// Validate implements validator.Validator.
+func (r *ReconciliationRequest) Validate() error {
+	return r.Format.Validate()
+}
+
+// UnmarshalHTTP implements http.RequestUnmarshaler.
+func (r *ReconciliationRequest) UnmarshalHTTPRequest(req *http.Request) error {
+	query := req.URL.Query()
+
+	r.Format = reconcile.Format(query.Get("format"))
+	if len(r.Format) == 0 {
+		r.Format = statementFormat(req.Header.Get("Content-Type"))
+	}
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	if v := query.Get("tolerance"); len(v) > 0 {
+		tolerance, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		r.Tolerance = tolerance
+	}
+
+	entries, err := reconcile.Parse(http.MaxBytesReader(nil, req.Body, maxStatementSize), r.Format)
+	if err != nil {
+		return err
+	}
+	r.Entries = entries
+
+	return nil
+}
+
+// statementFormat derives statement format from the content type.
+func statementFormat(contentType string) reconcile.Format {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return reconcile.FormatCSV
+	case "application/xml", "text/xml":
+		return reconcile.FormatCamt053
+	default:
+		return ""
+	}
+}
+
+// Parse constructs and returns *reconcile.Request populated with information from the request.
+func (r *ReconciliationRequest) Parse() *reconcile.Request {
+	return &reconcile.Request{
+		Entries:   r.Entries,
+		Tolerance: r.Tolerance,
+	}
+}
+
+// Reconciliation represents API response of reconciliation report.
+type Reconciliation struct {
+	Matched    int                     `json:"matched"`
+	Mismatched int                     `json:"mismatched"`
+	Unmatched  int                     `json:"unmatched"`
+	Results    []*ReconciliationResult `json:"results"`
+}
+
+// ReconciliationResult represents reconciliation result of a single statement entry or ledger transaction.
+type ReconciliationResult struct {
+	Status      string                 `json:"status"` // matched, mismatched or unmatched
+	Entry       *StatementEntry        `json:"entry,omitempty"`
+	Transaction *ReconciledTransaction `json:"transaction,omitempty"`
+	Reason      string                 `json:"reason,omitempty"`
+}
+
+// StatementEntry represents bank statement entry.
+type StatementEntry struct {
+	Reference   string    `json:"reference"`
+	Description string    `json:"description,omitempty"`
+	Amount      int       `json:"amount"` // Amount in cents, debits are negative
+	Date        time.Time `json:"date"`
+}
+
+// ReconciledTransaction represents ledger transaction.
+type ReconciledTransaction struct {
+	WalletID string    `json:"wallet_id"`
+	Version  uint64    `json:"version"`
+	Type     string    `json:"transaction"`
+	Amount   int       `json:"amount"` // Amount in cents, withdrawals are negative
+	Date     time.Time `json:"date"`
+}
+
+// NewReconciliationResponse constructs and returns response Reconciliation entity.
+func NewReconciliationResponse(report *reconcile.Report) *Reconciliation {
+	response := Reconciliation{
+		Matched:    report.Matched,
+		Mismatched: report.Mismatched,
+		Unmatched:  report.Unmatched,
+		Results:    []*ReconciliationResult{},
+	}
+
+	for _, v := range report.Results {
+		result := ReconciliationResult{
+			Status: string(v.Status),
+			Reason: v.Reason,
+		}
+		if e := v.Entry; e != nil {
+			result.Entry = &StatementEntry{
+				Reference:   e.Reference,
+				Description: e.Description,
+				Amount:      e.Amount,
+				Date:        e.Date,
+			}
+		}
+		if i := v.Item; i != nil {
+			result.Transaction = &ReconciledTransaction{
+				WalletID: i.WalletID,
+				Version:  i.Version,
+				Type:     i.Type,
+				Amount:   i.Amount,
+				Date:     i.Date,
+			}
+		}
+		response.Results = append(response.Results, &result)
+	}
+
+	return &response
+}
+
+// MarshalHTTP implements http.Marshaler.
+func (r *Reconciliation) MarshalHTTP(w http.ResponseWriter) error {
+	return json.NewEncoder(w).Encode(r)
+}
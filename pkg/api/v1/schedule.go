@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/scheduler"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateScheduleRequest represents HTTP request for creating a new schedule.
+type CreateScheduleRequest struct {
+	Type     string    `json:"transaction"`
+	WalletID string    `json:"wallet_id"`
+	Amount   int       `json:"amount"`
+	Cron     string    `json:"cron,omitempty"` // Recurrence, empty for one-off schedule
+	Start    time.Time `json:"start"`          // Time of one-off transaction or time recurrence starts at, defaults to now
+}
+
+// Validate validates request data and returns an error if it's not a valid.
+// Validate implements validator.Validator.
+func (r *CreateScheduleRequest) Validate() error {
+	return r.Parse().Validate()
+}
+
+// UnmarshalHTTP implements http.RequestUnmarshaler.
+func (r *CreateScheduleRequest) UnmarshalHTTPRequest(req *http.Request) error {
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&r); err != nil {
+		return err
+	}
+	return r.Validate()
+}
+
+// Parse constructs and returns *scheduler.CreateScheduleRequest populated with information from the request.
+func (r *CreateScheduleRequest) Parse() *scheduler.CreateScheduleRequest {
+	return &scheduler.CreateScheduleRequest{
+		Type:     r.Type,
+		WalletID: r.WalletID,
+		Amount:   r.Amount,
+		Cron:     r.Cron,
+		Start:    r.Start,
+	}
+}
+
+// ScheduleRequest represents HTTP request addressing existing schedule.
+type ScheduleRequest struct {
+	ID string `json:"id"` // Schedule ID
+}
+
+// Validate validates request data and returns an error if it's not a valid.
+// Validate implements validator.Validator.
+func (r *ScheduleRequest) Validate() error {
+	if len(r.ID) < 3 {
+		return scheduler.ErrNotValidSchedule
+	}
+	return nil
+}
+
+// UnmarshalHTTP implements http.RequestUnmarshaler.
+func (r *ScheduleRequest) UnmarshalHTTPRequest(req *http.Request) error {
+	r.ID = mux.Vars(req)["id"]
+	return r.Validate()
+}
+
+// Parse parses and returns Schedule ID from the request.
+func (r *ScheduleRequest) Parse() string {
+	return r.ID
+}
+
+// Schedule represents API response Schedule entity.
+type Schedule struct {
+	ID        string     `json:"id"`
+	WalletID  string     `json:"wallet_id"`
+	Type      string     `json:"transaction"`
+	Amount    int        `json:"amount"`
+	Cron      string     `json:"cron,omitempty"`
+	Start     time.Time  `json:"start"`
+	Status    string     `json:"status"`         // active, paused, cancelled or completed
+	Next      *time.Time `json:"next,omitempty"` // Next occurrence
+	Attempts  int        `json:"attempts"`       // Failed attempts of the next occurrence
+	Executed  int        `json:"executed"`       // Number of executed occurrences
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// NewScheduleResponse constructs and returns response Schedule entity.
+func NewScheduleResponse(s *scheduler.Schedule) *Schedule {
+	response := Schedule{
+		ID:        s.ID,
+		WalletID:  s.WalletID,
+		Type:      s.Type,
+		Amount:    s.Amount,
+		Cron:      s.Cron,
+		Start:     s.Start,
+		Status:    string(s.Status),
+		Attempts:  s.Attempts,
+		Executed:  s.Executed,
+		LastError: s.LastError,
+	}
+	if !s.Next.IsZero() && (s.Status == scheduler.StatusActive || s.Status == scheduler.StatusPaused) {
+		next := s.Next
+		response.Next = &next
+	}
+	return &response
+}
+
+// MarshalHTTP implements http.Marshaler.
+func (r *Schedule) MarshalHTTP(w http.ResponseWriter) error {
+	return json.NewEncoder(w).Encode(r)
+}
@@ -3,16 +3,27 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/deividaspetraitis/ledger"
+
+	"github.com/gorilla/mux"
 )
 
+// IdempotencyKeyHeader is HTTP header carrying transaction idempotency key.
+// Transaction repeating idempotency key of already processed one is not processed again.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 // CreateTransactionRequest represents HTTP request for creating a wallet.
 type CreateTransactionRequest struct {
-	ID       int    `json:"id"`
-	Type     string `json:"transaction"`
-	WalletID string `json:"wallet_id"`
-	Amount   int    `json:"amount"`
+	ID                int               `json:"id"`
+	Type              string            `json:"transaction"`
+	WalletID          string            `json:"wallet_id"`
+	Amount            int               `json:"amount"`
+	Description       string            `json:"description,omitempty"`
+	ExternalReference string            `json:"external_reference,omitempty"`
+	Metadata          map[string]string `json:"metadata,omitempty"`
+	Key               string            `json:"-"` // Optional idempotency key given by IdempotencyKeyHeader
 }
 
 // Validate parses request fields and returns whether they contain valid data.
@@ -27,7 +38,7 @@ func (r *CreateTransactionRequest) Validate() error {
 		return ledger.ErrNotValidWalletID
 	}
 
-	if r.Amount == 0 {
+	if r.Amount <= 0 {
 		return ledger.ErrNotValidAmount
 	}
 
@@ -37,9 +48,13 @@ func (r *CreateTransactionRequest) Validate() error {
 // Parse constructs and returns *ledger.TransactionRequest populated with information from the request.
 func (r *CreateTransactionRequest) Parse() *ledger.TransactionRequest {
 	return &ledger.TransactionRequest{
-		Type:     r.Type,
-		WalletID: r.WalletID,
-		Amount:   r.Amount,
+		Type:              r.Type,
+		WalletID:          r.WalletID,
+		Amount:            r.Amount,
+		Description:       r.Description,
+		ExternalReference: r.ExternalReference,
+		Metadata:          r.Metadata,
+		Key:               r.Key,
 	}
 }
 
@@ -49,27 +64,171 @@ func (r *CreateTransactionRequest) UnmarshalHTTPRequest(req *http.Request) error
 	if err := decoder.Decode(&r); err != nil {
 		return err
 	}
+	r.Key = req.Header.Get(IdempotencyKeyHeader)
 
 	return r.Validate()
 }
 
-// NewCreateTransactionResponse constructs and returns CreateTransactionResponse.
-func NewCreateTransactionResponse(req *CreateTransactionRequest) *CreateTransactionResponse {
+// NewCreateTransactionResponse constructs and returns CreateTransactionResponse of the processed transaction.
+func NewCreateTransactionResponse(req *CreateTransactionRequest, receipt *ledger.Receipt) *CreateTransactionResponse {
 	return &CreateTransactionResponse{
-		Type:     req.Type,
-		WalletID: req.WalletID,
-		Amount:   req.Amount,
+		ID:                receipt.TransactionID,
+		Type:              req.Type,
+		WalletID:          req.WalletID,
+		Amount:            req.Amount,
+		Description:       req.Description,
+		ExternalReference: req.ExternalReference,
+		Metadata:          req.Metadata,
+		Balance:           receipt.Balance,
+		Version:           receipt.Version,
 	}
 }
 
 // CreateTransactionResponse represents transaction response.
 type CreateTransactionResponse struct {
+	ID                string            `json:"id"` // Transaction identifier
+	Type              string            `json:"transaction"`
+	WalletID          string            `json:"wallet_id"`
+	Amount            int               `json:"amount"`
+	Description       string            `json:"description,omitempty"`
+	ExternalReference string            `json:"external_reference,omitempty"`
+	Metadata          map[string]string `json:"metadata,omitempty"`
+	Balance           int               `json:"balance"` // Wallet balance once transaction was processed
+	Version           uint64            `json:"version"` // Wallet version once transaction was processed
+}
+
+// MarshalHTTP implements http.Marshaler.
+func (r *CreateTransactionResponse) MarshalHTTP(w http.ResponseWriter) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// TransactionQuote represents API response of transaction fee preview.
+type TransactionQuote struct {
 	Type     string `json:"transaction"`
 	WalletID string `json:"wallet_id"`
 	Amount   int    `json:"amount"`
+	Fee      int    `json:"fee"`
+	Total    int    `json:"total"` // Amount debited including fee for withdrawals, amount credited net of fee for deposits
+	Currency string `json:"currency"`
+}
+
+// NewTransactionQuoteResponse constructs and returns TransactionQuote.
+func NewTransactionQuoteResponse(q *ledger.Quote) *TransactionQuote {
+	return &TransactionQuote{
+		Type:     q.Type,
+		WalletID: q.WalletID,
+		Amount:   q.Amount,
+		Fee:      q.Fee,
+		Total:    q.Total,
+		Currency: q.Currency,
+	}
 }
 
 // MarshalHTTP implements http.Marshaler.
-func (r *CreateTransactionResponse) MarshalHTTP(w http.ResponseWriter) error {
+func (r *TransactionQuote) MarshalHTTP(w http.ResponseWriter) error {
 	return json.NewEncoder(w).Encode(r)
 }
+
+// Transaction represents API response entity of persisted transaction.
+type Transaction struct {
+	ID                string            `json:"id,omitempty"` // Transaction identifier, transactions persisted before identifiers were introduced have none
+	WalletID          string            `json:"wallet_id"`
+	Version           uint64            `json:"version"`
+	Type              string            `json:"transaction"`
+	Amount            int               `json:"amount"`
+	Description       string            `json:"description,omitempty"`
+	ExternalReference string            `json:"external_reference,omitempty"`
+	Metadata          map[string]string `json:"metadata,omitempty"`
+	Timestamp         time.Time         `json:"timestamp"`
+}
+
+// NewTransactionResponse constructs and returns Transaction.
+func NewTransactionResponse(e *ledger.HistoryEntry) *Transaction {
+	return &Transaction{
+		ID:                e.ID,
+		WalletID:          e.WalletID,
+		Version:           e.Version,
+		Type:              e.Type,
+		Amount:            e.Amount,
+		Description:       e.Description,
+		ExternalReference: e.ExternalReference,
+		Metadata:          e.Metadata,
+		Timestamp:         e.Timestamp,
+	}
+}
+
+// MarshalHTTP implements http.Marshaler.
+func (r *Transaction) MarshalHTTP(w http.ResponseWriter) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// Transactions represents API response of transactions list.
+type Transactions struct {
+	Transactions []*Transaction `json:"transactions"`
+}
+
+// NewTransactionsResponse constructs and returns Transactions.
+func NewTransactionsResponse(entries []*ledger.HistoryEntry) *Transactions {
+	r := Transactions{
+		Transactions: make([]*Transaction, 0, len(entries)),
+	}
+	for _, v := range entries {
+		r.Transactions = append(r.Transactions, NewTransactionResponse(v))
+	}
+	return &r
+}
+
+// MarshalHTTP implements http.Marshaler.
+func (r *Transactions) MarshalHTTP(w http.ResponseWriter) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// FindTransactionsRequest represents HTTP request for searching transactions by external reference.
+type FindTransactionsRequest struct {
+	ExternalReference string `json:"external_reference"`
+}
+
+// Validate validates request data and returns an error if it's not a valid.
+// Validate implements validator.Validator.
+func (r *FindTransactionsRequest) Validate() error {
+	if len(r.ExternalReference) == 0 {
+		return ledger.ErrNotValidReference
+	}
+	return nil
+}
+
+// UnmarshalHTTP implements http.RequestUnmarshaler.
+func (r *FindTransactionsRequest) UnmarshalHTTPRequest(req *http.Request) error {
+	r.ExternalReference = req.URL.Query().Get("external_reference")
+	return r.Validate()
+}
+
+// Parse parses and returns external reference from the request.
+func (r *FindTransactionsRequest) Parse() string {
+	return r.ExternalReference
+}
+
+// GetTransactionRequest represents HTTP request for retrieving a transaction.
+type GetTransactionRequest struct {
+	ID string `json:"id"` // Transaction ID
+}
+
+// Validate validates request data and returns an error if it's not a valid.
+// Validate implements validator.Validator.
+func (r *GetTransactionRequest) Validate() error {
+	if len(r.ID) == 0 {
+		return ledger.ErrNotValidTransactionID
+	}
+	return nil
+}
+
+// UnmarshalHTTP implements http.RequestUnmarshaler.
+func (r *GetTransactionRequest) UnmarshalHTTPRequest(req *http.Request) error {
+	r.ID = mux.Vars(req)["id"]
+	return r.Validate()
+}
+
+// Parse parses and returns transaction ID from the request.
+func (r *GetTransactionRequest) Parse() string {
+	return r.ID
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/deividaspetraitis/go/validator"
+	"github.com/deividaspetraitis/ledger"
+)
+
+func TestCreateTransactionRequest(t *testing.T) {
+	const walletID = "60f76d5d-f5d0-405b-b996-11c082d4e644"
+
+	var testcases = []struct {
+		Type   string
+		Amount int
+		Error  error
+	}{
+		{ledger.TransactionDeposit, 100, nil},
+		{ledger.TransactionWithdraw, 100, nil},
+		{ledger.TransactionDeposit, 0, ledger.ErrNotValidAmount},
+		{ledger.TransactionDeposit, -100, ledger.ErrNotValidAmount},
+		{ledger.TransactionWithdraw, -100, ledger.ErrNotValidAmount},
+	}
+
+	for i, v := range testcases {
+		req := CreateTransactionRequest{
+			Type:     v.Type,
+			WalletID: walletID,
+			Amount:   v.Amount,
+		}
+
+		err := validator.Validate(&req)
+		if err != v.Error {
+			t.Errorf("#%d got %v, want %v", i, err, v.Error)
+		}
+	}
+}
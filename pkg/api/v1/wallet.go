@@ -11,18 +11,49 @@ import (
 
 // Wallet represents API response Wallet entity.
 type Wallet struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Balance int    `json:"balance"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Balance  int    `json:"balance"`
+	Tier     string `json:"tier,omitempty"`     // Fee schedule tier
+	Degraded bool   `json:"degraded,omitempty"` // Wallet was restored skipping some of its events
+
+	OverdraftLimit  int  `json:"overdraft_limit,omitempty"`  // Credit line in cents
+	OverdraftRate   int  `json:"overdraft_rate,omitempty"`   // Annual overdraft interest rate in basis points
+	AvailableCredit *int `json:"available_credit,omitempty"` // Unused part of the credit line, present when wallet has one
+
+	Interest *InterestTerms `json:"interest,omitempty"` // Interest terms, present when wallet earns interest
+}
+
+// InterestTerms represents API interest terms entity.
+type InterestTerms struct {
+	Rate     int    `json:"rate"`      // Annual interest rate in basis points
+	Method   string `json:"method"`    // simple or compound
+	DayCount string `json:"day_count"` // act/365 or 30/360
 }
 
 // NewWalletResponse constructs and returns response Wallet entity.
 func NewWalletResponse(w *ledger.Wallet) *Wallet {
-	return &Wallet{
-		ID:      w.ID,
-		Name:    w.Name,
-		Balance: w.Balance,
+	response := Wallet{
+		ID:             w.ID,
+		Name:           w.Name,
+		Balance:        w.Balance,
+		Tier:           w.Tier,
+		Degraded:       w.Degraded,
+		OverdraftLimit: w.OverdraftLimit,
+		OverdraftRate:  w.OverdraftRate,
+	}
+	if w.OverdraftLimit > 0 {
+		credit := w.AvailableCredit()
+		response.AvailableCredit = &credit
 	}
+	if w.Interest.Rate > 0 {
+		response.Interest = &InterestTerms{
+			Rate:     w.Interest.Rate,
+			Method:   w.Interest.Method,
+			DayCount: w.Interest.DayCount,
+		}
+	}
+	return &response
 }
 
 // MarshalHTTP implements http.Marshaler.
@@ -32,7 +63,8 @@ func (r *Wallet) MarshalHTTP(w http.ResponseWriter) error {
 
 // CreateWalletRequest represents HTTP request for creating a new wallet.
 type CreateWalletRequest struct {
-	Name string `json:"name"` // Wallet name
+	Name string `json:"name"`           // Wallet name
+	Tier string `json:"tier,omitempty"` // Fee schedule tier, optional
 }
 
 // Validate validates request data and returns an error if it's not a valid.
@@ -57,6 +89,7 @@ func (r *CreateWalletRequest) UnmarshalHTTPRequest(req *http.Request) error {
 func (r *CreateWalletRequest) Parse() *ledger.CreateWalletRequest {
 	return &ledger.CreateWalletRequest{
 		Name: r.Name,
+		Tier: r.Tier,
 	}
 }
 
@@ -85,3 +118,69 @@ func (r *GetWalletRequest) UnmarshalHTTPRequest(req *http.Request) error {
 func (r *GetWalletRequest) Parse() string {
 	return r.ID
 }
+
+// SetOverdraftRequest represents HTTP request for setting wallet credit line.
+type SetOverdraftRequest struct {
+	WalletID string `json:"-"`     // Wallet ID
+	Limit    int    `json:"limit"` // Credit line in cents, zero closes it
+	Rate     int    `json:"rate"`  // Annual overdraft interest rate in basis points
+}
+
+// Validate validates request data and returns an error if it's not a valid.
+// Validate implements validator.Validator.
+func (r *SetOverdraftRequest) Validate() error {
+	return r.Parse().Validate()
+}
+
+// UnmarshalHTTP implements http.RequestUnmarshaler.
+func (r *SetOverdraftRequest) UnmarshalHTTPRequest(req *http.Request) error {
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&r); err != nil {
+		return err
+	}
+	r.WalletID = mux.Vars(req)["id"]
+	return r.Validate()
+}
+
+// Parse constructs and returns *ledger.SetOverdraftRequest populated with information from the request.
+func (r *SetOverdraftRequest) Parse() *ledger.SetOverdraftRequest {
+	return &ledger.SetOverdraftRequest{
+		WalletID: r.WalletID,
+		Limit:    r.Limit,
+		Rate:     r.Rate,
+	}
+}
+
+// SetInterestTermsRequest represents HTTP request for setting wallet interest terms.
+type SetInterestTermsRequest struct {
+	WalletID string `json:"-"` // Wallet ID
+	InterestTerms
+}
+
+// Validate validates request data and returns an error if it's not a valid.
+// Validate implements validator.Validator.
+func (r *SetInterestTermsRequest) Validate() error {
+	return r.Parse().Validate()
+}
+
+// UnmarshalHTTP implements http.RequestUnmarshaler.
+func (r *SetInterestTermsRequest) UnmarshalHTTPRequest(req *http.Request) error {
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&r); err != nil {
+		return err
+	}
+	r.WalletID = mux.Vars(req)["id"]
+	return r.Validate()
+}
+
+// Parse constructs and returns *ledger.SetInterestTermsRequest populated with information from the request.
+func (r *SetInterestTermsRequest) Parse() *ledger.SetInterestTermsRequest {
+	return &ledger.SetInterestTermsRequest{
+		WalletID: r.WalletID,
+		InterestTerms: ledger.InterestTerms{
+			Rate:     r.Rate,
+			Method:   r.Method,
+			DayCount: r.DayCount,
+		},
+	}
+}
@@ -0,0 +1,233 @@
+// Package client implements Go client of the ledger service v1 HTTP API.
+//
+// Requests and responses are the types of pkg/api/v1. Requests failing with retryable errors are retried
+// with exponential backoff, transactions are given idempotency keys so retried ones are processed at most once.
+// Errors responded by the service are returned as *Error matching one of Err* errors, see errors.Is.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// Defaults of Config zero values.
+const (
+	DefaultRetries    = 3
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 10 * time.Second
+)
+
+// clientIDHeader is HTTP header identifying API client, the one the service rate limits requests by.
+const clientIDHeader = "X-Client-ID"
+
+// Config represents client configuration.
+type Config struct {
+	Address    string        // Base URL of the service, e.g. https://ledger.example.com
+	ClientID   string        // API client identifier requests are rate limited by when sent through trusted proxy, optional
+	Retries    int           // Maximum number of retries of a request, defaults to DefaultRetries, negative disables retries
+	MinBackoff time.Duration // Delay before the first retry, doubled on every next one, defaults to DefaultMinBackoff
+	MaxBackoff time.Duration // Maximum delay between retries, defaults to DefaultMaxBackoff
+}
+
+// Validate implements validator.Validator.
+func (c *Config) Validate() error {
+	u, err := url.Parse(c.Address)
+	if err != nil {
+		return errors.Wrapf(err, "address %q is not valid", c.Address)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return errors.Newf("address %q is not valid, http or https URL is required", c.Address)
+	}
+	if c.MinBackoff < 0 || c.MaxBackoff < 0 {
+		return errors.New("backoff must not be negative")
+	}
+	if c.MinBackoff > 0 && c.MaxBackoff > 0 && c.MinBackoff > c.MaxBackoff {
+		return errors.New("minimum backoff exceeds maximum backoff")
+	}
+	return nil
+}
+
+// Client is a client of the ledger service v1 HTTP API.
+// Client is safe for concurrent use.
+type Client struct {
+	cfg    Config
+	base   string // base URL without trailing slash
+	client *http.Client
+}
+
+// New constructs a new Client of the given configuration sending requests using client,
+// if client is nil http.DefaultClient is used.
+func New(cfg *Config, client *http.Client) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	c := Client{
+		cfg:    *cfg,
+		base:   strings.TrimSuffix(cfg.Address, "/"),
+		client: client,
+	}
+	if c.client == nil {
+		c.client = http.DefaultClient
+	}
+	if c.cfg.Retries == 0 {
+		c.cfg.Retries = DefaultRetries
+	}
+	if c.cfg.MinBackoff == 0 {
+		c.cfg.MinBackoff = DefaultMinBackoff
+	}
+	if c.cfg.MaxBackoff == 0 {
+		c.cfg.MaxBackoff = max(DefaultMaxBackoff, c.cfg.MinBackoff)
+	}
+	return &c, nil
+}
+
+// call represents a single API call, it is sent once per attempt.
+type call struct {
+	method      string
+	path        string // escaped path
+	query       url.Values
+	header      http.Header
+	body        []byte
+	contentType string
+
+	// idempotent reports whether repeating call has the same effect as sending it once,
+	// only such calls are retried when their outcome is not known.
+	idempotent bool
+}
+
+// newCall constructs a new call of JSON encoded body, if any.
+func newCall(method, path string, body any, idempotent bool) (*call, error) {
+	c := call{
+		method:     method,
+		path:       path,
+		header:     make(http.Header),
+		idempotent: idempotent,
+	}
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		c.body = b
+		c.contentType = "application/json"
+	}
+	return &c, nil
+}
+
+// do performs call decoding response into v, if v is not nil.
+// Call failing with retryable error is retried with exponential backoff until retries are exhausted.
+func (c *Client) do(ctx context.Context, call *call, v any) error {
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, call, v)
+		if err == nil || attempt >= c.cfg.Retries || ctx.Err() != nil || !retryable(call, err) {
+			return err
+		}
+
+		timer := time.NewTimer(c.backoff(attempt, err))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// send sends single attempt of the call.
+func (c *Client) send(ctx context.Context, call *call, v any) error {
+	u := c.base + call.path
+	if len(call.query) > 0 {
+		u += "?" + call.query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, call.method, u, bytes.NewReader(call.body))
+	if err != nil {
+		return err
+	}
+	for k, values := range call.header {
+		req.Header[k] = values
+	}
+	if len(call.contentType) > 0 {
+		req.Header.Set("Content-Type", call.contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	if len(c.cfg.ClientID) > 0 {
+		req.Header.Set(clientIDHeader, c.cfg.ClientID)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16)) // nolint: drain so connection is reused
+		return newError(resp.StatusCode, retryAfter(resp.Header.Get("Retry-After")))
+	}
+
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.Wrapf(err, "decoding %s %s response", call.method, call.path)
+	}
+	return nil
+}
+
+// retryable reports whether call failed with err may be retried.
+// Rate limited and shed requests were not processed and are always retried,
+// while calls of unknown outcome are retried only if they are idempotent.
+func retryable(call *call, err error) bool {
+	e, ok := err.(*Error)
+	if !ok {
+		// transport error, request may or may not have reached the service
+		_, ok := err.(*url.Error)
+		return ok && call.idempotent
+	}
+
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return call.idempotent
+	default:
+		return false
+	}
+}
+
+// backoff returns delay before retrying attempt failed with err.
+// Delay requested by the service is respected, otherwise it grows exponentially with jitter.
+func (c *Client) backoff(attempt int, err error) time.Duration {
+	if e, ok := err.(*Error); ok && e.RetryAfter > 0 {
+		return min(e.RetryAfter, c.cfg.MaxBackoff)
+	}
+
+	d := c.cfg.MinBackoff
+	for i := 0; i < attempt && d < c.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, c.cfg.MaxBackoff)
+	// equal jitter spreads retries of concurrent clients while keeping at least half of the delay
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter parses Retry-After header value given in seconds, zero is returned if it's not valid.
+func retryAfter(v string) time.Duration {
+	seconds, err := strconv.Atoi(v)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,449 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/config"
+	"github.com/deividaspetraitis/ledger/database/backend"
+	"github.com/deividaspetraitis/ledger/database/cache"
+	"github.com/deividaspetraitis/ledger/database/schema"
+	"github.com/deividaspetraitis/ledger/fee"
+	ihttp "github.com/deividaspetraitis/ledger/http"
+	"github.com/deividaspetraitis/ledger/index"
+	"github.com/deividaspetraitis/ledger/interest"
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+	"github.com/deividaspetraitis/ledger/pkg/client"
+	"github.com/deividaspetraitis/ledger/reconcile"
+	"github.com/deividaspetraitis/ledger/scheduler"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+	"github.com/deividaspetraitis/go/log"
+)
+
+// newServer starts server of the API handler backed by SQLite database within test temporary directory,
+// requests are passed to the handler through middleware, if any.
+func newServer(t *testing.T, middleware func(http.Handler) http.Handler) *httptest.Server {
+	server, _ := startServer(t, middleware)
+	return server
+}
+
+// startServer starts server as newServer does and returns it along its transactions processor.
+func startServer(t *testing.T, middleware func(http.Handler) http.Handler) (*httptest.Server, *ledger.Processor) {
+	ctx := context.Background()
+
+	cfg := config.Default()
+	cfg.Store.Backend = backend.SQLite
+	cfg.Store.SQLite.Path = filepath.Join(t.TempDir(), "ledger.db")
+
+	store, closeStore, err := backend.Open(ctx, cfg.Store, cfg.Database, cfg.Events)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	transactions := index.New(cfg.Index, store.RecordsAfter)
+	wallets := cache.New[*ledger.WalletAggregate](cfg.Cache)
+
+	save := transactions.Save(wallets.Save(store.Save))
+	get := wallets.Load(func(ctx context.Context, aggregate es.Aggregate, id string) (*ledger.WalletAggregate, error) {
+		return backend.Get[*ledger.WalletAggregate](ctx, store, aggregate, id)
+	})
+
+	fees, err := fee.New(cfg.Fees)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	processor := ledger.NewProcessor(cfg.Processor, save, get, fees)
+
+	schedules := scheduler.New(cfg.Scheduler, store.Save, func(ctx context.Context, aggregate es.Aggregate, id string) (*scheduler.ScheduleAggregate, error) {
+		return backend.Get[*scheduler.ScheduleAggregate](ctx, store, aggregate, id)
+	}, store.IDs, processor.CreateTransaction)
+	accrual := ledger.NewAccrual(cfg.Overdraft, store.Records, cfg.Events.Policy(), get, store.IDs, processor.Execute)
+	poster := interest.New(cfg.Interest, store.Records, cfg.Events.Policy(), get, store.IDs, processor.Execute)
+	reconciler := reconcile.NewReconciler(cfg.Reconcile, reconcile.NewGetItemsFunc(store.Records), transactions.FindWallet)
+
+	var handler http.Handler = ihttp.API(nil, ihttp.NewLimiter(ihttp.LimitsConfig{}), ihttp.NewAuthorizer(nil), ihttp.NewToggles(nil), log.Default(), save, get, processor, func(ctx context.Context, id string) (*schema.SignedHead, error) {
+		var wallet ledger.WalletAggregate
+		if err := store.Load(ctx, &wallet, id); err != nil {
+			return nil, err
+		}
+		return (*schema.Signer)(nil).Sign(id, uint64(wallet.Root().Version()), wallet.ChainHead()), nil
+	}, reconciler, schedules, accrual, poster, store.Records, transactions)
+	if middleware != nil {
+		handler = middleware(handler)
+	}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		server.Close()
+		closeStore() // nolint
+	})
+	return server, processor
+}
+
+// newClient returns client of the server retrying requests without noticeable delay.
+func newClient(t *testing.T, server *httptest.Server, retries int) *client.Client {
+	c, err := client.New(&client.Config{
+		Address:    server.URL,
+		ClientID:   "test",
+		Retries:    retries,
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 5 * time.Millisecond,
+	}, server.Client())
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return c
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newServer(t, nil), 0)
+
+	wallet, err := c.CreateWallet(ctx, &api.CreateWalletRequest{Name: "savings"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	var testcases = []struct {
+		req     *api.CreateTransactionRequest
+		balance int
+	}{
+		{&api.CreateTransactionRequest{Type: ledger.TransactionDeposit, Amount: 5000, ExternalReference: "INV-1"}, 5000},
+		{&api.CreateTransactionRequest{Type: ledger.TransactionWithdraw, Amount: 1250, Key: "payout-1"}, 3750},
+		{&api.CreateTransactionRequest{Type: ledger.TransactionWithdraw, Amount: 1250, Key: "payout-1"}, 3750}, // repeated key is not processed again
+	}
+
+	var ids []string
+	for i, tt := range testcases {
+		tt.req.WalletID = wallet.ID
+		transaction, err := c.CreateTransaction(ctx, tt.req)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if transaction.Balance != tt.balance {
+			t.Errorf("#%d balance got %v, want %v", i, transaction.Balance, tt.balance)
+		}
+		ids = append(ids, transaction.ID)
+	}
+	if ids[1] != ids[2] {
+		t.Errorf("repeated transaction ID got %v, want %v", ids[2], ids[1])
+	}
+
+	got, err := c.GetWallet(ctx, wallet.ID)
+	if err != nil || got.Balance != 3750 {
+		t.Errorf("got %v and %v, want balance %v and %v", got, err, 3750, nil)
+	}
+
+	history, err := c.GetHistory(ctx, wallet.ID)
+	if err != nil || len(history.Transactions) != 2 {
+		t.Errorf("got %v and %v, want %d transactions and %v", history, err, 2, nil)
+	}
+
+	transaction, err := c.GetTransaction(ctx, ids[1])
+	if err != nil || transaction.Amount != 1250 || transaction.WalletID != wallet.ID {
+		t.Errorf("got %v and %v, want withdrawal of %v and %v", transaction, err, 1250, nil)
+	}
+
+	found, err := c.FindTransactions(ctx, "INV-1")
+	if err != nil || len(found.Transactions) != 1 || found.Transactions[0].ID != ids[0] {
+		t.Errorf("got %v and %v, want transaction %v and %v", found, err, ids[0], nil)
+	}
+
+	quote, err := c.QuoteTransaction(ctx, &api.CreateTransactionRequest{Type: ledger.TransactionWithdraw, WalletID: wallet.ID, Amount: 100})
+	if err != nil || quote.Total != 100 {
+		t.Errorf("got %v and %v, want total %v and %v", quote, err, 100, nil)
+	}
+
+	got, err = c.SetOverdraft(ctx, &api.SetOverdraftRequest{WalletID: wallet.ID, Limit: 10000, Rate: 1500})
+	if err != nil || got.OverdraftLimit != 10000 {
+		t.Errorf("got %v and %v, want overdraft limit %v and %v", got, err, 10000, nil)
+	}
+
+	got, err = c.SetInterestTerms(ctx, &api.SetInterestTermsRequest{WalletID: wallet.ID, InterestTerms: api.InterestTerms{Rate: 200, Method: "simple", DayCount: "act/365"}})
+	if err != nil || got.Interest == nil || got.Interest.Rate != 200 {
+		t.Errorf("got %v and %v, want interest rate %v and %v", got, err, 200, nil)
+	}
+
+	head, err := c.GetChainHead(ctx, wallet.ID)
+	if err != nil || head.WalletID != wallet.ID || len(head.Hash) == 0 {
+		t.Errorf("got %v and %v, want head of %v and %v", head, err, wallet.ID, nil)
+	}
+
+	statement := fmt.Sprintf("date,reference,amount\n%s,INV-1,50.00\n", time.Now().UTC().Format("2006-01-02"))
+	reconciliation, err := c.Reconcile(ctx, strings.NewReader(statement), reconcile.FormatCSV, 48*time.Hour)
+	if err != nil || reconciliation.Matched != 1 {
+		t.Errorf("got %v and %v, want %d matched and %v", reconciliation, err, 1, nil)
+	}
+
+	schedule, err := c.CreateSchedule(ctx, &api.CreateScheduleRequest{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: 100, Cron: "0 9 * * *"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	for i, change := range []struct {
+		do     func(context.Context, string) (*api.Schedule, error)
+		status string
+	}{
+		{c.GetSchedule, string(scheduler.StatusActive)},
+		{c.PauseSchedule, string(scheduler.StatusPaused)},
+		{c.ResumeSchedule, string(scheduler.StatusActive)},
+		{c.CancelSchedule, string(scheduler.StatusCancelled)},
+	} {
+		got, err := change.do(ctx, schedule.ID)
+		if err != nil || got.Status != change.status {
+			t.Errorf("#%d got %v and %v, want status %v and %v", i, got, err, change.status, nil)
+		}
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newServer(t, nil), 0)
+
+	wallet, err := c.CreateWallet(ctx, &api.CreateWalletRequest{Name: "errors"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	schedule, err := c.CreateSchedule(ctx, &api.CreateScheduleRequest{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: 100, Cron: "0 9 * * *"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	var testcases = []struct {
+		do         func() error
+		err        error
+		statusCode int
+	}{
+		{func() error {
+			_, err := c.CreateWallet(ctx, &api.CreateWalletRequest{})
+			return err
+		}, client.ErrBadRequest, http.StatusBadRequest},
+		{func() error {
+			_, err := c.CreateTransaction(ctx, &api.CreateTransactionRequest{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: 100, Key: strings.Repeat("k", 129)})
+			return err
+		}, client.ErrBadRequest, http.StatusBadRequest},
+		{func() error {
+			_, err := c.GetTransaction(ctx, "5b1c2f7e-3f7e-4a43-8d1f-6a1f1c1e9b10")
+			return err
+		}, client.ErrNotFound, http.StatusNotFound},
+		{func() error {
+			_, err := c.ResumeSchedule(ctx, schedule.ID) // schedule is active
+			return err
+		}, client.ErrConflict, http.StatusConflict},
+		{func() error {
+			_, err := c.CreateTransaction(ctx, &api.CreateTransactionRequest{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: 0})
+			return err
+		}, client.ErrBadRequest, http.StatusBadRequest},
+		{func() error {
+			_, err := c.CreateTransaction(ctx, &api.CreateTransactionRequest{Type: "REFUND", WalletID: wallet.ID, Amount: 100})
+			return err
+		}, client.ErrBadRequest, http.StatusBadRequest},
+		{func() error {
+			_, err := c.CreateTransaction(ctx, &api.CreateTransactionRequest{Type: ledger.TransactionDeposit, WalletID: "5b1c2f7e-3f7e-4a43-8d1f-6a1f1c1e9b10", Amount: 100})
+			return err
+		}, client.ErrNotFound, http.StatusNotFound},
+		{func() error {
+			_, err := c.CreateTransaction(ctx, &api.CreateTransactionRequest{Type: ledger.TransactionWithdraw, WalletID: wallet.ID, Amount: 100})
+			return err
+		}, client.ErrUnprocessable, http.StatusUnprocessableEntity}, // insufficient balance
+	}
+
+	for i, tt := range testcases {
+		err := tt.do()
+		if !errors.Is(err, tt.err) {
+			t.Errorf("#%d got %v, want %v", i, err, tt.err)
+			continue
+		}
+		if e, ok := err.(*client.Error); !ok || e.StatusCode != tt.statusCode {
+			t.Errorf("#%d got %v, want HTTP status %v", i, err, tt.statusCode)
+		}
+	}
+}
+
+func TestClientErrorsClosed(t *testing.T) {
+	ctx := context.Background()
+	server, processor := startServer(t, nil)
+	c := newClient(t, server, 0)
+
+	wallet, err := c.CreateWallet(ctx, &api.CreateWalletRequest{Name: "closed"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if err := processor.Close(ctx); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	_, err = c.CreateTransaction(ctx, &api.CreateTransactionRequest{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: 100})
+	if !errors.Is(err, client.ErrUnavailable) {
+		t.Fatalf("got %v, want %v", err, client.ErrUnavailable)
+	}
+	if e, ok := err.(*client.Error); !ok || e.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got %v, want HTTP status %v", err, http.StatusServiceUnavailable)
+	}
+}
+
+// fault represents failure injected into response to the request.
+type fault int
+
+const (
+	faultNone        fault = iota
+	faultLost              // request is processed, but its response is replaced by 502 Bad Gateway
+	faultDropped           // request is processed, but connection is closed without response
+	faultRateLimited       // request is rejected with 429 Too Many Requests
+	faultUnavailable       // request is rejected with 503 Service Unavailable
+)
+
+// injector injects faults into responses of requests to the path, in order of faults.
+type injector struct {
+	mu     sync.Mutex
+	path   string
+	faults []fault
+	keys   []string // idempotency keys of requests to the path
+}
+
+// requests returns idempotency keys of requests to the path.
+func (f *injector) requests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.keys...)
+}
+
+// middleware returns middleware injecting faults.
+func (f *injector) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != f.path {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		f.mu.Lock()
+		fault := faultNone
+		if len(f.keys) < len(f.faults) {
+			fault = f.faults[len(f.keys)]
+		}
+		f.keys = append(f.keys, r.Header.Get(api.IdempotencyKeyHeader))
+		f.mu.Unlock()
+
+		switch fault {
+		case faultLost:
+			next.ServeHTTP(httptest.NewRecorder(), r)
+			w.WriteHeader(http.StatusBadGateway)
+		case faultDropped:
+			next.ServeHTTP(httptest.NewRecorder(), r)
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				panic(err)
+			}
+			conn.Close()
+		case faultRateLimited:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case faultUnavailable:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func TestClientRetries(t *testing.T) {
+	var testcases = []struct {
+		faults  []fault
+		retries int
+		err     error
+		calls   int // requests reaching the path
+		balance int // wallet balance once request is done
+	}{
+		{nil, 0, nil, 1, 1000},
+		{[]fault{faultLost, faultLost}, 0, nil, 3, 1000},
+		{[]fault{faultDropped}, 0, nil, 2, 1000},
+		{[]fault{faultRateLimited, faultUnavailable, faultLost}, 0, nil, 4, 1000},
+		{[]fault{faultUnavailable, faultUnavailable}, 1, client.ErrUnavailable, 2, 0},
+		{[]fault{faultLost}, -1, client.ErrUnavailable, 1, 1000}, // outcome is unknown, yet transaction was processed
+	}
+
+	for i, tt := range testcases {
+		ctx := context.Background()
+		faults := injector{path: "/transactions", faults: tt.faults}
+		server := newServer(t, faults.middleware)
+		c := newClient(t, server, tt.retries)
+
+		wallet, err := c.CreateWallet(ctx, &api.CreateWalletRequest{Name: "retries"})
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+
+		_, err = c.CreateTransaction(ctx, &api.CreateTransactionRequest{Type: ledger.TransactionDeposit, WalletID: wallet.ID, Amount: 1000})
+		if !errors.Is(err, tt.err) || (err == nil) != (tt.err == nil) {
+			t.Errorf("#%d got %v, want %v", i, err, tt.err)
+		}
+		keys := faults.requests()
+		if len(keys) != tt.calls {
+			t.Errorf("#%d calls got %v, want %v", i, len(keys), tt.calls)
+		}
+		for _, key := range keys {
+			if len(key) == 0 || key != keys[0] {
+				t.Errorf("#%d idempotency keys got %v, want the same key", i, keys)
+				break
+			}
+		}
+
+		got, err := c.GetWallet(ctx, wallet.ID)
+		if err != nil || got.Balance != tt.balance {
+			t.Errorf("#%d got %v and %v, want balance %v and %v", i, got, err, tt.balance, nil)
+		}
+	}
+}
+
+func TestClientRetriesNotIdempotent(t *testing.T) {
+	var testcases = []struct {
+		faults []fault
+		fail   bool
+		calls  int
+	}{
+		{[]fault{faultRateLimited, faultUnavailable}, false, 3}, // rejected requests were not processed
+		{[]fault{faultLost}, true, 1},                           // wallet may have been created
+		{[]fault{faultDropped}, true, 1},
+	}
+
+	for i, tt := range testcases {
+		faults := injector{path: "/wallets", faults: tt.faults}
+		c := newClient(t, newServer(t, faults.middleware), 0)
+
+		if _, err := c.CreateWallet(context.Background(), &api.CreateWalletRequest{Name: "retries"}); (err != nil) != tt.fail {
+			t.Errorf("#%d got %v, want fail %v", i, err, tt.fail)
+		}
+		if calls := len(faults.requests()); calls != tt.calls {
+			t.Errorf("#%d calls got %v, want %v", i, calls, tt.calls)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	var testcases = []struct {
+		cfg  client.Config
+		fail bool
+	}{
+		{client.Config{Address: "http://localhost:8000"}, false},
+		{client.Config{Address: "https://ledger.example.com/api/"}, false},
+		{client.Config{Address: "localhost:8000"}, true},
+		{client.Config{Address: "ftp://localhost"}, true},
+		{client.Config{Address: "http://localhost", MinBackoff: -time.Second}, true},
+		{client.Config{Address: "http://localhost", MinBackoff: time.Minute, MaxBackoff: time.Second}, true},
+	}
+
+	for i, tt := range testcases {
+		if _, err := client.New(&tt.cfg, nil); (err != nil) != tt.fail {
+			t.Errorf("#%d got %v, want fail %v", i, err, tt.fail)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// Errors responded by the service, matched by *Error of the corresponding HTTP status codes.
+var (
+	ErrBadRequest       = errors.New("request is not valid")                     // 400
+	ErrNotFound         = errors.New("resource not found")                       // 404
+	ErrConflict         = errors.New("request conflicts with resource state")    // 409
+	ErrUnprocessable    = errors.New("request is rejected by the ledger")        // 422
+	ErrRateLimited      = errors.New("request rate limit exceeded")              // 429
+	ErrServer           = errors.New("service failed to process request")        // 500 and other 5xx
+	ErrUnavailable      = errors.New("service is unavailable")                   // 502, 503 and 504
+	ErrUnexpectedStatus = errors.New("service responded with unexpected status") // any other status
+)
+
+// Error represents an error responded by the service.
+// Error matches one of Err* errors of its status code, see errors.Is.
+type Error struct {
+	StatusCode int           // HTTP status code
+	RetryAfter time.Duration // Delay requested by the service before retrying, zero if none was given
+	err        error
+}
+
+// newError constructs a new Error of the given HTTP status code.
+func newError(code int, retryAfter time.Duration) *Error {
+	e := Error{
+		StatusCode: code,
+		RetryAfter: retryAfter,
+	}
+
+	switch {
+	case code == http.StatusBadRequest:
+		e.err = ErrBadRequest
+	case code == http.StatusNotFound:
+		e.err = ErrNotFound
+	case code == http.StatusConflict:
+		e.err = ErrConflict
+	case code == http.StatusUnprocessableEntity:
+		e.err = ErrUnprocessable
+	case code == http.StatusTooManyRequests:
+		e.err = ErrRateLimited
+	case code == http.StatusBadGateway, code == http.StatusServiceUnavailable, code == http.StatusGatewayTimeout:
+		e.err = ErrUnavailable
+	case code >= 500 && code <= 599:
+		e.err = ErrServer
+	default:
+		e.err = ErrUnexpectedStatus
+	}
+	return &e
+}
+
+// Error implements error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: HTTP %d", e.err, e.StatusCode)
+}
+
+// Unwrap returns Err* error of the status code.
+func (e *Error) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+	"github.com/deividaspetraitis/ledger/reconcile"
+)
+
+// Reconcile reconciles bank statement of the given format read from statement against wallets transactions.
+// Non-zero tolerance overrides date tolerance configured by the service.
+func (c *Client) Reconcile(ctx context.Context, statement io.Reader, format reconcile.Format, tolerance time.Duration) (*api.Reconciliation, error) {
+	if err := format.Validate(); err != nil {
+		return nil, err
+	}
+
+	// statement is read upfront so it can be sent again on retries
+	body, err := io.ReadAll(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	call, err := newCall(http.MethodPost, "/reconciliations", nil, true)
+	if err != nil {
+		return nil, err
+	}
+	call.body = body
+	call.contentType = "application/octet-stream"
+	call.query = url.Values{"format": {string(format)}}
+	if tolerance > 0 {
+		call.query.Set("tolerance", tolerance.String())
+	}
+
+	var reconciliation api.Reconciliation
+	if err := c.do(ctx, call, &reconciliation); err != nil {
+		return nil, err
+	}
+	return &reconciliation, nil
+}
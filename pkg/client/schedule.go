@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+)
+
+// CreateSchedule creates a scheduled or recurring transaction.
+// Request is retried only when it was rejected without being processed, as repeating it would create another schedule.
+func (c *Client) CreateSchedule(ctx context.Context, req *api.CreateScheduleRequest) (*api.Schedule, error) {
+	call, err := newCall(http.MethodPost, "/schedules", req, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedule api.Schedule
+	if err := c.do(ctx, call, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// GetSchedule retrieves schedule of the given ID.
+func (c *Client) GetSchedule(ctx context.Context, id string) (*api.Schedule, error) {
+	return c.schedule(ctx, http.MethodGet, id, "", true)
+}
+
+// PauseSchedule pauses schedule of the given ID.
+func (c *Client) PauseSchedule(ctx context.Context, id string) (*api.Schedule, error) {
+	return c.schedule(ctx, http.MethodPost, id, "/pause", false)
+}
+
+// ResumeSchedule resumes paused schedule of the given ID.
+func (c *Client) ResumeSchedule(ctx context.Context, id string) (*api.Schedule, error) {
+	return c.schedule(ctx, http.MethodPost, id, "/resume", false)
+}
+
+// CancelSchedule cancels schedule of the given ID.
+func (c *Client) CancelSchedule(ctx context.Context, id string) (*api.Schedule, error) {
+	return c.schedule(ctx, http.MethodPost, id, "/cancel", false)
+}
+
+// schedule performs call of the schedule of the given ID and returns the schedule responded.
+// Status changes are not idempotent, once applied repeating them is responded with conflict.
+func (c *Client) schedule(ctx context.Context, method, id, action string, idempotent bool) (*api.Schedule, error) {
+	call, err := newCall(method, "/schedules/"+url.PathEscape(id)+action, nil, idempotent)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedule api.Schedule
+	if err := c.do(ctx, call, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
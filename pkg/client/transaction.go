@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+
+	"github.com/google/uuid"
+)
+
+// CreateTransaction creates a new transaction and returns it along the wallet balance once it was processed.
+// Request without idempotency key is given a random one, so retried request is processed at most once.
+// Transaction repeating key of already processed one is not processed again, its identifier is returned instead.
+func (c *Client) CreateTransaction(ctx context.Context, req *api.CreateTransactionRequest) (*api.CreateTransactionResponse, error) {
+	call, err := newCall(http.MethodPost, "/transactions", req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	key := req.Key
+	if len(key) == 0 {
+		key = uuid.NewString()
+	}
+	call.header.Set(api.IdempotencyKeyHeader, key)
+
+	var transaction api.CreateTransactionResponse
+	if err := c.do(ctx, call, &transaction); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// QuoteTransaction previews fee of the transaction without processing it.
+func (c *Client) QuoteTransaction(ctx context.Context, req *api.CreateTransactionRequest) (*api.TransactionQuote, error) {
+	call, err := newCall(http.MethodPost, "/transactions/quote", req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var quote api.TransactionQuote
+	if err := c.do(ctx, call, &quote); err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// GetTransaction retrieves transaction of the given ID.
+func (c *Client) GetTransaction(ctx context.Context, id string) (*api.Transaction, error) {
+	call, err := newCall(http.MethodGet, "/transactions/"+url.PathEscape(id), nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var transaction api.Transaction
+	if err := c.do(ctx, call, &transaction); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// FindTransactions retrieves transactions of the given external reference.
+func (c *Client) FindTransactions(ctx context.Context, reference string) (*api.Transactions, error) {
+	call, err := newCall(http.MethodGet, "/transactions", nil, true)
+	if err != nil {
+		return nil, err
+	}
+	call.query = url.Values{"external_reference": {reference}}
+
+	var transactions api.Transactions
+	if err := c.do(ctx, call, &transactions); err != nil {
+		return nil, err
+	}
+	return &transactions, nil
+}
+
+// GetHistory retrieves transactions of the wallet of the given ID.
+func (c *Client) GetHistory(ctx context.Context, walletID string) (*api.Transactions, error) {
+	call, err := newCall(http.MethodGet, "/wallets/"+url.PathEscape(walletID)+"/transactions", nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions api.Transactions
+	if err := c.do(ctx, call, &transactions); err != nil {
+		return nil, err
+	}
+	return &transactions, nil
+}
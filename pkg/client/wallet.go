@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/deividaspetraitis/ledger/pkg/api/v1"
+)
+
+// CreateWallet creates a new wallet.
+// Request is retried only when it was rejected without being processed, as repeating it would create another wallet.
+func (c *Client) CreateWallet(ctx context.Context, req *api.CreateWalletRequest) (*api.Wallet, error) {
+	call, err := newCall(http.MethodPost, "/wallets", req, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallet api.Wallet
+	if err := c.do(ctx, call, &wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// GetWallet retrieves wallet of the given ID.
+func (c *Client) GetWallet(ctx context.Context, id string) (*api.Wallet, error) {
+	call, err := newCall(http.MethodGet, "/wallets/"+url.PathEscape(id), nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallet api.Wallet
+	if err := c.do(ctx, call, &wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// SetOverdraft sets credit line of the wallet given by req.WalletID and returns updated wallet.
+func (c *Client) SetOverdraft(ctx context.Context, req *api.SetOverdraftRequest) (*api.Wallet, error) {
+	call, err := newCall(http.MethodPut, "/admin/wallets/"+url.PathEscape(req.WalletID)+"/overdraft", req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallet api.Wallet
+	if err := c.do(ctx, call, &wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// SetInterestTerms sets interest terms of the wallet given by req.WalletID and returns updated wallet.
+func (c *Client) SetInterestTerms(ctx context.Context, req *api.SetInterestTermsRequest) (*api.Wallet, error) {
+	call, err := newCall(http.MethodPut, "/admin/wallets/"+url.PathEscape(req.WalletID)+"/interest", req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallet api.Wallet
+	if err := c.do(ctx, call, &wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// GetChainHead verifies events hash chain of the wallet of the given ID and returns its signed head.
+func (c *Client) GetChainHead(ctx context.Context, id string) (*api.ChainHead, error) {
+	call, err := newCall(http.MethodGet, "/admin/wallets/"+url.PathEscape(id)+"/chain", nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var head api.ChainHead
+	if err := c.do(ctx, call, &head); err != nil {
+		return nil, err
+	}
+	return &head, nil
+}
@@ -0,0 +1,455 @@
+package ledger
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/fee"
+
+	"github.com/deividaspetraitis/go/database"
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/validator"
+)
+
+// Default processor configuration values.
+const (
+	defaultMailboxSize = 128
+	defaultBatchSize   = 32
+	defaultIdleTimeout = time.Minute
+	defaultTimeout     = 10 * time.Second
+)
+
+// closeInterval is how often mailbox of closed processor checks whether it was drained.
+const closeInterval = 10 * time.Millisecond
+
+// ErrProcessorClosed is returned when command is sent to closed processor.
+var ErrProcessorClosed = errors.New("processor is closed")
+
+// ErrMailboxFull is returned when command can not be queued without waiting as wallet mailbox is full.
+var ErrMailboxFull = errors.New("wallet mailbox is full")
+
+// ProcessorConfig represents transactions processor configuration.
+type ProcessorConfig struct {
+	MailboxSize int           `mapstructure:"mailbox"` // Maximum number of transactions queued per wallet
+	BatchSize   int           `mapstructure:"batch"`   // Maximum number of transactions persisted within a single append
+	IdleTimeout time.Duration `mapstructure:"idle"`    // Time after which idle wallet is evicted from memory
+	Timeout     time.Duration `mapstructure:"timeout"` // Maximum time of loading or persisting a wallet
+}
+
+// Validate implements validator.Validator.
+func (c *ProcessorConfig) Validate() error {
+	if c.MailboxSize < 0 || c.BatchSize < 0 || c.IdleTimeout < 0 || c.Timeout < 0 {
+		return errors.New("mailbox, batch, idle and timeout can not be negative")
+	}
+	return nil
+}
+
+// Processor serialises transactions per wallet.
+//
+// Each wallet being transacted gets its own mailbox drained by a dedicated goroutine.
+// The goroutine keeps wallet aggregate in memory between transactions and persists
+// all transactions found queued in the mailbox within a single append,
+// so concurrent transactions on the same wallet do not race against each other.
+// Idle wallets are evicted from memory after configured timeout.
+// Wallet modified outside of the processor, e.g. by another instance, is reloaded and the batch applied once again.
+//
+// Fees charged by persisted transactions are queued into the house wallet mailbox,
+// so the house wallet collects them serialised with its own transactions.
+// Fees which were not collected are collected later by Collector.
+type Processor struct {
+	cfg  ProcessorConfig
+	save database.SaveAggregateFunc
+	get  database.GetAggregateFunc[*WalletAggregate]
+	fees atomic.Pointer[fee.Schedule]
+
+	mu        sync.Mutex
+	mailboxes map[string]*mailbox
+	closed    chan struct{}  // closed once processor is closed
+	running   sync.WaitGroup // running mailboxes
+}
+
+// mailbox holds transactions queued for a single wallet.
+type mailbox struct {
+	commands chan *command
+	senders  int // number of senders about to queue a command, guarded by Processor.mu
+}
+
+// command represents queued transaction, fee collection or wallet operation awaiting to be processed.
+type command struct {
+	ctx     context.Context
+	tx      *Transaction
+	collect *FeeCollected                // fee to be credited to the house wallet, set instead of tx
+	op      func(*WalletAggregate) error // operation applied on the wallet, set instead of tx
+	result  chan result
+}
+
+// result represents outcome of processed command.
+type result struct {
+	wallet *Wallet
+	err    error
+}
+
+// reply sends command processing result to the caller.
+// Nobody waits for fee collection results, so their outcome is recorded instead.
+func (c *command) reply(wallet *Wallet, err error) {
+	if c.collect != nil {
+		collected(c.collect, err)
+	}
+	c.result <- result{wallet: wallet, err: err}
+}
+
+// NewProcessor constructs a new Processor persisting wallets using save and loading them using get,
+// transactions are charged according to fees schedule.
+// If cfg is nil or some of its values are not set defaults are used.
+func NewProcessor(cfg *ProcessorConfig, save database.SaveAggregateFunc, get database.GetAggregateFunc[*WalletAggregate], fees *fee.Schedule) *Processor {
+	var c ProcessorConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.MailboxSize < 1 {
+		c.MailboxSize = defaultMailboxSize
+	}
+	if c.BatchSize < 1 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = defaultIdleTimeout
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+
+	p := Processor{
+		cfg:       c,
+		save:      save,
+		get:       get,
+		mailboxes: make(map[string]*mailbox),
+		closed:    make(chan struct{}),
+	}
+	p.fees.Store(fees)
+	return &p
+}
+
+// SetFees replaces fees schedule, transactions processed afterwards are charged according to it.
+func (p *Processor) SetFees(fees *fee.Schedule) {
+	p.fees.Store(fees)
+}
+
+// CreateTransaction queues a new transaction for the given wallet and waits until it's processed.
+// It's a drop-in replacement for CreateTransaction, including idempotency key handling.
+func (p *Processor) CreateTransaction(ctx context.Context, req *TransactionRequest) (*Receipt, error) {
+	// transaction must be a valid
+	if err := validator.Validate(req); err != nil {
+		return nil, err
+	}
+
+	cmd := &command{
+		ctx:    ctx,
+		tx:     newTransaction(req),
+		result: make(chan result, 1),
+	}
+
+	wallet, err := p.send(ctx, req.WalletID, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// transaction is replied once processed, thus its ID is settled.
+	return newReceipt(cmd.tx, wallet), nil
+}
+
+// Execute queues operation for the given wallet and waits until it's processed.
+// Operation is serialised with wallet transactions and events it applies are persisted along them.
+func (p *Processor) Execute(ctx context.Context, id string, op func(*WalletAggregate) error) (*Wallet, error) {
+	return p.send(ctx, id, &command{
+		ctx:    ctx,
+		op:     op,
+		result: make(chan result, 1),
+	})
+}
+
+// send queues command into the wallet mailbox and waits for its result.
+// If ctx is done before command is processed ctx error is returned, command queued already may still be processed.
+func (p *Processor) send(ctx context.Context, id string, cmd *command) (*Wallet, error) {
+	mb, err := p.acquire(id)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case mb.commands <- cmd:
+		p.release(mb)
+	case <-ctx.Done():
+		p.release(mb)
+		return nil, ctx.Err()
+	}
+
+	// once queued command is always replied to, result is buffered so nobody has to wait for it.
+	select {
+	case res := <-cmd.result:
+		return res.wallet, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops accepting commands and waits until commands queued already are processed and mailboxes are stopped,
+// or ctx is done.
+func (p *Processor) Close(ctx context.Context) error {
+	p.mu.Lock()
+	select {
+	case <-p.closed:
+	default:
+		close(p.closed)
+	}
+	p.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		p.running.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// QuoteTransaction previews fee of the transaction without processing it.
+func (p *Processor) QuoteTransaction(ctx context.Context, req *TransactionRequest) (*Quote, error) {
+	return QuoteTransaction(ctx, p.get, p.fees.Load(), req)
+}
+
+// Fees returns current fees schedule.
+func (p *Processor) Fees() *fee.Schedule {
+	return p.fees.Load()
+}
+
+// collect queues fees into the house wallet mailbox without waiting for them to be collected.
+// Fees which can not be queued right away, e.g. as house wallet mailbox is full, are left to Collector.
+func (p *Processor) collect(fees []*FeeCollected) {
+	for _, v := range fees {
+		mb, err := p.acquire(p.fees.Load().House())
+		if err != nil {
+			collected(v, err)
+			continue
+		}
+		select {
+		case mb.commands <- &command{
+			ctx:     context.Background(), // nobody waits for the result, see command.reply
+			collect: v,
+			result:  make(chan result, 1),
+		}:
+		default:
+			collected(v, ErrMailboxFull)
+		}
+		p.release(mb)
+	}
+}
+
+// acquire returns mailbox of the given wallet, starting it if needed.
+// Returned mailbox is guaranteed to be drained until release is called.
+// If processor is closed ErrProcessorClosed is returned.
+func (p *Processor) acquire(id string) (*mailbox, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.closed:
+		return nil, ErrProcessorClosed
+	default:
+	}
+
+	mb, ok := p.mailboxes[id]
+	if !ok {
+		mb = &mailbox{
+			commands: make(chan *command, p.cfg.MailboxSize),
+		}
+		p.mailboxes[id] = mb
+		p.running.Add(1)
+		go p.run(id, mb)
+	}
+	mb.senders++
+
+	return mb, nil
+}
+
+// release marks that caller is done with queuing a command into the mailbox.
+func (p *Processor) release(mb *mailbox) {
+	p.mu.Lock()
+	mb.senders--
+	p.mu.Unlock()
+}
+
+// retire removes mailbox if it's not used and reports whether it was removed.
+func (p *Processor) retire(id string, mb *mailbox) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if mb.senders > 0 || len(mb.commands) > 0 {
+		return false
+	}
+	delete(p.mailboxes, id)
+	return true
+}
+
+// run drains wallet mailbox until it stays idle for the configured timeout or, once processor is closed, until it's drained.
+func (p *Processor) run(id string, mb *mailbox) {
+	defer p.running.Done()
+
+	var wallet *WalletAggregate
+
+	timeout, closed := p.cfg.IdleTimeout, p.closed
+	idle := time.NewTimer(timeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case cmd := <-mb.commands:
+			batch := []*command{cmd}
+		drain:
+			for len(batch) < p.cfg.BatchSize {
+				select {
+				case cmd := <-mb.commands:
+					batch = append(batch, cmd)
+				default:
+					break drain
+				}
+			}
+
+			wallet = p.process(wallet, id, batch)
+
+			if !idle.Stop() {
+				select {
+				case <-idle.C:
+				default:
+				}
+			}
+			idle.Reset(timeout)
+		case <-idle.C:
+			if p.retire(id, mb) {
+				return
+			}
+			idle.Reset(timeout)
+		case <-closed:
+			// no more commands are accepted, retire as soon as queued ones are processed.
+			timeout, closed = closeInterval, nil
+			if !idle.Stop() {
+				select {
+				case <-idle.C:
+				default:
+				}
+			}
+			idle.Reset(0)
+		}
+	}
+}
+
+// process applies batch of transactions on the wallet and persists accepted ones within a single append.
+// If wallet was modified outside of the processor it's reloaded and accepted commands are applied once again.
+// Likewise if a command failed leaving some of its events applied, as they can not be taken back.
+// It returns wallet aggregate to be reused by following batches, nil means wallet has to be reloaded.
+func (p *Processor) process(wallet *WalletAggregate, id string, batch []*command) *WalletAggregate {
+	for retried := false; ; {
+		if wallet == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), p.cfg.Timeout)
+			loaded, err := p.get(ctx, &WalletAggregate{}, id)
+			cancel()
+			if err != nil {
+				for _, cmd := range batch {
+					cmd.reply(nil, err)
+				}
+				return nil
+			}
+			wallet = loaded
+		}
+
+		accepted, states, dirty := p.apply(wallet, batch)
+		if dirty {
+			wallet, batch = nil, accepted
+			continue
+		}
+		if len(accepted) == 0 {
+			return wallet
+		}
+
+		fees := feesCharged(wallet)
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.cfg.Timeout)
+		err := p.save(ctx, wallet)
+		cancel()
+
+		if errors.Is(err, ErrVersionConflict) && !retried {
+			// aggregate is outdated, e.g. wallet was transacted by another instance or ledgerctl.
+			wallet, batch, retried = nil, accepted, true
+			continue
+		}
+		if err != nil {
+			// aggregate holds transactions which were not persisted, drop it.
+			for _, cmd := range accepted {
+				cmd.reply(nil, errors.Wrap(err, "unable to persist transaction"))
+			}
+			return nil
+		}
+		charged(fees)
+
+		for i, cmd := range accepted {
+			cmd.reply(&states[i], nil)
+		}
+
+		p.collect(fees)
+
+		return wallet
+	}
+}
+
+// apply applies batch of commands on the wallet, rejected commands are replied right away.
+// It returns accepted commands along with wallet state once each of them was applied.
+// If rejected command left some of its events applied, wallet is dirty: applying stops and
+// accepted commands are returned followed by commands not applied yet.
+func (p *Processor) apply(wallet *WalletAggregate, batch []*command) ([]*command, []Wallet, bool) {
+	var (
+		accepted []*command
+		states   []Wallet
+	)
+	for i, cmd := range batch {
+		// caller gave up waiting for the result
+		if err := cmd.ctx.Err(); err != nil {
+			cmd.reply(nil, err)
+			continue
+		}
+
+		pending := len(wallet.Events())
+
+		var err error
+		switch {
+		case cmd.collect != nil:
+			err = wallet.CollectFee(cmd.collect)
+		case cmd.op != nil:
+			err = cmd.op(wallet)
+		default:
+			cmd.tx.Fee = transactionFee(p.fees.Load(), &wallet.Wallet, cmd.tx)
+			err = wallet.ProcessTransaction(cmd.tx)
+		}
+		// duplicate is replied with the current state once batch is persisted,
+		// as the transaction it repeats might be pending within the same batch.
+		if errors.Is(err, ErrDuplicateTransaction) {
+			err = nil
+		}
+		if err != nil {
+			cmd.reply(nil, err)
+			if len(wallet.Events()) != pending {
+				return append(accepted, batch[i+1:]...), nil, true
+			}
+			continue
+		}
+
+		accepted = append(accepted, cmd)
+		states = append(states, wallet.Wallet)
+	}
+	return accepted, states, false
+}
@@ -0,0 +1,655 @@
+package ledger
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/fee"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// memStore is an in-memory aggregates store enforcing optimistic concurrency, stale aggregates are rejected with ErrVersionConflict.
+type memStore struct {
+	latency time.Duration    // simulated append latency
+	now     func() time.Time // time events are persisted at
+
+	mu      sync.Mutex
+	streams map[string][]es.MarshalUnmarshaler
+	stamps  map[string][]time.Time // times stream events were persisted at
+	appends int
+	reads   int // events read while restoring aggregates
+}
+
+func newMemStore(latency time.Duration) *memStore {
+	return &memStore{
+		latency: latency,
+		now:     time.Now,
+		streams: make(map[string][]es.MarshalUnmarshaler),
+		stamps:  make(map[string][]time.Time),
+	}
+}
+
+func (s *memStore) save(ctx context.Context, aggregate es.Aggregate) error {
+	events := aggregate.Events()
+	if len(events) == 0 {
+		return nil
+	}
+
+	time.Sleep(s.latency)
+
+	s.mu.Lock()
+	id := events[0].AggregateID
+	if uint64(len(s.streams[id]))+1 != uint64(events[0].Version) {
+		s.mu.Unlock()
+		return ErrVersionConflict
+	}
+	for _, v := range events {
+		s.streams[id] = append(s.streams[id], v.Data)
+		s.stamps[id] = append(s.stamps[id], s.now())
+	}
+	s.appends++
+	s.mu.Unlock()
+
+	for _, v := range events {
+		if err := aggregate.Sync(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memStore) get(ctx context.Context, aggregate es.Aggregate, id string) (*WalletAggregate, error) {
+	s.mu.Lock()
+	stream := s.streams[id]
+	// only events following aggregate version are read, so aggregate restored earlier is caught up
+	if version := int(aggregate.Root().Version()); version <= len(stream) {
+		s.reads += len(stream) - version
+		stream = stream[version:]
+	}
+	s.mu.Unlock()
+
+	if len(stream) == 0 && aggregate.Root().Version() == 0 {
+		return nil, ErrEntryNotFound
+	}
+
+	var events []*es.Event
+	for _, v := range stream {
+		events = append(events, es.NewEvent(id, aggregate, v))
+	}
+
+	if err := aggregate.Reply(events); err != nil {
+		return nil, err
+	}
+	return aggregate.(*WalletAggregate), nil
+}
+
+// createWallet stores a new wallet and returns its ID.
+func (s *memStore) createWallet(t testing.TB) string {
+	wallet, err := CreateWallet(context.Background(), s.save, &CreateWalletRequest{Name: "test wallet"})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return wallet.ID
+}
+
+func TestProcessorCreateTransaction(t *testing.T) {
+	store := newMemStore(time.Millisecond)
+	id := store.createWallet(t)
+
+	processor := NewProcessor(nil, store.save, store.get, nil)
+
+	// concurrent deposits should all succeed
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := processor.CreateTransaction(context.Background(), &TransactionRequest{
+				Type:     TransactionDeposit,
+				WalletID: id,
+				Amount:   10,
+			})
+			if err != nil {
+				t.Errorf("got %v, want %v", err, nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// withdrawal exceeding the balance is rejected without affecting the wallet
+	_, err := processor.CreateTransaction(context.Background(), &TransactionRequest{
+		Type:     TransactionWithdraw,
+		WalletID: id,
+		Amount:   1001,
+	})
+	if err != ErrInsufficientBalance {
+		t.Errorf("got %v, want %v", err, ErrInsufficientBalance)
+	}
+
+	wallet, err := processor.CreateTransaction(context.Background(), &TransactionRequest{
+		Type:     TransactionWithdraw,
+		WalletID: id,
+		Amount:   1000,
+	})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if wallet.Balance != 0 {
+		t.Errorf("balance got %v, want %v", wallet.Balance, 0)
+	}
+
+	stored, err := GetWallet(context.Background(), store.get, id)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if stored.Balance != 0 {
+		t.Errorf("stored balance got %v, want %v", stored.Balance, 0)
+	}
+
+	// queued transactions are group committed
+	if store.appends >= 102 {
+		t.Errorf("appends got %v, want less than %v", store.appends, 102)
+	}
+}
+
+func TestProcessorSaveFailure(t *testing.T) {
+	store := newMemStore(0)
+	id := store.createWallet(t)
+
+	var fail atomic.Bool
+	processor := NewProcessor(nil, func(ctx context.Context, aggregate es.Aggregate) error {
+		if fail.Load() {
+			return ErrVersionConflict
+		}
+		return store.save(ctx, aggregate)
+	}, store.get, nil)
+
+	deposit := &TransactionRequest{
+		Type:     TransactionDeposit,
+		WalletID: id,
+		Amount:   10,
+	}
+
+	fail.Store(true)
+	if _, err := processor.CreateTransaction(context.Background(), deposit); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("got %v, want %v", err, ErrVersionConflict)
+	}
+
+	// failed transaction must not leak into following ones
+	fail.Store(false)
+	wallet, err := processor.CreateTransaction(context.Background(), deposit)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if wallet.Balance != 10 {
+		t.Errorf("balance got %v, want %v", wallet.Balance, 10)
+	}
+}
+
+func TestProcessorVersionConflict(t *testing.T) {
+	store := newMemStore(0)
+	id := store.createWallet(t)
+
+	processor := NewProcessor(nil, store.save, store.get, nil)
+
+	deposit := &TransactionRequest{
+		Type:     TransactionDeposit,
+		WalletID: id,
+		Amount:   10,
+	}
+	if _, err := processor.CreateTransaction(context.Background(), deposit); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	// wallet is transacted outside of the processor, e.g. by another instance, processor holds outdated aggregate.
+	if _, err := CreateTransaction(context.Background(), store.save, store.get, nil, deposit); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	receipt, err := processor.CreateTransaction(context.Background(), deposit)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if receipt.Balance != 30 {
+		t.Errorf("balance got %v, want %v", receipt.Balance, 30)
+	}
+}
+
+func TestProcessorFailedOperation(t *testing.T) {
+	store := newMemStore(0)
+	id := store.createWallet(t)
+
+	processor := NewProcessor(nil, store.save, store.get, nil)
+
+	// operation fails having some of its events applied.
+	errFailed := errors.New("failed")
+	_, err := processor.Execute(context.Background(), id, func(wallet *WalletAggregate) error {
+		if err := wallet.ProcessTransaction(&Transaction{Type: TransactionDeposit, WalletID: id, Amount: 100}); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("got %v, want %v", err, errFailed)
+	}
+
+	// events of failed operation must not be persisted along following transactions.
+	receipt, err := processor.CreateTransaction(context.Background(), &TransactionRequest{
+		Type:     TransactionDeposit,
+		WalletID: id,
+		Amount:   10,
+	})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if receipt.Balance != 10 {
+		t.Errorf("balance got %v, want %v", receipt.Balance, 10)
+	}
+	if restored, _ := store.get(context.Background(), &WalletAggregate{}, id); restored.Balance != 10 {
+		t.Errorf("persisted balance got %v, want %v", restored.Balance, 10)
+	}
+}
+
+func TestProcessorContext(t *testing.T) {
+	store := newMemStore(time.Second)
+	id := store.createWallet(t)
+
+	processor := NewProcessor(nil, store.save, store.get, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := processor.CreateTransaction(ctx, &TransactionRequest{
+		Type:     TransactionDeposit,
+		WalletID: id,
+		Amount:   10,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed >= store.latency {
+		t.Errorf("got %v, want caller released before save completes", elapsed)
+	}
+}
+
+func TestProcessorClose(t *testing.T) {
+	store := newMemStore(time.Millisecond)
+	id := store.createWallet(t)
+
+	processor := NewProcessor(nil, store.save, store.get, nil)
+
+	deposit := &TransactionRequest{
+		Type:     TransactionDeposit,
+		WalletID: id,
+		Amount:   10,
+	}
+
+	// transactions queued before close are processed, later ones are rejected.
+	var (
+		wg        sync.WaitGroup
+		processed atomic.Int64
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := processor.CreateTransaction(context.Background(), deposit)
+			switch {
+			case err == nil:
+				processed.Add(1)
+			case !errors.Is(err, ErrProcessorClosed):
+				t.Errorf("got %v, want %v", err, ErrProcessorClosed)
+			}
+		}()
+	}
+
+	if err := processor.Close(context.Background()); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	wg.Wait()
+
+	processor.mu.Lock()
+	if n := len(processor.mailboxes); n != 0 {
+		t.Errorf("mailboxes got %v, want %v", n, 0)
+	}
+	processor.mu.Unlock()
+
+	if _, err := processor.CreateTransaction(context.Background(), deposit); !errors.Is(err, ErrProcessorClosed) {
+		t.Errorf("got %v, want %v", err, ErrProcessorClosed)
+	}
+
+	want := int(processed.Load()) * deposit.Amount
+	wallet, err := GetWallet(context.Background(), store.get, id)
+	if err != nil || wallet.Balance != want {
+		t.Errorf("got %v and %v, want balance %v and %v", wallet, err, want, nil)
+	}
+}
+
+func TestProcessorWalletNotFound(t *testing.T) {
+	store := newMemStore(0)
+	processor := NewProcessor(nil, store.save, store.get, nil)
+
+	_, err := processor.CreateTransaction(context.Background(), &TransactionRequest{
+		Type:     TransactionDeposit,
+		WalletID: newID(),
+		Amount:   10,
+	})
+	if err != ErrEntryNotFound {
+		t.Errorf("got %v, want %v", err, ErrEntryNotFound)
+	}
+}
+
+func TestProcessorIdle(t *testing.T) {
+	store := newMemStore(0)
+	id := store.createWallet(t)
+
+	processor := NewProcessor(&ProcessorConfig{IdleTimeout: time.Millisecond}, store.save, store.get, nil)
+
+	deposit := &TransactionRequest{
+		Type:     TransactionDeposit,
+		WalletID: id,
+		Amount:   10,
+	}
+
+	for i := 1; i <= 3; i++ {
+		wallet, err := processor.CreateTransaction(context.Background(), deposit)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if wallet.Balance != i*10 {
+			t.Errorf("#%d balance got %v, want %v", i, wallet.Balance, i*10)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	processor.mu.Lock()
+	defer processor.mu.Unlock()
+	if n := len(processor.mailboxes); n != 0 {
+		t.Errorf("mailboxes got %v, want %v", n, 0)
+	}
+}
+
+func TestProcessorIdempotency(t *testing.T) {
+	store := newMemStore(0)
+	id := store.createWallet(t)
+
+	processor := NewProcessor(nil, store.save, store.get, nil)
+
+	deposit := &TransactionRequest{
+		Type:     TransactionDeposit,
+		WalletID: id,
+		Amount:   10,
+		Key:      "schedule:1:2024-01-01T00:00:00Z",
+	}
+
+	// concurrent duplicates are processed once
+	var (
+		wg  sync.WaitGroup
+		ids = make([]string, 10)
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			receipt, err := processor.CreateTransaction(context.Background(), deposit)
+			if err != nil {
+				t.Errorf("got %v, want %v", err, nil)
+				return
+			}
+			ids[i] = receipt.TransactionID
+		}(i)
+	}
+	wg.Wait()
+
+	// duplicate of persisted transaction is recognised by restored wallet as well
+	wallet, err := CreateTransaction(context.Background(), store.save, store.get, nil, deposit)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if wallet.Balance != 10 {
+		t.Errorf("balance got %v, want %v", wallet.Balance, 10)
+	}
+
+	if n := len(store.streams[id]); n != 2 {
+		t.Fatalf("events got %v, want %v", n, 2)
+	}
+
+	// duplicates are replied with ID of the persisted transaction
+	want := store.streams[id][1].(*Deposit).ID
+	for i, v := range append(ids, wallet.TransactionID) {
+		if v != want {
+			t.Errorf("#%d transaction ID got %v, want %v", i, v, want)
+		}
+	}
+}
+
+func TestProcessorTransactionID(t *testing.T) {
+	store := newMemStore(0)
+	id := store.createWallet(t)
+
+	processor := NewProcessor(nil, store.save, store.get, nil)
+
+	var testcases = []struct {
+		tx      *TransactionRequest
+		balance int
+		version uint64
+	}{
+		{tx: &TransactionRequest{Type: TransactionDeposit, WalletID: id, Amount: 100}, balance: 100, version: 2},
+		{tx: &TransactionRequest{Type: TransactionWithdraw, WalletID: id, Amount: 40}, balance: 60, version: 3},
+	}
+
+	for i, tt := range testcases {
+		receipt, err := processor.CreateTransaction(context.Background(), tt.tx)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if !isValidID(receipt.TransactionID) {
+			t.Errorf("#%d transaction ID got %q, want UUID", i, receipt.TransactionID)
+		}
+		if receipt.Balance != tt.balance || receipt.Version != tt.version {
+			t.Errorf("#%d got %v/%v, want %v/%v", i, receipt.Balance, receipt.Version, tt.balance, tt.version)
+		}
+
+		// identifier is persisted along the transaction
+		if entry := NewHistoryEntry(id, tt.version, time.Time{}, store.streams[id][tt.version-1]); entry == nil || entry.ID != receipt.TransactionID {
+			t.Errorf("#%d persisted transaction got %+v, want ID %v", i, entry, receipt.TransactionID)
+		}
+	}
+}
+
+// newFeeSchedule returns schedule charging 1% plus 10 cents on withdrawals credited to the house wallet.
+func newFeeSchedule(t testing.TB, house string) *fee.Schedule {
+	schedule, err := fee.New(&fee.Config{
+		House: house,
+		Schedules: map[string]map[string]*fee.Rule{
+			"withdraw": {fee.DefaultTier: {BasisPoints: 100, Fixed: 10}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	return schedule
+}
+
+func TestProcessorFees(t *testing.T) {
+	store := newMemStore(0)
+	house := store.createWallet(t)
+	id := store.createWallet(t)
+
+	processor := NewProcessor(nil, store.save, store.get, newFeeSchedule(t, house))
+
+	var testcases = []struct {
+		tx *TransactionRequest
+
+		balance int
+		err     error
+	}{
+		// deposits are free of charge
+		{
+			tx:      &TransactionRequest{Type: TransactionDeposit, WalletID: id, Amount: 1000},
+			balance: 1000,
+		},
+		// withdrawal is charged 1% plus 10 cents
+		{
+			tx:      &TransactionRequest{Type: TransactionWithdraw, WalletID: id, Amount: 500},
+			balance: 485,
+		},
+		// balance covers the amount, but not the fee
+		{
+			tx:  &TransactionRequest{Type: TransactionWithdraw, WalletID: id, Amount: 485},
+			err: ErrInsufficientBalance,
+		},
+	}
+
+	for i, tt := range testcases {
+		wallet, err := processor.CreateTransaction(context.Background(), tt.tx)
+		if err != tt.err {
+			t.Fatalf("#%d got %v, want %v", i, err, tt.err)
+		}
+		if err == nil && wallet.Balance != tt.balance {
+			t.Errorf("#%d balance got %v, want %v", i, wallet.Balance, tt.balance)
+		}
+	}
+
+	// fee is collected by the house wallet asynchronously
+	deadline := time.Now().Add(time.Second)
+	for {
+		wallet, err := GetWallet(context.Background(), store.get, house)
+		if err != nil {
+			t.Fatalf("got %v, want %v", err, nil)
+		}
+		if wallet.Balance == 15 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("house balance got %v, want %v", wallet.Balance, 15)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// withdrawal and its fee are persisted within the same append
+	store.mu.Lock()
+	stream := store.streams[id]
+	store.mu.Unlock()
+	if n := len(stream); n != 4 {
+		t.Fatalf("events got %v, want %v", n, 4)
+	}
+	if e, ok := stream[3].(*FeeCharged); !ok || e.Amount != 15 || e.Transaction != TransactionWithdraw {
+		t.Errorf("got %#v, want fee of %v", stream[3], 15)
+	}
+}
+
+func TestQuoteTransaction(t *testing.T) {
+	store := newMemStore(0)
+	house := store.createWallet(t)
+	id := store.createWallet(t)
+	fees := newFeeSchedule(t, house)
+
+	var testcases = []struct {
+		req *TransactionRequest
+
+		quote *Quote
+		err   error
+	}{
+		{
+			req:   &TransactionRequest{Type: "withdraw", WalletID: id, Amount: 1000},
+			quote: &Quote{Type: TransactionWithdraw, WalletID: id, Amount: 1000, Fee: 20, Total: 1020, Currency: fee.DefaultCurrency},
+		},
+		{
+			req:   &TransactionRequest{Type: TransactionDeposit, WalletID: id, Amount: 1000},
+			quote: &Quote{Type: TransactionDeposit, WalletID: id, Amount: 1000, Fee: 0, Total: 1000, Currency: fee.DefaultCurrency},
+		},
+		{
+			req:   &TransactionRequest{Type: TransactionWithdraw, WalletID: house, Amount: 1000},
+			quote: &Quote{Type: TransactionWithdraw, WalletID: house, Amount: 1000, Fee: 0, Total: 1000, Currency: fee.DefaultCurrency},
+		},
+		{
+			req: &TransactionRequest{Type: "transfer", WalletID: id, Amount: 1000},
+			err: ErrNotValidTransaction,
+		},
+		{
+			req: &TransactionRequest{Type: TransactionWithdraw, WalletID: newID(), Amount: 1000},
+			err: ErrEntryNotFound,
+		},
+	}
+
+	for i, tt := range testcases {
+		quote, err := QuoteTransaction(context.Background(), store.get, fees, tt.req)
+		if err != tt.err {
+			t.Fatalf("#%d got %v, want %v", i, err, tt.err)
+		}
+		if err == nil && *quote != *tt.quote {
+			t.Errorf("#%d got %+v, want %+v", i, quote, tt.quote)
+		}
+	}
+}
+
+func TestProcessorSetFees(t *testing.T) {
+	store := newMemStore(0)
+	house := store.createWallet(t)
+	id := store.createWallet(t)
+
+	processor := NewProcessor(nil, store.save, store.get, nil)
+
+	var testcases = []struct {
+		fees *fee.Schedule
+		fee  int
+	}{
+		{nil, 0},
+		{newFeeSchedule(t, house), 20},
+		{nil, 0},
+	}
+
+	for i, tt := range testcases {
+		processor.SetFees(tt.fees)
+
+		quote, err := processor.QuoteTransaction(context.Background(), &TransactionRequest{Type: TransactionWithdraw, WalletID: id, Amount: 1000})
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if quote.Fee != tt.fee {
+			t.Errorf("#%d got %v, want %v", i, quote.Fee, tt.fee)
+		}
+	}
+}
+
+// benchmarkHotWallet runs parallel deposits against a single wallet using createTransaction
+// and reports ratio of failed transactions.
+func benchmarkHotWallet(b *testing.B, store *memStore, createTransaction func(ctx context.Context, req *TransactionRequest) (*Receipt, error)) {
+	id := store.createWallet(b)
+
+	var failed atomic.Int64
+	b.SetParallelism(16)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := createTransaction(context.Background(), &TransactionRequest{
+				Type:     TransactionDeposit,
+				WalletID: id,
+				Amount:   1,
+			})
+			if err != nil {
+				failed.Add(1)
+			}
+		}
+	})
+	b.ReportMetric(float64(failed.Load())/float64(b.N), "failed/op")
+}
+
+func BenchmarkCreateTransaction(b *testing.B) {
+	store := newMemStore(100 * time.Microsecond)
+	benchmarkHotWallet(b, store, func(ctx context.Context, req *TransactionRequest) (*Receipt, error) {
+		return CreateTransaction(ctx, store.save, store.get, nil, req)
+	})
+}
+
+func BenchmarkProcessorCreateTransaction(b *testing.B) {
+	store := newMemStore(100 * time.Microsecond)
+	benchmarkHotWallet(b, store, NewProcessor(nil, store.save, store.get, nil).CreateTransaction)
+}
@@ -0,0 +1,333 @@
+// Package reconcile implements reconciliation of wallets transactions against external bank statements.
+//
+// Statement entries are matched to Deposit and Withdraw events of the wallet referenced by the entry,
+// i.e. wallet ID found in the entry reference or description, otherwise wallet having transaction of external reference
+// equal to the entry reference. Entry is matched when amount is equal and booking date is within the date tolerance
+// of the event, mismatched when only the date is within tolerance. Transactions of external reference equal to the entry reference are preferred.
+// Entries and ledger transactions of referenced wallets within the statement period left without a pair are unmatched.
+package reconcile
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+	"github.com/deividaspetraitis/go/validator"
+)
+
+// DefaultTolerance is used when reconciliation date tolerance is not configured.
+const DefaultTolerance = 72 * time.Hour
+
+// Config represents reconciliation configuration.
+type Config struct {
+	Tolerance time.Duration `mapstructure:"tolerance"` // Maximum difference between statement and ledger dates, defaults to DefaultTolerance
+}
+
+// Validate implements validator.Validator.
+func (c *Config) Validate() error {
+	if c.Tolerance < 0 {
+		return errors.New("tolerance can not be negative")
+	}
+	return nil
+}
+
+// Item represents ledger transaction subject to reconciliation.
+type Item struct {
+	WalletID string    // Wallet identifier
+	Version  uint64    // Version of the transaction event
+	Type     string    // Transaction type, see ledger.TransactionDeposit and ledger.TransactionWithdraw
+	Amount   int       // Amount in cents, deposits are positive and withdrawals are negative
+	Date     time.Time // Time transaction was persisted
+
+	ExternalReference string // Reference of the transaction in external system
+}
+
+// GetItemsFunc returns transactions of the wallet.
+// If wallet does not exist ledger.ErrEntryNotFound is returned.
+type GetItemsFunc func(ctx context.Context, walletID string) ([]*Item, error)
+
+// RecordsFunc returns iterator over persisted records of the aggregate.
+type RecordsFunc func(ctx context.Context, aggregate es.Aggregate, id string) (schema.Iterator, error)
+
+// NewGetItemsFunc constructs GetItemsFunc reading wallet transactions from persisted records.
+func NewGetItemsFunc(records RecordsFunc) GetItemsFunc {
+	return func(ctx context.Context, walletID string) ([]*Item, error) {
+		history, err := ledger.History(ctx, ledger.RecordsFunc(records), walletID)
+		if err != nil {
+			return nil, err
+		}
+
+		var items []*Item
+		for _, v := range history {
+			item := Item{
+				WalletID:          walletID,
+				Version:           v.Version,
+				Type:              v.Type,
+				Amount:            v.Amount,
+				ExternalReference: v.ExternalReference,
+				Date:              v.Timestamp,
+			}
+			if v.Type == ledger.TransactionWithdraw {
+				item.Amount = -v.Amount
+			}
+			items = append(items, &item)
+		}
+		return items, nil
+	}
+}
+
+// FindWalletFunc returns ID of the wallet having transaction of the given external reference,
+// empty string if there is no such transaction.
+type FindWalletFunc func(ctx context.Context, reference string) (string, error)
+
+// Status represents reconciliation result status.
+type Status string
+
+// Reconciliation result statuses.
+const (
+	StatusMatched    Status = "matched"    // entry and transaction amounts and dates match
+	StatusMismatched Status = "mismatched" // entry and transaction dates match, but amounts differ
+	StatusUnmatched  Status = "unmatched"  // entry or transaction has no pair
+)
+
+// Result represents reconciliation result of a single statement entry or ledger transaction.
+type Result struct {
+	Status Status
+	Entry  *Entry // Statement entry, nil for unmatched ledger transactions
+	Item   *Item  // Ledger transaction, nil for unmatched statement entries
+	Reason string // Human readable reason of mismatched or unmatched result
+}
+
+// Report represents reconciliation report.
+type Report struct {
+	Matched    int       // Number of matched results
+	Mismatched int       // Number of mismatched results
+	Unmatched  int       // Number of unmatched results
+	Results    []*Result // Results of statement entries in the statement order followed by unmatched ledger transactions
+}
+
+// add adds result to the report.
+func (r *Report) add(result *Result) {
+	switch result.Status {
+	case StatusMatched:
+		r.Matched++
+	case StatusMismatched:
+		r.Mismatched++
+	case StatusUnmatched:
+		r.Unmatched++
+	}
+	r.Results = append(r.Results, result)
+}
+
+// Request represents a request for reconciling bank statement.
+type Request struct {
+	Entries   []*Entry      // Statement entries
+	Tolerance time.Duration // Maximum difference between statement and ledger dates, defaults to DefaultTolerance
+}
+
+// Validate implements validator.Validator.
+func (r *Request) Validate() error {
+	if r.Tolerance < 0 {
+		return errors.New("date tolerance can not be negative")
+	}
+	return nil
+}
+
+// Reconciler reconciles statements using configured date tolerance.
+type Reconciler struct {
+	tolerance  time.Duration
+	getItems   GetItemsFunc
+	findWallet FindWalletFunc
+}
+
+// NewReconciler constructs a new Reconciler retrieving wallets transactions using getItems
+// and resolving wallets of entries referencing no wallet by their reference using findWallet, if not nil.
+func NewReconciler(cfg *Config, getItems GetItemsFunc, findWallet FindWalletFunc) *Reconciler {
+	r := Reconciler{
+		tolerance:  DefaultTolerance,
+		getItems:   getItems,
+		findWallet: findWallet,
+	}
+	if cfg != nil && cfg.Tolerance > 0 {
+		r.tolerance = cfg.Tolerance
+	}
+	return &r
+}
+
+// Reconcile reconciles statement entries, request without date tolerance is reconciled using configured one.
+func (r *Reconciler) Reconcile(ctx context.Context, req *Request) (*Report, error) {
+	if req.Tolerance == 0 {
+		req.Tolerance = r.tolerance
+	}
+	return Reconcile(ctx, r.getItems, r.findWallet, req)
+}
+
+// uuid matches wallet ID within references.
+var uuid = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// walletID returns wallet ID referenced by the entry, empty string if entry references no wallet.
+func (e *Entry) walletID() string {
+	if id := uuid.FindString(e.Reference); len(id) > 0 {
+		return strings.ToLower(id)
+	}
+	return strings.ToLower(uuid.FindString(e.Description))
+}
+
+// Reconcile reconciles statement entries against wallets transactions retrieved using getItems.
+// Wallets of entries referencing no wallet are resolved by transaction external reference equal to the entry reference using findWallet, if not nil.
+func Reconcile(ctx context.Context, getItems GetItemsFunc, findWallet FindWalletFunc, req *Request) (*Report, error) {
+	if err := validator.Validate(req); err != nil {
+		return nil, err
+	}
+
+	tolerance := req.Tolerance
+	if tolerance == 0 {
+		tolerance = DefaultTolerance
+	}
+
+	// resolve wallets referenced by entries
+	wallets := make(map[*Entry]string)
+	for _, entry := range req.Entries {
+		id := entry.walletID()
+		if len(id) == 0 && len(entry.Reference) > 0 && findWallet != nil {
+			var err error
+			if id, err = findWallet(ctx, entry.Reference); err != nil {
+				return nil, errors.Wrapf(err, "unable to find wallet of %s", entry.Reference)
+			}
+		}
+		wallets[entry] = id
+	}
+
+	// retrieve transactions of referenced wallets
+	var (
+		items   = make(map[string][]*Item) // transactions by wallet ID
+		missing = make(map[string]bool)    // wallets which do not exist
+	)
+	for _, entry := range req.Entries {
+		id := wallets[entry]
+		if len(id) == 0 || missing[id] || items[id] != nil {
+			continue
+		}
+
+		v, err := getItems(ctx, id)
+		switch {
+		case errors.Is(err, ledger.ErrEntryNotFound):
+			missing[id] = true
+		case err != nil:
+			return nil, errors.Wrapf(err, "unable to retrieve transactions of %s", id)
+		default:
+			items[id] = append([]*Item{}, v...)
+		}
+	}
+
+	results := make([]*Result, len(req.Entries))
+	paired := make(map[*Item]bool)
+
+	// pair matching entries first, so mismatched entries do not take transactions of matching ones.
+	for _, pass := range []Status{StatusMatched, StatusMismatched} {
+		for i, entry := range req.Entries {
+			if results[i] != nil {
+				continue
+			}
+
+			id := wallets[entry]
+			switch {
+			case len(id) == 0:
+				results[i] = &Result{Status: StatusUnmatched, Entry: entry, Reason: "no wallet reference"}
+				continue
+			case missing[id]:
+				results[i] = &Result{Status: StatusUnmatched, Entry: entry, Reason: "wallet not found"}
+				continue
+			}
+
+			if item := pair(entry, items[id], paired, tolerance, pass == StatusMatched); item != nil {
+				paired[item] = true
+				results[i] = &Result{Status: pass, Entry: entry, Item: item}
+				if pass == StatusMismatched {
+					results[i].Reason = "amount differs"
+				}
+			}
+		}
+	}
+
+	var report Report
+	for i, result := range results {
+		if result == nil {
+			result = &Result{Status: StatusUnmatched, Entry: req.Entries[i], Reason: "no ledger transaction"}
+		}
+		report.add(result)
+	}
+
+	// transactions of referenced wallets within statement period left without a pair
+	from, to := period(req.Entries)
+	var unpaired []*Item
+	for _, v := range items {
+		for _, item := range v {
+			if !paired[item] && !item.Date.Before(from.Add(-tolerance)) && !item.Date.After(to.Add(tolerance)) {
+				unpaired = append(unpaired, item)
+			}
+		}
+	}
+	sort.Slice(unpaired, func(i, j int) bool {
+		return unpaired[i].Date.Before(unpaired[j].Date)
+	})
+	for _, item := range unpaired {
+		report.add(&Result{Status: StatusUnmatched, Item: item, Reason: "no statement entry"})
+	}
+
+	return &report, nil
+}
+
+// pair returns not yet paired transaction closest in time to the entry within tolerance,
+// transaction of external reference equal to the entry reference is preferred.
+// Transaction must be of the same direction as the entry and, if exact, of the same amount.
+func pair(entry *Entry, items []*Item, paired map[*Item]bool, tolerance time.Duration, exact bool) *Item {
+	var (
+		best       *Item
+		diff       time.Duration
+		referenced bool
+	)
+	for _, item := range items {
+		if paired[item] || (item.Amount < 0) != (entry.Amount < 0) {
+			continue
+		}
+		if exact && item.Amount != entry.Amount {
+			continue
+		}
+
+		d := item.Date.Sub(entry.Date)
+		if d < 0 {
+			d = -d
+		}
+		if d > tolerance {
+			continue
+		}
+
+		ref := len(item.ExternalReference) > 0 && item.ExternalReference == entry.Reference
+		if best == nil || (ref && !referenced) || (ref == referenced && d < diff) {
+			best, diff, referenced = item, d, ref
+		}
+	}
+	return best
+}
+
+// period returns the first and the last entries dates, the last date is inclusive.
+func period(entries []*Entry) (time.Time, time.Time) {
+	var from, to time.Time
+	for i, entry := range entries {
+		if i == 0 || entry.Date.Before(from) {
+			from = entry.Date
+		}
+		if i == 0 || entry.Date.After(to) {
+			to = entry.Date
+		}
+	}
+	return from, to.Add(24 * time.Hour)
+}
@@ -0,0 +1,257 @@
+package reconcile
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+const (
+	walletA = "a18c247b-8c28-468f-97a8-0bf33a48b922"
+	walletB = "3f1a0e0a-5a41-4d8e-8a0e-2f9f3c1b7d10"
+	walletC = "7b9db2f1-6777-4a9e-ac3d-efe0f7456a44"
+)
+
+// date returns time of given date and hour.
+func date(s string, hour int) time.Time {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t.Add(time.Duration(hour) * time.Hour)
+}
+
+func TestParseAmount(t *testing.T) {
+	var testcases = []struct {
+		amount string
+		cents  int
+		err    bool
+	}{
+		{amount: "50.00", cents: 5000},
+		{amount: "50", cents: 5000},
+		{amount: "0.5", cents: 50},
+		{amount: "-12.50", cents: -1250},
+		{amount: "+1.01", cents: 101},
+		{amount: " 7.25 ", cents: 725},
+		{amount: "1.001", err: true},
+		{amount: "1,00", err: true},
+		{amount: ".50", err: true},
+		{amount: "", err: true},
+		{amount: "1.-5", err: true},
+	}
+
+	for i, tt := range testcases {
+		cents, err := parseAmount(tt.amount)
+		if (err != nil) != tt.err {
+			t.Errorf("#%d got %v, want error %v", i, err, tt.err)
+		}
+		if cents != tt.cents {
+			t.Errorf("#%d got %v, want %v", i, cents, tt.cents)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	var testcases = []struct {
+		file   string
+		format Format
+		want   []*Entry
+	}{
+		{
+			file:   "testdata/statement.csv",
+			format: FormatCSV,
+			want: []*Entry{
+				{Reference: walletA, Description: "Top up", Amount: 5000, Date: date("2024-03-01", 0)},
+				{Reference: walletA, Description: "Payout", Amount: -1250, Date: date("2024-03-02", 0)},
+				{Reference: "INV-2024-77", Description: "Wallet " + walletB, Amount: 3000, Date: date("2024-03-03", 0)},
+				{Reference: "no reference", Amount: 1000, Date: date("2024-03-04", 0)},
+			},
+		},
+		{
+			file:   "testdata/statement.camt053.xml",
+			format: FormatCamt053,
+			want: []*Entry{
+				{Reference: walletA, Description: "Top up", Amount: 5000, Date: date("2024-03-01", 0)},
+				{Reference: walletA, Description: "Payout", Amount: -1250, Date: date("2024-03-02", 0).Add(10*time.Hour + 30*time.Minute)},
+				{Reference: "INV-2024-77", Description: "Wallet " + walletB, Amount: 3000, Date: date("2024-03-03", 0)},
+				{Reference: "no reference", Amount: 1000, Date: date("2024-03-04", 0)},
+			},
+		},
+	}
+
+	for i, tt := range testcases {
+		f, err := os.Open(tt.file)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+
+		entries, err := Parse(f, tt.format)
+		f.Close()
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+
+		if len(entries) != len(tt.want) {
+			t.Fatalf("#%d got %v entries, want %v", i, len(entries), len(tt.want))
+		}
+		for j := range entries {
+			got, want := entries[j], tt.want[j]
+			if got.Reference != want.Reference || got.Description != want.Description || got.Amount != want.Amount || !got.Date.Equal(want.Date) {
+				t.Errorf("#%d.%d got %+v, want %+v", i, j, got, want)
+			}
+		}
+	}
+}
+
+func TestParseNotValidStatement(t *testing.T) {
+	var testcases = []struct {
+		statement string
+		format    Format
+	}{
+		{statement: "", format: FormatCSV},
+		{statement: "date,amount\n2024-03-01,1.00\n", format: FormatCSV},
+		{statement: "date,reference,amount\n01/03/2024,ref,1.00\n", format: FormatCSV},
+		{statement: "date,reference,amount\n2024-03-01,ref,1.000\n", format: FormatCSV},
+		{statement: "<Document>", format: FormatCamt053},
+		{statement: "<Document><BkToCstmrStmt><Stmt><Ntry><Amt>1.00</Amt><CdtDbtInd>X</CdtDbtInd><BookgDt><Dt>2024-03-01</Dt></BookgDt></Ntry></Stmt></BkToCstmrStmt></Document>", format: FormatCamt053},
+		{statement: "<Document><BkToCstmrStmt><Stmt><Ntry><Amt>1.00</Amt><CdtDbtInd>CRDT</CdtDbtInd></Ntry></Stmt></BkToCstmrStmt></Document>", format: FormatCamt053},
+	}
+
+	for i, tt := range testcases {
+		_, err := Parse(strings.NewReader(tt.statement), tt.format)
+		if !errors.Is(err, ErrNotValidStatement) {
+			t.Errorf("#%d got %v, want %v", i, err, ErrNotValidStatement)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	transactions := map[string][]*Item{
+		walletA: {
+			{WalletID: walletA, Version: 2, Type: ledger.TransactionDeposit, Amount: 100, Date: date("2024-01-01", 9)}, // outside statement period
+			{WalletID: walletA, Version: 3, Type: ledger.TransactionDeposit, Amount: 5000, Date: date("2024-02-29", 23)},
+			{WalletID: walletA, Version: 4, Type: ledger.TransactionWithdraw, Amount: -1250, Date: date("2024-03-02", 11)},
+			{WalletID: walletA, Version: 5, Type: ledger.TransactionDeposit, Amount: 700, Date: date("2024-03-03", 8)},
+		},
+		walletB: {
+			{WalletID: walletB, Version: 2, Type: ledger.TransactionDeposit, Amount: 3100, Date: date("2024-03-02", 15)},
+		},
+	}
+
+	getItems := func(ctx context.Context, id string) ([]*Item, error) {
+		items, ok := transactions[id]
+		if !ok {
+			return nil, ledger.ErrEntryNotFound
+		}
+		return items, nil
+	}
+
+	entries := []*Entry{
+		{Reference: walletA, Amount: 5000, Date: date("2024-03-01", 0)},
+		{Reference: walletA, Amount: -1250, Date: date("2024-03-02", 0)},
+		{Reference: "INV-2024-77", Description: "Wallet " + walletB, Amount: 3000, Date: date("2024-03-03", 0)},
+		{Reference: "no reference", Amount: 1000, Date: date("2024-03-04", 0)},
+		{Reference: walletC, Amount: 1000, Date: date("2024-03-04", 0)},
+		{Reference: walletA, Amount: 5000, Date: date("2024-03-15", 0)}, // duplicate beyond tolerance
+	}
+
+	report, err := Reconcile(context.Background(), getItems, nil, &Request{Entries: entries})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	var want = []struct {
+		status  Status
+		entry   *Entry
+		version uint64 // paired transaction version, 0 if none
+	}{
+		{status: StatusMatched, entry: entries[0], version: 3},
+		{status: StatusMatched, entry: entries[1], version: 4},
+		{status: StatusMismatched, entry: entries[2], version: 2},
+		{status: StatusUnmatched, entry: entries[3]},
+		{status: StatusUnmatched, entry: entries[4]},
+		{status: StatusUnmatched, entry: entries[5]},
+		{status: StatusUnmatched, version: 5},
+	}
+
+	if len(report.Results) != len(want) {
+		t.Fatalf("got %v results, want %v", len(report.Results), len(want))
+	}
+	for i, w := range want {
+		got := report.Results[i]
+
+		var version uint64
+		if got.Item != nil {
+			version = got.Item.Version
+		}
+		if got.Status != w.status || got.Entry != w.entry || version != w.version {
+			t.Errorf("#%d got %v %+v %v, want %v %+v %v", i, got.Status, got.Entry, version, w.status, w.entry, w.version)
+		}
+	}
+
+	if report.Matched != 2 || report.Mismatched != 1 || report.Unmatched != 4 {
+		t.Errorf("got %d/%d/%d, want %d/%d/%d", report.Matched, report.Mismatched, report.Unmatched, 2, 1, 4)
+	}
+}
+
+func TestReconcileExternalReference(t *testing.T) {
+	items := []*Item{
+		{WalletID: walletB, Version: 2, Type: ledger.TransactionDeposit, Amount: 3000, Date: date("2024-03-03", 9)},
+		{WalletID: walletB, Version: 3, Type: ledger.TransactionDeposit, Amount: 3000, Date: date("2024-03-03", 12), ExternalReference: "INV-2024-77"},
+	}
+
+	getItems := func(ctx context.Context, id string) ([]*Item, error) {
+		if id != walletB {
+			return nil, ledger.ErrEntryNotFound
+		}
+		return items, nil
+	}
+	findWallet := func(ctx context.Context, reference string) (string, error) {
+		if reference == "INV-2024-77" {
+			return walletB, nil
+		}
+		return "", nil
+	}
+
+	entries := []*Entry{
+		{Reference: "INV-2024-77", Amount: 3000, Date: date("2024-03-03", 0)},
+		{Reference: "INV-2024-78", Amount: 3000, Date: date("2024-03-03", 0)},
+	}
+
+	report, err := Reconcile(context.Background(), getItems, findWallet, &Request{Entries: entries})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	var want = []struct {
+		status  Status
+		entry   *Entry
+		version uint64 // paired transaction version, 0 if none
+	}{
+		// transaction of the same reference is preferred over the closer one
+		{status: StatusMatched, entry: entries[0], version: 3},
+		{status: StatusUnmatched, entry: entries[1]},
+		{status: StatusUnmatched, version: 2},
+	}
+
+	if len(report.Results) != len(want) {
+		t.Fatalf("got %v results, want %v", len(report.Results), len(want))
+	}
+	for i, w := range want {
+		got := report.Results[i]
+
+		var version uint64
+		if got.Item != nil {
+			version = got.Item.Version
+		}
+		if got.Status != w.status || got.Entry != w.entry || version != w.version {
+			t.Errorf("#%d got %v %+v %v, want %v %+v %v", i, got.Status, got.Entry, version, w.status, w.entry, w.version)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// Format represents bank statement file format.
+type Format string
+
+// Supported statement formats.
+const (
+	FormatCSV     Format = "csv"     // CSV with date, reference, amount and optional description columns
+	FormatCamt053 Format = "camt053" // ISO 20022 camt.053 bank to customer statement
+)
+
+// ErrNotValidFormat is returned when statement format is not supported.
+var ErrNotValidFormat = errors.New("given statement format is not supported")
+
+// ErrNotValidStatement is returned when statement file can not be parsed.
+var ErrNotValidStatement = errors.New("given statement is not a valid statement")
+
+// Validate implements validator.Validator.
+func (f Format) Validate() error {
+	switch f {
+	case FormatCSV, FormatCamt053:
+		return nil
+	default:
+		return ErrNotValidFormat
+	}
+}
+
+// dateLayout is layout of statement dates.
+const dateLayout = "2006-01-02"
+
+// Entry represents a single bank statement entry.
+type Entry struct {
+	Reference   string    // Payment reference
+	Description string    // Free text describing the payment, e.g. unstructured remittance information
+	Amount      int       // Amount in cents, credits are positive and debits are negative
+	Date        time.Time // Booking date
+}
+
+// Parse parses statement entries of given format read from r.
+func Parse(r io.Reader, format Format) ([]*Entry, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(r)
+	case FormatCamt053:
+		return parseCamt053(r)
+	default:
+		return nil, ErrNotValidFormat
+	}
+}
+
+// parseCSV parses CSV statement. First row must be a header naming date, reference, amount
+// and optionally description columns in any order, other columns are ignored.
+// Dates are formatted as 2006-01-02 and amounts as decimal numbers with up to 2 fraction digits.
+func parseCSV(r io.Reader) ([]*Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.Wrap(ErrNotValidStatement, "missing header")
+	}
+
+	columns := map[string]int{"description": -1}
+	for i, v := range header {
+		columns[strings.ToLower(strings.TrimSpace(v))] = i
+	}
+	for _, v := range []string{"date", "reference", "amount"} {
+		if _, ok := columns[v]; !ok {
+			return nil, errors.Wrapf(ErrNotValidStatement, "missing %s column", v)
+		}
+	}
+
+	var entries []*Entry
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(ErrNotValidStatement, "line %d: %v", line, err)
+		}
+
+		// value returns column value or empty string if row is too short.
+		value := func(column string) string {
+			if i := columns[column]; i >= 0 && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		date, err := time.Parse(dateLayout, value("date"))
+		if err != nil {
+			return nil, errors.Wrapf(ErrNotValidStatement, "line %d: date: %v", line, err)
+		}
+
+		amount, err := parseAmount(value("amount"))
+		if err != nil {
+			return nil, errors.Wrapf(ErrNotValidStatement, "line %d: amount: %v", line, err)
+		}
+
+		entries = append(entries, &Entry{
+			Reference:   value("reference"),
+			Description: value("description"),
+			Amount:      amount,
+			Date:        date,
+		})
+	}
+}
+
+// camt053 represents subset of ISO 20022 camt.053 document relevant for reconciliation.
+type camt053 struct {
+	Statements []struct {
+		Entries []struct {
+			Amount      string   `xml:"Amt"`
+			Indicator   string   `xml:"CdtDbtInd"`
+			BookingDate camtDate `xml:"BookgDt"`
+			ValueDate   camtDate `xml:"ValDt"`
+			Reference   string   `xml:"AcctSvcrRef"`
+			Info        string   `xml:"AddtlNtryInf"`
+			Details     []struct {
+				Transactions []struct {
+					EndToEndID   string   `xml:"Refs>EndToEndId"`
+					Unstructured []string `xml:"RmtInf>Ustrd"`
+				} `xml:"TxDtls"`
+			} `xml:"NtryDtls"`
+		} `xml:"Ntry"`
+	} `xml:"BkToCstmrStmt>Stmt"`
+}
+
+// camtDate represents camt.053 date choice.
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+// parse parses date choice, it returns zero time if date is not set.
+func (d *camtDate) parse() (time.Time, error) {
+	switch {
+	case len(d.Date) > 0:
+		return time.Parse(dateLayout, d.Date)
+	case len(d.DateTime) > 0:
+		if t, err := time.Parse(time.RFC3339, d.DateTime); err == nil {
+			return t, nil
+		}
+		return time.Parse("2006-01-02T15:04:05", d.DateTime)
+	default:
+		return time.Time{}, nil
+	}
+}
+
+// notProvided is a placeholder used by banks when end to end reference is not provided.
+const notProvided = "NOTPROVIDED"
+
+// parseCamt053 parses camt.053 statement producing an entry for every statement entry.
+// Entry reference is the end to end reference of the first transaction details, falling back to
+// account servicer reference, while description holds unstructured remittance information.
+func parseCamt053(r io.Reader) ([]*Entry, error) {
+	var doc camt053
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, errors.Wrapf(ErrNotValidStatement, "%v", err)
+	}
+
+	var entries []*Entry
+	for _, stmt := range doc.Statements {
+		for i, v := range stmt.Entries {
+			amount, err := parseAmount(v.Amount)
+			if err != nil {
+				return nil, errors.Wrapf(ErrNotValidStatement, "entry %d: amount: %v", i+1, err)
+			}
+
+			switch v.Indicator {
+			case "CRDT":
+			case "DBIT":
+				amount = -amount
+			default:
+				return nil, errors.Wrapf(ErrNotValidStatement, "entry %d: credit debit indicator %q", i+1, v.Indicator)
+			}
+
+			date, err := v.BookingDate.parse()
+			if err == nil && date.IsZero() {
+				date, err = v.ValueDate.parse()
+			}
+			if err != nil || date.IsZero() {
+				return nil, errors.Wrapf(ErrNotValidStatement, "entry %d: missing or not valid date", i+1)
+			}
+
+			entry := Entry{
+				Reference: strings.TrimSpace(v.Reference),
+				Amount:    amount,
+				Date:      date,
+			}
+
+			var (
+				description []string
+				referenced  bool
+			)
+			for _, details := range v.Details {
+				for _, tx := range details.Transactions {
+					if ref := strings.TrimSpace(tx.EndToEndID); !referenced && len(ref) > 0 && ref != notProvided {
+						entry.Reference, referenced = ref, true
+					}
+					description = append(description, tx.Unstructured...)
+				}
+			}
+			if len(description) == 0 && len(v.Info) > 0 {
+				description = append(description, v.Info)
+			}
+			entry.Description = strings.TrimSpace(strings.Join(description, " "))
+
+			entries = append(entries, &entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// parseAmount parses decimal amount with up to 2 fraction digits into cents.
+func parseAmount(s string) (int, error) {
+	s = strings.TrimSpace(s)
+
+	var negative bool
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative, s = true, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	units, fraction, _ := strings.Cut(s, ".")
+	if len(units) == 0 || len(fraction) > 2 {
+		return 0, errors.Newf("not a valid amount: %q", s)
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+
+	var cents int
+	for _, part := range []string{units, fraction} {
+		v, err := strconv.ParseUint(part, 10, 31)
+		if err != nil {
+			return 0, errors.Newf("not a valid amount: %q", s)
+		}
+		cents = cents*100 + int(v)
+	}
+	if negative {
+		cents = -cents
+	}
+	return cents, nil
+}
@@ -0,0 +1,178 @@
+package scheduler
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+// ErrNotValidCron is returned when recurrence expression is not valid.
+var ErrNotValidCron = errors.New("given recurrence is not a valid cron expression")
+
+// descriptors maps supported shorthands into cron expressions.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field represents bounds of cron expression field.
+type field struct {
+	min, max int
+}
+
+// cron fields in expression order.
+var fields = []field{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 6},  // day of week, 0 is Sunday
+}
+
+// Cron represents parsed cron expression of five fields: minute, hour, day of month, month and day of week.
+// Fields support *, lists, ranges and steps, e.g. "0 9 1,15 * *" or "*/30 8-18 * * 1-5".
+// As in cron, when both day of month and day of week are restricted, time matching either of them matches.
+type Cron struct {
+	minute, hour, dom, month, dow uint64 // bit sets of matching values
+	anyDOM, anyDOW                bool   // day of month or day of week is not restricted
+}
+
+// ParseCron parses cron expression or one of the descriptors: @yearly, @monthly, @weekly, @daily, @hourly.
+func ParseCron(spec string) (*Cron, error) {
+	spec = strings.TrimSpace(spec)
+	if v, ok := descriptors[spec]; ok {
+		spec = v
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, errors.Wrapf(ErrNotValidCron, "expected %d fields, got %d", len(fields), len(parts))
+	}
+
+	var sets [5]uint64
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "%q", part)
+		}
+		sets[i] = set
+	}
+
+	// 7 is an alias of Sunday
+	if sets[4]&(1<<7) != 0 {
+		sets[4] = sets[4]&^(1<<7) | 1
+	}
+
+	return &Cron{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		anyDOM: parts[2] == "*",
+		anyDOW: parts[4] == "*",
+	}, nil
+}
+
+// parseField parses comma separated list of field values into a bit set.
+func parseField(spec string, f field) (uint64, error) {
+	max := f.max
+	if f.min == 0 && f.max == 6 {
+		max = 7 // day of week allows 7 for Sunday
+	}
+
+	var set uint64
+	for _, item := range strings.Split(spec, ",") {
+		from, to, step := f.min, f.max, 1
+
+		if i := strings.Index(item, "/"); i >= 0 {
+			v, err := strconv.Atoi(item[i+1:])
+			if err != nil || v < 1 {
+				return 0, ErrNotValidCron
+			}
+			step, item = v, item[:i]
+		}
+
+		switch {
+		case item == "*":
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, ErrNotValidCron
+			}
+			if to, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, ErrNotValidCron
+			}
+		default:
+			v, err := strconv.Atoi(item)
+			if err != nil {
+				return 0, ErrNotValidCron
+			}
+			from, to = v, v
+			if step > 1 {
+				to = f.max
+			}
+		}
+
+		if from < f.min || to > max || from > to {
+			return 0, ErrNotValidCron
+		}
+		for v := from; v <= to; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// has reports whether value v is within the set.
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+// day reports whether the day of t matches.
+func (c *Cron) day(t time.Time) bool {
+	dom, dow := has(c.dom, t.Day()), has(c.dow, int(t.Weekday()))
+	switch {
+	case c.anyDOM && c.anyDOW:
+		return true
+	case c.anyDOM:
+		return dow
+	case c.anyDOW:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// maxYears bounds search of the next matching time, e.g. of "0 0 30 2 *" which never matches.
+const maxYears = 5
+
+// Next returns the first matching time after t with minute precision.
+// Zero time is returned if expression does not match any time within the following years.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxYears, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case !has(c.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.day(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !has(c.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !has(c.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+func TestCronNext(t *testing.T) {
+	date := func(v string) time.Time {
+		d, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	var testcases = []struct {
+		spec  string
+		after string
+
+		next string
+	}{
+		{"@monthly", "2024-01-15T10:00:00Z", "2024-02-01T00:00:00Z"},
+		{"0 9 1 * *", "2024-01-01T09:00:00Z", "2024-02-01T09:00:00Z"},
+		{"0 9 1 * *", "2024-01-01T08:59:30Z", "2024-01-01T09:00:00Z"},
+		{"*/15 * * * *", "2024-01-01T10:07:00Z", "2024-01-01T10:15:00Z"},
+		{"30 8-18/5 * * *", "2024-01-01T13:31:00Z", "2024-01-01T18:30:00Z"},
+		{"0 0 * * 1-5", "2024-01-05T12:00:00Z", "2024-01-08T00:00:00Z"}, // friday to monday
+		{"0 0 * * 7", "2024-01-01T00:00:00Z", "2024-01-07T00:00:00Z"},   // 7 is sunday
+		{"0 0 13 * 5", "2024-01-01T00:00:00Z", "2024-01-05T00:00:00Z"},  // 13th or friday
+		{"0 0 29 2 *", "2024-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},  // leap day
+		{"0 0 31 * *", "2024-04-01T00:00:00Z", "2024-05-31T00:00:00Z"},
+		{"0 0 30 2 *", "2024-01-01T00:00:00Z", "0001-01-01T00:00:00Z"}, // never
+	}
+
+	for i, tt := range testcases {
+		cron, err := ParseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if next := cron.Next(date(tt.after)); !next.Equal(date(tt.next)) {
+			t.Errorf("#%d got %v, want %v", i, next, tt.next)
+		}
+	}
+}
+
+func TestParseCronNotValid(t *testing.T) {
+	for i, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@every 1h",
+	} {
+		if _, err := ParseCron(spec); !errors.Is(err, ErrNotValidCron) {
+			t.Errorf("#%d got %v, want %v", i, err, ErrNotValidCron)
+		}
+	}
+}
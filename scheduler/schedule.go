@@ -0,0 +1,455 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+	"github.com/deividaspetraitis/ledger/database/schema"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+	"github.com/deividaspetraitis/go/validator"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+)
+
+// init initialises program state.
+// register schedule aggregate along its events.
+func init() {
+	for _, event := range []func() es.MarshalUnmarshaler{
+		func() es.MarshalUnmarshaler { return &ScheduleCreated{} },
+		func() es.MarshalUnmarshaler { return &SchedulePaused{} },
+		func() es.MarshalUnmarshaler { return &ScheduleResumed{} },
+		func() es.MarshalUnmarshaler { return &ScheduleCancelled{} },
+		func() es.MarshalUnmarshaler { return &OccurrenceExecuted{} },
+		func() es.MarshalUnmarshaler { return &OccurrenceFailed{} },
+		func() es.MarshalUnmarshaler { return &OccurrenceSkipped{} },
+	} {
+		es.RegisterAggregateEvent(&ScheduleAggregate{}, event)
+		schema.Register(event(), 1)
+	}
+}
+
+// Common schedule errors.
+var (
+	ErrNotValidSchedule = errors.New("given schedule is not valid")
+	ErrNotValidStatus   = errors.New("operation is not allowed in current schedule status")
+)
+
+// Status represents schedule status.
+type Status string
+
+// Schedule statuses.
+const (
+	StatusActive    Status = "active"    // occurrences are executed when due
+	StatusPaused    Status = "paused"    // occurrences are not executed until schedule is resumed
+	StatusCancelled Status = "cancelled" // schedule will never be executed again
+	StatusCompleted Status = "completed" // schedule has no more occurrences
+)
+
+// CreateScheduleRequest represents a request for creating a new schedule.
+type CreateScheduleRequest struct {
+	Type     string    // Transaction type, see ledger.TransactionDeposit and ledger.TransactionWithdraw
+	WalletID string    // Wallet identifier for the transaction
+	Amount   int       // Amount for the transaction
+	Cron     string    // Recurrence cron expression, empty for one-off schedule
+	Start    time.Time // Time of one-off transaction or time recurrence starts at, defaults to now
+}
+
+// Validate implements validator.Validator.
+func (r *CreateScheduleRequest) Validate() error {
+	if _, err := uuid.Parse(r.WalletID); err != nil {
+		return ledger.ErrNotValidWalletID
+	}
+
+	if !slices.Contains([]string{ledger.TransactionDeposit, ledger.TransactionWithdraw}, strings.ToUpper(r.Type)) {
+		return ledger.ErrNotValidTransaction
+	}
+
+	if r.Amount <= 0 {
+		return ledger.ErrNotValidAmount
+	}
+
+	if len(r.Cron) > 0 {
+		if _, err := ParseCron(r.Cron); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ScheduleCreated represents an event emitted when a schedule is created.
+type ScheduleCreated struct {
+	ID          string    `json:"id"`
+	WalletID    string    `json:"wallet_id"`
+	Transaction string    `json:"transaction"`
+	Amount      int       `json:"amount"`
+	Cron        string    `json:"cron,omitempty"`
+	Start       time.Time `json:"start"`
+}
+
+// Implements es.MarshalUnmarshaler
+func (e *ScheduleCreated) UnmarshalJSON(b []byte) error {
+	type event ScheduleCreated
+	temp := event(*e)
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+	*e = ScheduleCreated(temp)
+	return nil
+}
+
+// Implements es.MarshalUnmarshaler
+func (e *ScheduleCreated) MarshalJSON() ([]byte, error) {
+	type event ScheduleCreated
+	return json.Marshal(event(*e))
+}
+
+// SchedulePaused represents an event emitted when a schedule is paused.
+type SchedulePaused struct {
+	ID string    `json:"id"`
+	At time.Time `json:"at"`
+}
+
+// Implements es.MarshalUnmarshaler
+func (e *SchedulePaused) UnmarshalJSON(b []byte) error {
+	type event SchedulePaused
+	temp := event(*e)
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+	*e = SchedulePaused(temp)
+	return nil
+}
+
+// Implements es.MarshalUnmarshaler
+func (e *SchedulePaused) MarshalJSON() ([]byte, error) {
+	type event SchedulePaused
+	return json.Marshal(event(*e))
+}
+
+// ScheduleResumed represents an event emitted when a paused schedule is resumed.
+type ScheduleResumed struct {
+	ID string    `json:"id"`
+	At time.Time `json:"at"`
+}
+
+// Implements es.MarshalUnmarshaler
+func (e *ScheduleResumed) UnmarshalJSON(b []byte) error {
+	type event ScheduleResumed
+	temp := event(*e)
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+	*e = ScheduleResumed(temp)
+	return nil
+}
+
+// Implements es.MarshalUnmarshaler
+func (e *ScheduleResumed) MarshalJSON() ([]byte, error) {
+	type event ScheduleResumed
+	return json.Marshal(event(*e))
+}
+
+// ScheduleCancelled represents an event emitted when a schedule is cancelled.
+type ScheduleCancelled struct {
+	ID string    `json:"id"`
+	At time.Time `json:"at"`
+}
+
+// Implements es.MarshalUnmarshaler
+func (e *ScheduleCancelled) UnmarshalJSON(b []byte) error {
+	type event ScheduleCancelled
+	temp := event(*e)
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+	*e = ScheduleCancelled(temp)
+	return nil
+}
+
+// Implements es.MarshalUnmarshaler
+func (e *ScheduleCancelled) MarshalJSON() ([]byte, error) {
+	type event ScheduleCancelled
+	return json.Marshal(event(*e))
+}
+
+// OccurrenceExecuted represents an event emitted when transaction of the occurrence is processed.
+type OccurrenceExecuted struct {
+	ID         string    `json:"id"`
+	Occurrence time.Time `json:"occurrence"`
+	Key        string    `json:"key"` // Idempotency key of the transaction
+}
+
+// Implements es.MarshalUnmarshaler
+func (e *OccurrenceExecuted) UnmarshalJSON(b []byte) error {
+	type event OccurrenceExecuted
+	temp := event(*e)
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+	*e = OccurrenceExecuted(temp)
+	return nil
+}
+
+// Implements es.MarshalUnmarshaler
+func (e *OccurrenceExecuted) MarshalJSON() ([]byte, error) {
+	type event OccurrenceExecuted
+	return json.Marshal(event(*e))
+}
+
+// OccurrenceFailed represents an event emitted when transaction of the occurrence failed and is going to be retried.
+type OccurrenceFailed struct {
+	ID         string    `json:"id"`
+	Occurrence time.Time `json:"occurrence"`
+	Attempt    int       `json:"attempt"`
+	Reason     string    `json:"reason"`
+	RetryAt    time.Time `json:"retry_at"`
+}
+
+// Implements es.MarshalUnmarshaler
+func (e *OccurrenceFailed) UnmarshalJSON(b []byte) error {
+	type event OccurrenceFailed
+	temp := event(*e)
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+	*e = OccurrenceFailed(temp)
+	return nil
+}
+
+// Implements es.MarshalUnmarshaler
+func (e *OccurrenceFailed) MarshalJSON() ([]byte, error) {
+	type event OccurrenceFailed
+	return json.Marshal(event(*e))
+}
+
+// OccurrenceSkipped represents an event emitted when the occurrence is given up.
+type OccurrenceSkipped struct {
+	ID         string    `json:"id"`
+	Occurrence time.Time `json:"occurrence"`
+	Reason     string    `json:"reason"`
+}
+
+// Implements es.MarshalUnmarshaler
+func (e *OccurrenceSkipped) UnmarshalJSON(b []byte) error {
+	type event OccurrenceSkipped
+	temp := event(*e)
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return err
+	}
+	*e = OccurrenceSkipped(temp)
+	return nil
+}
+
+// Implements es.MarshalUnmarshaler
+func (e *OccurrenceSkipped) MarshalJSON() ([]byte, error) {
+	type event OccurrenceSkipped
+	return json.Marshal(event(*e))
+}
+
+// Schedule represents current state of the schedule.
+type Schedule struct {
+	ID        string    // Unique schedule identifier
+	WalletID  string    // Wallet identifier for the transaction
+	Type      string    // Transaction type
+	Amount    int       // Amount for the transaction
+	Cron      string    // Recurrence cron expression, empty for one-off schedule
+	Start     time.Time // Time of one-off transaction or time recurrence starts at
+	Status    Status    // Schedule status
+	Next      time.Time // Next occurrence, zero if there are no more occurrences
+	Attempts  int       // Failed attempts of the next occurrence
+	RetryAt   time.Time // Time of the next attempt of failed occurrence
+	Executed  int       // Number of executed occurrences
+	LastError string    // Reason of the last failed or skipped occurrence
+
+	cron *Cron
+}
+
+// Due reports whether next occurrence should be executed at the given time.
+func (s *Schedule) Due(now time.Time) bool {
+	if s.Status != StatusActive || s.Next.IsZero() || s.Next.After(now) {
+		return false
+	}
+	return s.RetryAt.IsZero() || !s.RetryAt.After(now)
+}
+
+// Key returns deterministic idempotency key of the transaction of the occurrence.
+func (s *Schedule) Key(occurrence time.Time) string {
+	return "schedule:" + s.ID + ":" + occurrence.UTC().Format(time.RFC3339)
+}
+
+// advance moves schedule to the occurrence following given one.
+func (s *Schedule) advance(occurrence time.Time) {
+	s.Attempts, s.RetryAt = 0, time.Time{}
+
+	if s.cron == nil {
+		s.Next = time.Time{}
+	} else {
+		s.Next = s.cron.Next(occurrence)
+	}
+
+	if s.Next.IsZero() && s.Status == StatusActive {
+		s.Status = StatusCompleted
+	}
+}
+
+// on applies given event to the schedule to update its state.
+func (s *Schedule) on(event *es.Event) error {
+	switch e := event.Data.(type) {
+	case *ScheduleCreated:
+		*s = Schedule{
+			ID:       e.ID,
+			WalletID: e.WalletID,
+			Type:     e.Transaction,
+			Amount:   e.Amount,
+			Cron:     e.Cron,
+			Start:    e.Start,
+			Status:   StatusActive,
+			Next:     e.Start,
+		}
+		if len(e.Cron) > 0 {
+			cron, err := ParseCron(e.Cron)
+			if err != nil {
+				return err
+			}
+			s.cron = cron
+			// first occurrence at or after the start
+			s.Next = cron.Next(e.Start.Add(-time.Nanosecond))
+			if s.Next.IsZero() {
+				s.Status = StatusCompleted
+			}
+		}
+	case *SchedulePaused:
+		s.Status = StatusPaused
+	case *ScheduleResumed:
+		s.Status = StatusActive
+		s.Attempts, s.RetryAt = 0, time.Time{}
+		// occurrences missed while paused are not caught up
+		for s.cron != nil && !s.Next.IsZero() && s.Next.Before(e.At) {
+			s.advance(s.Next)
+		}
+	case *ScheduleCancelled:
+		s.Status = StatusCancelled
+	case *OccurrenceExecuted:
+		s.Executed++
+		s.LastError = ""
+		s.advance(e.Occurrence)
+	case *OccurrenceFailed:
+		s.Attempts, s.RetryAt, s.LastError = e.Attempt, e.RetryAt, e.Reason
+	case *OccurrenceSkipped:
+		s.LastError = e.Reason
+		s.advance(e.Occurrence)
+	default:
+		return errors.Newf("unsupported event: %#v", e)
+	}
+
+	return nil
+}
+
+// ScheduleAggregate represents Schedule's aggregate.
+type ScheduleAggregate struct {
+	es.AggregateRoot
+	Schedule
+}
+
+// NewSchedule creates and returns a new schedule starting at request start time or at now if it's not given.
+// It validates CreateScheduleRequest and if it does not pass, error will be returned instead.
+func NewSchedule(req *CreateScheduleRequest, now time.Time) (*ScheduleAggregate, error) {
+	if err := validator.Validate(req); err != nil {
+		return nil, err
+	}
+
+	start := req.Start
+	if start.IsZero() {
+		start = now
+	}
+
+	var aggregate ScheduleAggregate
+
+	id := uuid.NewString()
+
+	err := aggregate.Apply(es.NewEvent(id, &aggregate, &ScheduleCreated{
+		ID:          id,
+		WalletID:    req.WalletID,
+		Transaction: strings.ToUpper(req.Type),
+		Amount:      req.Amount,
+		Cron:        req.Cron,
+		Start:       start.UTC(),
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	return &aggregate, nil
+}
+
+// Pause pauses active schedule.
+func (s *ScheduleAggregate) Pause(at time.Time) error {
+	if s.Status != StatusActive {
+		return ErrNotValidStatus
+	}
+	return s.Apply(es.NewEvent(s.ID, s, &SchedulePaused{ID: s.ID, At: at.UTC()}))
+}
+
+// Resume resumes paused schedule, recurring occurrences missed while paused are skipped.
+func (s *ScheduleAggregate) Resume(at time.Time) error {
+	if s.Status != StatusPaused {
+		return ErrNotValidStatus
+	}
+	return s.Apply(es.NewEvent(s.ID, s, &ScheduleResumed{ID: s.ID, At: at.UTC()}))
+}
+
+// Cancel cancels schedule which is not cancelled or completed yet.
+func (s *ScheduleAggregate) Cancel(at time.Time) error {
+	if s.Status == StatusCancelled || s.Status == StatusCompleted {
+		return ErrNotValidStatus
+	}
+	return s.Apply(es.NewEvent(s.ID, s, &ScheduleCancelled{ID: s.ID, At: at.UTC()}))
+}
+
+// executed records that transaction of the next occurrence was processed.
+func (s *ScheduleAggregate) executed(key string) error {
+	return s.Apply(es.NewEvent(s.ID, s, &OccurrenceExecuted{ID: s.ID, Occurrence: s.Next, Key: key}))
+}
+
+// failed records failed attempt of the next occurrence to be retried at given time.
+func (s *ScheduleAggregate) failed(reason string, retryAt time.Time) error {
+	return s.Apply(es.NewEvent(s.ID, s, &OccurrenceFailed{
+		ID:         s.ID,
+		Occurrence: s.Next,
+		Attempt:    s.Attempts + 1,
+		Reason:     reason,
+		RetryAt:    retryAt.UTC(),
+	}))
+}
+
+// skipped records that the next occurrence was given up.
+func (s *ScheduleAggregate) skipped(reason string) error {
+	return s.Apply(es.NewEvent(s.ID, s, &OccurrenceSkipped{ID: s.ID, Occurrence: s.Next, Reason: reason}))
+}
+
+// Reply implements es.Aggregate.
+func (s *ScheduleAggregate) Reply(event []*es.Event) error {
+	if err := s.Root().Reply(event); err != nil {
+		return err
+	}
+	for _, v := range event {
+		if err := s.on(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply implements es.Aggregate.
+func (s *ScheduleAggregate) Apply(event *es.Event) error {
+	if err := s.AggregateRoot.Apply(event); err != nil {
+		return err
+	}
+	return s.on(event)
+}
@@ -0,0 +1,296 @@
+// Package scheduler implements scheduled and recurring wallet transactions, e.g. standing orders.
+//
+// Schedules are event sourced aggregates persisted along wallets. Scheduler periodically executes due occurrences
+// of active schedules as ledger transactions carrying deterministic idempotency key of the occurrence,
+// so occurrence executed more than once, e.g. after a crash or by several instances, is processed by the ledger only once.
+// Occurrences failing on insufficient balance are retried, occurrences failing permanently are skipped.
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+
+	"github.com/deividaspetraitis/go/database"
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+	"github.com/deividaspetraitis/go/log"
+)
+
+// Default scheduler configuration values.
+const (
+	defaultInterval = time.Minute
+	defaultDiscover = 10 * time.Minute
+	defaultRetry    = time.Hour
+	defaultAttempts = 3
+
+	// maxOccurrences limits occurrences of a single schedule caught up within one tick.
+	maxOccurrences = 100
+)
+
+// Config represents scheduler configuration.
+type Config struct {
+	Interval time.Duration `mapstructure:"interval"` // How often due occurrences are looked for
+	Discover time.Duration `mapstructure:"discover"` // How often persisted schedules are discovered, e.g. ones created by other instances
+	Retry    time.Duration `mapstructure:"retry"`    // Delay before occurrence failed on insufficient balance is retried
+	Attempts int           `mapstructure:"attempts"` // Maximum attempts of occurrence before it's skipped
+}
+
+// Validate implements validator.Validator.
+func (c *Config) Validate() error {
+	if c.Interval < 0 || c.Discover < 0 || c.Retry < 0 || c.Attempts < 0 {
+		return errors.New("interval, discover, retry and attempts can not be negative")
+	}
+	return nil
+}
+
+// TransactionFunc processes transaction, see ledger.CreateTransaction.
+type TransactionFunc func(context.Context, *ledger.TransactionRequest) (*ledger.Receipt, error)
+
+// IDsFunc returns IDs of all persisted aggregates of the aggregate type.
+type IDsFunc func(ctx context.Context, aggregate es.Aggregate) ([]string, error)
+
+// Scheduler manages schedules and executes their due occurrences.
+type Scheduler struct {
+	cfg         Config
+	save        database.SaveAggregateFunc
+	get         database.GetAggregateFunc[*ScheduleAggregate]
+	ids         IDsFunc
+	transaction TransactionFunc
+	now         func() time.Time
+
+	mu         sync.Mutex
+	schedules  map[string]bool // IDs of known schedules, nil until discovered
+	finished   map[string]bool // IDs of schedules which will never be executed again
+	discovered time.Time       // Last time schedules were discovered
+}
+
+// New constructs a new Scheduler persisting schedules using save, loading them using get
+// and executing occurrences using transaction. Existing schedules are discovered using ids.
+// If cfg is nil or some of its values are not set defaults are used.
+func New(cfg *Config, save database.SaveAggregateFunc, get database.GetAggregateFunc[*ScheduleAggregate], ids IDsFunc, transaction TransactionFunc) *Scheduler {
+	var c Config
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Interval <= 0 {
+		c.Interval = defaultInterval
+	}
+	if c.Discover <= 0 {
+		c.Discover = defaultDiscover
+	}
+	if c.Retry <= 0 {
+		c.Retry = defaultRetry
+	}
+	if c.Attempts < 1 {
+		c.Attempts = defaultAttempts
+	}
+
+	return &Scheduler{
+		cfg:         c,
+		save:        save,
+		get:         get,
+		ids:         ids,
+		transaction: transaction,
+		now:         time.Now,
+		finished:    make(map[string]bool),
+	}
+}
+
+// Create creates a new schedule.
+func (s *Scheduler) Create(ctx context.Context, req *CreateScheduleRequest) (*Schedule, error) {
+	schedule, err := NewSchedule(req, s.now())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.save(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.schedules != nil {
+		s.schedules[schedule.ID] = true
+	}
+	s.mu.Unlock()
+
+	return &schedule.Schedule, nil
+}
+
+// Get retrieves existing schedule.
+// If schedule does not exist ledger.ErrEntryNotFound is returned.
+func (s *Scheduler) Get(ctx context.Context, id string) (*Schedule, error) {
+	schedule, err := s.get(ctx, &ScheduleAggregate{}, id)
+	if err != nil {
+		return nil, err
+	}
+	return &schedule.Schedule, nil
+}
+
+// Pause pauses active schedule.
+func (s *Scheduler) Pause(ctx context.Context, id string) (*Schedule, error) {
+	return s.update(ctx, id, (*ScheduleAggregate).Pause)
+}
+
+// Resume resumes paused schedule.
+func (s *Scheduler) Resume(ctx context.Context, id string) (*Schedule, error) {
+	return s.update(ctx, id, (*ScheduleAggregate).Resume)
+}
+
+// Cancel cancels schedule.
+func (s *Scheduler) Cancel(ctx context.Context, id string) (*Schedule, error) {
+	return s.update(ctx, id, (*ScheduleAggregate).Cancel)
+}
+
+// update applies operation on the schedule and persists it.
+func (s *Scheduler) update(ctx context.Context, id string, op func(*ScheduleAggregate, time.Time) error) (*Schedule, error) {
+	schedule, err := s.get(ctx, &ScheduleAggregate{}, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := op(schedule, s.now()); err != nil {
+		return nil, err
+	}
+
+	if err := s.save(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	return &schedule.Schedule, nil
+}
+
+// Run executes due occurrences every configured interval until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.Tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick executes occurrences of all schedules due at the moment.
+func (s *Scheduler) Tick(ctx context.Context) {
+	ids, err := s.known(ctx)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"component": "scheduler",
+		}).Println("unable to discover schedules")
+		return
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.execute(ctx, id); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"component": "scheduler",
+				"schedule":  id,
+			}).Println("unable to execute schedule")
+		}
+	}
+}
+
+// known returns IDs of known schedules, persisted schedules are discovered every configured discover interval,
+// so schedules created by other instances or modified outside of the scheduler are picked up.
+func (s *Scheduler) known(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.schedules == nil || now.Sub(s.discovered) >= s.cfg.Discover {
+		ids, err := s.ids(ctx, &ScheduleAggregate{})
+		if err != nil {
+			return nil, err
+		}
+		s.schedules = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			if !s.finished[id] {
+				s.schedules[id] = true
+			}
+		}
+		s.discovered = now
+	}
+
+	var ids []string
+	for id := range s.schedules {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// forget stops tracking schedule which will never be executed again.
+func (s *Scheduler) forget(id string) {
+	s.mu.Lock()
+	delete(s.schedules, id)
+	s.finished[id] = true
+	s.mu.Unlock()
+}
+
+// execute executes due occurrences of the schedule persisting outcome of each of them.
+// Transient failures leave occurrence due to be executed on the next tick.
+func (s *Scheduler) execute(ctx context.Context, id string) error {
+	schedule, err := s.get(ctx, &ScheduleAggregate{}, id)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < maxOccurrences && schedule.Due(s.now()); i++ {
+		key := schedule.Key(schedule.Next)
+
+		_, err := s.transaction(ctx, &ledger.TransactionRequest{
+			Type:     schedule.Type,
+			WalletID: schedule.WalletID,
+			Amount:   schedule.Amount,
+			Key:      key,
+		})
+		switch {
+		case err == nil:
+			err = schedule.executed(key)
+		case errors.Is(err, ledger.ErrInsufficientBalance) && schedule.Attempts+1 < s.cfg.Attempts:
+			err = schedule.failed(err.Error(), s.now().Add(s.cfg.Retry))
+		case errors.Is(err, ledger.ErrInsufficientBalance), permanent(err):
+			err = schedule.skipped(err.Error())
+		default:
+			return errors.Wrap(err, "unable to process transaction")
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.save(ctx, schedule); err != nil {
+			return errors.Wrap(err, "unable to persist schedule")
+		}
+	}
+
+	if schedule.Status == StatusCancelled || schedule.Status == StatusCompleted {
+		s.forget(id)
+	}
+
+	return nil
+}
+
+// permanent reports whether transaction failed for a reason retrying won't fix.
+func permanent(err error) bool {
+	for _, target := range []error{
+		ledger.ErrEntryNotFound,
+		ledger.ErrNotValidWalletID,
+		ledger.ErrNotValidTransaction,
+		ledger.ErrNotValidAmount,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,401 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deividaspetraitis/ledger"
+
+	"github.com/deividaspetraitis/go/errors"
+	"github.com/deividaspetraitis/go/es"
+)
+
+// memStore is an in-memory schedules store.
+type memStore struct {
+	mu      sync.Mutex
+	streams map[string][]es.MarshalUnmarshaler
+}
+
+func newMemStore() *memStore {
+	return &memStore{streams: make(map[string][]es.MarshalUnmarshaler)}
+}
+
+func (s *memStore) save(ctx context.Context, aggregate es.Aggregate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range aggregate.Events() {
+		s.streams[v.AggregateID] = append(s.streams[v.AggregateID], v.Data)
+		if err := aggregate.Sync(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memStore) get(ctx context.Context, aggregate es.Aggregate, id string) (*ScheduleAggregate, error) {
+	s.mu.Lock()
+	stream := s.streams[id]
+	s.mu.Unlock()
+
+	if len(stream) == 0 {
+		return nil, ledger.ErrEntryNotFound
+	}
+
+	var events []*es.Event
+	for _, v := range stream {
+		events = append(events, es.NewEvent(id, aggregate, v))
+	}
+	if err := aggregate.Reply(events); err != nil {
+		return nil, err
+	}
+	return aggregate.(*ScheduleAggregate), nil
+}
+
+func (s *memStore) ids(ctx context.Context, aggregate es.Aggregate) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id := range s.streams {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// wallet is a fake ledger wallet processing idempotent transactions.
+type wallet struct {
+	id      string
+	balance int
+	keys    []string
+	err     error // error returned instead of processing transaction
+}
+
+func (w *wallet) transaction(ctx context.Context, req *ledger.TransactionRequest) (*ledger.Receipt, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	if req.WalletID != w.id {
+		return nil, ledger.ErrEntryNotFound
+	}
+	for _, k := range w.keys {
+		if k == req.Key {
+			return &ledger.Receipt{Wallet: ledger.Wallet{ID: w.id, Balance: w.balance}}, nil
+		}
+	}
+
+	switch req.Type {
+	case ledger.TransactionDeposit:
+		w.balance += req.Amount
+	case ledger.TransactionWithdraw:
+		if w.balance < req.Amount {
+			return nil, ledger.ErrInsufficientBalance
+		}
+		w.balance -= req.Amount
+	}
+	w.keys = append(w.keys, req.Key)
+	return &ledger.Receipt{Wallet: ledger.Wallet{ID: w.id, Balance: w.balance}}, nil
+}
+
+// newScheduler returns scheduler of the wallet with clock set to now.
+func newScheduler(w *wallet, now *time.Time) *Scheduler {
+	store := newMemStore()
+	s := New(nil, store.save, store.get, store.ids, w.transaction)
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func TestSchedulerRecurring(t *testing.T) {
+	w := &wallet{id: "a18c247b-8c28-468f-97a8-0bf33a48b922"}
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	s := newScheduler(w, &now)
+
+	schedule, err := s.Create(context.Background(), &CreateScheduleRequest{
+		Type:     "deposit",
+		WalletID: w.id,
+		Amount:   5000,
+		Cron:     "@monthly",
+		Start:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	// missed occurrences are caught up, repeated ticks execute nothing
+	s.Tick(context.Background())
+	s.Tick(context.Background())
+
+	if w.balance != 15000 {
+		t.Errorf("balance got %v, want %v", w.balance, 15000)
+	}
+
+	want := []string{
+		"schedule:" + schedule.ID + ":2024-01-01T00:00:00Z",
+		"schedule:" + schedule.ID + ":2024-02-01T00:00:00Z",
+		"schedule:" + schedule.ID + ":2024-03-01T00:00:00Z",
+	}
+	if len(w.keys) != len(want) {
+		t.Fatalf("keys got %v, want %v", w.keys, want)
+	}
+	for i := range want {
+		if w.keys[i] != want[i] {
+			t.Errorf("#%d key got %v, want %v", i, w.keys[i], want[i])
+		}
+	}
+
+	schedule, err = s.Get(context.Background(), schedule.ID)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if next := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC); !schedule.Next.Equal(next) || schedule.Executed != 3 {
+		t.Errorf("got next %v executed %v, want %v %v", schedule.Next, schedule.Executed, next, 3)
+	}
+}
+
+func TestSchedulerRetry(t *testing.T) {
+	w := &wallet{id: "a18c247b-8c28-468f-97a8-0bf33a48b922", balance: 100}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newScheduler(w, &now)
+
+	schedule, err := s.Create(context.Background(), &CreateScheduleRequest{
+		Type:     ledger.TransactionWithdraw,
+		WalletID: w.id,
+		Amount:   500,
+		Cron:     "@daily",
+	})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	var testcases = []struct {
+		after time.Duration // clock advance before the tick
+
+		attempts int
+		next     time.Time
+		balance  int
+	}{
+		// insufficient balance, retried in an hour
+		{0, 1, now, 100},
+		// retry is not due yet
+		{30 * time.Minute, 1, now, 100},
+		// second attempt fails
+		{30 * time.Minute, 2, now, 100},
+		// third attempt fails, occurrence is skipped
+		{time.Hour, 0, now.AddDate(0, 0, 1), 100},
+	}
+
+	for i, tt := range testcases {
+		now = now.Add(tt.after)
+		s.Tick(context.Background())
+
+		schedule, err = s.Get(context.Background(), schedule.ID)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if schedule.Attempts != tt.attempts || !schedule.Next.Equal(tt.next) || w.balance != tt.balance {
+			t.Errorf("#%d got attempts %v next %v balance %v, want %v %v %v", i, schedule.Attempts, schedule.Next, w.balance, tt.attempts, tt.next, tt.balance)
+		}
+	}
+
+	// next occurrence succeeds once funded
+	w.balance = 1000
+	now = time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	s.Tick(context.Background())
+	if w.balance != 500 {
+		t.Errorf("balance got %v, want %v", w.balance, 500)
+	}
+}
+
+func TestSchedulerOneOff(t *testing.T) {
+	w := &wallet{id: "a18c247b-8c28-468f-97a8-0bf33a48b922"}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newScheduler(w, &now)
+
+	schedule, err := s.Create(context.Background(), &CreateScheduleRequest{
+		Type:     ledger.TransactionDeposit,
+		WalletID: w.id,
+		Amount:   100,
+		Start:    now.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	s.Tick(context.Background())
+	if w.balance != 0 {
+		t.Errorf("balance got %v, want %v", w.balance, 0)
+	}
+
+	now = now.Add(time.Hour)
+	s.Tick(context.Background())
+	s.Tick(context.Background())
+	if w.balance != 100 {
+		t.Errorf("balance got %v, want %v", w.balance, 100)
+	}
+
+	schedule, err = s.Get(context.Background(), schedule.ID)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if schedule.Status != StatusCompleted {
+		t.Errorf("status got %v, want %v", schedule.Status, StatusCompleted)
+	}
+	if _, err := s.Cancel(context.Background(), schedule.ID); err != ErrNotValidStatus {
+		t.Errorf("got %v, want %v", err, ErrNotValidStatus)
+	}
+}
+
+func TestSchedulerDiscover(t *testing.T) {
+	w := &wallet{id: "a18c247b-8c28-468f-97a8-0bf33a48b922"}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// two instances sharing the store
+	store := newMemStore()
+	var instances []*Scheduler
+	for i := 0; i < 2; i++ {
+		s := New(&Config{Discover: time.Hour}, store.save, store.get, store.ids, w.transaction)
+		s.now = func() time.Time { return now }
+		instances = append(instances, s)
+	}
+	runner, creator := instances[0], instances[1]
+
+	runner.Tick(context.Background())
+	if _, err := creator.Create(context.Background(), &CreateScheduleRequest{
+		Type:     ledger.TransactionDeposit,
+		WalletID: w.id,
+		Amount:   100,
+		Cron:     "@hourly",
+		Start:    now,
+	}); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	var testcases = []struct {
+		elapsed time.Duration
+		balance int
+	}{
+		{0, 0},                  // schedule created by another instance is not known yet
+		{30 * time.Minute, 0},   // nor before discover interval elapses
+		{30 * time.Minute, 200}, // discovered, missed occurrence is caught up
+		{time.Hour, 300},
+	}
+
+	for i, tt := range testcases {
+		now = now.Add(tt.elapsed)
+		runner.Tick(context.Background())
+		if w.balance != tt.balance {
+			t.Errorf("#%d balance got %v, want %v", i, w.balance, tt.balance)
+		}
+	}
+}
+
+func TestSchedulerPauseResumeCancel(t *testing.T) {
+	w := &wallet{id: "a18c247b-8c28-468f-97a8-0bf33a48b922"}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newScheduler(w, &now)
+
+	schedule, err := s.Create(context.Background(), &CreateScheduleRequest{
+		Type:     ledger.TransactionDeposit,
+		WalletID: w.id,
+		Amount:   100,
+		Cron:     "@daily",
+	})
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+
+	if _, err := s.Pause(context.Background(), schedule.ID); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if _, err := s.Pause(context.Background(), schedule.ID); err != ErrNotValidStatus {
+		t.Errorf("got %v, want %v", err, ErrNotValidStatus)
+	}
+
+	// paused schedule is not executed, missed occurrences are skipped on resume
+	now = now.Add(50 * time.Hour)
+	s.Tick(context.Background())
+	if w.balance != 0 {
+		t.Errorf("balance got %v, want %v", w.balance, 0)
+	}
+
+	schedule, err = s.Resume(context.Background(), schedule.ID)
+	if err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	if next := time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC); !schedule.Next.Equal(next) {
+		t.Errorf("next got %v, want %v", schedule.Next, next)
+	}
+
+	// cancelled schedule is never executed
+	if _, err := s.Cancel(context.Background(), schedule.ID); err != nil {
+		t.Fatalf("got %v, want %v", err, nil)
+	}
+	now = now.AddDate(0, 0, 7)
+	s.Tick(context.Background())
+	if w.balance != 0 {
+		t.Errorf("balance got %v, want %v", w.balance, 0)
+	}
+	if _, err := s.Resume(context.Background(), schedule.ID); err != ErrNotValidStatus {
+		t.Errorf("got %v, want %v", err, ErrNotValidStatus)
+	}
+}
+
+func TestSchedulerFailures(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var testcases = []struct {
+		err error
+
+		next time.Time // next occurrence after the tick
+	}{
+		// permanent failure, occurrence is skipped
+		{ledger.ErrEntryNotFound, now.AddDate(0, 0, 1)},
+		// transient failure, occurrence stays due
+		{errors.New("database unavailable"), now},
+	}
+
+	for i, tt := range testcases {
+		w := &wallet{id: "a18c247b-8c28-468f-97a8-0bf33a48b922", err: tt.err}
+		s := newScheduler(w, &now)
+
+		schedule, err := s.Create(context.Background(), &CreateScheduleRequest{
+			Type:     ledger.TransactionDeposit,
+			WalletID: w.id,
+			Amount:   100,
+			Cron:     "@daily",
+		})
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+
+		s.Tick(context.Background())
+
+		schedule, err = s.Get(context.Background(), schedule.ID)
+		if err != nil {
+			t.Fatalf("#%d got %v, want %v", i, err, nil)
+		}
+		if !schedule.Next.Equal(tt.next) {
+			t.Errorf("#%d next got %v, want %v", i, schedule.Next, tt.next)
+		}
+	}
+}
+
+func TestCreateScheduleNotValid(t *testing.T) {
+	var testcases = []struct {
+		req *CreateScheduleRequest
+		err error
+	}{
+		{&CreateScheduleRequest{Type: "deposit", WalletID: "-", Amount: 100}, ledger.ErrNotValidWalletID},
+		{&CreateScheduleRequest{Type: "transfer", WalletID: "a18c247b-8c28-468f-97a8-0bf33a48b922", Amount: 100}, ledger.ErrNotValidTransaction},
+		{&CreateScheduleRequest{Type: "deposit", WalletID: "a18c247b-8c28-468f-97a8-0bf33a48b922", Amount: -100}, ledger.ErrNotValidAmount},
+		{&CreateScheduleRequest{Type: "deposit", WalletID: "a18c247b-8c28-468f-97a8-0bf33a48b922", Amount: 100, Cron: "every day"}, ErrNotValidCron},
+	}
+
+	for i, tt := range testcases {
+		if _, err := NewSchedule(tt.req, time.Now()); !errors.Is(err, tt.err) {
+			t.Errorf("#%d got %v, want %v", i, err, tt.err)
+		}
+	}
+}
@@ -3,7 +3,12 @@ package ledger
 import (
 	"context"
 	"encoding/json"
+	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/deividaspetraitis/ledger/fee"
 
 	"github.com/deividaspetraitis/go/database"
 	"github.com/deividaspetraitis/go/errors"
@@ -20,8 +25,13 @@ const (
 
 // Deposit represents wallet deposit transaction event.
 type Deposit struct {
-	WalletID string
-	Amount   int
+	ID                string            `json:"id,omitempty"` // Transaction identifier
+	WalletID          string            `json:"wallet_id"`
+	Amount            int               `json:"amount"`
+	Key               string            `json:"key,omitempty"` // Idempotency key
+	Description       string            `json:"description,omitempty"`
+	ExternalReference string            `json:"external_reference,omitempty"` // Reference of the transaction in external system
+	Metadata          map[string]string `json:"metadata,omitempty"`
 }
 
 // Implements es.MarshalUnmarshaler
@@ -42,10 +52,15 @@ func (d *Deposit) MarshalJSON() ([]byte, error) {
 	return json.Marshal(temp)
 }
 
-// Withdraw represents wallet deposit transaction event.
+// Withdraw represents wallet withdraw transaction event.
 type Withdraw struct {
-	WalletID string
-	Amount   int
+	ID                string            `json:"id,omitempty"` // Transaction identifier
+	WalletID          string            `json:"wallet_id"`
+	Amount            int               `json:"amount"`
+	Key               string            `json:"key,omitempty"` // Idempotency key
+	Description       string            `json:"description,omitempty"`
+	ExternalReference string            `json:"external_reference,omitempty"` // Reference of the transaction in external system
+	Metadata          map[string]string `json:"metadata,omitempty"`
 }
 
 // Implements es.MarshalUnmarshaler
@@ -66,11 +81,34 @@ func (w Withdraw) MarshalJSON() ([]byte, error) {
 	return json.Marshal(temp)
 }
 
+// Transaction details limits.
+const (
+	maxKeyLength         = 128  // idempotency key length
+	maxDescriptionLength = 256  // description length in bytes
+	maxReferenceLength   = 128  // external reference length
+	maxMetadataEntries   = 16   // number of metadata entries
+	maxMetadataSize      = 2048 // total length of metadata keys and values in bytes
+	maxMetadataValue     = 256  // metadata value length in bytes
+)
+
+var (
+	// referenceRe matches valid external references.
+	referenceRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:/-]*$`)
+
+	// metadataKeyRe matches valid metadata keys.
+	metadataKeyRe = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,63}$`)
+)
+
 // TransactionRequest represents a request for creating a new transaction.
 type TransactionRequest struct {
 	Type     string // Describes transaction type, see docs for supported common transaction types.
 	WalletID string // Wallet identifier for the transaction.
 	Amount   int    // Amount for the transaction.
+	Key      string // Optional idempotency key, transaction repeated with the same key is processed only once.
+
+	Description       string            // Optional human readable description, e.g. payroll or refund.
+	ExternalReference string            // Optional reference of the transaction in external system, e.g. bank statement reference.
+	Metadata          map[string]string // Optional key/value data attached to the transaction.
 }
 
 // Validate implements validator.Validator.
@@ -84,18 +122,84 @@ func (tx *TransactionRequest) Validate() error {
 		return ErrNotValidTransaction
 	}
 
-	if tx.Amount == 0 {
+	if tx.Amount <= 0 {
 		return ErrNotValidAmount
 	}
 
+	if len(tx.Key) > maxKeyLength {
+		return ErrNotValidKey
+	}
+
+	if len(tx.Description) > maxDescriptionLength || !isPrintable(tx.Description) {
+		return ErrNotValidDescription
+	}
+
+	if len(tx.ExternalReference) > 0 && (len(tx.ExternalReference) > maxReferenceLength || !referenceRe.MatchString(tx.ExternalReference)) {
+		return ErrNotValidReference
+	}
+
+	return validateMetadata(tx.Metadata)
+}
+
+// validateMetadata validates number of metadata entries, their size and characters.
+func validateMetadata(metadata map[string]string) error {
+	if len(metadata) > maxMetadataEntries {
+		return ErrNotValidMetadata
+	}
+
+	var size int
+	for k, v := range metadata {
+		if !metadataKeyRe.MatchString(k) || len(v) > maxMetadataValue || !isPrintable(v) {
+			return ErrNotValidMetadata
+		}
+		size += len(k) + len(v)
+	}
+	if size > maxMetadataSize {
+		return ErrNotValidMetadata
+	}
+
 	return nil
 }
 
+// isPrintable reports whether s is a valid UTF-8 text without control characters.
+func isPrintable(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
 // Transaction represents wallet transaction command.
 type Transaction struct {
+	ID       string // Unique transaction identifier assigned by the service.
 	Type     string // Describes transaction type, see docs for supported common transaction types.
 	WalletID string // Wallet identifier for the transaction.
 	Amount   int    // Amount for the transaction.
+	Fee      int    // Fee charged on top of the transaction, see fee.Schedule.
+	Key      string // Idempotency key, see TransactionRequest.
+
+	Description       string            // See TransactionRequest.
+	ExternalReference string            // See TransactionRequest.
+	Metadata          map[string]string // See TransactionRequest.
+}
+
+// newTransaction constructs transaction command of the request assigning it a new identifier.
+func newTransaction(req *TransactionRequest) *Transaction {
+	return &Transaction{
+		ID:                newID(),
+		Type:              req.Type,
+		WalletID:          req.WalletID,
+		Amount:            req.Amount,
+		Key:               req.Key,
+		Description:       req.Description,
+		ExternalReference: req.ExternalReference,
+		Metadata:          req.Metadata,
+	}
 }
 
 // Validate implements validator.Validator.
@@ -107,8 +211,25 @@ func (tx *Transaction) Validate() error {
 	return nil
 }
 
-// CreateTransaction creates a new transaction for the given wallet.
-func CreateTransaction(ctx context.Context, saveAggregate database.SaveAggregateFunc, getWallet database.GetAggregateFunc[*WalletAggregate], req *TransactionRequest) (*Wallet, error) {
+// Receipt represents processed transaction.
+type Receipt struct {
+	TransactionID string // Identifier of the transaction, of the original one if the transaction was repeated
+	Wallet               // Wallet state once the transaction was processed
+}
+
+// newReceipt constructs receipt of the transaction processed on the wallet.
+func newReceipt(tx *Transaction, wallet *Wallet) *Receipt {
+	return &Receipt{
+		TransactionID: tx.ID,
+		Wallet:        *wallet,
+	}
+}
+
+// CreateTransaction creates a new transaction for the given wallet charging fees according to the schedule.
+// Charged fees are credited to the house wallet once transaction is persisted, failure to do so is logged and left to Collector.
+// Transaction repeating idempotency key of already processed one is not processed again,
+// receipt of the original transaction along current wallet state is returned instead.
+func CreateTransaction(ctx context.Context, saveAggregate database.SaveAggregateFunc, getWallet database.GetAggregateFunc[*WalletAggregate], fees *fee.Schedule, req *TransactionRequest) (*Receipt, error) {
 	// transaction must be a valid
 	if err := validator.Validate(req); err != nil {
 		return nil, err
@@ -120,18 +241,25 @@ func CreateTransaction(ctx context.Context, saveAggregate database.SaveAggregate
 		return nil, err
 	}
 
-	err = wallet.ProcessTransaction(&Transaction{
-		Type:     req.Type,
-		WalletID: req.WalletID,
-		Amount:   req.Amount,
-	})
-	if err != nil {
+	tx := newTransaction(req)
+	tx.Fee = transactionFee(fees, &wallet.Wallet, tx)
+
+	if err := wallet.ProcessTransaction(tx); err != nil {
+		if errors.Is(err, ErrDuplicateTransaction) {
+			return newReceipt(tx, &wallet.Wallet), nil
+		}
 		return nil, err
 	}
 
+	collect := feesCharged(wallet)
 	if err := saveAggregate(ctx, wallet); err != nil {
 		return nil, errors.Wrap(err, "unable to persist transaction")
 	}
+	charged(collect)
+
+	for _, v := range collect {
+		collected(v, collectFee(ctx, saveAggregate, getWallet, fees.House(), v))
+	}
 
-	return &wallet.Wallet, nil
+	return newReceipt(tx, &wallet.Wallet), nil
 }
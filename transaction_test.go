@@ -0,0 +1,105 @@
+package ledger
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/deividaspetraitis/go/errors"
+)
+
+func TestTransactionRequestValidate(t *testing.T) {
+	const walletID = "a18c247b-8c28-468f-97a8-0bf33a48b922"
+
+	// metadata returns n metadata entries of values of the given length.
+	metadata := func(n, length int) map[string]string {
+		m := make(map[string]string)
+		for i := 0; i < n; i++ {
+			m["key_"+strings.Repeat("k", i)] = strings.Repeat("v", length)
+		}
+		return m
+	}
+
+	var testcases = []struct {
+		req *TransactionRequest
+		err error
+	}{
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: 100},
+		},
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: 0},
+			err: ErrNotValidAmount,
+		},
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: -100},
+			err: ErrNotValidAmount,
+		},
+		{
+			req: &TransactionRequest{Type: TransactionWithdraw, WalletID: walletID, Amount: -100},
+			err: ErrNotValidAmount,
+		},
+		{
+			req: &TransactionRequest{
+				Type:              TransactionDeposit,
+				WalletID:          walletID,
+				Amount:            100,
+				Description:       "Payroll – March",
+				ExternalReference: "INV-2024/77:a.b_c",
+				Metadata:          map[string]string{"employee.id": "42", "cost-center": "R&D"},
+			},
+		},
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: 100, Description: strings.Repeat("d", maxDescriptionLength+1)},
+			err: ErrNotValidDescription,
+		},
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: 100, Description: "line\nbreak"},
+			err: ErrNotValidDescription,
+		},
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: 100, Description: "\xff"},
+			err: ErrNotValidDescription,
+		},
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: 100, ExternalReference: "INV 77"},
+			err: ErrNotValidReference,
+		},
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: 100, ExternalReference: "-77"},
+			err: ErrNotValidReference,
+		},
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: 100, ExternalReference: strings.Repeat("r", maxReferenceLength+1)},
+			err: ErrNotValidReference,
+		},
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: 100, Metadata: metadata(maxMetadataEntries, 100)},
+		},
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: 100, Metadata: metadata(maxMetadataEntries+1, 1)},
+			err: ErrNotValidMetadata,
+		},
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: 100, Metadata: metadata(maxMetadataEntries, maxMetadataValue)},
+			err: ErrNotValidMetadata,
+		},
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: 100, Metadata: metadata(1, maxMetadataValue+1)},
+			err: ErrNotValidMetadata,
+		},
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: 100, Metadata: map[string]string{"Key": "value"}},
+			err: ErrNotValidMetadata,
+		},
+		{
+			req: &TransactionRequest{Type: TransactionDeposit, WalletID: walletID, Amount: 100, Metadata: map[string]string{"key": "tab\tvalue"}},
+			err: ErrNotValidMetadata,
+		},
+	}
+
+	for i, tt := range testcases {
+		if err := tt.req.Validate(); !errors.Is(err, tt.err) {
+			t.Errorf("#%d got %v, want %v", i, err, tt.err)
+		}
+	}
+}
@@ -3,7 +3,11 @@ package ledger
 import (
 	"context"
 	"encoding/json"
+	"regexp"
 	"strings"
+	"time"
+
+	"github.com/deividaspetraitis/ledger/database/schema"
 
 	"github.com/deividaspetraitis/go/database"
 	"github.com/deividaspetraitis/go/errors"
@@ -23,11 +27,73 @@ func init() {
 	es.RegisterAggregateEvent(&WalletAggregate{}, func() es.MarshalUnmarshaler {
 		return &Withdraw{}
 	})
+	es.RegisterAggregateEvent(&WalletAggregate{}, func() es.MarshalUnmarshaler {
+		return &FeeCharged{}
+	})
+	es.RegisterAggregateEvent(&WalletAggregate{}, func() es.MarshalUnmarshaler {
+		return &FeeCollected{}
+	})
+	es.RegisterAggregateEvent(&WalletAggregate{}, func() es.MarshalUnmarshaler {
+		return &OverdraftLimitSet{}
+	})
+	es.RegisterAggregateEvent(&WalletAggregate{}, func() es.MarshalUnmarshaler {
+		return &OverdraftInterestAccrued{}
+	})
+	es.RegisterAggregateEvent(&WalletAggregate{}, func() es.MarshalUnmarshaler {
+		return &InterestTermsSet{}
+	})
+	es.RegisterAggregateEvent(&WalletAggregate{}, func() es.MarshalUnmarshaler {
+		return &InterestPosted{}
+	})
+
+	// current events versions.
+	schema.Register(&WalletInitialized{}, 2)
+	schema.Register(&Deposit{}, 5)
+	schema.Register(&Withdraw{}, 5)
+	schema.Register(&FeeCharged{}, 1)
+	schema.Register(&FeeCollected{}, 1)
+	schema.Register(&OverdraftLimitSet{}, 1)
+	schema.Register(&OverdraftInterestAccrued{}, 1)
+	schema.Register(&InterestTermsSet{}, 1)
+	schema.Register(&InterestPosted{}, 1)
+
+	// version 1 events were persisted with Go field names.
+	schema.RegisterUpcaster("WalletInitialized", 1, schema.RenameFields(map[string]string{
+		"ID":      "id",
+		"Name":    "name",
+		"Balance": "balance",
+	}))
+	schema.RegisterUpcaster("Deposit", 1, schema.RenameFields(map[string]string{
+		"WalletID": "wallet_id",
+		"Amount":   "amount",
+	}))
+	schema.RegisterUpcaster("Withdraw", 1, schema.RenameFields(map[string]string{
+		"WalletID": "wallet_id",
+		"Amount":   "amount",
+	}))
+
+	// version 3 events gained optional idempotency key.
+	schema.RegisterUpcaster("Deposit", 2, unchanged)
+	schema.RegisterUpcaster("Withdraw", 2, unchanged)
+
+	// version 4 events gained optional description, external reference and metadata.
+	schema.RegisterUpcaster("Deposit", 3, unchanged)
+	schema.RegisterUpcaster("Withdraw", 3, unchanged)
+
+	// version 5 events gained transaction identifier, older transactions have none.
+	schema.RegisterUpcaster("Deposit", 4, unchanged)
+	schema.RegisterUpcaster("Withdraw", 4, unchanged)
+}
+
+// unchanged upcasts payloads which are compatible with the next event version as they are.
+func unchanged(data []byte) ([]byte, error) {
+	return data, nil
 }
 
 // CreateWalletRequest represents a request for creating a new wallet.
 type CreateWalletRequest struct {
 	Name string
+	Tier string // Fee schedule tier, defaults to fee.DefaultTier
 }
 
 // Validate implements validator.Validator.
@@ -35,14 +101,26 @@ func (r *CreateWalletRequest) Validate() error {
 	if len(r.Name) == 0 {
 		return ErrNotValidWalletName
 	}
+	if len(r.Tier) > 0 && !isValidTier(r.Tier) {
+		return ErrNotValidWalletTier
+	}
 	return nil
 }
 
+// tier matches valid wallet tier names.
+var tier = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// isValidTier reports whether given tier is a valid wallet tier name.
+func isValidTier(v string) bool {
+	return tier.MatchString(v)
+}
+
 // WalletInitialized represents an event emitted when a wallet is created.
 type WalletInitialized struct {
-	ID      string // Unique wallet identifier
-	Name    string // Wallet name
-	Balance int    // Wallet balance in cents
+	ID      string `json:"id"`             // Unique wallet identifier
+	Name    string `json:"name"`           // Wallet name
+	Balance int    `json:"balance"`        // Wallet balance in cents
+	Tier    string `json:"tier,omitempty"` // Fee schedule tier
 }
 
 func (w *WalletInitialized) UnmarshalJSON(b []byte) error {
@@ -76,6 +154,7 @@ func NewWallet(req *CreateWalletRequest) (*WalletAggregate, error) {
 		ID:      id,
 		Name:    req.Name,
 		Balance: 0,
+		Tier:    req.Tier,
 	}))
 	if err != nil {
 		return nil, err
@@ -96,33 +175,89 @@ func newWallet(id string, name string, balance int) *Wallet {
 // WalletAggregate represents Wallet's aggregate.
 type WalletAggregate struct {
 	es.AggregateRoot
+	schema.Chain
 	Wallet
+
+	keys      map[string]string  // identifiers of applied transactions by their idempotency keys
+	charged   map[uint64]int     // amounts of fees charged to the wallet by versions of their FeeCharged events
+	collected map[feeSource]bool // fees charged to other wallets and collected by the wallet
+}
+
+// Clone returns a copy of the wallet aggregate having the same state and version.
+// Clone is meant for persisted aggregates, events pending to be persisted are not copied.
+func (w *WalletAggregate) Clone() *WalletAggregate {
+	var clone WalletAggregate
+	for v := w.Root().Version(); v > 0; v-- {
+		clone.AdvanceVersion()
+	}
+	clone.Chain = w.Chain
+	clone.Wallet = w.Wallet
+	for k, v := range w.keys {
+		clone.track(k, v)
+	}
+	for k, v := range w.charged {
+		clone.charge(k, v)
+	}
+	for k := range w.collected {
+		clone.collect(k)
+	}
+	return &clone
+}
+
+// Flag implements schema.Flagger.
+// Flagged wallet was restored skipping some of its events, thus its state can not be trusted.
+func (w *WalletAggregate) Flag(err error) {
+	w.Degraded = true
 }
 
 // Wallet represents current state of the wallet.
 type Wallet struct {
-	ID      string // Unique wallet identifier
-	Name    string // Wallet name
-	Balance int    // Wallet balance in cents
+	ID       string // Unique wallet identifier
+	Name     string // Wallet name
+	Balance  int    // Wallet balance in cents
+	Tier     string // Fee schedule tier, empty means fee.DefaultTier
+	Degraded bool   // Reports whether wallet was restored skipping some of its events
+	Version  uint64 // Version of the last wallet event
+
+	OverdraftLimit int       // Maximum overdrawn balance in cents, zero means no credit line
+	OverdraftRate  int       // Annual interest rate of overdrawn balance in basis points
+	AccruedThrough time.Time // Last day overdraft interest was accrued for
+
+	Interest              InterestTerms // Terms wallet earns interest by
+	InterestPostedThrough time.Time     // Day after the last day of the last posted interest period
 }
 
 func (w *WalletAggregate) Deposit(tx *Transaction) error {
+	if tx.Fee > tx.Amount && !w.covers(tx.Fee-tx.Amount) {
+		return ErrInsufficientBalance
+	}
+
 	return w.Apply(es.NewEvent(w.ID, w, &Deposit{
-		WalletID: tx.WalletID,
-		Amount:   tx.Amount,
+		ID:                tx.ID,
+		WalletID:          tx.WalletID,
+		Amount:            tx.Amount,
+		Key:               tx.Key,
+		Description:       tx.Description,
+		ExternalReference: tx.ExternalReference,
+		Metadata:          tx.Metadata,
 	}))
 }
 
 var ErrInsufficientBalance = errors.New("insufficient balance")
 
 func (w *WalletAggregate) Withdraw(tx *Transaction) error {
-	if (w.Balance - tx.Amount) < 0 {
+	if !w.covers(tx.Amount + tx.Fee) {
 		return ErrInsufficientBalance
 	}
 
 	return w.Apply(es.NewEvent(w.ID, w, &Withdraw{
-		WalletID: tx.WalletID,
-		Amount:   tx.Amount,
+		ID:                tx.ID,
+		WalletID:          tx.WalletID,
+		Amount:            tx.Amount,
+		Key:               tx.Key,
+		Description:       tx.Description,
+		ExternalReference: tx.ExternalReference,
+		Metadata:          tx.Metadata,
 	}))
 }
 
@@ -135,6 +270,7 @@ func (w *WalletAggregate) Reply(event []*es.Event) error {
 		if err := w.on(v); err != nil {
 			return err
 		}
+		w.remember(v)
 	}
 	return nil
 }
@@ -144,7 +280,36 @@ func (w *WalletAggregate) Apply(event *es.Event) error {
 	if err := w.AggregateRoot.Apply(event); err != nil {
 		return err
 	}
-	return w.on(event)
+	if err := w.on(event); err != nil {
+		return err
+	}
+	w.remember(event)
+	return nil
+}
+
+// remember tracks idempotency key of the transaction event and fees charged or collected by the event, if any.
+func (w *WalletAggregate) remember(event *es.Event) {
+	switch e := event.Data.(type) {
+	case *Deposit:
+		w.track(e.Key, e.ID)
+	case *Withdraw:
+		w.track(e.Key, e.ID)
+	case *FeeCharged:
+		w.charge(uint64(event.Version), e.Amount)
+	case *FeeCollected:
+		w.collect(feeSource{wallet: e.Source, version: e.SourceVersion})
+	}
+}
+
+// track tracks idempotency key of applied transaction of the given identifier.
+func (w *WalletAggregate) track(key, id string) {
+	if len(key) == 0 {
+		return
+	}
+	if w.keys == nil {
+		w.keys = make(map[string]string)
+	}
+	w.keys[key] = id
 }
 
 // On applies given event to the wallet to update its state.
@@ -152,31 +317,66 @@ func (w *Wallet) on(event *es.Event) error {
 	switch e := event.Data.(type) {
 	case *WalletInitialized:
 		*w = *newWallet(e.ID, e.Name, e.Balance)
+		w.Tier = e.Tier
 	case *Deposit:
 		w.Balance += e.Amount
 	case *Withdraw:
 		w.Balance -= e.Amount
+	case *FeeCharged:
+		w.Balance -= e.Amount
+	case *FeeCollected:
+		w.Balance += e.Amount
+	case *OverdraftLimitSet:
+		w.OverdraftLimit = e.Limit
+		w.OverdraftRate = e.Rate
+	case *OverdraftInterestAccrued:
+		w.Balance -= e.Amount
+		w.AccruedThrough = e.Day
+	case *InterestTermsSet:
+		w.Interest = e.InterestTerms
+	case *InterestPosted:
+		w.Balance += e.Amount
+		w.InterestPostedThrough = e.To
 	default:
 		return errors.Newf("unsupported event: %#v", e)
 	}
+	w.Version = uint64(event.Version)
 
 	return nil
 }
 
-// ProcessTransaction applies transaction.
+// ProcessTransaction applies transaction along with its fee, if any.
+// Transaction and its fee are applied as pending events of the same append, thus persisted atomically.
+// If transaction with the same idempotency key was applied already ErrDuplicateTransaction is returned
+// and transaction ID is set to the ID of the original one.
 func (w *WalletAggregate) ProcessTransaction(tx *Transaction) error {
 	if err := validator.Validate(tx); err != nil {
 		return err
 	}
 
+	if id, ok := w.keys[tx.Key]; len(tx.Key) > 0 && ok {
+		tx.ID = id
+		return ErrDuplicateTransaction
+	}
+
+	var err error
 	switch strings.ToUpper(tx.Type) {
 	case TransactionDeposit:
-		return w.Deposit(tx)
+		err = w.Deposit(tx)
 	case TransactionWithdraw:
-		return w.Withdraw(tx)
+		err = w.Withdraw(tx)
 	default:
 		return ErrNotValidTransaction
 	}
+	if err != nil || tx.Fee == 0 {
+		return err
+	}
+
+	return w.Apply(es.NewEvent(w.ID, w, &FeeCharged{
+		WalletID:    tx.WalletID,
+		Amount:      tx.Fee,
+		Transaction: strings.ToUpper(tx.Type),
+	}))
 }
 
 // CreateWallet creates a new wallet with initialized defaults and information based on CreateWalletRequest.
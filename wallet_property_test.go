@@ -0,0 +1,310 @@
+package ledger
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/deividaspetraitis/go/errors"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// Wallet invariants are checked against generated command sequences, failing sequences are shrunk to a minimal one.
+const (
+	propertyRuns     = 500 // number of generated sequences
+	propertyCommands = 60  // maximum number of commands in a sequence
+	propertyWallets  = 3   // number of wallets commands are applied to
+)
+
+// Command operations.
+const (
+	opDeposit  = "deposit"
+	opWithdraw = "withdraw"
+	opTransfer = "transfer"
+)
+
+// walletCommand represents generated wallet command.
+type walletCommand struct {
+	op       string
+	from, to int // indexes of wallets, to is used by transfers only
+	amount   int
+	fee      int    // charged from the wallet command is applied to, from the sender of transfers
+	key      string // idempotency key, applied to the sender of transfers
+}
+
+// String implements fmt.Stringer.
+func (c walletCommand) String() string {
+	s := fmt.Sprintf("%s %d", c.op, c.from)
+	if c.op == opTransfer {
+		s += fmt.Sprintf("->%d", c.to)
+	}
+	s += fmt.Sprintf(" amount %d", c.amount)
+	if c.fee > 0 {
+		s += fmt.Sprintf(" fee %d", c.fee)
+	}
+	if len(c.key) > 0 {
+		s += fmt.Sprintf(" key %s", c.key)
+	}
+	return s
+}
+
+// generate returns a random sequence of commands.
+// Idempotency keys are drawn from a small pool and amounts are close to typical balances,
+// so duplicated and insufficient balance commands are generated along accepted ones.
+func generate(r *rand.Rand) []walletCommand {
+	cmds := make([]walletCommand, r.Intn(propertyCommands)+1)
+	for i := range cmds {
+		c := walletCommand{
+			op:     []string{opDeposit, opWithdraw, opTransfer}[r.Intn(3)],
+			from:   r.Intn(propertyWallets),
+			amount: r.Intn(1000) + 1,
+		}
+		if c.op == opTransfer {
+			c.to = (c.from + r.Intn(propertyWallets-1) + 1) % propertyWallets
+		}
+		if r.Intn(4) == 0 {
+			c.fee = r.Intn(50) + 1
+		}
+		if r.Intn(3) == 0 {
+			c.key = fmt.Sprintf("k%d", r.Intn(10))
+		}
+		cmds[i] = c
+	}
+	return cmds
+}
+
+// model represents expected state of the wallet.
+type model struct {
+	deposits    int // sum of accepted deposits
+	withdrawals int // sum of accepted withdrawals, including fees
+	keys        map[string]bool
+}
+
+// balance returns expected wallet balance.
+func (m *model) balance() int {
+	return m.deposits - m.withdrawals
+}
+
+// expect returns expected outcome of transaction changing wallet balance by delta.
+func (m *model) expect(key string, delta int) error {
+	if m.keys[key] {
+		return ErrDuplicateTransaction
+	}
+	if delta < 0 && m.balance()+delta < 0 {
+		return ErrInsufficientBalance
+	}
+	return nil
+}
+
+// accept records accepted transaction changing wallet balance by delta.
+func (m *model) accept(key string, delta int) {
+	if len(key) > 0 {
+		m.keys[key] = true
+	}
+	if delta > 0 {
+		m.deposits += delta
+	} else {
+		m.withdrawals -= delta
+	}
+}
+
+// transact applies transaction to the wallet and checks invariants of its outcome.
+func transact(wallet *WalletAggregate, m *model, tx *Transaction) (bool, error) {
+	delta := tx.Amount - tx.Fee
+	if strings.EqualFold(tx.Type, TransactionWithdraw) {
+		delta = -tx.Amount - tx.Fee
+	}
+	want := m.expect(tx.Key, delta)
+
+	before, events := wallet.Wallet, len(wallet.Events())
+	err := wallet.ProcessTransaction(tx)
+	if err != want {
+		return false, errors.Newf("got %v, want %v", err, want)
+	}
+
+	emitted := len(wallet.Events()) - events
+	if err != nil {
+		if emitted != 0 {
+			return false, errors.Newf("rejected command emitted %d events", emitted)
+		}
+		if !cmp.Equal(wallet.Wallet, before) {
+			return false, errors.Newf("rejected command changed wallet state %s", cmp.Diff(before, wallet.Wallet))
+		}
+		return false, nil
+	}
+
+	wantEmitted := 1
+	if tx.Fee > 0 {
+		wantEmitted++
+	}
+	if emitted != wantEmitted {
+		return false, errors.Newf("accepted command emitted %d events, want %d", emitted, wantEmitted)
+	}
+
+	m.accept(tx.Key, delta)
+	return true, nil
+}
+
+// check applies commands to new wallets and checks invariants after every walletCommand.
+// It returns error describing the first violated invariant.
+func check(cmds []walletCommand) error {
+	wallets := make([]*WalletAggregate, propertyWallets)
+	models := make([]*model, propertyWallets)
+	for i := range wallets {
+		wallet, err := NewWallet(&CreateWalletRequest{Name: fmt.Sprintf("wallet %d", i)})
+		if err != nil {
+			return err
+		}
+		wallets[i], models[i] = wallet, &model{keys: make(map[string]bool)}
+	}
+
+	for step, c := range cmds {
+		from := wallets[c.from]
+		tx := &Transaction{ID: newID(), WalletID: from.ID, Amount: c.amount, Fee: c.fee, Key: c.key}
+
+		var err error
+		switch c.op {
+		case opDeposit:
+			tx.Type = TransactionDeposit
+			_, err = transact(from, models[c.from], tx)
+		case opWithdraw:
+			tx.Type = TransactionWithdraw
+			_, err = transact(from, models[c.from], tx)
+		case opTransfer:
+			// transfer credits the recipient only once the sender was debited
+			tx.Type = TransactionWithdraw
+			var debited bool
+			if debited, err = transact(from, models[c.from], tx); err == nil && debited {
+				to := wallets[c.to]
+				credit := &Transaction{ID: newID(), Type: TransactionDeposit, WalletID: to.ID, Amount: c.amount}
+				if credited, cerr := transact(to, models[c.to], credit); cerr != nil {
+					err = cerr
+				} else if !credited {
+					err = errors.New("transfer debited sender without crediting recipient")
+				}
+			}
+		}
+		if err != nil {
+			return errors.Wrapf(err, "step %d %v", step, c)
+		}
+
+		for i, wallet := range wallets {
+			if err := invariants(wallet, models[i]); err != nil {
+				return errors.Wrapf(err, "step %d %v wallet %d", step, c, i)
+			}
+		}
+	}
+	return nil
+}
+
+// invariants checks invariants of the wallet state.
+func invariants(wallet *WalletAggregate, m *model) error {
+	if wallet.Balance < 0 {
+		return errors.Newf("negative balance %d", wallet.Balance)
+	}
+	if wallet.Balance != m.balance() {
+		return errors.Newf("balance %d, want deposits %d minus withdrawals %d", wallet.Balance, m.deposits, m.withdrawals)
+	}
+
+	var replayed WalletAggregate
+	if err := replayed.Reply(wallet.Events()); err != nil {
+		return errors.Wrap(err, "replaying events")
+	}
+	if !cmp.Equal(replayed.Wallet, wallet.Wallet) {
+		return errors.Newf("replayed state differs %s", cmp.Diff(wallet.Wallet, replayed.Wallet))
+	}
+	if !cmp.Equal(replayed.keys, wallet.keys, cmpopts.EquateEmpty()) {
+		return errors.Newf("replayed idempotency keys differ %s", cmp.Diff(wallet.keys, replayed.keys))
+	}
+	return nil
+}
+
+// shrink returns minimal sequence of commands still failing, commands are removed and amounts are reduced as long as
+// sequence fails.
+func shrink(cmds []walletCommand, fails func([]walletCommand) bool) []walletCommand {
+	for shrunk := true; shrunk; {
+		shrunk = false
+
+		// remove chunks of commands, halving chunk size down to a single command
+		for size := len(cmds) / 2; size > 0; size /= 2 {
+			for i := 0; i+size <= len(cmds); {
+				candidate := append(append([]walletCommand{}, cmds[:i]...), cmds[i+size:]...)
+				if len(candidate) > 0 && fails(candidate) {
+					cmds, shrunk = candidate, true
+					continue
+				}
+				i += size
+			}
+		}
+
+		// simplify remaining commands
+		for i := range cmds {
+			for _, simplify := range []func(c walletCommand) walletCommand{
+				func(c walletCommand) walletCommand { c.key = ""; return c },
+				func(c walletCommand) walletCommand { c.fee = 0; return c },
+				func(c walletCommand) walletCommand { c.fee /= 2; return c },
+				func(c walletCommand) walletCommand { c.amount = 1; return c },
+				func(c walletCommand) walletCommand { c.amount = (c.amount + 1) / 2; return c },
+			} {
+				c := simplify(cmds[i])
+				if c == cmds[i] {
+					continue
+				}
+				candidate := append([]walletCommand{}, cmds...)
+				candidate[i] = c
+				if fails(candidate) {
+					cmds, shrunk = candidate, true
+				}
+			}
+		}
+	}
+	return cmds
+}
+
+// TestWalletInvariants applies generated command sequences to wallets checking that after every command
+// balance is not negative, balance equals to sum of accepted deposits minus withdrawals, replaying emitted events
+// reproduces the same state and rejected commands emit no events.
+func TestWalletInvariants(t *testing.T) {
+	fails := func(cmds []walletCommand) bool {
+		return check(cmds) != nil
+	}
+
+	for seed := int64(1); seed <= propertyRuns; seed++ {
+		cmds := generate(rand.New(rand.NewSource(seed)))
+		if err := check(cmds); err != nil {
+			shrunk := shrink(cmds, fails)
+			t.Fatalf("seed %d: %v\nshrunk to %d of %d commands: %v\n%v", seed, err, len(shrunk), len(cmds), shrunk, check(shrunk))
+		}
+	}
+}
+
+func TestShrink(t *testing.T) {
+	// property fails once a wallet is debited more than 100 in total
+	fails := func(cmds []walletCommand) bool {
+		var debited int
+		for _, c := range cmds {
+			if c.op != opDeposit {
+				debited += c.amount + c.fee
+			}
+		}
+		return debited > 100
+	}
+
+	cmds := []walletCommand{
+		{op: opDeposit, amount: 500, key: "k1"},
+		{op: opWithdraw, amount: 70, fee: 10},
+		{op: opDeposit, amount: 20},
+		{op: opTransfer, from: 1, to: 2, amount: 40, key: "k2"},
+	}
+	want := []walletCommand{
+		{op: opWithdraw, amount: 70},
+		{op: opTransfer, from: 1, to: 2, amount: 40},
+	}
+
+	if got := shrink(cmds, fails); !cmp.Equal(got, want, cmp.AllowUnexported(walletCommand{})) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -53,7 +53,9 @@ func TestWalletOn(t *testing.T) {
 				},
 			},
 			wallet: func(id string, aggregate es.Aggregate) *Wallet {
-				return newWallet(id, "test wallet", 135)
+				w := newWallet(id, "test wallet", 135)
+				w.Version = 3
+				return w
 			},
 		},
 	}
@@ -117,7 +119,9 @@ func TestSync(t *testing.T) {
 				},
 			},
 			wallet: func(id string, aggregate es.Aggregate) *Wallet {
-				return newWallet(id, "test wallet", 135)
+				w := newWallet(id, "test wallet", 135)
+				w.Version = 3
+				return w
 			},
 		},
 		{
@@ -148,7 +152,9 @@ func TestSync(t *testing.T) {
 				},
 			},
 			wallet: func(id string, aggregate es.Aggregate) *Wallet {
-				return newWallet(id, "test wallet", 135)
+				w := newWallet(id, "test wallet", 135)
+				w.Version = 3
+				return w
 			},
 		},
 	}